@@ -0,0 +1,100 @@
+package bankxgo
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// aimdLimiter is an adaptive concurrency limiter in the spirit of Netflix's
+// concurrency-limits AIMD strategy. The in-flight cap grows additively while
+// requests complete within the SLO and the cap is actually being used, and shrinks
+// multiplicatively as soon as a request takes longer than the SLO.
+type aimdLimiter struct {
+	mu       sync.Mutex
+	slo      time.Duration
+	limit    float64
+	min      float64
+	max      float64
+	backoff  float64
+	inFlight int
+}
+
+const (
+	defaultAIMDInitialLimit = 20
+	defaultAIMDMinLimit     = 1
+	defaultAIMDMaxLimit     = 1000
+	defaultAIMDBackoffRatio = 0.9
+)
+
+func newAIMDLimiter(slo time.Duration, cfg AdaptiveLimitCfg) *aimdLimiter {
+	al := &aimdLimiter{limit: float64(cfg.InitialLimit)}
+	if al.limit <= 0 {
+		al.limit = defaultAIMDInitialLimit
+	}
+	al.configure(slo, cfg)
+	return al
+}
+
+// update applies a new config, keeping the current limit within the new bounds
+func (al *aimdLimiter) update(slo time.Duration, cfg AdaptiveLimitCfg) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.configure(slo, cfg)
+}
+
+func (al *aimdLimiter) configure(slo time.Duration, cfg AdaptiveLimitCfg) {
+	al.slo = slo
+	al.min = float64(cfg.MinLimit)
+	al.max = float64(cfg.MaxLimit)
+	al.backoff = cfg.BackoffRatio
+	if al.min <= 0 {
+		al.min = defaultAIMDMinLimit
+	}
+	if al.max <= 0 {
+		al.max = defaultAIMDMaxLimit
+	}
+	al.limit = math.Min(math.Max(al.limit, al.min), al.max)
+	if al.backoff <= 0 || al.backoff >= 1 {
+		al.backoff = defaultAIMDBackoffRatio
+	}
+}
+
+// acquire admits a request if the in-flight count is below the current limit.
+// The returned func must be called once the request completes. A request that
+// first waits wait on other limiters is admitted right away so it keeps its
+// place, but the wait does not count towards its latency.
+func (al *aimdLimiter) acquire(wait time.Duration) (func(), bool) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	if float64(al.inFlight) >= math.Floor(al.limit) {
+		return nil, false
+	}
+	al.inFlight++
+	inFlight := al.inFlight
+	start := time.Now().Add(wait)
+	return func() {
+		al.release(time.Since(start), inFlight)
+	}, true
+}
+
+func (al *aimdLimiter) release(latency time.Duration, inFlight int) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.inFlight--
+	if latency > al.slo {
+		al.limit = math.Max(al.min, al.limit*al.backoff)
+		return
+	}
+	// only grow the limit when it was the bottleneck, otherwise an idle
+	// endpoint would drift towards the max limit
+	if float64(inFlight)*2 >= al.limit {
+		al.limit = math.Min(al.max, al.limit+1)
+	}
+}
+
+func (al *aimdLimiter) status() (limit float64, inFlight int) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	return math.Floor(al.limit), al.inFlight
+}
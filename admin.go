@@ -0,0 +1,71 @@
+package bankxgo
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// CheckBalanceMismatch flags accounts whose stored balance (actual) differs
+	// from the sum of their charges (expected)
+	CheckBalanceMismatch = "balance_mismatch"
+	// CheckUnbalancedTxn flags transactions whose debits (expected) and credits
+	// (actual) differ
+	CheckUnbalancedTxn = "unbalanced_transaction"
+	// CheckMixedCurrencyTxn flags transactions with charges in more than one
+	// currency, actual being the number of currencies
+	CheckMixedCurrencyTxn = "mixed_currency_transaction"
+	// CheckNegativeBalance flags customer accounts with a balance (actual) below
+	// zero less their overdraft limit and the overdraft interest and fees
+	// charged to them (expected). The charges may take a balance beyond the
+	// limit, withdrawals may not.
+	CheckNegativeBalance = "negative_balance"
+)
+
+// ReconciliationIssue is a finding of a reconciliation check. AcctID is set for
+// account checks and TxID for transaction checks.
+type ReconciliationIssue struct {
+	Check    string          `json:"check"`
+	AcctID   snowflake.ID    `json:"acctID,omitempty"`
+	TxID     int64           `json:"txID,omitempty"`
+	Expected decimal.Decimal `json:"expected"`
+	Actual   decimal.Decimal `json:"actual"`
+}
+
+// Adjustment is a manual correction of an account's balance by an operator,
+// booked against the currency's system account
+type Adjustment struct {
+	TxID   int64        `json:"txID"`
+	AcctID snowflake.ID `json:"acctID"`
+	// Amount is added to the balance, negative amounts are taken off
+	Amount    decimal.Decimal `json:"amount"`
+	Balance   decimal.Decimal `json:"balance"`
+	Reason    string          `json:"reason"`
+	Operator  string          `json:"operator"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// AdminStore is the persistence needed by operational tooling, ie. bankxctl
+type AdminStore interface {
+	CreateAccount(ctx context.Context, req CreateAccountReq) error
+	GetAccount(ctx context.Context, id snowflake.ID) (*Account, error)
+	GetAccountByEmail(ctx context.Context, email string) (*Account, error)
+	GetAccountCharges(ctx context.Context, id snowflake.ID) ([]Charge, error)
+	// SetAccountFrozen freezes or unfreezes the account, frozen accounts cannot
+	// deposit or withdraw
+	SetAccountFrozen(ctx context.Context, id snowflake.ID, frozen bool) error
+	// SetOverdraftLimit sets how far below zero withdrawals may take the
+	// balance of the account, lowering it does not affect an overdrawn balance
+	SetOverdraftLimit(ctx context.Context, id snowflake.ID, limit decimal.Decimal) error
+	// Adjust books adj.Amount to the account against sysAcct and records the
+	// reason and operator. It fails if the balance would go below zero less
+	// the overdraft limit.
+	Adjust(ctx context.Context, adj Adjustment, sysAcct snowflake.ID) (*Adjustment, error)
+	// Reconcile runs every reconciliation check. Balances of the excluded
+	// (system) accounts are not maintained, so they are only checked through
+	// their transactions.
+	Reconcile(ctx context.Context, exclude []snowflake.ID) ([]ReconciliationIssue, error)
+}
@@ -0,0 +1,293 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: bankx.proto
+
+package bankxpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Bankxgo_CreateAccount_FullMethodName = "/bankxgo.v1.Bankxgo/CreateAccount"
+	Bankxgo_Deposit_FullMethodName       = "/bankxgo.v1.Bankxgo/Deposit"
+	Bankxgo_Withdraw_FullMethodName      = "/bankxgo.v1.Bankxgo/Withdraw"
+	Bankxgo_Balance_FullMethodName       = "/bankxgo.v1.Bankxgo/Balance"
+	Bankxgo_Statement_FullMethodName     = "/bankxgo.v1.Bankxgo/Statement"
+)
+
+// BankxgoClient is the client API for Bankxgo service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Bankxgo mirrors the REST API for internal services. Account operations
+// identify the customer by the email of the account, as the REST API does with
+// the `email` header. Callers may identify themselves for rate limiting with
+// the `x-client-id` metadata key, otherwise the peer address is used.
+type BankxgoClient interface {
+	CreateAccount(ctx context.Context, in *CreateAccountRequest, opts ...grpc.CallOption) (*Account, error)
+	Deposit(ctx context.Context, in *ChargeRequest, opts ...grpc.CallOption) (*BalanceResponse, error)
+	// Withdraw charges the configured fee on top of the amount
+	Withdraw(ctx context.Context, in *ChargeRequest, opts ...grpc.CallOption) (*Receipt, error)
+	Balance(ctx context.Context, in *BalanceRequest, opts ...grpc.CallOption) (*BalanceResponse, error)
+	// Statement streams the PDF statement of the account in chunks, to be
+	// concatenated in order
+	Statement(ctx context.Context, in *StatementRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StatementChunk], error)
+}
+
+type bankxgoClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBankxgoClient(cc grpc.ClientConnInterface) BankxgoClient {
+	return &bankxgoClient{cc}
+}
+
+func (c *bankxgoClient) CreateAccount(ctx context.Context, in *CreateAccountRequest, opts ...grpc.CallOption) (*Account, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Account)
+	err := c.cc.Invoke(ctx, Bankxgo_CreateAccount_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bankxgoClient) Deposit(ctx context.Context, in *ChargeRequest, opts ...grpc.CallOption) (*BalanceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BalanceResponse)
+	err := c.cc.Invoke(ctx, Bankxgo_Deposit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bankxgoClient) Withdraw(ctx context.Context, in *ChargeRequest, opts ...grpc.CallOption) (*Receipt, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Receipt)
+	err := c.cc.Invoke(ctx, Bankxgo_Withdraw_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bankxgoClient) Balance(ctx context.Context, in *BalanceRequest, opts ...grpc.CallOption) (*BalanceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BalanceResponse)
+	err := c.cc.Invoke(ctx, Bankxgo_Balance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bankxgoClient) Statement(ctx context.Context, in *StatementRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StatementChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Bankxgo_ServiceDesc.Streams[0], Bankxgo_Statement_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StatementRequest, StatementChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Bankxgo_StatementClient = grpc.ServerStreamingClient[StatementChunk]
+
+// BankxgoServer is the server API for Bankxgo service.
+// All implementations must embed UnimplementedBankxgoServer
+// for forward compatibility.
+//
+// Bankxgo mirrors the REST API for internal services. Account operations
+// identify the customer by the email of the account, as the REST API does with
+// the `email` header. Callers may identify themselves for rate limiting with
+// the `x-client-id` metadata key, otherwise the peer address is used.
+type BankxgoServer interface {
+	CreateAccount(context.Context, *CreateAccountRequest) (*Account, error)
+	Deposit(context.Context, *ChargeRequest) (*BalanceResponse, error)
+	// Withdraw charges the configured fee on top of the amount
+	Withdraw(context.Context, *ChargeRequest) (*Receipt, error)
+	Balance(context.Context, *BalanceRequest) (*BalanceResponse, error)
+	// Statement streams the PDF statement of the account in chunks, to be
+	// concatenated in order
+	Statement(*StatementRequest, grpc.ServerStreamingServer[StatementChunk]) error
+	mustEmbedUnimplementedBankxgoServer()
+}
+
+// UnimplementedBankxgoServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBankxgoServer struct{}
+
+func (UnimplementedBankxgoServer) CreateAccount(context.Context, *CreateAccountRequest) (*Account, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAccount not implemented")
+}
+func (UnimplementedBankxgoServer) Deposit(context.Context, *ChargeRequest) (*BalanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Deposit not implemented")
+}
+func (UnimplementedBankxgoServer) Withdraw(context.Context, *ChargeRequest) (*Receipt, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Withdraw not implemented")
+}
+func (UnimplementedBankxgoServer) Balance(context.Context, *BalanceRequest) (*BalanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Balance not implemented")
+}
+func (UnimplementedBankxgoServer) Statement(*StatementRequest, grpc.ServerStreamingServer[StatementChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method Statement not implemented")
+}
+func (UnimplementedBankxgoServer) mustEmbedUnimplementedBankxgoServer() {}
+func (UnimplementedBankxgoServer) testEmbeddedByValue()                 {}
+
+// UnsafeBankxgoServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BankxgoServer will
+// result in compilation errors.
+type UnsafeBankxgoServer interface {
+	mustEmbedUnimplementedBankxgoServer()
+}
+
+func RegisterBankxgoServer(s grpc.ServiceRegistrar, srv BankxgoServer) {
+	// If the following call pancis, it indicates UnimplementedBankxgoServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Bankxgo_ServiceDesc, srv)
+}
+
+func _Bankxgo_CreateAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BankxgoServer).CreateAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bankxgo_CreateAccount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BankxgoServer).CreateAccount(ctx, req.(*CreateAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bankxgo_Deposit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChargeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BankxgoServer).Deposit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bankxgo_Deposit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BankxgoServer).Deposit(ctx, req.(*ChargeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bankxgo_Withdraw_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChargeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BankxgoServer).Withdraw(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bankxgo_Withdraw_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BankxgoServer).Withdraw(ctx, req.(*ChargeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bankxgo_Balance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BankxgoServer).Balance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bankxgo_Balance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BankxgoServer).Balance(ctx, req.(*BalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bankxgo_Statement_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StatementRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BankxgoServer).Statement(m, &grpc.GenericServerStream[StatementRequest, StatementChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Bankxgo_StatementServer = grpc.ServerStreamingServer[StatementChunk]
+
+// Bankxgo_ServiceDesc is the grpc.ServiceDesc for Bankxgo service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Bankxgo_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bankxgo.v1.Bankxgo",
+	HandlerType: (*BankxgoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateAccount",
+			Handler:    _Bankxgo_CreateAccount_Handler,
+		},
+		{
+			MethodName: "Deposit",
+			Handler:    _Bankxgo_Deposit_Handler,
+		},
+		{
+			MethodName: "Withdraw",
+			Handler:    _Bankxgo_Withdraw_Handler,
+		},
+		{
+			MethodName: "Balance",
+			Handler:    _Bankxgo_Balance_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Statement",
+			Handler:       _Bankxgo_Statement_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "bankx.proto",
+}
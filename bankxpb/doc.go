@@ -0,0 +1,5 @@
+// Package bankxpb is the protobuf and gRPC code generated from bankx.proto,
+// served by bankxgo.NewGRPCServer
+package bankxpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative bankx.proto
@@ -0,0 +1,177 @@
+package bankxgo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/shopspring/decimal"
+)
+
+// Batch modes, an atomic batch posts all of its items or none of them while a
+// best effort batch posts every item it can
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+const (
+	// BatchProcessing batches are being posted, or were interrupted and are
+	// resumed by posting them again with the same idempotency key
+	BatchProcessing = "processing"
+	BatchPosted     = "posted"
+	// BatchPartial best effort batches have both posted and failed items
+	BatchPartial = "partial"
+	BatchFailed  = "failed"
+)
+
+const (
+	BatchItemPending = "pending"
+	BatchItemPosted  = "posted"
+	BatchItemFailed  = "failed"
+	// BatchItemSkipped items were not posted as another item of their atomic
+	// batch failed
+	BatchItemSkipped = "skipped"
+)
+
+// Types of batch items
+const (
+	BatchDeposit    = "deposit"
+	BatchWithdrawal = "withdrawal"
+)
+
+// MaxBatchItems bounds the items of a batch, which is posted within the request
+const MaxBatchItems = 5000
+
+// maxIdempotencyKeyLen bounds idempotency keys, ie. a UUID fits comfortably
+const maxIdempotencyKeyLen = 255
+
+// BatchReq posts deposits and withdrawals to any number of accounts at once.
+// Each item is authorised by the email of its account like a single charge.
+type BatchReq struct {
+	// Key makes posting the batch idempotent, posting again with the same key
+	// returns the recorded batch
+	Key string
+	// Mode is BatchAtomic, the default, or BatchBestEffort
+	Mode   string
+	Items  []BatchItemReq
+	Client string
+}
+
+type BatchItemReq struct {
+	// Type is BatchDeposit or BatchWithdrawal
+	Type   string          `json:"type"`
+	AcctID snowflake.ID    `json:"acctID"`
+	Email  string          `json:"email"`
+	Amount decimal.Decimal `json:"amount"`
+
+	// not passed from input but from middleware
+	Currency string `json:"-"`
+}
+
+type BatchReqByID struct {
+	BatchID snowflake.ID
+	// Key is the idempotency key the batch was posted with, which is needed to
+	// fetch it
+	Key    string
+	Client string
+}
+
+// Batch is the record of a posted batch and the outcome of each item
+type Batch struct {
+	ID         snowflake.ID `json:"batchID"`
+	Key        string       `json:"-"`
+	Mode       string       `json:"mode"`
+	Status     string       `json:"status"`
+	Items      []BatchItem  `json:"items"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	FinishedAt *time.Time   `json:"finishedAt,omitempty"`
+	// Hash identifies the request, a key reused for another request is a conflict
+	Hash string `json:"-"`
+	// Replayed is set when the batch was posted by an earlier request with the
+	// same key
+	Replayed bool `json:"-"`
+}
+
+type BatchItem struct {
+	Type   string          `json:"type"`
+	AcctID snowflake.ID    `json:"acctID"`
+	Amount decimal.Decimal `json:"amount"`
+	Status string          `json:"status"`
+	// Fee and Balance are set once the item is posted
+	Fee     *decimal.Decimal `json:"fee,omitempty"`
+	Balance *decimal.Decimal `json:"balance,omitempty"`
+	// ErrorCode is one of the Code constants, set if the item failed
+	ErrorCode string `json:"errorCode,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BatchPosting is a pending item of a batch, ready to be charged
+type BatchPosting struct {
+	// Index is the position of the item in the batch
+	Index   int
+	Type    string
+	AcctID  snowflake.ID
+	SysAcct snowflake.ID
+	Amount  decimal.Decimal
+	// Limits and Fee only apply to withdrawals
+	Limits WithdrawalLimits
+	Fee    Fee
+}
+
+// batchHash identifies the mode and items of the request, amounts compare by
+// value so `100` and `100.00` are the same
+func batchHash(req BatchReq) string {
+	type item struct {
+		Type   string `json:"type"`
+		AcctID int64  `json:"acctID"`
+		Email  string `json:"email"`
+		Amount string `json:"amount"`
+	}
+	canonical := struct {
+		Mode  string `json:"mode"`
+		Items []item `json:"items"`
+	}{Mode: req.Mode, Items: make([]item, len(req.Items))}
+	for i, it := range req.Items {
+		canonical.Items[i] = item{it.Type, it.AcctID.Int64(), it.Email, it.Amount.String()}
+	}
+	// marshalling plain strings and numbers cannot fail
+	b, _ := json.Marshal(canonical)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// batchItemError is the code and description of an item failure recorded on
+// the batch
+func batchItemError(err DomainError) (code, detail string) {
+	var brerr ErrBadRequest
+	if errors.As(err, &brerr) && len(brerr.Fields) > 0 {
+		fields := make([]string, 0, len(brerr.Fields))
+		for f, msg := range brerr.Fields {
+			fields = append(fields, f+": "+msg)
+		}
+		sort.Strings(fields)
+		return err.Code(), strings.Join(fields, ", ")
+	}
+	return err.Code(), err.Error()
+}
+
+// batchAccounts returns the distinct accounts of the postings in ascending
+// order, the order in which they are locked so concurrent batches cannot deadlock
+func batchAccounts(postings []BatchPosting) []int64 {
+	seen := make(map[snowflake.ID]bool, len(postings))
+	accts := make([]int64, 0, len(postings))
+	for _, p := range postings {
+		if !seen[p.AcctID] {
+			seen[p.AcctID] = true
+			accts = append(accts, p.AcctID.Int64())
+		}
+	}
+	sort.Slice(accts, func(i, j int) bool { return accts[i] < accts[j] })
+	return accts
+}
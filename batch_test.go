@@ -0,0 +1,187 @@
+package bankxgo_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+func TestPostBatch(t *testing.T) {
+	log := zerolog.Nop()
+	usdSysAcct := snowflake.ParseInt64(7241301734201495552)
+	feeAcct := snowflake.ParseInt64(7241301734201495553)
+	payer := snowflake.ParseInt64(7241407009730334720)
+	payee := snowflake.ParseInt64(7241407009730334721)
+	req := bankxgo.BatchReq{
+		Key:  "payroll-2024-09",
+		Mode: bankxgo.BatchAtomic,
+		Items: []bankxgo.BatchItemReq{
+			{Type: bankxgo.BatchWithdrawal, AcctID: payer, Email: "payer@bank.com", Amount: decimal.New(100, 0), Currency: "USD"},
+			{Type: bankxgo.BatchDeposit, AcctID: payee, Email: "payee@bank.com", Amount: decimal.New(100, 0), Currency: "USD"},
+		},
+	}
+	newService := func(tt *testing.T) (bankxgo.Service, *mocks.MockRepository) {
+		repo := mocks.NewMockRepository(gomock.NewController(tt))
+		repo.EXPECT().
+			GetAccount(gomock.Any(), usdSysAcct).
+			Return(&bankxgo.Account{AcctID: usdSysAcct, Currency: "USD"}, nil)
+		repo.EXPECT().
+			GetAccount(gomock.Any(), feeAcct).
+			Return(&bankxgo.Account{AcctID: feeAcct, Currency: "USD"}, nil)
+		fees := map[string]bankxgo.FeePolicy{
+			"USD": {Account: feeAcct, Withdraw: bankxgo.FeeSchedule{Flat: decimal.New(2, 0)}},
+		}
+		wdLimits := map[string]bankxgo.WithdrawalLimits{"USD": {MaxDailyCount: 3}}
+		svc, err := bankxgo.NewService(
+			repo,
+			bankxgo.NewSystemAccounts(map[string]snowflake.ID{"USD": usdSysAcct}, []snowflake.ID{feeAcct}),
+			wdLimits,
+			fees,
+			&log,
+			bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 100}),
+		)
+		require.Nil(tt, err)
+		return svc, repo
+	}
+	pending := func(b bankxgo.Batch) *bankxgo.Batch {
+		b.Items = append([]bankxgo.BatchItem(nil), b.Items...)
+		return &b
+	}
+
+	t.Run("records the batch and posts its items", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		svc, repo := newService(tt)
+
+		var recorded bankxgo.Batch
+		repo.EXPECT().
+			CreateBatch(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, b bankxgo.Batch) (*bankxgo.Batch, error) {
+				recorded = b
+				return pending(b), nil
+			})
+		repo.EXPECT().
+			PostBatch(gomock.Any(), snowflake.ID(100), bankxgo.BatchAtomic, gomock.Len(2)).
+			DoAndReturn(func(_ context.Context, _ snowflake.ID, _ string, postings []bankxgo.BatchPosting) (*bankxgo.Batch, error) {
+				wd, dep := postings[0], postings[1]
+				as.Equal(0, wd.Index)
+				as.Equal(bankxgo.BatchWithdrawal, wd.Type)
+				as.Equal(payer, wd.AcctID)
+				as.Equal(usdSysAcct, wd.SysAcct)
+				as.Equal(3, wd.Limits.MaxDailyCount)
+				as.True(decimal.New(2, 0).Equal(wd.Fee.Amount))
+				as.Equal(feeAcct, wd.Fee.Account)
+				as.Equal(1, dep.Index)
+				as.Equal(bankxgo.BatchDeposit, dep.Type)
+				as.Equal(payee, dep.AcctID)
+				as.Equal(bankxgo.Fee{}, dep.Fee)
+				posted := pending(recorded)
+				posted.Status = bankxgo.BatchPosted
+				return posted, nil
+			})
+
+		b, err := svc.PostBatch(context.Background(), req)
+		reqrd.Nil(err)
+		as.Equal(snowflake.ID(100), recorded.ID)
+		as.Equal(req.Key, recorded.Key)
+		as.NotEmpty(recorded.Hash)
+		as.Len(recorded.Items, 2)
+		as.Equal(bankxgo.BatchItemPending, recorded.Items[0].Status)
+		as.Equal(bankxgo.BatchPosted, b.Status)
+		as.False(b.Replayed)
+	})
+
+	t.Run("returns a finished batch with the same key as is", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		svc, repo := newService(tt)
+
+		repo.EXPECT().
+			CreateBatch(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, b bankxgo.Batch) (*bankxgo.Batch, error) {
+				earlier := pending(b)
+				earlier.ID = 7
+				earlier.Status = bankxgo.BatchFailed
+				return earlier, nil
+			})
+
+		// amounts compare by value
+		again := req
+		again.Items = append([]bankxgo.BatchItemReq(nil), req.Items...)
+		again.Items[1].Amount = decimal.RequireFromString("100.00")
+		b, err := svc.PostBatch(context.Background(), again)
+		reqrd.Nil(err)
+		as.Equal(snowflake.ID(7), b.ID)
+		as.Equal(bankxgo.BatchFailed, b.Status)
+		as.True(b.Replayed)
+	})
+
+	t.Run("resumes the pending items of an interrupted batch", func(tt *testing.T) {
+		reqrd := require.New(tt)
+		svc, repo := newService(tt)
+
+		repo.EXPECT().
+			CreateBatch(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, b bankxgo.Batch) (*bankxgo.Batch, error) {
+				earlier := pending(b)
+				earlier.ID = 7
+				earlier.Items[0].Status = bankxgo.BatchItemPosted
+				return earlier, nil
+			})
+		repo.EXPECT().
+			PostBatch(gomock.Any(), snowflake.ID(7), bankxgo.BatchAtomic, gomock.Len(1)).
+			DoAndReturn(func(_ context.Context, _ snowflake.ID, _ string, postings []bankxgo.BatchPosting) (*bankxgo.Batch, error) {
+				reqrd.Equal(1, postings[0].Index)
+				return &bankxgo.Batch{ID: 7, Status: bankxgo.BatchPosted}, nil
+			})
+
+		b, err := svc.PostBatch(context.Background(), req)
+		reqrd.Nil(err)
+		assert.True(tt, b.Replayed)
+	})
+
+	t.Run("returns a conflict when the key was used for another batch", func(tt *testing.T) {
+		svc, repo := newService(tt)
+
+		repo.EXPECT().
+			CreateBatch(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, b bankxgo.Batch) (*bankxgo.Batch, error) {
+				earlier := pending(b)
+				earlier.ID = 7
+				earlier.Hash = "another request"
+				return earlier, nil
+			})
+
+		_, err := svc.PostBatch(context.Background(), req)
+		assert.Equal(tt, bankxgo.ErrConflict{Field: "Idempotency-Key"}, err)
+	})
+}
+
+func TestGetBatch(t *testing.T) {
+	t.Run("needs the idempotency key of the batch", func(tt *testing.T) {
+		as := assert.New(tt)
+		log := zerolog.Nop()
+		repo := mocks.NewMockRepository(gomock.NewController(tt))
+		svc, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(nil, nil), nil, nil, &log, bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}))
+		require.Nil(tt, err)
+
+		batch := &bankxgo.Batch{ID: 7, Key: "payroll-2024-09", Status: bankxgo.BatchPosted}
+		repo.EXPECT().GetBatch(gomock.Any(), snowflake.ID(7)).Return(batch, nil).Times(2)
+
+		b, err := svc.GetBatch(context.Background(), bankxgo.BatchReqByID{BatchID: 7, Key: "payroll-2024-09"})
+		as.Nil(err)
+		as.Equal(batch, b)
+
+		_, err = svc.GetBatch(context.Background(), bankxgo.BatchReqByID{BatchID: 7, Key: "payroll-2024-10"})
+		as.Equal(bankxgo.ErrNotFound{ID: 7}, err)
+	})
+}
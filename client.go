@@ -0,0 +1,98 @@
+package bankxgo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIDHeader identifies the API client a trusted proxy forwards a request
+// for, ie. one resolved from an API key by a gateway. It is ignored unless the
+// request comes from one of the TrustedProxies.
+const ClientIDHeader = "X-Client-ID"
+
+// TrustedProxies are the networks of the proxies allowed to name the client
+// they forward for, as the client is otherwise whoever the remote IP is
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses CIDRs (ie. 10.0.0.0/8) and single IPs
+func ParseTrustedProxies(addrs []string) (TrustedProxies, error) {
+	var nets TrustedProxies
+	for _, a := range addrs {
+		if !strings.Contains(a, "/") {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", a)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", a)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Trusts reports whether host, an IP, is one of the trusted proxies
+func (t TrustedProxies) Trusts(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range t {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// client returns the client named by id if host is a trusted proxy, or host
+func (t TrustedProxies) client(host, id string) string {
+	if id != "" && t.Trusts(host) {
+		return id
+	}
+	return host
+}
+
+type clientCtxKey struct{}
+
+// NewClientMiddleware resolves the client of every request for per client
+// rate limiting and logging, see TrustedProxies. It should wrap every other
+// HTTP middleware.
+func NewClientMiddleware(trusted TrustedProxies) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := trusted.client(remoteHost(r.RemoteAddr), r.Header.Get(ClientIDHeader))
+			ctx := context.WithValue(r.Context(), clientCtxKey{}, client)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// clientKey identifies the caller for per client rate limiting, as resolved
+// by NewClientMiddleware, or by the remote IP without it
+func clientKey(r *http.Request) string {
+	if client, ok := r.Context().Value(clientCtxKey{}).(string); ok {
+		return client
+	}
+	return remoteHost(r.RemoteAddr)
+}
+
+// remoteHost strips the port off addr
+func remoteHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/bwmarrin/snowflake"
+	"github.com/shopspring/decimal"
+)
+
+// accountView is an account as shown to operators, unlike the API it includes
+// the email, currency, balance and status
+type accountView struct {
+	AcctID         snowflake.ID    `json:"acctID"`
+	Email          string          `json:"email"`
+	Currency       string          `json:"currency"`
+	Type           string          `json:"type"`
+	Balance        decimal.Decimal `json:"balance"`
+	OverdraftLimit decimal.Decimal `json:"overdraftLimit"`
+	// Pending is the sum of the deposits not settled yet
+	Pending decimal.Decimal `json:"pending"`
+	Frozen  bool            `json:"frozen"`
+	// System is the kind of system account, if it is one
+	System string `json:"system,omitempty"`
+}
+
+type chargeView struct {
+	ID        int64           `json:"id"`
+	Date      time.Time       `json:"date"`
+	Kind      string          `json:"kind"`
+	Amount    decimal.Decimal `json:"amount"`
+	Fee       bool            `json:"fee"`
+	TxTyp     string          `json:"txType"`
+	Direction string          `json:"direction"`
+	bankxgo.Memo
+}
+
+func (a *app) account(args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	switch args[0] {
+	case "get":
+		return a.accountGet(args[1:])
+	case "txns":
+		return a.accountTxns(args[1:])
+	case "freeze":
+		return a.accountFreeze(args[1:], true)
+	case "unfreeze":
+		return a.accountFreeze(args[1:], false)
+	case "adjust":
+		return a.accountAdjust(args[1:])
+	case "overdraft":
+		return a.accountOverdraft(args[1:])
+	default:
+		return errUsage
+	}
+}
+
+// lookup finds an account by its ID or, if ref contains an @, its email
+func (a *app) lookup(ref string) (*bankxgo.Account, error) {
+	if strings.Contains(ref, "@") {
+		return a.store.GetAccountByEmail(context.Background(), ref)
+	}
+	id, err := snowflake.ParseString(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account ID %q: %w", ref, err)
+	}
+	return a.store.GetAccount(context.Background(), id)
+}
+
+func (a *app) printAccount(acct *bankxgo.Account) error {
+	sysAccts, err := a.systemAccounts()
+	if err != nil {
+		return err
+	}
+	v := accountView{
+		AcctID:         acct.AcctID,
+		Email:          acct.Email,
+		Currency:       acct.Currency,
+		Type:           acct.Type,
+		Balance:        acct.Balance,
+		OverdraftLimit: acct.OverdraftLimit,
+		Pending:        acct.Pending,
+		Frozen:         acct.Frozen,
+		System:         sysAccts[acct.AcctID][0],
+	}
+	header := []string{"ACCOUNT", "EMAIL", "CURRENCY", "TYPE", "BALANCE", "OVERDRAFT", "PENDING", "FROZEN", "SYSTEM"}
+	row := []string{
+		v.AcctID.String(),
+		v.Email,
+		v.Currency,
+		v.Type,
+		v.Balance.StringFixed(2),
+		v.OverdraftLimit.StringFixed(2),
+		v.Pending.StringFixed(2),
+		fmt.Sprint(v.Frozen),
+		v.System,
+	}
+	return a.out.print(v, header, [][]string{row})
+}
+
+func (a *app) accountGet(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	acct, err := a.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	return a.printAccount(acct)
+}
+
+func (a *app) accountTxns(args []string) error {
+	fs := flag.NewFlagSet("txns", flag.ExitOnError)
+	limit := fs.Int("limit", 20, "number of most recent charges to list")
+	ref := fs.String("reference", "", "only list the charges of transactions with this reference")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	acct, err := a.lookup(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	charges, err := a.store.GetAccountCharges(context.Background(), acct.AcctID)
+	if err != nil {
+		return err
+	}
+
+	// charges are in posting order, list the most recent first
+	views := []chargeView{}
+	rows := [][]string{}
+	for i := len(charges) - 1; i >= 0 && len(views) < *limit; i-- {
+		c := charges[i]
+		if *ref != "" && c.Memo.Reference != *ref {
+			continue
+		}
+		v := chargeView{
+			ID:        c.ID,
+			Date:      c.CreatedAt,
+			Kind:      c.Kind(),
+			Amount:    c.Amount,
+			Fee:       c.Fee,
+			TxTyp:     c.TxTyp,
+			Direction: c.Typ,
+			Memo:      c.Memo,
+		}
+		views = append(views, v)
+		rows = append(rows, []string{
+			fmt.Sprint(v.ID),
+			v.Date.Format(time.DateTime),
+			v.Kind,
+			v.Direction,
+			v.Amount.StringFixed(2),
+			v.Reference,
+			v.Description,
+		})
+	}
+	return a.out.print(views, []string{"ID", "DATE", "KIND", "DIRECTION", "AMOUNT", "REFERENCE", "DESCRIPTION"}, rows)
+}
+
+func (a *app) accountFreeze(args []string, frozen bool) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	acct, err := a.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	if err = a.store.SetAccountFrozen(context.Background(), acct.AcctID, frozen); err != nil {
+		return err
+	}
+	acct.Frozen = frozen
+	return a.printAccount(acct)
+}
+
+func (a *app) accountAdjust(args []string) error {
+	fs := flag.NewFlagSet("adjust", flag.ExitOnError)
+	amount := fs.String("amount", "", "amount to add to the balance, negative to take off")
+	reason := fs.String("reason", "", "why the adjustment is made, required")
+	operator := fs.String("operator", currentUser(), "who makes the adjustment")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	if strings.TrimSpace(*reason) == "" {
+		return errors.New("--reason is required")
+	}
+	if strings.TrimSpace(*operator) == "" {
+		return errors.New("--operator is required")
+	}
+	amt, err := decimal.NewFromString(*amount)
+	if err != nil || amt.IsZero() {
+		return fmt.Errorf("invalid --amount %q", *amount)
+	}
+	if amt.Exponent() < -2 {
+		return fmt.Errorf("--amount %q has more than 2 decimal places", *amount)
+	}
+
+	acct, err := a.lookup(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	sysAccts, err := a.systemAccounts()
+	if err != nil {
+		return err
+	}
+	if kind, ok := sysAccts[acct.AcctID]; ok {
+		return fmt.Errorf("account %s is the %s %s account, system accounts cannot be adjusted", acct.AcctID, kind[1], kind[0])
+	}
+	var sysAcct snowflake.ID
+	for id, kind := range sysAccts {
+		if kind[0] == "system" && kind[1] == acct.Currency {
+			sysAcct = id
+		}
+	}
+	if sysAcct == 0 {
+		return fmt.Errorf("no system account configured for %s", acct.Currency)
+	}
+
+	adj, err := a.store.Adjust(context.Background(), bankxgo.Adjustment{
+		AcctID:   acct.AcctID,
+		Amount:   amt,
+		Reason:   strings.TrimSpace(*reason),
+		Operator: strings.TrimSpace(*operator),
+	}, sysAcct)
+	if err != nil {
+		return err
+	}
+	header := []string{"TRANSACTION", "ACCOUNT", "AMOUNT", "BALANCE", "OPERATOR", "REASON"}
+	row := []string{
+		fmt.Sprint(adj.TxID),
+		adj.AcctID.String(),
+		adj.Amount.StringFixed(2),
+		adj.Balance.StringFixed(2),
+		adj.Operator,
+		adj.Reason,
+	}
+	return a.out.print(adj, header, [][]string{row})
+}
+
+func (a *app) accountOverdraft(args []string) error {
+	fs := flag.NewFlagSet("overdraft", flag.ExitOnError)
+	limit := fs.String("limit", "", "how far below zero withdrawals may take the balance, 0 for none")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	lim, err := decimal.NewFromString(*limit)
+	if err != nil || lim.IsNegative() {
+		return fmt.Errorf("invalid --limit %q", *limit)
+	}
+	if lim.Exponent() < -2 {
+		return fmt.Errorf("--limit %q has more than 2 decimal places", *limit)
+	}
+
+	acct, err := a.lookup(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	sysAccts, err := a.systemAccounts()
+	if err != nil {
+		return err
+	}
+	if kind, ok := sysAccts[acct.AcctID]; ok {
+		return fmt.Errorf("account %s is the %s %s account, system accounts cannot overdraw", acct.AcctID, kind[1], kind[0])
+	}
+	if err = a.store.SetOverdraftLimit(context.Background(), acct.AcctID, lim); err != nil {
+		return err
+	}
+	acct.OverdraftLimit = lim
+	return a.printAccount(acct)
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
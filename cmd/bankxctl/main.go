@@ -0,0 +1,191 @@
+// bankxctl is the operators' CLI. It works directly on the database configured
+// in the config file, bypassing the service and its limits, eg.
+//
+//	bankxctl account get 7241722241547769001
+//	bankxctl account get user@email.com
+//	bankxctl account txns --limit=50 7241722241547769001
+//	bankxctl account txns --reference=INV-1234 7241722241547769001
+//	bankxctl account freeze 7241722241547769001
+//	bankxctl account unfreeze 7241722241547769001
+//	bankxctl account adjust --amount=-10.50 --reason="duplicate deposit #1234" 7241722241547769001
+//	bankxctl account overdraft --limit=500 7241722241547769001
+//	bankxctl sysacct list
+//	bankxctl sysacct add --currency=JPY
+//	bankxctl sysacct rotate --currency=PHP
+//	bankxctl reconcile
+//
+// Every command prints a table, or JSON with --output=json. Flags go before the
+// positional arguments.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+)
+
+const usage = `usage: bankxctl [--config=config.yml] [--set=key.path=value] [--output=table|json] <command>
+
+commands:
+  account get <id|email>
+  account txns [--limit=20] [--reference=<ref>] <id>
+  account freeze <id>
+  account unfreeze <id>
+  account adjust --amount=<amount> --reason=<reason> [--operator=<name>] <id>
+  account overdraft --limit=<amount> <id>
+  sysacct list
+  sysacct add --currency=<code>
+  sysacct rotate --currency=<code>
+  reconcile`
+
+type app struct {
+	cfg     *bankxgo.Config
+	cfgPath string
+	store   bankxgo.AdminStore
+	out     *printer
+	node    *snowflake.Node
+}
+
+func main() {
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+
+	cfgFlags := bankxgo.RegisterConfigFlags(flag.CommandLine)
+	output := flag.String("output", "table", "output format, table or json")
+	flag.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	out, err := newPrinter(os.Stdout, *output)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error parsing flags")
+	}
+
+	cfg, err := cfgFlags.Load()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error loading config")
+	}
+
+	pgendpt, err := bankxgo.NewPostgresEndpoint(cfg.Database, &logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error starting database")
+	}
+	node, err := snowflake.NewNode(bankxgo.AdminNode)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error starting ID generator")
+	}
+
+	a := &app{
+		cfg:     cfg,
+		cfgPath: cfgFlags.Path,
+		store:   pgendpt,
+		out:     out,
+		node:    node,
+	}
+	args := flag.Args()
+	switch args[0] {
+	case "account":
+		err = a.account(args[1:])
+	case "sysacct":
+		err = a.sysacct(args[1:])
+	case "reconcile":
+		err = a.reconcile()
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+	if err == errUsage {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		logger.Fatal().Err(err).Str("command", strings.Join(args, " ")).Msg("command failed")
+	}
+}
+
+var errUsage = errors.New("usage")
+
+// systemAccounts returns every configured system account keyed by ID, with
+// its kind and currency
+func (a *app) systemAccounts() (map[snowflake.ID][2]string, error) {
+	accts := make(map[snowflake.ID][2]string)
+	add := func(kind, currency, id string) error {
+		sid, err := snowflake.ParseString(id)
+		if err != nil {
+			return fmt.Errorf("%s account of %s: %w", kind, currency, err)
+		}
+		accts[sid] = [2]string{kind, strings.ToUpper(currency)}
+		return nil
+	}
+	for c, id := range a.cfg.SystemAccounts {
+		if err := add("system", c, id); err != nil {
+			return nil, err
+		}
+	}
+	for c, fc := range a.cfg.Fees {
+		if err := add("fee", c, fc.Account); err != nil {
+			return nil, err
+		}
+	}
+	for c, ic := range a.cfg.Interest {
+		if err := add("interest", c, ic.Account); err != nil {
+			return nil, err
+		}
+	}
+	for c, oc := range a.cfg.Overdraft {
+		if err := add("overdraft", c, oc.Account); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range a.cfg.RetiredSystemAccounts {
+		if err := add("retired", "", id); err != nil {
+			return nil, err
+		}
+	}
+	return accts, nil
+}
+
+func (a *app) reconcile() error {
+	sysAccts, err := a.systemAccounts()
+	if err != nil {
+		return err
+	}
+	exclude := make([]snowflake.ID, 0, len(sysAccts))
+	for id := range sysAccts {
+		exclude = append(exclude, id)
+	}
+	issues, err := a.store.Reconcile(context.Background(), exclude)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(issues))
+	for _, i := range issues {
+		acct, txn := "", ""
+		if i.AcctID != 0 {
+			acct = i.AcctID.String()
+		}
+		if i.TxID != 0 {
+			txn = fmt.Sprint(i.TxID)
+		}
+		rows = append(rows, []string{i.Check, acct, txn, i.Expected.String(), i.Actual.String()})
+	}
+	header := []string{"CHECK", "ACCOUNT", "TRANSACTION", "EXPECTED", "ACTUAL"}
+	if err = a.out.print(issues, header, rows); err != nil {
+		return err
+	}
+	// a non-zero exit status lets schedulers alert on issues
+	if len(issues) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
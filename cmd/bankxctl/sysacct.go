@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/bwmarrin/snowflake"
+)
+
+type sysAcctView struct {
+	Kind     string       `json:"kind"`
+	Currency string       `json:"currency"`
+	AcctID   snowflake.ID `json:"acctID"`
+	// Status is ok, missing if the account is not in the database, or
+	// currency_mismatch if the account is of another currency
+	Status string `json:"status"`
+}
+
+func (a *app) sysacct(args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	switch args[0] {
+	case "list":
+		return a.sysacctList()
+	case "add":
+		return a.sysacctAdd(args[1:], false)
+	case "rotate":
+		return a.sysacctAdd(args[1:], true)
+	default:
+		return errUsage
+	}
+}
+
+func (a *app) sysacctList() error {
+	sysAccts, err := a.systemAccounts()
+	if err != nil {
+		return err
+	}
+	views := make([]sysAcctView, 0, len(sysAccts))
+	for id, kind := range sysAccts {
+		v := sysAcctView{Kind: kind[0], Currency: kind[1], AcctID: id, Status: "ok"}
+		acct, err := a.store.GetAccount(context.Background(), id)
+		switch {
+		case errors.As(err, &bankxgo.ErrNotFound{}):
+			v.Status = "missing"
+		case err != nil:
+			return err
+		case v.Kind == "retired":
+			v.Currency = acct.Currency
+		case acct.Currency != v.Currency:
+			v.Status = "currency_mismatch"
+		}
+		views = append(views, v)
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if views[i].Currency != views[j].Currency {
+			return views[i].Currency < views[j].Currency
+		}
+		return views[i].Kind < views[j].Kind
+	})
+
+	rows := make([][]string, 0, len(views))
+	for _, v := range views {
+		rows = append(rows, []string{v.Kind, v.Currency, v.AcctID.String(), v.Status})
+	}
+	return a.out.print(views, []string{"KIND", "CURRENCY", "ACCOUNT", "STATUS"}, rows)
+}
+
+// sysacctAdd creates a system account for the currency and sets it in the
+// config file. Rotating replaces the currency's current system account, which
+// is moved to `retired_system_accounts`. Servers pick up the new account on restart.
+func (a *app) sysacctAdd(args []string, rotate bool) error {
+	fs := flag.NewFlagSet("sysacct", flag.ExitOnError)
+	currency := fs.String("currency", "", "ISO 4217 code of the currency, required")
+	fs.Parse(args)
+	cur := strings.ToUpper(strings.TrimSpace(*currency))
+	if !bankxgo.IsCurrency(cur) {
+		return errors.New("--currency must be an ISO 4217 code")
+	}
+
+	var (
+		oldID  snowflake.ID
+		exists bool
+	)
+	for c, id := range a.cfg.SystemAccounts {
+		if strings.EqualFold(c, cur) {
+			parsed, err := snowflake.ParseString(id)
+			if err != nil {
+				return fmt.Errorf("system account of %s: %w", cur, err)
+			}
+			oldID, exists = parsed, true
+		}
+	}
+	if exists && !rotate {
+		return fmt.Errorf("%s already has system account %s, use rotate to replace it", cur, oldID)
+	}
+	if !exists && rotate {
+		return fmt.Errorf("%s has no system account to rotate, use add", cur)
+	}
+
+	id := a.node.Generate()
+	req := bankxgo.CreateAccountReq{
+		AcctID:   id,
+		Email:    fmt.Sprintf("%s+%s@root.co", strings.ToLower(cur), id),
+		Currency: cur,
+	}
+	if err := a.store.CreateAccount(context.Background(), req); err != nil {
+		return err
+	}
+	var retired []snowflake.ID
+	if rotate {
+		retired = append(retired, oldID)
+	}
+	ids := map[string]snowflake.ID{"system_accounts." + cur: id}
+	if err := bankxgo.SetConfigAccounts(a.cfgPath, ids, retired...); err != nil {
+		return fmt.Errorf("account %s created but not set in %s: %w", id, a.cfgPath, err)
+	}
+
+	acct, err := a.store.GetAccount(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return a.printAccount(acct)
+}
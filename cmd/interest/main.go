@@ -0,0 +1,169 @@
+// interest runs the daily interest accrual, the monthly interest posting and
+// the daily overdraft charges. It is meant to be run by cron or any other
+// scheduler, eg.
+//
+//	interest --config=config.yml accrue                   # accrues yesterday
+//	interest --config=config.yml accrue --date=2024-09-30
+//	interest --config=config.yml post                     # posts last month
+//	interest --config=config.yml post --month=2024-09
+//	interest --config=config.yml overdraft                # charges yesterday
+//	interest --config=config.yml overdraft --date=2024-09-30
+//
+// All are idempotent so a failed run can be restarted safely.
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+)
+
+func main() {
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+
+	cfgFlags := bankxgo.RegisterConfigFlags(flag.CommandLine)
+	flag.Parse()
+	if flag.NArg() < 1 {
+		logger.Fatal().Msg("usage: interest [--config=config.yml] [--set=key.path=value] accrue|post|overdraft [flags]")
+	}
+
+	cfg, err := cfgFlags.Load()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error loading config")
+	}
+
+	pgendpt, err := bankxgo.NewPostgresEndpoint(cfg.Database, &logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error starting database")
+	}
+
+	var sysAccts []snowflake.ID
+	for c, sa := range cfg.SystemAccounts {
+		id, err := snowflake.ParseString(sa)
+		if err != nil {
+			logger.Fatal().
+				Err(err).
+				Str("currency", c).
+				Msg("error parsing system account ID")
+		}
+		sysAccts = append(sysAccts, id)
+	}
+	for c, fc := range cfg.Fees {
+		id, err := snowflake.ParseString(fc.Account)
+		if err != nil {
+			logger.Fatal().
+				Err(err).
+				Str("currency", c).
+				Msg("error parsing fee account ID")
+		}
+		sysAccts = append(sysAccts, id)
+	}
+	for _, ra := range cfg.RetiredSystemAccounts {
+		id, err := snowflake.ParseString(ra)
+		if err != nil {
+			logger.Fatal().
+				Err(err).
+				Str("acctID", ra).
+				Msg("error parsing retired system account ID")
+		}
+		sysAccts = append(sysAccts, id)
+	}
+
+	// the interest and overdraft jobs exclude their own accounts, but not
+	// each other's
+	policies := make(map[string]bankxgo.InterestPolicy)
+	var intAccts []snowflake.ID
+	for c, ic := range cfg.Interest {
+		id, err := snowflake.ParseString(ic.Account)
+		if err != nil {
+			logger.Fatal().
+				Err(err).
+				Str("currency", c).
+				Msg("error parsing interest account ID")
+		}
+		policies[strings.ToUpper(c)] = bankxgo.InterestPolicy{
+			Account:    id,
+			AnnualRate: ic.AnnualRate,
+			DayCount:   ic.DayCount,
+		}
+		intAccts = append(intAccts, id)
+	}
+	odPolicies := make(map[string]bankxgo.OverdraftPolicy)
+	var odAccts []snowflake.ID
+	for c, oc := range cfg.Overdraft {
+		id, err := snowflake.ParseString(oc.Account)
+		if err != nil {
+			logger.Fatal().
+				Err(err).
+				Str("currency", c).
+				Msg("error parsing overdraft account ID")
+		}
+		odPolicies[strings.ToUpper(c)] = bankxgo.OverdraftPolicy{
+			Account:    id,
+			AnnualRate: oc.AnnualRate,
+			DayCount:   oc.DayCount,
+			DailyFee:   oc.DailyFee,
+		}
+		odAccts = append(odAccts, id)
+	}
+
+	interestJob := func() *bankxgo.InterestJob {
+		job, err := bankxgo.NewInterestJob(pgendpt, policies, append(sysAccts, odAccts...), &logger)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error starting interest job")
+		}
+		return job
+	}
+
+	switch cmd := flag.Arg(0); cmd {
+	case "accrue":
+		fs := flag.NewFlagSet("accrue", flag.ExitOnError)
+		yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+		date := fs.String("date", yesterday, "day to accrue interest for (UTC), YYYY-MM-DD")
+		fs.Parse(flag.Args()[1:])
+		day, err := time.Parse(time.DateOnly, *date)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error parsing date")
+		}
+		if err = interestJob().Accrue(day); err != nil {
+			logger.Fatal().Err(err).Msg("interest accrual failed")
+		}
+	case "post":
+		fs := flag.NewFlagSet("post", flag.ExitOnError)
+		now := time.Now().UTC()
+		lastMonth := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC).Format("2006-01")
+		month := fs.String("month", lastMonth, "month to post interest for (UTC), YYYY-MM")
+		fs.Parse(flag.Args()[1:])
+		m, err := time.Parse("2006-01", *month)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error parsing month")
+		}
+		if err = interestJob().Post(m); err != nil {
+			logger.Fatal().Err(err).Msg("interest posting failed")
+		}
+	case "overdraft":
+		fs := flag.NewFlagSet("overdraft", flag.ExitOnError)
+		yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+		date := fs.String("date", yesterday, "day to charge overdrawn accounts for (UTC), YYYY-MM-DD")
+		fs.Parse(flag.Args()[1:])
+		day, err := time.Parse(time.DateOnly, *date)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error parsing date")
+		}
+		job, err := bankxgo.NewOverdraftJob(pgendpt, odPolicies, append(sysAccts, intAccts...), &logger)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error starting overdraft job")
+		}
+		if err = job.Charge(day); err != nil {
+			logger.Fatal().Err(err).Msg("overdraft charge failed")
+		}
+	default:
+		logger.Fatal().Str("command", cmd).Msg("unknown command, expected accrue, post or overdraft")
+	}
+}
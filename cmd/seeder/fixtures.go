@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// fixtures are demo customers and their transactions, see
+// testdata/demo_fixtures.yml
+type fixtures struct {
+	Customers []customerFixture `yaml:"customers"`
+}
+
+type customerFixture struct {
+	Email    string `yaml:"email"`
+	Currency string `yaml:"currency"`
+	// AcctID is optional, an ID is generated if it is empty
+	AcctID       string               `yaml:"id"`
+	Transactions []transactionFixture `yaml:"transactions"`
+}
+
+type transactionFixture struct {
+	// Type is either deposit or withdraw
+	Type   string          `yaml:"type"`
+	Amount decimal.Decimal `yaml:"amount"`
+	// Reference is optional, `fixture-<n>` for the n-th transaction of the
+	// customer if empty. It has to be unique among the customer's transactions.
+	Reference string `yaml:"reference"`
+}
+
+// reference identifies the i-th transaction of a customer, so it is only
+// posted once
+func (t transactionFixture) reference(i int) string {
+	if t.Reference != "" {
+		return t.Reference
+	}
+	return fmt.Sprintf("fixture-%d", i+1)
+}
+
+// fixtureStore is what loading fixtures needs of the database besides the
+// service
+type fixtureStore interface {
+	CreateAccount(ctx context.Context, req bankxgo.CreateAccountReq) error
+	GetAccountByEmail(ctx context.Context, email string) (*bankxgo.Account, error)
+	ListTransactions(ctx context.Context, acctID snowflake.ID, filter bankxgo.TransactionFilter) ([]bankxgo.Transaction, error)
+}
+
+// loadFixtures creates the customers of the fixture file and posts their
+// transactions through the service, so fees and limits apply as they would
+// through the API. Existing customers are kept and transactions are posted
+// with their reference unless the customer already has one with it, so a run
+// that failed halfway is completed by running it again.
+func loadFixtures(path string, store fixtureStore, svc bankxgo.Service, ids bankxgo.IDGenerator, log *zerolog.Logger) error {
+	ctx := context.Background()
+	bits, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var fx fixtures
+	if err = yaml.Unmarshal(bits, &fx); err != nil {
+		return err
+	}
+	// validate the whole file first so a bad entry does not leave it half loaded
+	for i, c := range fx.Customers {
+		if !strings.Contains(c.Email, "@") {
+			return fmt.Errorf("customers[%d].email: invalid email %q", i, c.Email)
+		}
+		if c.AcctID != "" {
+			if _, err = snowflake.ParseString(c.AcctID); err != nil {
+				return fmt.Errorf("customers[%d].id: %w", i, err)
+			}
+		}
+		refs := make(map[string]bool)
+		for j, t := range c.Transactions {
+			ref := t.reference(j)
+			if refs[ref] {
+				return fmt.Errorf("customers[%d].transactions[%d].reference: %q is not unique", i, j, ref)
+			}
+			refs[ref] = true
+			if utf8.RuneCountInString(ref) > bankxgo.MaxReferenceLen {
+				return fmt.Errorf("customers[%d].transactions[%d].reference: exceeds %d characters", i, j, bankxgo.MaxReferenceLen)
+			}
+			if t.Type != "deposit" && t.Type != "withdraw" {
+				return fmt.Errorf("customers[%d].transactions[%d].type: expected deposit or withdraw, got %q", i, j, t.Type)
+			}
+			if !t.Amount.IsPositive() {
+				return fmt.Errorf("customers[%d].transactions[%d].amount: must be positive", i, j)
+			}
+		}
+	}
+
+	for _, c := range fx.Customers {
+		acct, err := loadCustomer(ctx, store, c, ids)
+		if err != nil {
+			return err
+		}
+
+		posted := 0
+		for j, t := range c.Transactions {
+			ref := t.reference(j)
+			txns, err := store.ListTransactions(ctx, acct.AcctID, bankxgo.TransactionFilter{Reference: ref, Limit: 1})
+			if err != nil {
+				return err
+			}
+			if len(txns) > 0 {
+				continue
+			}
+			charge := bankxgo.ChargeReq{
+				Amount:   t.Amount,
+				Memo:     bankxgo.Memo{Reference: ref},
+				AcctID:   acct.AcctID,
+				Email:    acct.Email,
+				Currency: acct.Currency,
+			}
+			if t.Type == "deposit" {
+				_, err = svc.Deposit(ctx, charge)
+			} else {
+				_, err = svc.Withdraw(ctx, charge)
+			}
+			if err != nil {
+				return fmt.Errorf("posting %s %s of %s for %s: %w", t.Type, ref, t.Amount, c.Email, err)
+			}
+			posted++
+		}
+		log.Info().
+			Str("email", bankxgo.RedactEmail(c.Email)).
+			Str("acctID", acct.AcctID.String()).
+			Int("posted", posted).
+			Int("skipped", len(c.Transactions)-posted).
+			Msg("customer loaded")
+	}
+	return nil
+}
+
+// loadCustomer returns the customer's account, creating it unless it exists
+func loadCustomer(ctx context.Context, store fixtureStore, c customerFixture, ids bankxgo.IDGenerator) (*bankxgo.Account, error) {
+	acct, err := store.GetAccountByEmail(ctx, c.Email)
+	if err == nil {
+		return acct, nil
+	}
+	if !errors.As(err, &bankxgo.ErrNotFound{}) {
+		return nil, err
+	}
+
+	req := bankxgo.CreateAccountReq{
+		Email:    c.Email,
+		Currency: strings.ToUpper(c.Currency),
+	}
+	if c.AcctID != "" {
+		req.AcctID, _ = snowflake.ParseString(c.AcctID)
+	} else if req.AcctID, err = ids.Generate(); err != nil {
+		return nil, fmt.Errorf("creating customer %s: %w", c.Email, err)
+	}
+	if err = store.CreateAccount(ctx, req); err != nil {
+		return nil, fmt.Errorf("creating customer %s: %w", c.Email, err)
+	}
+	return &bankxgo.Account{AcctID: req.AcctID, Email: req.Email, Currency: req.Currency}, nil
+}
@@ -1,10 +1,21 @@
+// seeder prepares a database for bankxgo, eg.
+//
+//	seeder --config=config.yml
+//	seeder --config=config.yml --generate-ids
+//	seeder --config=config.yml --fixtures=testdata/demo_fixtures.yml
+//
+// It creates the schema if the database has none and adds the configured
+// system, fee, interest and overdraft accounts that do not exist yet. Existing
+// data is left alone, so seeding can be rerun safely.
 package main
 
 import (
 	"flag"
 	"os"
+	"strings"
 
 	"github.com/arhyth/bankxgo"
+	"github.com/bwmarrin/snowflake"
 	"github.com/rs/zerolog"
 	"gopkg.in/yaml.v3"
 )
@@ -13,24 +24,113 @@ func main() {
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
 
-	var cfg bankxgo.Config
-	cfp := flag.String("config", "config.yml", "path to configuration file")
-	cfgfl, err := os.Open(*cfp)
+	cfgFlags := bankxgo.RegisterConfigFlags(flag.CommandLine)
+	genIDs := flag.Bool("generate-ids", false, "generate the missing account IDs in the config file")
+	fixtures := flag.String("fixtures", "", "path to a file of demo customers and transactions to load")
+	flag.Parse()
+
+	node, err := snowflake.NewNode(bankxgo.SeederNode)
 	if err != nil {
-		logger.Fatal().Err(err).Msg("error opening config file")
+		logger.Fatal().Err(err).Msg("error starting ID generator")
 	}
-	if err = yaml.NewDecoder(cfgfl).Decode(&cfg); err != nil {
-		logger.Fatal().Err(err).Msg("error decoding config file")
+	if *genIDs {
+		// the config is invalid until the IDs are filled in, so it is read as is
+		var raw bankxgo.Config
+		bits, err := os.ReadFile(cfgFlags.Path)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error reading config file")
+		}
+		if err = yaml.Unmarshal(bits, &raw); err != nil {
+			logger.Fatal().Err(err).Msg("error decoding config file")
+		}
+		ids := generateMissingIDs(&raw, node)
+		if len(ids) > 0 {
+			if err = bankxgo.SetConfigAccounts(cfgFlags.Path, ids); err != nil {
+				logger.Fatal().Err(err).Msg("error writing generated IDs to config file")
+			}
+		}
+		for k, id := range ids {
+			logger.Info().Str("key", k).Str("acctID", id.String()).Msg("generated account ID")
+		}
 	}
 
-	lh, err := bankxgo.NewLocalHelper(&cfg)
+	cfg, err := cfgFlags.Load()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error loading config, run with --generate-ids to fill in missing account IDs")
+	}
+
+	lh, err := bankxgo.NewLocalHelper(cfg)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("error starting local helper")
 	}
-	if _, err = lh.InitDB(); err != nil {
-		logger.Fatal().Err(err).Msg("error initializing database")
+	exists, err := lh.SchemaExists()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error inspecting database")
+	}
+	if !exists {
+		if _, err = lh.InitDB(); err != nil {
+			logger.Fatal().Err(err).Msg("error initializing database")
+		}
+		logger.Info().Msg("created database schema")
 	}
 	if err = lh.PrepareSystemAccounts(); err != nil {
 		logger.Fatal().Err(err).Msg("error preparing system accounts")
 	}
+
+	if *fixtures == "" {
+		return
+	}
+	pgendpt, err := bankxgo.NewPostgresEndpoint(cfg.Database, &logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error starting database")
+	}
+	fees := make(map[string]bankxgo.FeePolicy)
+	for c, fc := range cfg.Fees {
+		fees[strings.ToUpper(c)] = bankxgo.FeePolicy{Account: lh.FeeAccts[strings.ToUpper(c)], Withdraw: fc.Withdraw}
+	}
+	svc, err := bankxgo.NewService(pgendpt, bankxgo.NewSystemAccounts(lh.SysAccts, nil), cfg.WithdrawalLimits, fees, &logger, bankxgo.WithIDGenerator(bankxgo.NodeIDGenerator(node)))
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error starting service")
+	}
+	if err = loadFixtures(*fixtures, pgendpt, svc, bankxgo.NodeIDGenerator(node), &logger); err != nil {
+		logger.Fatal().Err(err).Msg("error loading fixtures")
+	}
+}
+
+// generateMissingIDs fills in the system, fee, interest and overdraft accounts that are
+// configured without an ID, returning them keyed by their config key path
+func generateMissingIDs(cfg *bankxgo.Config, node *snowflake.Node) map[string]snowflake.ID {
+	ids := make(map[string]snowflake.ID)
+	for c, id := range cfg.SystemAccounts {
+		if strings.TrimSpace(id) == "" {
+			gen := node.Generate()
+			cfg.SystemAccounts[c] = gen.String()
+			ids["system_accounts."+c] = gen
+		}
+	}
+	for c, fc := range cfg.Fees {
+		if strings.TrimSpace(fc.Account) == "" {
+			gen := node.Generate()
+			fc.Account = gen.String()
+			cfg.Fees[c] = fc
+			ids["fees."+c+".account"] = gen
+		}
+	}
+	for c, ic := range cfg.Interest {
+		if strings.TrimSpace(ic.Account) == "" {
+			gen := node.Generate()
+			ic.Account = gen.String()
+			cfg.Interest[c] = ic
+			ids["interest."+c+".account"] = gen
+		}
+	}
+	for c, oc := range cfg.Overdraft {
+		if strings.TrimSpace(oc.Account) == "" {
+			gen := node.Generate()
+			oc.Account = gen.String()
+			cfg.Overdraft[c] = oc
+			ids["overdraft."+c+".account"] = gen
+		}
+	}
+	return ids
 }
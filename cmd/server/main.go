@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/arhyth/bankxgo"
 	"github.com/bwmarrin/snowflake"
-	"gopkg.in/yaml.v3"
 
 	"github.com/rs/zerolog"
 )
@@ -16,40 +21,117 @@ import (
 func main() {
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	var cfg bankxgo.Config
-	cfp := flag.String("config", "config.yml", "path to configuration file")
-	cfgfl, err := os.Open(*cfp)
+	cfgFlags := bankxgo.RegisterConfigFlags(flag.CommandLine)
+	flag.Parse()
+	cfg, err := cfgFlags.Load()
 	if err != nil {
-		logger.Fatal().Err(err).Msg("error opening config file")
-	}
-	if err = yaml.NewDecoder(cfgfl).Decode(&cfg); err != nil {
-		logger.Fatal().Err(err).Msg("error decoding config file")
+		logger.Fatal().Err(err).Msg("error loading config")
 	}
 
-	pgendpt, err := bankxgo.NewPostgresEndpoint(cfg.Database.ConnStr, &logger)
+	pgendpt, err := bankxgo.NewPostgresEndpoint(cfg.Database, &logger)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("error starting database")
 	}
 
-	sysAccts := make(map[string]snowflake.ID)
-	for c, sa := range cfg.SystemAccounts {
-		id, err := snowflake.ParseString(sa)
+	byCurrency, internalAccts, err := cfg.SystemAccountIDs()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error parsing system accounts")
+	}
+	// system accounts are shared by the service and the validation middleware
+	// so they can be swapped on reload
+	sysAccts := bankxgo.NewSystemAccounts(byCurrency, internalAccts)
+
+	fees := make(map[string]bankxgo.FeePolicy)
+	for c, fc := range cfg.Fees {
+		id, err := snowflake.ParseString(fc.Account)
 		if err != nil {
 			logger.Fatal().
 				Err(err).
 				Str("currency", c).
-				Msg("error parsing system account ID")
+				Msg("error parsing fee account ID")
 		}
-		sysAccts[strings.ToUpper(c)] = id
+		fees[strings.ToUpper(c)] = bankxgo.FeePolicy{Account: id, Withdraw: fc.Withdraw}
+	}
+
+	ids, lease, err := bankxgo.NewIDGenerator(cfg.Node, pgendpt, &logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error assigning snowflake node")
+	}
+	// the lease outlives ctx until the statement workers stopped, see below
+	leaseCtx, releaseLease := context.WithCancel(context.Background())
+	defer releaseLease()
+	leaseDone := make(chan struct{})
+	if lease != nil {
+		go func() {
+			defer close(leaseDone)
+			lease.Run(leaseCtx)
+		}()
+		go func() {
+			select {
+			case <-lease.Lost():
+				logger.Fatal().Int64("node", lease.Node()).Msg("snowflake node lease lost, stopping")
+			case <-leaseCtx.Done():
+			}
+		}()
+	} else {
+		close(leaseDone)
 	}
 
-	svc, err := bankxgo.NewService(pgendpt, sysAccts, &logger)
+	svcOpts := []bankxgo.ServiceOption{bankxgo.WithIDGenerator(ids)}
+	var blobs bankxgo.BlobStore
+	if cfg.StatementJobs.Dir != "" {
+		blobs = &bankxgo.LocalBlobStore{Dir: cfg.StatementJobs.Dir}
+		svcOpts = append(svcOpts, bankxgo.WithBlobStore(blobs))
+	}
+	templates, err := bankxgo.NewStatementTemplates(cfg.StatementTemplates)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error loading statement templates")
+	}
+	svcOpts = append(svcOpts, bankxgo.WithStatementTemplates(templates))
+	signer, err := bankxgo.NewStatementSigner(cfg.StatementSecurity)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error configuring statement security")
+	}
+	if signer != nil {
+		svcOpts = append(svcOpts, bankxgo.WithStatementSigner(signer))
+	}
+	// balance events committed by any instance are pushed to the streams
+	// connected to this one
+	broker := bankxgo.NewBalanceEventBroker(&logger)
+	go broker.Run(ctx, pgendpt)
+	svcOpts = append(svcOpts, bankxgo.WithBalanceEvents(broker))
+	svc, err := bankxgo.NewService(pgendpt, sysAccts, cfg.WithdrawalLimits, fees, &logger, svcOpts...)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("error starting service")
 	}
 
-	limitmw := bankxgo.NewlimitMiddleware(&cfg.ServiceLimits)
+	workersDone := make(chan struct{})
+	if blobs != nil && cfg.StatementJobs.Workers > 0 {
+		// workers render with the bare service, jobs are validated when requested
+		worker := bankxgo.NewStatementWorker(pgendpt, blobs, svc, cfg.StatementJobs, &logger)
+		go func() {
+			defer close(workersDone)
+			worker.Run(ctx, cfg.StatementJobs.Workers)
+		}()
+	} else {
+		close(workersDone)
+	}
+
+	limits, err := bankxgo.NewServiceLimits(&cfg.ServiceLimits)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error configuring service limits")
+	}
+	limitmw := bankxgo.NewlimitMiddleware(limits)
+
+	// service limits and system accounts are reloaded on SIGHUP or when the
+	// config file changes
+	reloader := bankxgo.NewConfigReloader(cfgFlags, cfg, pgendpt, limits, sysAccts, &logger)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go reloader.Run(ctx, hup, 5*time.Second)
 	validmw := bankxgo.NewValidationMiddleware(pgendpt, sysAccts)
 	// !!! note: the order of middlewares is inverse of the call order
 	mws := []bankxgo.Middleware{
@@ -59,7 +141,75 @@ func main() {
 	for _, mw := range mws {
 		svc = mw(svc)
 	}
-	hndlr := bankxgo.NewHTTPHandler(svc, &logger)
+	if cfg.Scheduler.Enabled {
+		// scheduled payments are validated and charged against the per account
+		// limits like any other batch, only the batches limits let them through
+		scheduler := bankxgo.NewScheduler(pgendpt, svc, cfg.Scheduler, &logger)
+		go scheduler.Run(ctx)
+	}
+	trusted, err := bankxgo.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error parsing trusted proxies")
+	}
+	// the gRPC API is served by the same middleware-wrapped service
+	if cfg.GRPC.Port > 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPC.Port))
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error listening for gRPC")
+		}
+		gsrv := bankxgo.NewGRPCServer(svc, &logger, bankxgo.WithGRPCTrustedProxies(trusted))
+		go func() {
+			<-ctx.Done()
+			gsrv.GracefulStop()
+		}()
+		go func() {
+			if err := gsrv.Serve(lis); err != nil {
+				logger.Fatal().Err(err).Msg("gRPC server failed")
+			}
+		}()
+	}
+	hndlr := bankxgo.NewHTTPHandler(svc, &logger, bankxgo.WithSettlementKey(cfg.Settlement.SigningKey))
+	if cfg.OpenAPIValidation.Requests || cfg.OpenAPIValidation.Responses {
+		v, err := bankxgo.NewOpenAPIValidator(bankxgo.OpenAPISpec)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error loading OpenAPI spec")
+		}
+		hndlr = bankxgo.NewOpenAPIMiddleware(v, cfg.OpenAPIValidation, &logger)(hndlr)
+	}
+	// outermost, so requests rejected by the validation are logged too, with
+	// the client resolved before anything else
+	hndlr = bankxgo.NewRequestLogMiddleware(&logger)(hndlr)
+	hndlr = bankxgo.NewClientMiddleware(trusted)(hndlr)
 
-	http.ListenAndServe(":3000", hndlr)
+	// debug endpoints are not authenticated, so they are kept off the API's
+	// listener
+	if cfg.Debug.Addr != "" {
+		dmux := http.NewServeMux()
+		dmux.Handle("/debug/limits", limits)
+		dsrv := &http.Server{Addr: cfg.Debug.Addr, Handler: dmux}
+		go func() {
+			<-ctx.Done()
+			dsrv.Shutdown(context.Background())
+		}()
+		go func() {
+			if err := dsrv.ListenAndServe(); err != http.ErrServerClosed {
+				logger.Fatal().Err(err).Msg("debug server failed")
+			}
+		}()
+	}
+
+	srv := &http.Server{Addr: ":3000", Handler: hndlr}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	if err = srv.ListenAndServe(); err != http.ErrServerClosed {
+		logger.Fatal().Err(err).Msg("server failed")
+	}
+	// jobs being rendered still generate IDs, so the node is only let go of
+	// once they are done
+	<-workersDone
+	// let the lease be released so the node can be handed out right away
+	releaseLease()
+	<-leaseDone
 }
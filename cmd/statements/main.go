@@ -0,0 +1,145 @@
+// statements closes the monthly statement cycles of every account, snapshotting
+// the closing balances and pre-rendering the PDF of each closed period. It is
+// meant to be run daily by cron or any other scheduler, eg.
+//
+//	statements --config=config.yml close                   # closes cycles ended by yesterday
+//	statements --config=config.yml close --date=2024-09-30
+//
+// Closing is idempotent and catches up on missed runs, so a failed run can be
+// restarted safely.
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+)
+
+func main() {
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+
+	cfgFlags := bankxgo.RegisterConfigFlags(flag.CommandLine)
+	flag.Parse()
+	if flag.NArg() < 1 {
+		logger.Fatal().Msg("usage: statements [--config=config.yml] [--set=key.path=value] close [flags]")
+	}
+
+	cfg, err := cfgFlags.Load()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error loading config")
+	}
+
+	pgendpt, err := bankxgo.NewPostgresEndpoint(cfg.Database, &logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error starting database")
+	}
+
+	sysAccts := make(map[string]snowflake.ID)
+	var exclude []snowflake.ID
+	for c, sa := range cfg.SystemAccounts {
+		id, err := snowflake.ParseString(sa)
+		if err != nil {
+			logger.Fatal().
+				Err(err).
+				Str("currency", c).
+				Msg("error parsing system account ID")
+		}
+		sysAccts[strings.ToUpper(c)] = id
+		exclude = append(exclude, id)
+	}
+	for c, fc := range cfg.Fees {
+		id, err := snowflake.ParseString(fc.Account)
+		if err != nil {
+			logger.Fatal().
+				Err(err).
+				Str("currency", c).
+				Msg("error parsing fee account ID")
+		}
+		exclude = append(exclude, id)
+	}
+	for c, ic := range cfg.Interest {
+		id, err := snowflake.ParseString(ic.Account)
+		if err != nil {
+			logger.Fatal().
+				Err(err).
+				Str("currency", c).
+				Msg("error parsing interest account ID")
+		}
+		exclude = append(exclude, id)
+	}
+	for c, oc := range cfg.Overdraft {
+		id, err := snowflake.ParseString(oc.Account)
+		if err != nil {
+			logger.Fatal().
+				Err(err).
+				Str("currency", c).
+				Msg("error parsing overdraft account ID")
+		}
+		exclude = append(exclude, id)
+	}
+	for _, ra := range cfg.RetiredSystemAccounts {
+		id, err := snowflake.ParseString(ra)
+		if err != nil {
+			logger.Fatal().
+				Err(err).
+				Str("acctID", ra).
+				Msg("error parsing retired system account ID")
+		}
+		exclude = append(exclude, id)
+	}
+
+	// statements are rendered exactly as the server would, with the customer's
+	// template and signed if configured
+	var blobs bankxgo.BlobStore
+	if cfg.StatementJobs.Dir != "" {
+		blobs = &bankxgo.LocalBlobStore{Dir: cfg.StatementJobs.Dir}
+	}
+	templates, err := bankxgo.NewStatementTemplates(cfg.StatementTemplates)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error loading statement templates")
+	}
+	node, err := snowflake.NewNode(bankxgo.StatementsNode)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error creating snowflake node")
+	}
+	svcOpts := []bankxgo.ServiceOption{bankxgo.WithStatementTemplates(templates), bankxgo.WithIDGenerator(bankxgo.NodeIDGenerator(node))}
+	signer, err := bankxgo.NewStatementSigner(cfg.StatementSecurity)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error configuring statement security")
+	}
+	if signer != nil {
+		svcOpts = append(svcOpts, bankxgo.WithStatementSigner(signer))
+	}
+	svc, err := bankxgo.NewService(pgendpt, bankxgo.NewSystemAccounts(sysAccts, exclude), cfg.WithdrawalLimits, nil, &logger, svcOpts...)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error starting service")
+	}
+
+	job, err := bankxgo.NewStatementCycleJob(pgendpt, svc, blobs, cfg.StatementCycles, exclude, &logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error starting statement cycle job")
+	}
+
+	switch cmd := flag.Arg(0); cmd {
+	case "close":
+		fs := flag.NewFlagSet("close", flag.ExitOnError)
+		yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+		date := fs.String("date", yesterday, "close cycles ended on or before this day (UTC), YYYY-MM-DD")
+		fs.Parse(flag.Args()[1:])
+		day, err := time.Parse(time.DateOnly, *date)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("error parsing date")
+		}
+		if err = job.Close(day); err != nil {
+			logger.Fatal().Err(err).Msg("closing statement cycles failed")
+		}
+	default:
+		logger.Fatal().Str("command", cmd).Msg("unknown command, expected close")
+	}
+}
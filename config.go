@@ -1,11 +1,68 @@
 package bankxgo
 
+import "github.com/shopspring/decimal"
+
 type Config struct {
-	Database struct {
-		ConnStr string `yaml:"conn_str"`
-	} `yaml:"database"`
+	Database       DatabaseCfg       `yaml:"database"`
 	SystemAccounts map[string]string `yaml:"system_accounts"`
-	ServiceLimits  ServiceLimitsCfg  `yaml:"service_limits"`
+	// RetiredSystemAccounts are system accounts replaced by `bankxctl sysacct rotate`,
+	// kept so they are never treated as customer accounts
+	RetiredSystemAccounts []string         `yaml:"retired_system_accounts"`
+	ServiceLimits         ServiceLimitsCfg `yaml:"service_limits"`
+	Node                  NodeCfg          `yaml:"node"`
+	// WithdrawalLimits are the default withdrawal limits per currency,
+	// these can be overridden per account in the `withdrawal_limits` table
+	WithdrawalLimits map[string]WithdrawalLimits `yaml:"withdrawal_limits"`
+	// Fees are keyed by currency
+	Fees map[string]FeeCfg `yaml:"fees"`
+	// Interest is keyed by currency
+	Interest map[string]InterestCfg `yaml:"interest"`
+	// Overdraft is keyed by currency
+	Overdraft         map[string]OverdraftCfg `yaml:"overdraft"`
+	StatementJobs     StatementJobsCfg        `yaml:"statement_jobs"`
+	StatementCycles   StatementCyclesCfg      `yaml:"statement_cycles"`
+	StatementSecurity StatementSecurityCfg    `yaml:"statement_security"`
+	Settlement        SettlementCfg           `yaml:"settlement"`
+	// StatementTemplates are keyed by name, customers without a preference
+	// get the `default` template
+	StatementTemplates map[string]StatementTemplateCfg `yaml:"statement_templates"`
+	OpenAPIValidation  OpenAPIValidationCfg            `yaml:"openapi_validation"`
+	GRPC               GRPCCfg                         `yaml:"grpc"`
+	Debug              DebugCfg                        `yaml:"debug"`
+	// TrustedProxies are the IPs or CIDRs of the proxies allowed to name the
+	// client they forward for, see ClientIDHeader
+	TrustedProxies []string     `yaml:"trusted_proxies"`
+	Scheduler      SchedulerCfg `yaml:"scheduler"`
+}
+
+type DatabaseCfg struct {
+	ConnStr string `yaml:"conn_str"`
+	// MaxConns is the size of the connection pool, 10 if zero
+	MaxConns int32 `yaml:"max_conns"`
+	// MinConns are kept open even when idle
+	MinConns int32 `yaml:"min_conns"`
+}
+
+func (c DatabaseCfg) maxConns() int32 {
+	if c.MaxConns == 0 {
+		return 10
+	}
+	return c.MaxConns
+}
+
+// NodeCfg configures the snowflake node of the instance, which has to be unique
+// among running instances so the IDs they generate never collide
+type NodeCfg struct {
+	// Strategy is one of static (default), env, hostname or lease
+	Strategy string `yaml:"strategy"`
+	// ID is the node of the static strategy, 0-1020
+	ID int64 `yaml:"id"`
+	// Env is the environment variable holding the node of the env strategy,
+	// BANKXGO_NODE_ID if empty
+	Env string `yaml:"env"`
+	// LeaseSec is how long a lease of the lease strategy lasts unless renewed,
+	// 60 if zero
+	LeaseSec int `yaml:"lease_sec"`
 }
 
 type ServiceLimitsCfg struct {
@@ -14,10 +71,176 @@ type ServiceLimitsCfg struct {
 	Withdraw      EndpointLimitCfg `yaml:"withdraw"`
 	Balance       EndpointLimitCfg `yaml:"balance"`
 	Statement     EndpointLimitCfg `yaml:"statement"`
+	// StatementJobs limits both requesting and fetching statement jobs
+	StatementJobs EndpointLimitCfg `yaml:"statement_jobs"`
+	// StatementPeriods limits both listing and fetching closed statement periods
+	StatementPeriods EndpointLimitCfg `yaml:"statement_periods"`
+	VerifyStatement  EndpointLimitCfg `yaml:"verify_statement"`
+	Preferences      EndpointLimitCfg `yaml:"preferences"`
+	// BalanceEvents limits opening balance event streams, not their duration
+	BalanceEvents EndpointLimitCfg `yaml:"balance_events"`
+	// BalanceEventStreams caps the balance event streams held open at once
+	BalanceEventStreams StreamLimitCfg `yaml:"balance_event_streams"`
+	// Batches limits both posting and fetching batches, their items count
+	// against the per account limits of Deposit and Withdraw
+	Batches EndpointLimitCfg `yaml:"batches"`
+	// ScheduledPayments limits managing scheduled payments, not their runs
+	ScheduledPayments EndpointLimitCfg `yaml:"scheduled_payments"`
+	Transactions      EndpointLimitCfg `yaml:"transactions"`
+	Settlements       EndpointLimitCfg `yaml:"settlements"`
 }
 
 type EndpointLimitCfg struct {
 	SloMs int `yaml:"slo_ms"`
-	Rate  int `yaml:"rate"`
-	Burst int `yaml:"burst"`
+	// Strategy is either "token_bucket" (default), which uses Rate and Burst,
+	// or "aimd", which adapts the in-flight cap using Adaptive and SloMs
+	Strategy string           `yaml:"strategy"`
+	Rate     int              `yaml:"rate"`
+	Burst    int              `yaml:"burst"`
+	Adaptive AdaptiveLimitCfg `yaml:"adaptive"`
+	// PerAccount and PerClient are optional; a zero rate disables the keyed limiter
+	PerAccount KeyedLimitCfg `yaml:"per_account"`
+	PerClient  KeyedLimitCfg `yaml:"per_client"`
+}
+
+// KeyedLimitCfg configures a token bucket per key (account ID, API client or remote IP).
+// MaxKeys bounds the number of buckets kept in memory; least recently used keys are evicted.
+type KeyedLimitCfg struct {
+	Rate    int `yaml:"rate"`
+	Burst   int `yaml:"burst"`
+	MaxKeys int `yaml:"max_keys"`
+}
+
+// StreamLimitCfg caps the streams an account or API client holds open at
+// once. Zero values mean no cap.
+type StreamLimitCfg struct {
+	PerAccount int `yaml:"per_account"`
+	PerClient  int `yaml:"per_client"`
+}
+
+// AdaptiveLimitCfg configures the AIMD concurrency limiter. Zero values fall back
+// to sane defaults.
+type AdaptiveLimitCfg struct {
+	InitialLimit int     `yaml:"initial_limit"`
+	MinLimit     int     `yaml:"min_limit"`
+	MaxLimit     int     `yaml:"max_limit"`
+	BackoffRatio float64 `yaml:"backoff_ratio"`
+}
+
+// WithdrawalLimits caps the amount that can leave an account. Zero values mean no limit.
+// Daily limits are counted from the start of the current day in the database timezone.
+type WithdrawalLimits struct {
+	MaxPerTxn     decimal.Decimal `yaml:"max_per_txn"`
+	MaxDailyTotal decimal.Decimal `yaml:"max_daily_total"`
+	MaxDailyCount int             `yaml:"max_daily_count"`
+}
+
+type FeeCfg struct {
+	// Account is the snowflake ID of the currency's fee revenue system account
+	Account  string      `yaml:"account"`
+	Withdraw FeeSchedule `yaml:"withdraw"`
+}
+
+type InterestCfg struct {
+	// Account is the snowflake ID of the currency's interest expense system account
+	Account string `yaml:"account"`
+	// AnnualRate is in percent, eg. 2.5 for 2.5% p.a.
+	AnnualRate decimal.Decimal `yaml:"annual_rate"`
+	// DayCount is one of ACT/365 (default), ACT/360, ACT/ACT or 30/360
+	DayCount string `yaml:"day_count"`
+}
+
+type OverdraftCfg struct {
+	// Account is the snowflake ID of the currency's overdraft income system account
+	Account string `yaml:"account"`
+	// AnnualRate is in percent, charged on the overdrawn part of the balance
+	AnnualRate decimal.Decimal `yaml:"annual_rate"`
+	// DayCount is one of ACT/365 (default), ACT/360, ACT/ACT or 30/360
+	DayCount string `yaml:"day_count"`
+	// DailyFee is charged for every day the account ends overdrawn
+	DailyFee decimal.Decimal `yaml:"daily_fee"`
+}
+
+type StatementJobsCfg struct {
+	// Dir is where rendered statements are stored
+	Dir     string `yaml:"dir"`
+	Workers int    `yaml:"workers"`
+	// LeaseSec is how long a job may run before it is considered abandoned
+	LeaseSec int `yaml:"lease_sec"`
+	PollMs   int `yaml:"poll_ms"`
+	// MaxAttempts bounds the attempts of a job, whether they failed or crashed
+	// their worker, 3 if zero
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+type StatementCyclesCfg struct {
+	// DefaultDay is the day of the month the statement cycle of accounts without
+	// a preferred cycle day closes on, 1 if zero. Days past the end of shorter
+	// months close on their last day.
+	DefaultDay int `yaml:"default_day"`
+}
+
+// SchedulerCfg configures the runs of scheduled payments, see Scheduler
+type SchedulerCfg struct {
+	// Enabled runs the scheduler in the server, any number of instances may
+	// as only the one holding the scheduler lock runs payments
+	Enabled bool `yaml:"enabled"`
+	// PollSec is how often due payments are looked for, 30 if zero
+	PollSec int `yaml:"poll_sec"`
+	// BatchSize is the number of payments run per poll, 100 if zero
+	BatchSize int `yaml:"batch_size"`
+	// RetrySec is the time between attempts of payments retried on
+	// insufficient funds, 3600 if zero
+	RetrySec int `yaml:"retry_sec"`
+	// MaxRetries is the number of retries before the occurrence is skipped, 3 if zero
+	MaxRetries int `yaml:"max_retries"`
+}
+
+// StatementSecurityCfg configures the protection of PDF statements. Keys are
+// arbitrary strings, preferably 32 or more random bytes, ie. `openssl rand -base64 32`.
+type StatementSecurityCfg struct {
+	// SigningKey signs statement verification codes, statements are not signed if empty
+	SigningKey string `yaml:"signing_key"`
+	// PasswordKey seals the statement passwords customers set, PDFs are
+	// encrypted with them. Statements are not encrypted if empty.
+	PasswordKey string `yaml:"password_key"`
+	// QRCode prints the verification code as a QR code as well
+	QRCode bool `yaml:"qr_code"`
+	// VerifyURL is the public URL of the verify endpoint the QR code links to,
+	// ie. https://bank.example/statements/verify/
+	VerifyURL string `yaml:"verify_url"`
+}
+
+// DebugCfg configures the listener of the debug endpoints, ie. /debug/limits,
+// which are not served with the API as they are not authenticated
+type DebugCfg struct {
+	// Addr is a loopback or otherwise private address, ie. 127.0.0.1:3002,
+	// the debug endpoints are not served if empty
+	Addr string `yaml:"addr"`
+}
+
+// SettlementCfg configures the settlement callbacks of pending deposits
+type SettlementCfg struct {
+	// SigningKey is shared with the payment processors, which sign their
+	// callbacks with it, see SignSettlement. Settlements are rejected if empty.
+	SigningKey string `yaml:"signing_key"`
+}
+
+// StatementTemplateCfg is the layout and branding of PDF statements
+type StatementTemplateCfg struct {
+	BankName string   `yaml:"bank_name"`
+	Address  []string `yaml:"address"`
+	// Logo is the path to a PNG or JPEG image, printed at the top left of each page
+	Logo string `yaml:"logo"`
+	// Locale is the BCP 47 tag of the default locale, ie. en-US, de-DE
+	Locale string `yaml:"locale"`
+	// Font is one of the PDF core fonts: Arial, Helvetica, Times or Courier
+	Font     string  `yaml:"font"`
+	FontSize float64 `yaml:"font_size"`
+	// PageSize is one of A3, A4, A5, Letter, Legal or Tabloid
+	PageSize string `yaml:"page_size"`
+	// AccentColor is the hex RGB color of highlights, ie. #8CD482
+	AccentColor string `yaml:"accent_color"`
+	// Labels override the translated labels of the locale, see the Label* constants
+	Labels map[string]string `yaml:"labels"`
 }
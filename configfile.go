@@ -0,0 +1,103 @@
+package bankxgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bwmarrin/snowflake"
+	"gopkg.in/yaml.v3"
+)
+
+// SetConfigAccounts writes account IDs to the config file at path. IDs are keyed
+// by their dotted key path, eg. `system_accounts.USD` or `fees.USD.account`, and
+// missing keys are added. Retired accounts are appended to
+// `retired_system_accounts`. Comments are kept but blank lines are not.
+func SetConfigAccounts(path string, ids map[string]snowflake.ID, retired ...snowflake.ID) error {
+	bits, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(bits, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return errors.New("config is not a YAML mapping")
+	}
+	root := doc.Content[0]
+
+	for keyPath, id := range ids {
+		keys := strings.Split(keyPath, ".")
+		m := root
+		for _, k := range keys[:len(keys)-1] {
+			if m = yamlMapValue(m, k, yaml.MappingNode); m.Kind != yaml.MappingNode {
+				return fmt.Errorf("%s: %s is not a YAML mapping", keyPath, k)
+			}
+		}
+		v := yamlMapValue(m, keys[len(keys)-1], yaml.ScalarNode)
+		if v.Kind != yaml.ScalarNode {
+			return fmt.Errorf("%s is not a YAML scalar", keyPath)
+		}
+		v.Tag, v.Value, v.Style = "!!int", id.String(), 0
+	}
+	if len(retired) > 0 {
+		seq := yamlMapValue(root, "retired_system_accounts", yaml.SequenceNode)
+		if seq.Kind != yaml.SequenceNode {
+			return errors.New("retired_system_accounts is not a YAML sequence")
+		}
+		for _, id := range retired {
+			seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: id.String()})
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err = enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err = enc.Close(); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a failed write never truncates the config
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.yml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		os.Chmod(tmp.Name(), info.Mode())
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// yamlMapValue returns the value of key in the mapping node m, matching
+// currency codes regardless of case, and adds an empty node of the given kind
+// if there is none
+func yamlMapValue(m *yaml.Node, key string, kind yaml.Kind) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if strings.EqualFold(m.Content[i].Value, key) {
+			return m.Content[i+1]
+		}
+	}
+	tag := map[yaml.Kind]string{
+		yaml.MappingNode:  "!!map",
+		yaml.SequenceNode: "!!seq",
+		yaml.ScalarNode:   "!!null",
+	}[kind]
+	value := &yaml.Node{Kind: kind, Tag: tag}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	return value
+}
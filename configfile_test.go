@@ -0,0 +1,89 @@
+package bankxgo_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/arhyth/bankxgo"
+)
+
+func TestSetConfigAccounts(t *testing.T) {
+	as := assert.New(t)
+	reqrd := require.New(t)
+
+	const cfgYAML = `database:
+  conn_str: postgresql://localhost:5432/bankxgo
+
+# one per currency
+system_accounts:
+  USD: 7241722241547767808 # treasury
+  PHP:
+
+fees:
+  USD:
+    account: ""
+    withdraw:
+      flat: 1.50
+`
+	write := func(tt *testing.T) string {
+		path := filepath.Join(tt.TempDir(), "config.yml")
+		require.NoError(tt, os.WriteFile(path, []byte(cfgYAML), 0o600))
+		return path
+	}
+	read := func(tt *testing.T, path string) (bankxgo.Config, string) {
+		bits, err := os.ReadFile(path)
+		require.NoError(tt, err)
+		var cfg bankxgo.Config
+		require.NoError(tt, yaml.Unmarshal(bits, &cfg))
+		return cfg, string(bits)
+	}
+
+	t.Run("sets empty and existing keys keeping comments", func(tt *testing.T) {
+		path := write(tt)
+		ids := map[string]snowflake.ID{
+			"system_accounts.usd": 1,
+			"system_accounts.PHP": 2,
+			"fees.USD.account":    3,
+		}
+		err := bankxgo.SetConfigAccounts(path, ids)
+		reqrd.Nil(err)
+
+		cfg, raw := read(tt, path)
+		as.Equal(map[string]string{"USD": "1", "PHP": "2"}, cfg.SystemAccounts)
+		as.Equal("3", cfg.Fees["USD"].Account)
+		as.Contains(raw, "# one per currency")
+		as.Contains(raw, "# treasury")
+		as.Empty(cfg.RetiredSystemAccounts)
+	})
+
+	t.Run("adds missing keys and retired accounts", func(tt *testing.T) {
+		path := write(tt)
+		ids := map[string]snowflake.ID{
+			"system_accounts.EUR":  4,
+			"interest.EUR.account": 5,
+		}
+		err := bankxgo.SetConfigAccounts(path, ids, 7241722241547767808)
+		reqrd.Nil(err)
+
+		cfg, _ := read(tt, path)
+		as.Equal("4", cfg.SystemAccounts["EUR"])
+		as.Equal("5", cfg.Interest["EUR"].Account)
+		as.Equal([]string{"7241722241547767808"}, cfg.RetiredSystemAccounts)
+		as.Equal("7241722241547767808", cfg.SystemAccounts["USD"])
+	})
+
+	t.Run("returns error if a key path is not a mapping", func(tt *testing.T) {
+		path := write(tt)
+		err := bankxgo.SetConfigAccounts(path, map[string]snowflake.ID{"database.conn_str.x": 1})
+		as.Error(err)
+
+		_, raw := read(tt, path)
+		as.Equal(cfgYAML, raw)
+	})
+}
@@ -0,0 +1,475 @@
+package bankxgo
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigEnvPrefix prefixes the environment variables overriding config keys.
+// Nested keys are separated by a double underscore, ie. `database.conn_str` is
+// overridden by BANKXGO_DATABASE__CONN_STR and `fees.USD.account` by
+// BANKXGO_FEES__USD__ACCOUNT.
+const ConfigEnvPrefix = "BANKXGO_"
+
+// ConfigError is an invalid config value, Key being its key path, ie. `fees.USD.account`
+type ConfigError struct {
+	Key string
+	Msg string
+}
+
+func (e ConfigError) Error() string {
+	return e.Key + ": " + e.Msg
+}
+
+// ConfigFlags are the command line flags of the config shared by all commands
+type ConfigFlags struct {
+	// Path is the config file
+	Path string
+	// Sets override config keys, ie. `database.conn_str=postgresql://...`
+	Sets []string
+}
+
+// RegisterConfigFlags registers --config and the repeatable --set on fs
+func RegisterConfigFlags(fs *flag.FlagSet) *ConfigFlags {
+	cf := &ConfigFlags{}
+	fs.StringVar(&cf.Path, "config", "config.yml", "path to configuration file")
+	fs.Func("set", "override a config key, ie. --set=database.conn_str=postgresql://... (repeatable)", func(s string) error {
+		if !strings.Contains(s, "=") {
+			return errors.New("expected key.path=value")
+		}
+		cf.Sets = append(cf.Sets, s)
+		return nil
+	})
+	return cf
+}
+
+// Load loads the config, see LoadConfig. Call it after the flags are parsed.
+func (cf *ConfigFlags) Load() (*Config, error) {
+	return LoadConfig(cf.Path, os.Environ(), cf.Sets)
+}
+
+// LoadConfig loads the YAML config file at path, overrides it with the
+// BANKXGO_* variables of environ and then with sets, each a `key.path=value`,
+// and validates the result. Override values are parsed as YAML, except for
+// string keys which take the value as is. Variables not starting with a known
+// top level key are ignored, ie. BANKXGO_NODE_ID.
+func LoadConfig(path string, environ []string, sets []string) (*Config, error) {
+	bits, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(bits, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: config is not a YAML mapping", path)
+	}
+	cfgTyp := reflect.TypeOf(Config{})
+
+	// environment variables are sorted so overlapping keys override predictably
+	env := append([]string(nil), environ...)
+	sort.Strings(env)
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, ConfigEnvPrefix) {
+			continue
+		}
+		keys := strings.Split(strings.TrimPrefix(name, ConfigEnvPrefix), "__")
+		if _, ok := structField(cfgTyp, keys[0]); !ok {
+			continue
+		}
+		if err = setConfigKey(root, cfgTyp, keys, value); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	for _, kv := range sets {
+		key, value, _ := strings.Cut(kv, "=")
+		if err = setConfigKey(root, cfgTyp, strings.Split(key, "."), value); err != nil {
+			return nil, fmt.Errorf("--set %s: %w", key, err)
+		}
+	}
+
+	if errs := checkConfigKeys(root, cfgTyp, ""); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	var cfg Config
+	if err = root.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if err = cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// structField finds the field of the struct type typ by its YAML key,
+// regardless of case
+func structField(typ reflect.Type, key string) (reflect.StructField, bool) {
+	if typ.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if name := yamlKey(f); name != "" && name != "-" && strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func yamlKey(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return name
+}
+
+// isConfigStruct tells whether values of typ are YAML mappings of known keys.
+// Types decoding themselves, ie. decimal.Decimal, are scalars.
+func isConfigStruct(typ reflect.Type) bool {
+	unmarshaler := reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+	return typ.Kind() == reflect.Struct &&
+		!reflect.PointerTo(typ).Implements(unmarshaler) &&
+		typ.PkgPath() == reflect.TypeOf(Config{}).PkgPath()
+}
+
+// setConfigKey sets the value of the key path in the mapping node m of type typ,
+// adding the missing mappings along the way
+func setConfigKey(m *yaml.Node, typ reflect.Type, keys []string, value string) error {
+	path := strings.Join(keys, ".")
+	for i, k := range keys {
+		switch {
+		case typ.Kind() == reflect.Map:
+			typ = typ.Elem()
+		case isConfigStruct(typ):
+			f, ok := structField(typ, k)
+			if !ok {
+				return ConfigError{Key: path, Msg: "unknown key"}
+			}
+			k = yamlKey(f)
+			typ = f.Type
+		default:
+			return ConfigError{Key: path, Msg: "unknown key"}
+		}
+
+		if i < len(keys)-1 {
+			if m = yamlMapValue(m, k, yaml.MappingNode); m.Kind != yaml.MappingNode {
+				return ConfigError{Key: path, Msg: "not a YAML mapping"}
+			}
+			continue
+		}
+
+		v := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+		if typ.Kind() != reflect.String {
+			var parsed yaml.Node
+			if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+				return ConfigError{Key: path, Msg: err.Error()}
+			}
+			if len(parsed.Content) > 0 {
+				v = parsed.Content[0]
+			}
+		}
+		*yamlMapValue(m, k, v.Kind) = *v
+	}
+	return nil
+}
+
+// checkConfigKeys returns an error for each key of the node n unknown to typ
+func checkConfigKeys(n *yaml.Node, typ reflect.Type, path string) []error {
+	var errs []error
+	switch {
+	case typ.Kind() == reflect.Map && n.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := path + "." + n.Content[i].Value
+			errs = append(errs, checkConfigKeys(n.Content[i+1], typ.Elem(), key)...)
+		}
+	case typ.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for i, item := range n.Content {
+			errs = append(errs, checkConfigKeys(item, typ.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case isConfigStruct(typ) && n.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i].Value
+			key := strings.TrimPrefix(path+"."+k, ".")
+			f, ok := structField(typ, k)
+			// YAML keys are case sensitive, unlike overrides
+			if !ok || yamlKey(f) != k {
+				errs = append(errs, ConfigError{Key: key, Msg: "unknown key"})
+				continue
+			}
+			errs = append(errs, checkConfigKeys(n.Content[i+1], f.Type, key)...)
+		}
+	}
+	return errs
+}
+
+// Validate checks the config as a whole, naming the key path of each invalid value
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, ConfigError{Key: key, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Database.ConnStr == "" {
+		fail("database.conn_str", "is required")
+	} else if _, err := pgxpool.ParseConfig(c.Database.ConnStr); err != nil {
+		fail("database.conn_str", "%s", err)
+	}
+	if c.Database.MaxConns < 0 || c.Database.MaxConns > 1000 {
+		fail("database.max_conns", "must be 0 (default) to 1000")
+	}
+	if c.Database.MinConns < 0 {
+		fail("database.min_conns", "cannot be negative")
+	} else if c.Database.MinConns > c.Database.maxConns() {
+		fail("database.min_conns", "cannot exceed max_conns (%d)", c.Database.maxConns())
+	}
+
+	switch c.Node.Strategy {
+	case "", NodeStrategyStatic:
+		if _, err := checkNodeID(c.Node.ID); err != nil {
+			fail("node.id", "must be 0-%d", maxInstanceNode)
+		}
+	case NodeStrategyEnv, NodeStrategyHostname, NodeStrategyLease:
+	default:
+		fail("node.strategy", "unknown strategy %q", c.Node.Strategy)
+	}
+	if c.Node.LeaseSec < 0 {
+		fail("node.lease_sec", "cannot be negative")
+	}
+	// the REST API listens on 3000
+	if c.GRPC.Port < 0 || c.GRPC.Port > 65535 || c.GRPC.Port == 3000 {
+		fail("grpc.port", "must be 1-65535 other than 3000, or 0 to disable")
+	}
+
+	if a := c.Debug.Addr; a != "" {
+		if _, _, err := net.SplitHostPort(a); err != nil {
+			fail("debug.addr", "must be host:port")
+		}
+	}
+	for i, a := range c.TrustedProxies {
+		if _, err := ParseTrustedProxies([]string{a}); err != nil {
+			fail(fmt.Sprintf("trusted_proxies[%d]", i), "must be an IP or CIDR")
+		}
+	}
+
+	// accounts are checked to be valid snowflake IDs and unique, as a system
+	// account booking against itself would go unnoticed
+	accts := make(map[snowflake.ID]string)
+	checkAcct := func(key, id string) {
+		sid, err := snowflake.ParseString(id)
+		switch {
+		case err != nil || sid <= 0:
+			fail(key, "invalid snowflake ID %q", id)
+		case accts[sid] != "":
+			fail(key, "account %s is also %s", id, accts[sid])
+		default:
+			accts[sid] = key
+		}
+	}
+	checkCurrency := func(key, code string) {
+		if !IsCurrency(code) {
+			fail(key, "unknown ISO 4217 currency code")
+		}
+	}
+	for cur, id := range c.SystemAccounts {
+		checkCurrency("system_accounts."+cur, cur)
+		checkAcct("system_accounts."+cur, id)
+	}
+	for i, id := range c.RetiredSystemAccounts {
+		checkAcct(fmt.Sprintf("retired_system_accounts[%d]", i), id)
+	}
+	for cur, fc := range c.Fees {
+		key := "fees." + cur
+		checkCurrency(key, cur)
+		checkAcct(key+".account", fc.Account)
+		ws := fc.Withdraw
+		for name, d := range map[string]decimal.Decimal{
+			"flat": ws.Flat, "percentage": ws.Percentage, "min": ws.Min, "max": ws.Max,
+		} {
+			if d.IsNegative() {
+				fail(key+".withdraw."+name, "cannot be negative")
+			}
+		}
+		if ws.Min.IsPositive() && ws.Max.IsPositive() && ws.Min.GreaterThan(ws.Max) {
+			fail(key+".withdraw.min", "cannot exceed max")
+		}
+		for i, t := range ws.Tiers {
+			if t.Flat.IsNegative() || t.Percentage.IsNegative() || t.UpTo.IsNegative() {
+				fail(fmt.Sprintf("%s.withdraw.tiers[%d]", key, i), "cannot be negative")
+			}
+			if i > 0 && !t.UpTo.IsZero() && !t.UpTo.GreaterThan(ws.Tiers[i-1].UpTo) {
+				fail(fmt.Sprintf("%s.withdraw.tiers[%d].up_to", key, i), "tiers must be in ascending order")
+			}
+		}
+	}
+	for cur, ic := range c.Interest {
+		key := "interest." + cur
+		checkCurrency(key, cur)
+		checkAcct(key+".account", ic.Account)
+		if !ic.AnnualRate.IsPositive() {
+			fail(key+".annual_rate", "must be positive")
+		}
+		switch ic.DayCount {
+		case "", DayCountACT365, DayCountACT360, DayCountACTACT, DayCount30360:
+		default:
+			fail(key+".day_count", "unknown day count %q", ic.DayCount)
+		}
+	}
+	for cur, oc := range c.Overdraft {
+		key := "overdraft." + cur
+		checkCurrency(key, cur)
+		checkAcct(key+".account", oc.Account)
+		if oc.AnnualRate.IsNegative() {
+			fail(key+".annual_rate", "cannot be negative")
+		}
+		if oc.DailyFee.IsNegative() {
+			fail(key+".daily_fee", "cannot be negative")
+		}
+		switch oc.DayCount {
+		case "", DayCountACT365, DayCountACT360, DayCountACTACT, DayCount30360:
+		default:
+			fail(key+".day_count", "unknown day count %q", oc.DayCount)
+		}
+	}
+	for cur, wl := range c.WithdrawalLimits {
+		key := "withdrawal_limits." + cur
+		checkCurrency(key, cur)
+		if wl.MaxPerTxn.IsNegative() {
+			fail(key+".max_per_txn", "cannot be negative")
+		}
+		if wl.MaxDailyTotal.IsNegative() {
+			fail(key+".max_daily_total", "cannot be negative")
+		}
+		if wl.MaxDailyCount < 0 {
+			fail(key+".max_daily_count", "cannot be negative")
+		}
+	}
+
+	limits := reflect.ValueOf(c.ServiceLimits)
+	for i := 0; i < limits.NumField(); i++ {
+		name := yamlKey(limits.Type().Field(i))
+		el, ok := limits.Field(i).Interface().(EndpointLimitCfg)
+		if !ok {
+			continue
+		}
+		key := "service_limits." + name
+		switch el.Strategy {
+		case "", LimitStrategyTokenBucket:
+			if el.Rate <= 0 {
+				fail(key+".rate", "must be positive")
+			}
+			if el.Burst <= 0 {
+				fail(key+".burst", "must be positive")
+			}
+		case LimitStrategyAIMD:
+			if el.SloMs <= 0 {
+				fail(key+".slo_ms", "must be positive with the aimd strategy")
+			}
+		default:
+			fail(key+".strategy", "unknown strategy %q", el.Strategy)
+		}
+		for kname, kl := range map[string]KeyedLimitCfg{"per_account": el.PerAccount, "per_client": el.PerClient} {
+			if kl.Rate < 0 || kl.Burst < 0 || kl.MaxKeys < 0 {
+				fail(key+"."+kname, "cannot be negative")
+			}
+		}
+	}
+	streams := c.ServiceLimits.BalanceEventStreams
+	if streams.PerAccount < 0 {
+		fail("service_limits.balance_event_streams.per_account", "cannot be negative")
+	}
+	if streams.PerClient < 0 {
+		fail("service_limits.balance_event_streams.per_client", "cannot be negative")
+	}
+
+	jobs := c.StatementJobs
+	for name, v := range map[string]int{
+		"workers": jobs.Workers, "lease_sec": jobs.LeaseSec, "poll_ms": jobs.PollMs, "max_attempts": jobs.MaxAttempts,
+	} {
+		if v < 0 {
+			fail("statement_jobs."+name, "cannot be negative")
+		}
+	}
+	if jobs.Workers > 0 && jobs.Dir == "" {
+		fail("statement_jobs.dir", "is required with workers")
+	}
+	sched := c.Scheduler
+	for name, v := range map[string]int{
+		"poll_sec": sched.PollSec, "batch_size": sched.BatchSize, "retry_sec": sched.RetrySec, "max_retries": sched.MaxRetries,
+	} {
+		if v < 0 {
+			fail("scheduler."+name, "cannot be negative")
+		}
+	}
+	if d := c.StatementCycles.DefaultDay; d < 0 || d > 31 {
+		fail("statement_cycles.default_day", "must be 1-31")
+	}
+	if _, err := NewStatementSigner(c.StatementSecurity); err != nil {
+		errs = append(errs, err)
+	}
+	if k := c.Settlement.SigningKey; k != "" && len(k) < 16 {
+		fail("settlement.signing_key", "must be at least 16 characters")
+	}
+
+	// errors are sorted so they read the same on every run
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+// SystemAccountIDs returns the system accounts keyed by currency and the
+// internal accounts, ie. fee revenue, interest expense, overdraft income and
+// retired system accounts, see NewSystemAccounts
+func (c *Config) SystemAccountIDs() (map[string]snowflake.ID, []snowflake.ID, error) {
+	byCurrency := make(map[string]snowflake.ID, len(c.SystemAccounts))
+	for cur, id := range c.SystemAccounts {
+		sid, err := snowflake.ParseString(id)
+		if err != nil {
+			return nil, nil, ConfigError{Key: "system_accounts." + cur, Msg: err.Error()}
+		}
+		byCurrency[strings.ToUpper(cur)] = sid
+	}
+	var internal []snowflake.ID
+	add := func(key, id string) error {
+		sid, err := snowflake.ParseString(id)
+		if err != nil {
+			return ConfigError{Key: key, Msg: err.Error()}
+		}
+		internal = append(internal, sid)
+		return nil
+	}
+	for cur, fc := range c.Fees {
+		if err := add("fees."+cur+".account", fc.Account); err != nil {
+			return nil, nil, err
+		}
+	}
+	for cur, ic := range c.Interest {
+		if err := add("interest."+cur+".account", ic.Account); err != nil {
+			return nil, nil, err
+		}
+	}
+	for cur, oc := range c.Overdraft {
+		if err := add("overdraft."+cur+".account", oc.Account); err != nil {
+			return nil, nil, err
+		}
+	}
+	for i, id := range c.RetiredSystemAccounts {
+		if err := add(fmt.Sprintf("retired_system_accounts[%d]", i), id); err != nil {
+			return nil, nil, err
+		}
+	}
+	return byCurrency, internal, nil
+}
@@ -3,6 +3,9 @@ package bankxgo
 import (
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
 )
 
 var (
@@ -10,6 +13,37 @@ var (
 	ErrServiceUnavailable = errors.New("service unavailable")
 )
 
+// Stable, machine-readable error codes reported to API clients, see Problem
+const (
+	CodeBadRequest         = "bad_request"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodeForbidden          = "forbidden"
+	CodeInsufficientFunds  = "insufficient_funds"
+	CodeAccountFrozen      = "account_frozen"
+	CodeRateLimited        = "rate_limited"
+	CodeServiceUnavailable = "service_unavailable"
+	CodeInternal           = "internal"
+)
+
+// DomainError is implemented by the errors the service reports to clients, as
+// opposed to internal failures
+type DomainError interface {
+	error
+	// Code is one of the Code constants
+	Code() string
+}
+
+var (
+	_ DomainError = ErrBadRequest{}
+	_ DomainError = ErrNotFound{}
+	_ DomainError = ErrConflict{}
+	_ DomainError = ErrForbidden{}
+	_ DomainError = ErrInsufficientFunds{}
+	_ DomainError = ErrAccountFrozen{}
+	_ DomainError = ErrRateLimited{}
+)
+
 type ErrBadRequest struct {
 	Fields map[string]string `json:"fields"`
 }
@@ -18,6 +52,8 @@ func (e ErrBadRequest) Error() string {
 	return fmt.Sprintf("missing/invalid params: %v", e.Fields)
 }
 
+func (e ErrBadRequest) Code() string { return CodeBadRequest }
+
 type ErrNotFound struct {
 	ID int64 `json:"id"`
 }
@@ -25,3 +61,63 @@ type ErrNotFound struct {
 func (e ErrNotFound) Error() string {
 	return "record not found"
 }
+
+func (e ErrNotFound) Code() string { return CodeNotFound }
+
+// ErrConflict is returned when a record with the same unique Field exists,
+// ie. an account with the same email
+type ErrConflict struct {
+	Field string
+}
+
+func (e ErrConflict) Error() string {
+	return fmt.Sprintf("%s already exists", e.Field)
+}
+
+func (e ErrConflict) Code() string { return CodeConflict }
+
+// ErrForbidden is returned when the caller may not act on the record, ie. the
+// email does not match the account's
+type ErrForbidden struct {
+	Reason string
+}
+
+func (e ErrForbidden) Error() string {
+	return "forbidden: " + e.Reason
+}
+
+func (e ErrForbidden) Code() string { return CodeForbidden }
+
+// ErrInsufficientFunds is returned when a charge exceeds the balance of the account
+type ErrInsufficientFunds struct {
+	AcctID snowflake.ID
+}
+
+func (e ErrInsufficientFunds) Error() string {
+	return fmt.Sprintf("insufficient funds in account %s", e.AcctID)
+}
+
+func (e ErrInsufficientFunds) Code() string { return CodeInsufficientFunds }
+
+// ErrAccountFrozen is returned when charging an account frozen by an operator
+type ErrAccountFrozen struct {
+	AcctID snowflake.ID
+}
+
+func (e ErrAccountFrozen) Error() string {
+	return fmt.Sprintf("account %s is frozen", e.AcctID)
+}
+
+func (e ErrAccountFrozen) Code() string { return CodeAccountFrozen }
+
+// ErrRateLimited is returned when a request is rejected by a rate limiter.
+// RetryAfter is the earliest time the caller may expect the request to be admitted.
+type ErrRateLimited struct {
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e ErrRateLimited) Error() string {
+	return fmt.Sprintf("rate limited, retry after %v", e.RetryAfter)
+}
+
+func (e ErrRateLimited) Code() string { return CodeRateLimited }
@@ -0,0 +1,147 @@
+package bankxgo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+)
+
+// BalanceEvent is a posted change to the balance of an account. Its ID
+// increases with every event of the account, in commit order.
+type BalanceEvent struct {
+	ID     int64        `json:"id"`
+	AcctID snowflake.ID `json:"acctID"`
+	// Type is the type of the transaction, ie. deposit or withdrawal
+	Type string `json:"type"`
+	// Amount is added to the balance, negative amounts are taken off. The fee
+	// is taken off on top.
+	Amount  decimal.Decimal `json:"amount"`
+	Fee     decimal.Decimal `json:"fee"`
+	Balance decimal.Decimal `json:"balance"`
+	At      time.Time       `json:"at"`
+}
+
+type BalanceEventsReq struct {
+	AcctID snowflake.ID
+	Email  string
+	Client string
+	// LastEventID is the last event the client received, the events after it
+	// are replayed before the live ones. Zero means live events only.
+	LastEventID int64
+}
+
+// BalanceEventListener delivers the balance events committed by every
+// instance, ie. through Postgres LISTEN/NOTIFY
+type BalanceEventListener interface {
+	// ListenBalanceEvents calls fn with every balance event until ctx is done
+	// or the connection fails, events committed in between are not delivered
+	ListenBalanceEvents(ctx context.Context, fn func(BalanceEvent)) error
+}
+
+// balanceSubBuffer is how many events a subscriber may fall behind by before
+// it is dropped
+const balanceSubBuffer = 64
+
+// BalanceEventBroker fans the balance events out to the subscribers of each
+// account. Subscribers that fall behind, or that may have missed events while
+// the listener reconnected, are dropped: their channel is closed so the client
+// resumes from the last event it got.
+type BalanceEventBroker struct {
+	mu   sync.Mutex
+	subs map[snowflake.ID]map[chan BalanceEvent]struct{}
+	log  *zerolog.Logger
+}
+
+func NewBalanceEventBroker(log *zerolog.Logger) *BalanceEventBroker {
+	return &BalanceEventBroker{
+		subs: make(map[snowflake.ID]map[chan BalanceEvent]struct{}),
+		log:  log,
+	}
+}
+
+// Subscribe returns the live events of the account and a func to unsubscribe,
+// which has to be called once done
+func (b *BalanceEventBroker) Subscribe(acctID snowflake.ID) (<-chan BalanceEvent, func()) {
+	ch := make(chan BalanceEvent, balanceSubBuffer)
+	b.mu.Lock()
+	if b.subs[acctID] == nil {
+		b.subs[acctID] = make(map[chan BalanceEvent]struct{})
+	}
+	b.subs[acctID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.drop(acctID, ch)
+		})
+	}
+}
+
+// Publish hands ev to the subscribers of its account without blocking
+func (b *BalanceEventBroker) Publish(ev BalanceEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[ev.AcctID] {
+		select {
+		case ch <- ev:
+		default:
+			b.drop(ev.AcctID, ch)
+		}
+	}
+}
+
+// drop closes the subscriber's channel, if it was not dropped already. b.mu
+// must be held.
+func (b *BalanceEventBroker) drop(acctID snowflake.ID, ch chan BalanceEvent) {
+	if _, ok := b.subs[acctID][ch]; !ok {
+		return
+	}
+	delete(b.subs[acctID], ch)
+	if len(b.subs[acctID]) == 0 {
+		delete(b.subs, acctID)
+	}
+	close(ch)
+}
+
+func (b *BalanceEventBroker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for acctID, chs := range b.subs {
+		for ch := range chs {
+			b.drop(acctID, ch)
+		}
+	}
+}
+
+// Run publishes the events of l until ctx is done, reconnecting with backoff
+// whenever the listener fails
+func (b *BalanceEventBroker) Run(ctx context.Context, l BalanceEventListener) {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := l.ListenBalanceEvents(ctx, b.Publish)
+		if ctx.Err() != nil {
+			b.dropAll()
+			return
+		}
+		// events may have been missed until the listener is back
+		b.dropAll()
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		b.log.Err(err).Dur("retryIn", backoff).Msg("balance event listener failed")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, 30*time.Second)
+	}
+}
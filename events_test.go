@@ -0,0 +1,123 @@
+package bankxgo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+func receive(tt *testing.T, ch <-chan bankxgo.BalanceEvent) (bankxgo.BalanceEvent, bool) {
+	select {
+	case ev, ok := <-ch:
+		return ev, ok
+	case <-time.After(time.Second):
+		tt.Fatal("timed out waiting for a balance event")
+		return bankxgo.BalanceEvent{}, false
+	}
+}
+
+func TestBalanceEventBroker(t *testing.T) {
+	log := zerolog.Nop()
+	acctID := snowflake.ParseInt64(7241407009730334720)
+	otherID := snowflake.ParseInt64(7241407009730334721)
+
+	t.Run("publishes to the subscribers of the account", func(tt *testing.T) {
+		as := assert.New(tt)
+		b := bankxgo.NewBalanceEventBroker(&log)
+		sub, unsubscribe := b.Subscribe(acctID)
+		defer unsubscribe()
+		other, unsubscribeOther := b.Subscribe(otherID)
+		defer unsubscribeOther()
+
+		b.Publish(bankxgo.BalanceEvent{ID: 1, AcctID: acctID})
+		ev, ok := receive(tt, sub)
+		as.True(ok)
+		as.Equal(int64(1), ev.ID)
+		as.Len(other, 0)
+	})
+
+	t.Run("drops subscribers that fall behind", func(tt *testing.T) {
+		as := assert.New(tt)
+		b := bankxgo.NewBalanceEventBroker(&log)
+		sub, unsubscribe := b.Subscribe(acctID)
+		defer unsubscribe()
+
+		for i := int64(1); i <= 100; i++ {
+			b.Publish(bankxgo.BalanceEvent{ID: i, AcctID: acctID})
+		}
+		n := 0
+		for range sub {
+			n++
+		}
+		as.Less(n, 100)
+	})
+
+	t.Run("unsubscribing closes the channel once", func(tt *testing.T) {
+		as := assert.New(tt)
+		b := bankxgo.NewBalanceEventBroker(&log)
+		sub, unsubscribe := b.Subscribe(acctID)
+		unsubscribe()
+		unsubscribe()
+		_, ok := <-sub
+		as.False(ok)
+		b.Publish(bankxgo.BalanceEvent{ID: 1, AcctID: acctID})
+	})
+}
+
+func TestBalanceEvents(t *testing.T) {
+	log := zerolog.Nop()
+	acctID := snowflake.ParseInt64(7241407009730334720)
+
+	t.Run("is unavailable without a broker", func(tt *testing.T) {
+		repo := mocks.NewMockRepository(gomock.NewController(tt))
+		svc, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(nil, nil), nil, nil, &log, bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}))
+		require.Nil(tt, err)
+
+		_, err = svc.BalanceEvents(context.Background(), bankxgo.BalanceEventsReq{AcctID: acctID})
+		assert.Equal(tt, bankxgo.ErrServiceUnavailable, err)
+	})
+
+	t.Run("replays the missed events before the live ones, once each", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		repo := mocks.NewMockRepository(gomock.NewController(tt))
+		broker := bankxgo.NewBalanceEventBroker(&log)
+		svc, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(nil, nil), nil, nil, &log, bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}), bankxgo.WithBalanceEvents(broker))
+		reqrd.Nil(err)
+
+		repo.EXPECT().
+			BalanceEventsAfter(gomock.Any(), acctID, int64(4)).
+			DoAndReturn(func(_ context.Context, _ snowflake.ID, _ int64) ([]bankxgo.BalanceEvent, error) {
+				// committed while the replay was queried, so published too
+				broker.Publish(bankxgo.BalanceEvent{ID: 6, AcctID: acctID})
+				return []bankxgo.BalanceEvent{
+					{ID: 5, AcctID: acctID, Balance: decimal.New(10, 0)},
+					{ID: 6, AcctID: acctID, Balance: decimal.New(20, 0)},
+				}, nil
+			})
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := svc.BalanceEvents(ctx, bankxgo.BalanceEventsReq{AcctID: acctID, LastEventID: 4})
+		reqrd.Nil(err)
+		broker.Publish(bankxgo.BalanceEvent{ID: 7, AcctID: acctID})
+
+		for _, want := range []int64{5, 6, 7} {
+			ev, ok := receive(tt, events)
+			reqrd.True(ok)
+			as.Equal(want, ev.ID)
+		}
+
+		cancel()
+		_, ok := receive(tt, events)
+		as.False(ok)
+	})
+}
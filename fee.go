@@ -0,0 +1,59 @@
+package bankxgo
+
+import (
+	"github.com/bwmarrin/snowflake"
+	"github.com/shopspring/decimal"
+)
+
+// FeeSchedule computes the fee of an operation. If there are tiers, the first tier
+// the amount falls into is used in place of the top level Flat and Percentage.
+// The resulting fee is then capped by Min and Max. Zero values mean no fee/cap.
+type FeeSchedule struct {
+	Flat       decimal.Decimal `yaml:"flat"`
+	Percentage decimal.Decimal `yaml:"percentage"`
+	Tiers      []FeeTier       `yaml:"tiers"`
+	Min        decimal.Decimal `yaml:"min"`
+	Max        decimal.Decimal `yaml:"max"`
+}
+
+// FeeTier applies to amounts up to and including UpTo, a zero UpTo means no upper bound.
+// Tiers are expected to be listed in ascending order of UpTo.
+type FeeTier struct {
+	UpTo       decimal.Decimal `yaml:"up_to"`
+	Flat       decimal.Decimal `yaml:"flat"`
+	Percentage decimal.Decimal `yaml:"percentage"`
+}
+
+var hundred = decimal.NewFromInt(100)
+
+// Compute returns the fee for amount rounded to cents
+func (fs FeeSchedule) Compute(amount decimal.Decimal) decimal.Decimal {
+	flat, pct := fs.Flat, fs.Percentage
+	for _, t := range fs.Tiers {
+		if t.UpTo.IsZero() || amount.LessThanOrEqual(t.UpTo) {
+			flat, pct = t.Flat, t.Percentage
+			break
+		}
+	}
+	fee := flat.Add(amount.Mul(pct).Div(hundred))
+	if fs.Min.IsPositive() && fee.LessThan(fs.Min) {
+		fee = fs.Min
+	}
+	if fs.Max.IsPositive() && fee.GreaterThan(fs.Max) {
+		fee = fs.Max
+	}
+	return fee.RoundBank(2)
+}
+
+// Fee is a fee to be charged along with an operation and booked
+// to the fee revenue system account
+type Fee struct {
+	Amount  decimal.Decimal
+	Account snowflake.ID
+}
+
+// FeePolicy is the fee revenue account and fee schedules of a currency
+type FeePolicy struct {
+	Account  snowflake.ID
+	Withdraw FeeSchedule
+}
@@ -0,0 +1,51 @@
+package bankxgo_test
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/arhyth/bankxgo"
+)
+
+func TestFeeScheduleCompute(t *testing.T) {
+	d := func(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+	cases := []struct {
+		name   string
+		sched  bankxgo.FeeSchedule
+		amount string
+		fee    string
+	}{
+		{"no schedule", bankxgo.FeeSchedule{}, "100", "0"},
+		{"flat", bankxgo.FeeSchedule{Flat: d("1.25")}, "100", "1.25"},
+		{"percentage", bankxgo.FeeSchedule{Percentage: d("0.5")}, "1234.56", "6.17"},
+		{"flat plus percentage", bankxgo.FeeSchedule{Flat: d("1"), Percentage: d("1")}, "50", "1.5"},
+		{"min cap", bankxgo.FeeSchedule{Percentage: d("1"), Min: d("2")}, "10", "2"},
+		{"max cap", bankxgo.FeeSchedule{Percentage: d("1"), Max: d("25")}, "10000", "25"},
+		{
+			"first matching tier",
+			bankxgo.FeeSchedule{Tiers: []bankxgo.FeeTier{
+				{UpTo: d("1000"), Flat: d("1")},
+				{UpTo: d("10000"), Percentage: d("0.2")},
+				{Flat: d("15")},
+			}},
+			"5000", "10",
+		},
+		{
+			"unbounded last tier",
+			bankxgo.FeeSchedule{Tiers: []bankxgo.FeeTier{
+				{UpTo: d("1000"), Flat: d("1")},
+				{Flat: d("15")},
+			}},
+			"50000", "15",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(tt *testing.T) {
+			as := assert.New(tt)
+			fee := c.sched.Compute(d(c.amount))
+			as.True(d(c.fee).Equal(fee), "expected %s, got %s", c.fee, fee)
+		})
+	}
+}
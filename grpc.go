@@ -0,0 +1,291 @@
+package bankxgo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/arhyth/bankxgo/bankxpb"
+)
+
+// statementChunkSize is the most PDF bytes sent per StatementChunk
+const statementChunkSize = 32 << 10
+
+// GRPCCfg configures the gRPC API, served next to the REST API
+type GRPCCfg struct {
+	// Port is disabled if zero
+	Port int `yaml:"port"`
+}
+
+// GRPCOption configures optional behaviour of the gRPC server
+type GRPCOption func(*grpcHandler)
+
+// WithGRPCTrustedProxies sets the proxies allowed to name the client they
+// forward for with the `x-client-id` metadata key, see TrustedProxies
+func WithGRPCTrustedProxies(trusted TrustedProxies) GRPCOption {
+	return func(h *grpcHandler) {
+		h.trusted = trusted
+	}
+}
+
+// NewGRPCServer serves the gRPC API of bankxpb on top of svc, which is
+// expected to be wrapped in the same middlewares as the REST API's
+func NewGRPCServer(svc Service, log *zerolog.Logger, opts ...GRPCOption) *grpc.Server {
+	hndlr := &grpcHandler{Svc: svc, Log: log}
+	for _, opt := range opts {
+		opt(hndlr)
+	}
+	srv := grpc.NewServer()
+	bankxpb.RegisterBankxgoServer(srv, hndlr)
+	return srv
+}
+
+type grpcHandler struct {
+	bankxpb.UnimplementedBankxgoServer
+
+	Svc     Service
+	Log     *zerolog.Logger
+	trusted TrustedProxies
+}
+
+func (h *grpcHandler) CreateAccount(ctx context.Context, in *bankxpb.CreateAccountRequest) (*bankxpb.Account, error) {
+	req := CreateAccountReq{
+		Email:    in.GetEmail(),
+		Currency: in.GetCurrency(),
+		Client:   h.clientKey(ctx),
+	}
+	acct, err := h.Svc.CreateAccount(ctx, req)
+	if err != nil {
+		return nil, h.status(ctx, "createAccount", err)
+	}
+	return &bankxpb.Account{AcctId: acct.AcctID.Int64()}, nil
+}
+
+func (h *grpcHandler) Deposit(ctx context.Context, in *bankxpb.ChargeRequest) (*bankxpb.BalanceResponse, error) {
+	req, err := h.chargeReq(ctx, in)
+	if err != nil {
+		return nil, h.status(ctx, "deposit", err)
+	}
+	bal, err := h.Svc.Deposit(ctx, req)
+	if err != nil {
+		return nil, h.status(ctx, "deposit", err)
+	}
+	return &bankxpb.BalanceResponse{Balance: pbDecimal(*bal)}, nil
+}
+
+func (h *grpcHandler) Withdraw(ctx context.Context, in *bankxpb.ChargeRequest) (*bankxpb.Receipt, error) {
+	req, err := h.chargeReq(ctx, in)
+	if err != nil {
+		return nil, h.status(ctx, "withdraw", err)
+	}
+	rcpt, err := h.Svc.Withdraw(ctx, req)
+	if err != nil {
+		return nil, h.status(ctx, "withdraw", err)
+	}
+	return &bankxpb.Receipt{
+		Amount:  pbDecimal(rcpt.Amount),
+		Fee:     pbDecimal(rcpt.Fee),
+		Balance: pbDecimal(rcpt.Balance),
+	}, nil
+}
+
+func (h *grpcHandler) Balance(ctx context.Context, in *bankxpb.BalanceRequest) (*bankxpb.BalanceResponse, error) {
+	req := BalanceReq{
+		AcctID: snowflake.ParseInt64(in.GetAcctId()),
+		Email:  in.GetEmail(),
+		Client: h.clientKey(ctx),
+	}
+	if req.Email == "" {
+		return nil, h.status(ctx, "balance", ErrBadRequest{map[string]string{"email": "missing or invalid"}})
+	}
+	bals, err := h.Svc.Balance(ctx, req)
+	if err != nil {
+		return nil, h.status(ctx, "balance", err)
+	}
+	return &bankxpb.BalanceResponse{
+		Balance:         pbDecimal(bals.Balance),
+		OverdraftLimit:  pbDecimal(bals.OverdraftLimit),
+		AvailableCredit: pbDecimal(bals.AvailableCredit),
+		Pending:         pbDecimal(bals.Pending),
+		Available:       pbDecimal(bals.Available),
+	}, nil
+}
+
+// Statement streams the PDF as it is rendered. An error after the first chunk
+// ends the stream with its status, so clients have to discard what they got.
+func (h *grpcHandler) Statement(in *bankxpb.StatementRequest, stream bankxpb.Bankxgo_StatementServer) error {
+	req := StatementReq{
+		AcctID: snowflake.ParseInt64(in.GetAcctId()),
+		Email:  in.GetEmail(),
+		Client: h.clientKey(stream.Context()),
+		Format: "pdf",
+	}
+	if req.Email == "" {
+		return h.status(stream.Context(), "statement", ErrBadRequest{map[string]string{"email": "missing or invalid"}})
+	}
+	fields := make(map[string]string)
+	for name, pair := range map[string]struct {
+		src *bankxpb.Date
+		dst *time.Time
+	}{"from": {in.GetFrom(), &req.From}, "to": {in.GetTo(), &req.To}} {
+		if pair.src == nil {
+			continue
+		}
+		d := time.Date(int(pair.src.Year), time.Month(pair.src.Month), int(pair.src.Day), 0, 0, 0, 0, time.UTC)
+		// time.Date normalizes, ie. February 30th to March 1st
+		if d.Year() != int(pair.src.Year) || d.Month() != time.Month(pair.src.Month) || d.Day() != int(pair.src.Day) {
+			fields[name] = "invalid date"
+			continue
+		}
+		*pair.dst = d
+	}
+	if len(fields) > 0 {
+		return h.status(stream.Context(), "statement", ErrBadRequest{Fields: fields})
+	}
+
+	w := &statementChunkWriter{stream: stream}
+	if err := h.Svc.Statement(stream.Context(), w, req); err != nil {
+		return h.status(stream.Context(), "statement", err)
+	}
+	return w.flush()
+}
+
+// statementChunkWriter sends what is written in chunks of statementChunkSize
+type statementChunkWriter struct {
+	stream bankxpb.Bankxgo_StatementServer
+	buf    []byte
+}
+
+func (w *statementChunkWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= statementChunkSize {
+		if err := w.stream.Send(&bankxpb.StatementChunk{Data: w.buf[:statementChunkSize]}); err != nil {
+			return 0, err
+		}
+		// a fresh buffer, as Send may still reference the sent one
+		w.buf = append([]byte(nil), w.buf[statementChunkSize:]...)
+	}
+	return len(p), nil
+}
+
+func (w *statementChunkWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	return w.stream.Send(&bankxpb.StatementChunk{Data: w.buf})
+}
+
+func (h *grpcHandler) chargeReq(ctx context.Context, in *bankxpb.ChargeRequest) (ChargeReq, error) {
+	req := ChargeReq{
+		AcctID: snowflake.ParseInt64(in.GetAcctId()),
+		Email:  in.GetEmail(),
+		Client: h.clientKey(ctx),
+	}
+	if req.Email == "" {
+		return req, ErrBadRequest{map[string]string{"email": "missing or invalid"}}
+	}
+	if in.GetAmount() == nil {
+		return req, ErrBadRequest{map[string]string{"amount": "missing"}}
+	}
+	amt, err := decimal.NewFromString(in.GetAmount().GetValue())
+	if err != nil {
+		return req, ErrBadRequest{map[string]string{"amount": "invalid format"}}
+	}
+	req.Amount = amt
+	return req, nil
+}
+
+func pbDecimal(d decimal.Decimal) *bankxpb.Decimal {
+	return &bankxpb.Decimal{Value: d.String()}
+}
+
+// clientKey identifies the caller for per client rate limiting like
+// NewClientMiddleware does for HTTP, by the peer's IP or, for trusted proxies,
+// the `x-client-id` metadata key
+func (h *grpcHandler) clientKey(ctx context.Context) string {
+	var host string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host = remoteHost(p.Addr.String())
+	}
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get("x-client-id"); len(ids) > 0 {
+			id = ids[0]
+		}
+	}
+	return h.trusted.client(host, id)
+}
+
+// status maps err to a gRPC status the way WriteHTTPError maps it to an HTTP
+// status, with the invalid fields and the retry delay as error details
+func (h *grpcHandler) status(ctx context.Context, method string, err error) error {
+	errnf := &ErrNotFound{}
+	errbr := &ErrBadRequest{}
+	errcf := &ErrConflict{}
+	errfb := &ErrForbidden{}
+	errif := &ErrInsufficientFunds{}
+	errfz := &ErrAccountFrozen{}
+	errrl := &ErrRateLimited{}
+	switch {
+	case errors.As(err, errnf):
+		return status.Error(codes.NotFound, errnf.Error())
+	case errors.As(err, errcf):
+		return grpcStatusWithDetails(codes.AlreadyExists, errcf.Error(), grpcErrorInfo(errcf))
+	case errors.As(err, errfb):
+		return grpcStatusWithDetails(codes.PermissionDenied, errfb.Error(), grpcErrorInfo(errfb))
+	case errors.As(err, errif):
+		return grpcStatusWithDetails(codes.FailedPrecondition, errif.Error(), grpcErrorInfo(errif))
+	case errors.As(err, errfz):
+		return grpcStatusWithDetails(codes.FailedPrecondition, errfz.Error(), grpcErrorInfo(errfz))
+	case errors.As(err, errbr):
+		fields := make([]string, 0, len(errbr.Fields))
+		for f := range errbr.Fields {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		br := &errdetails.BadRequest{}
+		for _, f := range fields {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f,
+				Description: errbr.Fields[f],
+			})
+		}
+		return grpcStatusWithDetails(codes.InvalidArgument, "missing/invalid params", br)
+	case errors.As(err, errrl):
+		return grpcStatusWithDetails(codes.ResourceExhausted, "too many requests", &errdetails.RetryInfo{
+			RetryDelay: durationpb.New(errrl.RetryAfter),
+		})
+	case errors.Is(err, ErrServiceUnavailable):
+		return status.Error(codes.Unavailable, "service unavailable")
+	default:
+		ctxLog(ctx, h.Log).Err(err).Str("method", method).Msg("internal error")
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+// grpcErrorInfo carries the stable error code, as in the HTTP problem's
+// `code`, for clients to tell apart errors sharing a gRPC code
+func grpcErrorInfo(err DomainError) *errdetails.ErrorInfo {
+	return &errdetails.ErrorInfo{Reason: err.Code(), Domain: "bankxgo"}
+}
+
+func grpcStatusWithDetails(code codes.Code, msg string, detail protoadapt.MessageV1) error {
+	st := status.New(code, msg)
+	if withDetail, err := st.WithDetails(detail); err == nil {
+		st = withDetail
+	}
+	return st.Err()
+}
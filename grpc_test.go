@@ -0,0 +1,242 @@
+package bankxgo_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/bankxpb"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+// grpcClient serves svc over an in-memory connection
+func grpcClient(tt *testing.T, svc bankxgo.Service) bankxpb.BankxgoClient {
+	nooplog := zerolog.Nop()
+	lis := bufconn.Listen(1 << 20)
+	srv := bankxgo.NewGRPCServer(svc, &nooplog)
+	go srv.Serve(lis)
+	tt.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.Nil(tt, err)
+	tt.Cleanup(func() { conn.Close() })
+	return bankxpb.NewBankxgoClient(conn)
+}
+
+func TestGRPCDeposit(t *testing.T) {
+	t.Run("returns the balance", func(tt *testing.T) {
+		as := assert.New(tt)
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		bal := decimal.RequireFromString("1234.56")
+		svc.EXPECT().
+			Deposit(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, r bankxgo.ChargeReq) (*decimal.Decimal, error) {
+				as.Equal(int64(1834563581361305763), r.AcctID.Int64())
+				as.Equal("arhyth@gmail.com", r.Email)
+				as.Equal("1234.56", r.Amount.String())
+				// the in-memory peer is not a trusted proxy
+				as.Equal("bufconn", r.Client)
+				return &bal, nil
+			})
+		client := grpcClient(tt, svc)
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-client-id", "ledger-svc")
+		resp, err := client.Deposit(ctx, &bankxpb.ChargeRequest{
+			AcctId: 1834563581361305763,
+			Email:  "arhyth@gmail.com",
+			Amount: &bankxpb.Decimal{Value: "1234.56"},
+		})
+		require.Nil(tt, err)
+		as.Equal("1234.56", resp.GetBalance().GetValue())
+	})
+
+	t.Run("takes the client from trusted proxies", func(tt *testing.T) {
+		as := assert.New(tt)
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		bal := decimal.RequireFromString("1")
+		svc.EXPECT().
+			Deposit(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, r bankxgo.ChargeReq) (*decimal.Decimal, error) {
+				as.Equal("ledger-svc", r.Client)
+				return &bal, nil
+			})
+		nooplog := zerolog.Nop()
+		trusted, err := bankxgo.ParseTrustedProxies([]string{"127.0.0.1"})
+		require.Nil(tt, err)
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.Nil(tt, err)
+		srv := bankxgo.NewGRPCServer(svc, &nooplog, bankxgo.WithGRPCTrustedProxies(trusted))
+		go srv.Serve(lis)
+		tt.Cleanup(srv.Stop)
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.Nil(tt, err)
+		tt.Cleanup(func() { conn.Close() })
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-client-id", "ledger-svc")
+		_, err = bankxpb.NewBankxgoClient(conn).Deposit(ctx, &bankxpb.ChargeRequest{
+			AcctId: 1834563581361305763,
+			Email:  "arhyth@gmail.com",
+			Amount: &bankxpb.Decimal{Value: "1"},
+		})
+		require.Nil(tt, err)
+	})
+
+	t.Run("returns InvalidArgument with the invalid fields", func(tt *testing.T) {
+		as := assert.New(tt)
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		client := grpcClient(tt, svc)
+
+		_, err := client.Deposit(context.Background(), &bankxpb.ChargeRequest{
+			AcctId: 1834563581361305763,
+			Email:  "arhyth@gmail.com",
+			Amount: &bankxpb.Decimal{Value: "12,50"},
+		})
+		st := status.Convert(err)
+		as.Equal(codes.InvalidArgument, st.Code())
+		reqrd := require.New(tt)
+		reqrd.Len(st.Details(), 1)
+		br, ok := st.Details()[0].(*errdetails.BadRequest)
+		reqrd.True(ok)
+		reqrd.Len(br.GetFieldViolations(), 1)
+		as.Equal("amount", br.GetFieldViolations()[0].GetField())
+		as.Equal("invalid format", br.GetFieldViolations()[0].GetDescription())
+	})
+}
+
+func TestGRPCErrors(t *testing.T) {
+	cases := []struct {
+		err  error
+		code codes.Code
+	}{
+		{bankxgo.ErrNotFound{ID: 1}, codes.NotFound},
+		{bankxgo.ErrBadRequest{Fields: map[string]string{"amount": "invalid format"}}, codes.InvalidArgument},
+		{bankxgo.ErrConflict{Field: "email"}, codes.AlreadyExists},
+		{bankxgo.ErrForbidden{Reason: "system account"}, codes.PermissionDenied},
+		{bankxgo.ErrAccountFrozen{AcctID: 1834563581361305763}, codes.FailedPrecondition},
+		{bankxgo.ErrRateLimited{RetryAfter: 2 * time.Second}, codes.ResourceExhausted},
+		{bankxgo.ErrServiceUnavailable, codes.Unavailable},
+		{errors.New("connection reset"), codes.Internal},
+	}
+	for _, c := range cases {
+		t.Run(c.code.String(), func(tt *testing.T) {
+			svc := mocks.NewMockService(gomock.NewController(tt))
+			svc.EXPECT().Withdraw(gomock.Any(), gomock.Any()).Return(nil, c.err)
+			client := grpcClient(tt, svc)
+
+			_, err := client.Withdraw(context.Background(), &bankxpb.ChargeRequest{
+				AcctId: 1834563581361305763,
+				Email:  "arhyth@gmail.com",
+				Amount: &bankxpb.Decimal{Value: "100"},
+			})
+			st := status.Convert(err)
+			assert.Equal(tt, c.code, st.Code())
+			if c.code == codes.ResourceExhausted {
+				require.Len(tt, st.Details(), 1)
+				ri, ok := st.Details()[0].(*errdetails.RetryInfo)
+				require.True(tt, ok)
+				assert.Equal(tt, 2*time.Second, ri.GetRetryDelay().AsDuration())
+			}
+			if c.code == codes.FailedPrecondition {
+				// tells frozen accounts apart from insufficient funds
+				require.Len(tt, st.Details(), 1)
+				ei, ok := st.Details()[0].(*errdetails.ErrorInfo)
+				require.True(tt, ok)
+				assert.Equal(tt, bankxgo.CodeAccountFrozen, ei.GetReason())
+			}
+			if c.code == codes.Internal {
+				// internal errors are not leaked
+				assert.Equal(tt, "internal server error", st.Message())
+			}
+		})
+	}
+}
+
+func TestGRPCStatement(t *testing.T) {
+	t.Run("streams the PDF in chunks", func(tt *testing.T) {
+		as := assert.New(tt)
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		pdf := bytes.Repeat([]byte("%PDF-1.3 "), 10000)
+		svc.EXPECT().
+			Statement(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, w io.Writer, r bankxgo.StatementReq) error {
+				as.Equal("pdf", r.Format)
+				as.Equal(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC), r.From)
+				as.True(r.To.IsZero())
+				_, err := w.Write(pdf)
+				return err
+			})
+		client := grpcClient(tt, svc)
+
+		stream, err := client.Statement(context.Background(), &bankxpb.StatementRequest{
+			AcctId: 1834563581361305763,
+			Email:  "arhyth@gmail.com",
+			From:   &bankxpb.Date{Year: 2024, Month: 9, Day: 1},
+		})
+		require.Nil(tt, err)
+		var got bytes.Buffer
+		chunks := 0
+		for {
+			chunk, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.Nil(tt, err)
+			got.Write(chunk.GetData())
+			chunks++
+		}
+		as.Equal(pdf, got.Bytes())
+		as.Equal(3, chunks)
+	})
+
+	t.Run("rejects invalid dates", func(tt *testing.T) {
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		client := grpcClient(tt, svc)
+
+		stream, err := client.Statement(context.Background(), &bankxpb.StatementRequest{
+			AcctId: 1834563581361305763,
+			Email:  "arhyth@gmail.com",
+			To:     &bankxpb.Date{Year: 2024, Month: 2, Day: 30},
+		})
+		require.Nil(tt, err)
+		_, err = stream.Recv()
+		assert.Equal(tt, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("ends the stream with the error status", func(tt *testing.T) {
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		svc.EXPECT().Statement(gomock.Any(), gomock.Any(), gomock.Any()).Return(bankxgo.ErrNotFound{ID: 1834563581361305763})
+		client := grpcClient(tt, svc)
+
+		stream, err := client.Statement(context.Background(), &bankxpb.StatementRequest{
+			AcctId: 1834563581361305763,
+			Email:  "arhyth@gmail.com",
+		})
+		require.Nil(tt, err)
+		_, err = stream.Recv()
+		assert.Equal(tt, codes.NotFound, status.Code(err))
+	})
+}
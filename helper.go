@@ -16,6 +16,9 @@ import (
 type LocalHelper struct {
 	Conn     *pgx.Conn
 	SysAccts map[string]snowflake.ID
+	FeeAccts map[string]snowflake.ID
+	IntAccts map[string]snowflake.ID
+	OdAccts  map[string]snowflake.ID
 }
 
 func NewLocalHelper(cfg *Config) (*LocalHelper, error) {
@@ -32,12 +35,46 @@ func NewLocalHelper(cfg *Config) (*LocalHelper, error) {
 		}
 		sysAcctSS[strings.ToUpper(k)] = id
 	}
+	feeAcctSS := make(map[string]snowflake.ID, len(cfg.Fees))
+	for k, v := range cfg.Fees {
+		id, err := snowflake.ParseString(v.Account)
+		if err != nil {
+			return nil, err
+		}
+		feeAcctSS[strings.ToUpper(k)] = id
+	}
+	intAcctSS := make(map[string]snowflake.ID, len(cfg.Interest))
+	for k, v := range cfg.Interest {
+		id, err := snowflake.ParseString(v.Account)
+		if err != nil {
+			return nil, err
+		}
+		intAcctSS[strings.ToUpper(k)] = id
+	}
+	odAcctSS := make(map[string]snowflake.ID, len(cfg.Overdraft))
+	for k, v := range cfg.Overdraft {
+		id, err := snowflake.ParseString(v.Account)
+		if err != nil {
+			return nil, err
+		}
+		odAcctSS[strings.ToUpper(k)] = id
+	}
 	return &LocalHelper{
 		Conn:     conn,
 		SysAccts: sysAcctSS,
+		FeeAccts: feeAcctSS,
+		IntAccts: intAcctSS,
+		OdAccts:  odAcctSS,
 	}, nil
 }
 
+// SchemaExists tells whether the database has been initialized, ie. by InitDB
+func (lh *LocalHelper) SchemaExists() (bool, error) {
+	var exists bool
+	err := lh.Conn.QueryRow(context.Background(), `SELECT to_regclass('accounts') IS NOT NULL`).Scan(&exists)
+	return exists, err
+}
+
 func (lh *LocalHelper) InitDB() (func(), error) {
 	initSQLpath := filepath.Join("testdata", "init_db.sql")
 	bits, err := os.ReadFile(initSQLpath)
@@ -50,10 +87,53 @@ func (lh *LocalHelper) InitDB() (func(), error) {
 	return lh.teardownDB(), err
 }
 
+// seedAcct is a row of the system accounts seed template
+type seedAcct struct {
+	ID       snowflake.ID
+	Email    string
+	Currency string
+}
+
+// PrepareSystemAccounts seeds the system accounts, fee revenue, interest
+// expense and overdraft income accounts. Accounts that already exist are left alone, so it can be
+// run against a live database, but it fails if one of them is of another
+// currency than configured.
 func (lh *LocalHelper) PrepareSystemAccounts() error {
+	accts := make([]seedAcct, 0, len(lh.SysAccts)+len(lh.FeeAccts)+len(lh.IntAccts)+len(lh.OdAccts))
+	for cur, id := range lh.SysAccts {
+		accts = append(accts, seedAcct{
+			ID:       id,
+			Email:    strings.ToLower(cur) + "@root.co",
+			Currency: cur,
+		})
+	}
+	for cur, id := range lh.FeeAccts {
+		accts = append(accts, seedAcct{
+			ID:       id,
+			Email:    strings.ToLower(cur) + "-fees@root.co",
+			Currency: cur,
+		})
+	}
+	for cur, id := range lh.IntAccts {
+		accts = append(accts, seedAcct{
+			ID:       id,
+			Email:    strings.ToLower(cur) + "-interest@root.co",
+			Currency: cur,
+		})
+	}
+	for cur, id := range lh.OdAccts {
+		accts = append(accts, seedAcct{
+			ID:       id,
+			Email:    strings.ToLower(cur) + "-overdraft@root.co",
+			Currency: cur,
+		})
+	}
+	if len(accts) == 0 {
+		return nil
+	}
+
 	funcMap := template.FuncMap{
-		"ToLower": strings.ToLower,
-		"add":     func(a, b int) int { return a + b },
+		"add": func(a, b int) int { return a + b },
 	}
 	seedPath := filepath.Join("testdata", "seed_system_accounts.tmpl")
 	bits, err := os.ReadFile(seedPath)
@@ -65,7 +145,7 @@ func (lh *LocalHelper) PrepareSystemAccounts() error {
 		return err
 	}
 	buf := new(bytes.Buffer)
-	if err = tmpl.Execute(buf, lh.SysAccts); err != nil {
+	if err = tmpl.Execute(buf, accts); err != nil {
 		return err
 	}
 
@@ -73,6 +153,18 @@ func (lh *LocalHelper) PrepareSystemAccounts() error {
 		return err
 	}
 
+	for _, a := range accts {
+		var currency string
+		err = lh.Conn.QueryRow(context.Background(), `SELECT currency FROM accounts WHERE pub_id = $1`, a.ID).
+			Scan(&currency)
+		if err != nil {
+			return err
+		}
+		if currency != a.Currency {
+			return fmt.Errorf("account %s is of %s, not %s", a.ID, currency, a.Currency)
+		}
+	}
+
 	return err
 }
 
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/bwmarrin/snowflake"
 	"github.com/go-chi/chi/v5"
@@ -18,14 +21,65 @@ type balanceJSONResp struct {
 	Balance decimal.Decimal `json:"balance"`
 }
 
-type genericErrResp struct {
-	Err string `json:"error"`
+type statementJobJSONReq struct {
+	Format string `json:"format"`
+	// From and To are dates in YYYY-MM-DD format
+	From string `json:"from"`
+	To   string `json:"to"`
 }
 
-func NewHTTPHandler(svc Service, log *zerolog.Logger) http.Handler {
+type batchJSONReq struct {
+	Mode  string         `json:"mode"`
+	Items []BatchItemReq `json:"items"`
+}
+
+// IdempotencyKeyHeader carries the idempotency key of a batch, which is also
+// needed to fetch it
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on the response to a batch posted by an
+// earlier request with the same idempotency key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+type statementVerifyJSONResp struct {
+	Valid bool `json:"valid"`
+	*StatementVerification
+}
+
+// defaultEventHeartbeat is how often an idle event stream gets a comment, to
+// keep proxies from timing it out and to notice gone clients
+const defaultEventHeartbeat = 15 * time.Second
+
+// eventRetry is how long clients wait before reconnecting to an event stream
+const eventRetry = 3 * time.Second
+
+// HTTPOption configures optional behaviour of the HTTP handler
+type HTTPOption func(*httpHandler)
+
+// WithEventHeartbeat sets how often idle balance event streams get a
+// heartbeat, 15 seconds by default
+func WithEventHeartbeat(d time.Duration) HTTPOption {
+	return func(h *httpHandler) {
+		h.heartbeat = d
+	}
+}
+
+// WithSettlementKey sets the key settlement callbacks are signed with, see
+// SignSettlement. Settlements are forbidden without one.
+func WithSettlementKey(key string) HTTPOption {
+	return func(h *httpHandler) {
+		h.settlementKey = []byte(key)
+	}
+}
+
+func NewHTTPHandler(svc Service, log *zerolog.Logger, opts ...HTTPOption) http.Handler {
 	hndlr := &httpHandler{
-		Svc: svc,
-		Log: log,
+		Svc:       svc,
+		Log:       log,
+		heartbeat: defaultEventHeartbeat,
+	}
+	for _, opt := range opts {
+		opt(hndlr)
 	}
 	mux := chi.NewMux()
 	mux.NotFound(HTTPNotFound)
@@ -36,21 +90,47 @@ func NewHTTPHandler(svc Service, log *zerolog.Logger) http.Handler {
 			rr.Post("/withdraw", hndlr.Withdraw)
 			rr.Get("/balance", hndlr.Balance)
 			rr.Get("/statement", hndlr.Statement)
+			rr.Post("/statements", hndlr.RequestStatement)
+			rr.Get("/statements", hndlr.ListStatementPeriods)
+			rr.Get("/statements/{to:[0-9]{4}-[0-9]{2}-[0-9]{2}}", hndlr.GetStatementPeriod)
+			rr.Put("/statement/preferences", hndlr.SetStatementPreference)
+			rr.Put("/statement/password", hndlr.SetStatementPassword)
+			rr.Get("/events", hndlr.BalanceEvents)
+			rr.Get("/transactions", hndlr.ListTransactions)
+			rr.Post("/scheduled-payments", hndlr.CreateScheduledPayment)
+			rr.Get("/scheduled-payments", hndlr.ListScheduledPayments)
+			rr.Get("/scheduled-payments/{paymentID:[0-9]+}", hndlr.GetScheduledPayment)
+			rr.Put("/scheduled-payments/{paymentID:[0-9]+}", hndlr.UpdateScheduledPayment)
+			rr.Delete("/scheduled-payments/{paymentID:[0-9]+}", hndlr.CancelScheduledPayment)
+			rr.Get("/scheduled-payments/{paymentID:[0-9]+}/runs", hndlr.ListScheduledPaymentRuns)
 		})
 	})
+	mux.Post("/batches", hndlr.PostBatch)
+	mux.Post("/settlements", hndlr.Settle)
+	mux.Get("/batches/{batchID:[0-9]+}", hndlr.GetBatch)
+	mux.Get("/statements/{jobID:[0-9]+}", hndlr.GetStatementJob)
+	mux.Get("/statements/verify/{code}", hndlr.VerifyStatement)
+	mux.Get("/openapi.json", ServeOpenAPI)
 
 	return mux
 }
 
 type httpHandler struct {
-	Svc Service
-	Log *zerolog.Logger
+	Svc           Service
+	Log           *zerolog.Logger
+	heartbeat     time.Duration
+	settlementKey []byte
+}
+
+// log returns the request-scoped logger set by NewRequestLogMiddleware, or Log
+func (h *httpHandler) log(r *http.Request) *zerolog.Logger {
+	return ctxLog(r.Context(), h.Log)
 }
 
 func (h *httpHandler) Deposit(w http.ResponseWriter, r *http.Request) {
 	email := r.Header.Get("email")
 	if email == "" {
-		h.Log.Error().Str("method", "balance").Msg("missing/invalid email")
+		h.log(r).Error().Str("method", "deposit").Msg("missing/invalid email")
 		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
 		return
 	}
@@ -58,26 +138,27 @@ func (h *httpHandler) Deposit(w http.ResponseWriter, r *http.Request) {
 	buf, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
 	if err != nil {
-		h.Log.Err(err).Str("method", "deposit").Msg("error reading HTTP request")
+		h.log(r).Err(err).Str("method", "deposit").Msg("error reading HTTP request")
 		WriteHTTPError(w, ErrInternalServer)
 		return
 	}
 	var req ChargeReq
 	if err = json.Unmarshal(buf, &req); err != nil {
-		h.Log.Err(err).Str("method", "deposit").Msg("error unmarshalling JSON")
+		h.log(r).Err(err).Str("method", "deposit").Msg("error unmarshalling JSON")
 		WriteHTTPError(w, ErrBadRequest{Fields: map[string]string{"request body": "malformed JSON"}})
 		return
 	}
 	pid := chi.URLParam(r, "acctID")
 	acctID, err := snowflake.ParseString(pid)
 	if err != nil {
-		h.Log.Err(err).Str("method", "deposit").Msg("error parsing account ID")
+		h.log(r).Err(err).Str("method", "deposit").Msg("error parsing account ID")
 		WriteHTTPError(w, ErrBadRequest{map[string]string{"acctID": "invalid format"}})
 		return
 	}
 	req.AcctID = acctID
 	req.Email = email
-	bal, err := h.Svc.Deposit(req)
+	req.Client = clientKey(r)
+	bal, err := h.Svc.Deposit(r.Context(), req)
 	if err != nil {
 		WriteHTTPError(w, err)
 		return
@@ -93,7 +174,7 @@ func (h *httpHandler) Deposit(w http.ResponseWriter, r *http.Request) {
 func (h *httpHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 	email := r.Header.Get("email")
 	if email == "" {
-		h.Log.Error().Str("method", "balance").Msg("missing/invalid email")
+		h.log(r).Error().Str("method", "withdraw").Msg("missing/invalid email")
 		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
 		return
 	}
@@ -101,34 +182,34 @@ func (h *httpHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 	buf, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
 	if err != nil {
-		h.Log.Err(err).Str("method", "withdraw").Msg("error reading HTTP request")
+		h.log(r).Err(err).Str("method", "withdraw").Msg("error reading HTTP request")
 		WriteHTTPError(w, ErrInternalServer)
 		return
 	}
 	var req ChargeReq
 	if err = json.Unmarshal(buf, &req); err != nil {
-		h.Log.Err(err).Str("method", "withdraw").Msg("error unmarshalling JSON")
+		h.log(r).Err(err).Str("method", "withdraw").Msg("error unmarshalling JSON")
 		WriteHTTPError(w, ErrBadRequest{Fields: map[string]string{"request body": "malformed JSON"}})
 		return
 	}
 	pid := chi.URLParam(r, "acctID")
 	acctID, err := snowflake.ParseString(pid)
 	if err != nil {
-		h.Log.Err(err).Str("method", "withdraw").Msg("error parsing account ID")
+		h.log(r).Err(err).Str("method", "withdraw").Msg("error parsing account ID")
 		WriteHTTPError(w, ErrBadRequest{map[string]string{"acctID": "invalid format"}})
 		return
 	}
 	req.AcctID = acctID
 	req.Email = email
-	bal, err := h.Svc.Withdraw(req)
+	req.Client = clientKey(r)
+	rcpt, err := h.Svc.Withdraw(r.Context(), req)
 	if err != nil {
 		WriteHTTPError(w, err)
 		return
 	}
 
-	resp := balanceJSONResp{Balance: *bal}
 	w.Header().Set("Content-Type", "application/json")
-	if err = json.NewEncoder(w).Encode(resp); err != nil {
+	if err = json.NewEncoder(w).Encode(rcpt); err != nil {
 		WriteHTTPError(w, err)
 	}
 }
@@ -136,7 +217,7 @@ func (h *httpHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 func (h *httpHandler) Balance(w http.ResponseWriter, r *http.Request) {
 	email := r.Header.Get("email")
 	if email == "" {
-		h.Log.Error().Str("method", "balance").Msg("missing/invalid email")
+		h.log(r).Error().Str("method", "balance").Msg("missing/invalid email")
 		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
 		return
 	}
@@ -144,23 +225,23 @@ func (h *httpHandler) Balance(w http.ResponseWriter, r *http.Request) {
 	pid := chi.URLParam(r, "acctID")
 	acctID, err := snowflake.ParseString(pid)
 	if err != nil {
-		h.Log.Err(err).Str("method", "balance").Msg("error parsing account ID")
+		h.log(r).Err(err).Str("method", "balance").Msg("error parsing account ID")
 		WriteHTTPError(w, ErrBadRequest{map[string]string{"acctID": "invalid format"}})
 		return
 	}
 	req := BalanceReq{
 		AcctID: acctID,
 		Email:  email,
+		Client: clientKey(r),
 	}
-	bal, err := h.Svc.Balance(req)
+	bals, err := h.Svc.Balance(r.Context(), req)
 	if err != nil {
 		WriteHTTPError(w, err)
 		return
 	}
 
-	resp := balanceJSONResp{Balance: *bal}
 	w.Header().Set("Content-Type", "application/json")
-	if err = json.NewEncoder(w).Encode(resp); err != nil {
+	if err = json.NewEncoder(w).Encode(bals); err != nil {
 		WriteHTTPError(w, err)
 	}
 }
@@ -168,43 +249,377 @@ func (h *httpHandler) Balance(w http.ResponseWriter, r *http.Request) {
 func (h *httpHandler) Statement(w http.ResponseWriter, r *http.Request) {
 	email := r.Header.Get("email")
 	if email == "" {
-		h.Log.Error().Str("method", "statement").Msg("missing/invalid email")
+		h.log(r).Error().Str("method", "statement").Msg("missing/invalid email")
 		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
 		return
 	}
 	pid := chi.URLParam(r, "acctID")
 	acctID, err := snowflake.ParseString(pid)
 	if err != nil {
-		h.Log.Err(err).Str("method", "statement").Msg("error parsing account ID")
+		h.log(r).Err(err).Str("method", "statement").Msg("error parsing account ID")
 		WriteHTTPError(w, ErrBadRequest{map[string]string{"acctID": "invalid format"}})
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/pdf")
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = StatementFormatFromAccept(r.Header.Get("Accept"))
+	}
+	rndr, ok := StatementRendererFor(format)
+	if !ok {
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"format": "unsupported"}})
+		return
+	}
+	req := StatementReq{
+		AcctID: acctID,
+		Email:  email,
+		Client: clientKey(r),
+		Format: format,
+	}
+	for param, dst := range map[string]*time.Time{"from": &req.From, "to": &req.To} {
+		v := query.Get(param)
+		if v == "" {
+			continue
+		}
+		if *dst, err = time.Parse(time.DateOnly, v); err != nil {
+			WriteHTTPError(w, ErrBadRequest{map[string]string{param: "invalid date, expected YYYY-MM-DD"}})
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", rndr.ContentType())
+	if err := h.Svc.Statement(r.Context(), w, req); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+// RequestStatement enqueues a statement job. The response is 202 Accepted with the
+// job status, or 200 OK if an identical statement was already rendered, and
+// in either case the Location of the job.
+func (h *httpHandler) RequestStatement(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("email")
+	if email == "" {
+		h.log(r).Error().Str("method", "requestStatement").Msg("missing/invalid email")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
+		return
+	}
+	pid := chi.URLParam(r, "acctID")
+	acctID, err := snowflake.ParseString(pid)
+	if err != nil {
+		h.log(r).Err(err).Str("method", "requestStatement").Msg("error parsing account ID")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"acctID": "invalid format"}})
+		return
+	}
+
+	buf, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		h.log(r).Err(err).Str("method", "requestStatement").Msg("error reading HTTP request")
+		WriteHTTPError(w, ErrInternalServer)
+		return
+	}
+	var body statementJobJSONReq
+	if len(buf) > 0 {
+		if err = json.Unmarshal(buf, &body); err != nil {
+			h.log(r).Err(err).Str("method", "requestStatement").Msg("error unmarshalling JSON")
+			WriteHTTPError(w, ErrBadRequest{Fields: map[string]string{"request body": "malformed JSON"}})
+			return
+		}
+	}
 	req := StatementReq{
 		AcctID: acctID,
 		Email:  email,
+		Client: clientKey(r),
+		Format: body.Format,
+	}
+	for param, v := range map[string]string{"from": body.From, "to": body.To} {
+		if v == "" {
+			continue
+		}
+		dst := &req.From
+		if param == "to" {
+			dst = &req.To
+		}
+		if *dst, err = time.Parse(time.DateOnly, v); err != nil {
+			WriteHTTPError(w, ErrBadRequest{map[string]string{param: "invalid date, expected YYYY-MM-DD"}})
+			return
+		}
+	}
+
+	job, err := h.Svc.RequestStatement(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/statements/"+job.ID.String())
+	if job.Status == StatementJobDone {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	if err = json.NewEncoder(w).Encode(job); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+// GetStatementJob returns the rendered file if the job is done, otherwise the
+// job status with 202 Accepted while it is pending or 200 OK if it failed
+func (h *httpHandler) GetStatementJob(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("email")
+	if email == "" {
+		h.log(r).Error().Str("method", "getStatementJob").Msg("missing/invalid email")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
+		return
+	}
+	pid := chi.URLParam(r, "jobID")
+	jobID, err := snowflake.ParseString(pid)
+	if err != nil {
+		h.log(r).Err(err).Str("method", "getStatementJob").Msg("error parsing job ID")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"jobID": "invalid format"}})
+		return
+	}
+	req := StatementJobReq{
+		JobID:  jobID,
+		Email:  email,
+		Client: clientKey(r),
+	}
+	job, file, err := h.Svc.GetStatementJob(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+
+	if file == nil {
+		w.Header().Set("Content-Type", "application/json")
+		if job.Status != StatementJobFailed {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		if err = json.NewEncoder(w).Encode(job); err != nil {
+			WriteHTTPError(w, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	contentType := "application/octet-stream"
+	if rndr, ok := StatementRendererFor(job.Format); ok {
+		contentType = rndr.ContentType()
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set(
+		"Content-Disposition",
+		fmt.Sprintf(`attachment; filename="statement-%s-%s.%s"`, job.AcctID, job.ID, job.Format),
+	)
+	if _, err = io.Copy(w, file); err != nil {
+		h.log(r).Err(err).Str("method", "getStatementJob").Msg("error writing statement")
+	}
+}
+
+func (h *httpHandler) ListStatementPeriods(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("email")
+	if email == "" {
+		h.log(r).Error().Str("method", "listStatementPeriods").Msg("missing/invalid email")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
+		return
+	}
+	pid := chi.URLParam(r, "acctID")
+	acctID, err := snowflake.ParseString(pid)
+	if err != nil {
+		h.log(r).Err(err).Str("method", "listStatementPeriods").Msg("error parsing account ID")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"acctID": "invalid format"}})
+		return
+	}
+	req := StatementPeriodsReq{
+		AcctID: acctID,
+		Email:  email,
+		Client: clientKey(r),
+	}
+	periods, err := h.Svc.ListStatementPeriods(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+	if periods == nil {
+		// an empty list rather than null
+		periods = []StatementPeriod{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(periods); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+// GetStatementPeriod returns the document of the closed period ending on the
+// `to` date, exactly as it was rendered when the period closed
+func (h *httpHandler) GetStatementPeriod(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("email")
+	if email == "" {
+		h.log(r).Error().Str("method", "getStatementPeriod").Msg("missing/invalid email")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
+		return
+	}
+	pid := chi.URLParam(r, "acctID")
+	acctID, err := snowflake.ParseString(pid)
+	if err != nil {
+		h.log(r).Err(err).Str("method", "getStatementPeriod").Msg("error parsing account ID")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"acctID": "invalid format"}})
+		return
+	}
+	to, err := time.Parse(time.DateOnly, chi.URLParam(r, "to"))
+	if err != nil {
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"to": "invalid date, expected YYYY-MM-DD"}})
+		return
+	}
+	req := StatementPeriodReq{
+		AcctID: acctID,
+		Email:  email,
+		Client: clientKey(r),
+		To:     to,
+	}
+	period, file, err := h.Svc.GetStatementPeriod(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+	defer file.Close()
+
+	contentType := "application/octet-stream"
+	if rndr, ok := StatementRendererFor(period.Format); ok {
+		contentType = rndr.ContentType()
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set(
+		"Content-Disposition",
+		fmt.Sprintf(`attachment; filename="statement-%s-%s.%s"`, period.AcctID, period.To.Format(time.DateOnly), period.Format),
+	)
+	if _, err = io.Copy(w, file); err != nil {
+		h.log(r).Err(err).Str("method", "getStatementPeriod").Msg("error writing statement")
+	}
+}
+
+// VerifyStatement is public as it is meant for third parties handed a
+// statement, ie. lenders, so it needs no email header
+func (h *httpHandler) VerifyStatement(w http.ResponseWriter, r *http.Request) {
+	req := VerifyStatementReq{
+		Code:   chi.URLParam(r, "code"),
+		Client: clientKey(r),
+	}
+	v, err := h.Svc.VerifyStatement(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+
+	resp := statementVerifyJSONResp{Valid: true, StatementVerification: v}
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(resp); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+func (h *httpHandler) SetStatementPreference(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("email")
+	if email == "" {
+		h.log(r).Error().Str("method", "setStatementPreference").Msg("missing/invalid email")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
+		return
+	}
+	pid := chi.URLParam(r, "acctID")
+	acctID, err := snowflake.ParseString(pid)
+	if err != nil {
+		h.log(r).Err(err).Str("method", "setStatementPreference").Msg("error parsing account ID")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"acctID": "invalid format"}})
+		return
+	}
+
+	buf, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		h.log(r).Err(err).Str("method", "setStatementPreference").Msg("error reading HTTP request")
+		WriteHTTPError(w, ErrInternalServer)
+		return
+	}
+	req := StatementPreferenceReq{
+		AcctID: acctID,
+		Email:  email,
+		Client: clientKey(r),
 	}
-	if err := h.Svc.Statement(w, req); err != nil {
+	if err = json.Unmarshal(buf, &req.StatementPreference); err != nil {
+		h.log(r).Err(err).Str("method", "setStatementPreference").Msg("error unmarshalling JSON")
+		WriteHTTPError(w, ErrBadRequest{Fields: map[string]string{"request body": "malformed JSON"}})
+		return
+	}
+	pref, err := h.Svc.SetStatementPreference(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(pref); err != nil {
 		WriteHTTPError(w, err)
 	}
 }
 
+func (h *httpHandler) SetStatementPassword(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("email")
+	if email == "" {
+		h.log(r).Error().Str("method", "setStatementPassword").Msg("missing/invalid email")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
+		return
+	}
+	pid := chi.URLParam(r, "acctID")
+	acctID, err := snowflake.ParseString(pid)
+	if err != nil {
+		h.log(r).Err(err).Str("method", "setStatementPassword").Msg("error parsing account ID")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"acctID": "invalid format"}})
+		return
+	}
+
+	buf, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		h.log(r).Err(err).Str("method", "setStatementPassword").Msg("error reading HTTP request")
+		WriteHTTPError(w, ErrInternalServer)
+		return
+	}
+	var req StatementPasswordReq
+	if err = json.Unmarshal(buf, &req); err != nil {
+		h.log(r).Err(err).Str("method", "setStatementPassword").Msg("error unmarshalling JSON")
+		WriteHTTPError(w, ErrBadRequest{Fields: map[string]string{"request body": "malformed JSON"}})
+		return
+	}
+	req.AcctID = acctID
+	req.Email = email
+	req.Client = clientKey(r)
+	if err = h.Svc.SetStatementPassword(r.Context(), req); err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *httpHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 	buf, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
 	if err != nil {
-		h.Log.Err(err).Str("method", "createAccount").Msg("error reading HTTP request")
+		h.log(r).Err(err).Str("method", "createAccount").Msg("error reading HTTP request")
 		WriteHTTPError(w, ErrInternalServer)
 		return
 	}
 	var req CreateAccountReq
 	if err = json.Unmarshal(buf, &req); err != nil {
-		h.Log.Err(err).Str("method", "createAccount").Msg("error unmarshalling JSON")
+		h.log(r).Err(err).Str("method", "createAccount").Msg("error unmarshalling JSON")
 		WriteHTTPError(w, ErrBadRequest{Fields: map[string]string{"request body": "malformed JSON"}})
 		return
 	}
-	acct, err := h.Svc.CreateAccount(req)
+	req.Client = clientKey(r)
+	acct, err := h.Svc.CreateAccount(r.Context(), req)
 	if err != nil {
 		WriteHTTPError(w, err)
 		return
@@ -217,41 +632,534 @@ func (h *httpHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func WriteHTTPError(w http.ResponseWriter, err error) {
-	var ne error
-	defer func() {
-		if ne != nil {
-			log.Error().
-				Err(ne).
-				Msg("error response encoding failed")
-		}
-	}()
+func (h *httpHandler) PostBatch(w http.ResponseWriter, r *http.Request) {
+	buf, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		h.log(r).Err(err).Str("method", "postBatch").Msg("error reading HTTP request")
+		WriteHTTPError(w, ErrInternalServer)
+		return
+	}
+	var body batchJSONReq
+	if err = json.Unmarshal(buf, &body); err != nil {
+		h.log(r).Err(err).Str("method", "postBatch").Msg("error unmarshalling JSON")
+		WriteHTTPError(w, ErrBadRequest{Fields: map[string]string{"request body": "malformed JSON"}})
+		return
+	}
+	req := BatchReq{
+		Key:    r.Header.Get(IdempotencyKeyHeader),
+		Mode:   body.Mode,
+		Items:  body.Items,
+		Client: clientKey(r),
+	}
+	batch, err := h.Svc.PostBatch(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	errnf := &ErrNotFound{}
-	errbr := &ErrBadRequest{}
-	if errors.As(err, errnf) {
-		w.WriteHeader(http.StatusNotFound)
-		ne = json.NewEncoder(w).Encode(errnf)
-	} else if errors.As(err, errbr) {
-		w.WriteHeader(http.StatusBadRequest)
-		ne = json.NewEncoder(w).Encode(errbr)
-	} else if err == ErrServiceUnavailable {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		resp := genericErrResp{Err: "service unavailable"}
-		ne = json.NewEncoder(w).Encode(resp)
+	w.Header().Set("Location", "/batches/"+batch.ID.String())
+	if batch.Replayed {
+		w.Header().Set(IdempotentReplayedHeader, "true")
+		w.WriteHeader(http.StatusOK)
 	} else {
-		w.WriteHeader(http.StatusInternalServerError)
-		resp := genericErrResp{Err: "internal server error"}
-		ne = json.NewEncoder(w).Encode(resp)
+		w.WriteHeader(http.StatusCreated)
+	}
+	if err = json.NewEncoder(w).Encode(batch); err != nil {
+		WriteHTTPError(w, err)
 	}
 }
 
-func HTTPNotFound(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotFound)
+func (h *httpHandler) Settle(w http.ResponseWriter, r *http.Request) {
+	if len(h.settlementKey) == 0 {
+		WriteHTTPError(w, ErrForbidden{Reason: "settlements are not enabled"})
+		return
+	}
+	buf, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		h.log(r).Err(err).Str("method", "settle").Msg("error reading HTTP request")
+		WriteHTTPError(w, ErrInternalServer)
+		return
+	}
+	// the signature is of the body as sent, it is checked before anything is
+	// made of the body
+	if err = verifySettlement(h.settlementKey, buf, r.Header.Get(SettlementSignatureHeader), time.Now()); err != nil {
+		h.log(r).Warn().Err(err).Str("method", "settle").Msg("settlement signature rejected")
+		WriteHTTPError(w, err)
+		return
+	}
+	var req SettleReq
+	if err = json.Unmarshal(buf, &req); err != nil {
+		h.log(r).Err(err).Str("method", "settle").Msg("error unmarshalling JSON")
+		WriteHTTPError(w, ErrBadRequest{Fields: map[string]string{"request body": "malformed JSON"}})
+		return
+	}
+	req.Client = clientKey(r)
+	txn, err := h.Svc.Settle(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(txn); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+func (h *httpHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	pid := chi.URLParam(r, "batchID")
+	batchID, err := snowflake.ParseString(pid)
+	if err != nil {
+		h.log(r).Err(err).Str("method", "getBatch").Msg("error parsing batch ID")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"batchID": "invalid format"}})
+		return
+	}
+	req := BatchReqByID{
+		BatchID: batchID,
+		Key:     r.Header.Get(IdempotencyKeyHeader),
+		Client:  clientKey(r),
+	}
+	batch, err := h.Svc.GetBatch(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(batch); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+// scheduledPaymentReq parses the email, account and, if in the route, payment
+// of a scheduled payment request. It writes the error response if invalid.
+func (h *httpHandler) scheduledPaymentReq(w http.ResponseWriter, r *http.Request, method string) (ScheduledPaymentReqByID, bool) {
+	req := ScheduledPaymentReqByID{
+		Email:  r.Header.Get("email"),
+		Client: clientKey(r),
+	}
+	if req.Email == "" {
+		h.log(r).Error().Str("method", method).Msg("missing/invalid email")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
+		return req, false
+	}
+	var err error
+	if req.AcctID, err = snowflake.ParseString(chi.URLParam(r, "acctID")); err != nil {
+		h.log(r).Err(err).Str("method", method).Msg("error parsing account ID")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"acctID": "invalid format"}})
+		return req, false
+	}
+	if pid := chi.URLParam(r, "paymentID"); pid != "" {
+		if req.PaymentID, err = snowflake.ParseString(pid); err != nil {
+			h.log(r).Err(err).Str("method", method).Msg("error parsing payment ID")
+			WriteHTTPError(w, ErrBadRequest{map[string]string{"paymentID": "invalid format"}})
+			return req, false
+		}
+	}
+	return req, true
+}
+
+// scheduledPaymentSpec reads the spec of a scheduled payment from the request
+// body. It writes the error response if invalid.
+func (h *httpHandler) scheduledPaymentSpec(w http.ResponseWriter, r *http.Request, method string) (ScheduledPaymentSpec, bool) {
+	var spec ScheduledPaymentSpec
+	buf, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		h.log(r).Err(err).Str("method", method).Msg("error reading HTTP request")
+		WriteHTTPError(w, ErrInternalServer)
+		return spec, false
+	}
+	if err = json.Unmarshal(buf, &spec); err != nil {
+		h.log(r).Err(err).Str("method", method).Msg("error unmarshalling JSON")
+		WriteHTTPError(w, ErrBadRequest{Fields: map[string]string{"request body": "malformed JSON"}})
+		return spec, false
+	}
+	return spec, true
+}
+
+// CreateScheduledPayment responds 201 Created with the payment and its Location
+func (h *httpHandler) CreateScheduledPayment(w http.ResponseWriter, r *http.Request) {
+	byID, ok := h.scheduledPaymentReq(w, r, "createScheduledPayment")
+	if !ok {
+		return
+	}
+	spec, ok := h.scheduledPaymentSpec(w, r, "createScheduledPayment")
+	if !ok {
+		return
+	}
+	req := ScheduledPaymentReq{
+		AcctID:               byID.AcctID,
+		Email:                byID.Email,
+		Client:               byID.Client,
+		ScheduledPaymentSpec: spec,
+	}
+	p, err := h.Svc.CreateScheduledPayment(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/accounts/"+p.AcctID.String()+"/scheduled-payments/"+p.ID.String())
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(p); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+func (h *httpHandler) ListScheduledPayments(w http.ResponseWriter, r *http.Request) {
+	byID, ok := h.scheduledPaymentReq(w, r, "listScheduledPayments")
+	if !ok {
+		return
+	}
+	req := ScheduledPaymentsReq{
+		AcctID: byID.AcctID,
+		Email:  byID.Email,
+		Client: byID.Client,
+	}
+	payments, err := h.Svc.ListScheduledPayments(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+	if payments == nil {
+		// an empty list rather than null
+		payments = []ScheduledPayment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(payments); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+func (h *httpHandler) GetScheduledPayment(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.scheduledPaymentReq(w, r, "getScheduledPayment")
+	if !ok {
+		return
+	}
+	p, err := h.Svc.GetScheduledPayment(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(p); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+func (h *httpHandler) UpdateScheduledPayment(w http.ResponseWriter, r *http.Request) {
+	byID, ok := h.scheduledPaymentReq(w, r, "updateScheduledPayment")
+	if !ok {
+		return
+	}
+	spec, ok := h.scheduledPaymentSpec(w, r, "updateScheduledPayment")
+	if !ok {
+		return
+	}
+	req := ScheduledPaymentReq{
+		AcctID:               byID.AcctID,
+		PaymentID:            byID.PaymentID,
+		Email:                byID.Email,
+		Client:               byID.Client,
+		ScheduledPaymentSpec: spec,
+	}
+	p, err := h.Svc.UpdateScheduledPayment(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(p); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+// CancelScheduledPayment responds with the cancelled payment, which is kept
+// along with its run history
+func (h *httpHandler) CancelScheduledPayment(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.scheduledPaymentReq(w, r, "cancelScheduledPayment")
+	if !ok {
+		return
+	}
+	p, err := h.Svc.CancelScheduledPayment(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(p); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+func (h *httpHandler) ListScheduledPaymentRuns(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.scheduledPaymentReq(w, r, "listScheduledPaymentRuns")
+	if !ok {
+		return
+	}
+	runs, err := h.Svc.ListScheduledPaymentRuns(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+	if runs == nil {
+		// an empty list rather than null
+		runs = []ScheduledPaymentRun{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(runs); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+// BalanceEvents streams the balance events of the account as Server-Sent
+// Events. Errors before the stream starts are reported as usual, after that
+// the stream simply ends and the client reconnects with the `Last-Event-ID`.
+// ListTransactions returns the history of the account, latest first. The
+// `before` query parameter pages through it by the ID of the last transaction
+// of the previous page.
+func (h *httpHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("email")
+	if email == "" {
+		h.log(r).Error().Str("method", "listTransactions").Msg("missing/invalid email")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
+		return
+	}
+	pid := chi.URLParam(r, "acctID")
+	acctID, err := snowflake.ParseString(pid)
+	if err != nil {
+		h.log(r).Err(err).Str("method", "listTransactions").Msg("error parsing account ID")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"acctID": "invalid format"}})
+		return
+	}
+
+	query := r.URL.Query()
+	req := TransactionsReq{
+		AcctID: acctID,
+		Email:  email,
+		Client: clientKey(r),
+	}
+	req.Reference = query.Get("reference")
+	if v := query.Get("before"); v != "" {
+		if req.Before, err = strconv.ParseInt(v, 10, 64); err != nil {
+			WriteHTTPError(w, ErrBadRequest{map[string]string{"before": "invalid format"}})
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			WriteHTTPError(w, ErrBadRequest{map[string]string{"limit": "invalid format"}})
+			return
+		}
+	}
+	txns, err := h.Svc.ListTransactions(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
+	}
+	if txns == nil {
+		// an empty list rather than null
+		txns = []Transaction{}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	resp := map[string]string{
-		"path": fmt.Sprintf("%s %s", r.Method, r.URL.Path),
+	if err = json.NewEncoder(w).Encode(txns); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+func (h *httpHandler) BalanceEvents(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("email")
+	if email == "" {
+		h.log(r).Error().Str("method", "balanceEvents").Msg("missing/invalid email")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"email": "missing or invalid"}})
+		return
+	}
+
+	pid := chi.URLParam(r, "acctID")
+	acctID, err := snowflake.ParseString(pid)
+	if err != nil {
+		h.log(r).Err(err).Str("method", "balanceEvents").Msg("error parsing account ID")
+		WriteHTTPError(w, ErrBadRequest{map[string]string{"acctID": "invalid format"}})
+		return
+	}
+	req := BalanceEventsReq{
+		AcctID: acctID,
+		Email:  email,
+		Client: clientKey(r),
+	}
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		if req.LastEventID, err = strconv.ParseInt(last, 10, 64); err != nil {
+			WriteHTTPError(w, ErrBadRequest{map[string]string{"Last-Event-ID": "invalid format"}})
+			return
+		}
+	}
+	events, err := h.Svc.BalanceEvents(r.Context(), req)
+	if err != nil {
+		WriteHTTPError(w, err)
+		return
 	}
-	json.NewEncoder(w).Encode(resp)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// keeps nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry.Milliseconds())
+	if err = rc.Flush(); err != nil {
+		h.log(r).Err(err).Str("method", "balanceEvents").Msg("streaming unsupported")
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				h.log(r).Err(err).Str("method", "balanceEvents").Msg("error marshalling event")
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: balance\ndata: %s\n\n", ev.ID, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		if err = rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// Problem is an RFC 7807 problem details object, the body of every error
+// response with the `application/problem+json` content type
+type Problem struct {
+	// Type identifies the problem type, `urn:bankxgo:problem:` followed by Code
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Code is one of the stable Code constants, for clients to act upon
+	Code string `json:"code"`
+	// Fields are the invalid parameters of a bad request or the conflicting
+	// fields of a conflict
+	Fields map[string]string `json:"fields,omitempty"`
+	// ID is the record not found
+	ID int64 `json:"id,omitempty"`
+	// AcctID is the account frozen or lacking funds
+	AcctID string `json:"acctID,omitempty"`
+}
+
+// problemStatuses are the HTTP statuses by error code
+var problemStatuses = map[string]int{
+	CodeBadRequest:         http.StatusBadRequest,
+	CodeNotFound:           http.StatusNotFound,
+	CodeConflict:           http.StatusConflict,
+	CodeForbidden:          http.StatusForbidden,
+	CodeInsufficientFunds:  http.StatusUnprocessableEntity,
+	CodeAccountFrozen:      http.StatusForbidden,
+	CodeRateLimited:        http.StatusTooManyRequests,
+	CodeServiceUnavailable: http.StatusServiceUnavailable,
+	CodeInternal:           http.StatusInternalServerError,
+}
+
+func newProblem(code, detail string) Problem {
+	status := problemStatuses[code]
+	return Problem{
+		Type:   "urn:bankxgo:problem:" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// NewProblem describes err to the client. Errors other than domain errors and
+// ErrServiceUnavailable are internal and their details are not disclosed.
+func NewProblem(err error) Problem {
+	var (
+		errbr ErrBadRequest
+		errnf ErrNotFound
+		errcf ErrConflict
+		errfb ErrForbidden
+		errif ErrInsufficientFunds
+		erraf ErrAccountFrozen
+		errrl ErrRateLimited
+	)
+	switch {
+	case errors.As(err, &errbr):
+		p := newProblem(CodeBadRequest, "missing or invalid parameters")
+		p.Fields = errbr.Fields
+		return p
+	case errors.As(err, &errnf):
+		p := newProblem(CodeNotFound, errnf.Error())
+		p.ID = errnf.ID
+		return p
+	case errors.As(err, &errcf):
+		p := newProblem(CodeConflict, errcf.Error())
+		p.Fields = map[string]string{errcf.Field: "already exists"}
+		return p
+	case errors.As(err, &errfb):
+		return newProblem(CodeForbidden, errfb.Reason)
+	case errors.As(err, &errif):
+		p := newProblem(CodeInsufficientFunds, "the amount exceeds the available balance")
+		p.AcctID = errif.AcctID.String()
+		return p
+	case errors.As(err, &erraf):
+		p := newProblem(CodeAccountFrozen, "the account is frozen")
+		p.AcctID = erraf.AcctID.String()
+		return p
+	case errors.As(err, &errrl):
+		return newProblem(CodeRateLimited, "too many requests")
+	case errors.Is(err, ErrServiceUnavailable):
+		return newProblem(CodeServiceUnavailable, "service unavailable")
+	default:
+		return newProblem(CodeInternal, "internal server error")
+	}
+}
+
+func WriteHTTPError(w http.ResponseWriter, err error) {
+	p := NewProblem(err)
+	var errrl ErrRateLimited
+	if errors.As(err, &errrl) {
+		secs := int(math.Ceil(errrl.RetryAfter.Seconds()))
+		if secs < 1 {
+			secs = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+	}
+	writeProblem(w, p)
+}
+
+func writeProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Error().
+			Err(err).
+			Msg("error response encoding failed")
+	}
+}
+
+func HTTPNotFound(w http.ResponseWriter, r *http.Request) {
+	p := newProblem(CodeNotFound, "no route for "+r.Method+" "+r.URL.Path)
+	p.Instance = r.URL.Path
+	writeProblem(w, p)
 }
@@ -2,11 +2,17 @@ package bankxgo_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bwmarrin/snowflake"
 	"github.com/rs/zerolog"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
@@ -26,8 +32,8 @@ func TestHTTPDeposit(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		bal := decimal.NewFromInt(1234)
 		svc.EXPECT().
-			Deposit(gomock.AssignableToTypeOf(bankxgo.ChargeReq{})).
-			DoAndReturn(func(r bankxgo.ChargeReq) (*decimal.Decimal, error) {
+			Deposit(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.ChargeReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.ChargeReq) (*decimal.Decimal, error) {
 				return &bal, nil
 			}).
 			Times(1)
@@ -61,10 +67,11 @@ func TestHTTPDeposit(t *testing.T) {
 		hndlr.ServeHTTP(w, req)
 
 		as.Equal(http.StatusNotFound, w.Code)
-		resp := map[string]string{}
+		resp := bankxgo.Problem{}
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
 		reqrd.Nil(err)
-		as.Contains(resp, "path")
+		as.Equal(bankxgo.CodeNotFound, resp.Code)
+		as.Equal(req.URL.Path, resp.Instance)
 	})
 
 	t.Run("/accounts/{acctID}/deposit returns error on missing email header", func(tt *testing.T) {
@@ -80,11 +87,12 @@ func TestHTTPDeposit(t *testing.T) {
 		hndlr.ServeHTTP(w, req)
 
 		as.Equal(http.StatusBadRequest, w.Code)
-		resp := map[string]map[string]string{}
+		as.Equal("application/problem+json", w.Header().Get("Content-Type"))
+		resp := bankxgo.Problem{}
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
 		reqrd.Nil(err)
-		as.Contains(resp, "fields")
-		as.Contains(resp["fields"], "email")
+		as.Equal(bankxgo.CodeBadRequest, resp.Code)
+		as.Contains(resp.Fields, "email")
 	})
 
 	t.Run("/accounts/{acctID}/deposit returns error on malformed request body", func(tt *testing.T) {
@@ -101,11 +109,12 @@ func TestHTTPDeposit(t *testing.T) {
 		hndlr.ServeHTTP(w, req)
 
 		as.Equal(http.StatusBadRequest, w.Code)
-		resp := map[string]map[string]string{}
+		as.Equal("application/problem+json", w.Header().Get("Content-Type"))
+		resp := bankxgo.Problem{}
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
 		reqrd.Nil(err)
-		as.Contains(resp, "fields")
-		as.Contains(resp["fields"], "request body")
+		as.Equal(bankxgo.CodeBadRequest, resp.Code)
+		as.Contains(resp.Fields, "request body")
 	})
 }
 func TestHTTPWithdraw(t *testing.T) {
@@ -114,11 +123,15 @@ func TestHTTPWithdraw(t *testing.T) {
 		as := assert.New(tt)
 		ctrl := gomock.NewController(tt)
 		svc := mocks.NewMockService(ctrl)
-		balance := decimal.NewFromUint64(1234)
+		rcpt := bankxgo.Receipt{
+			Amount:  decimal.NewFromInt(100),
+			Fee:     decimal.NewFromFloat(1.5),
+			Balance: decimal.NewFromUint64(1234),
+		}
 		svc.EXPECT().
-			Withdraw(gomock.AssignableToTypeOf(bankxgo.ChargeReq{})).
-			DoAndReturn(func(r bankxgo.ChargeReq) (*decimal.Decimal, error) {
-				return &balance, nil
+			Withdraw(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.ChargeReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.ChargeReq) (*bankxgo.Receipt, error) {
+				return &rcpt, nil
 			}).
 			Times(1)
 
@@ -133,10 +146,63 @@ func TestHTTPWithdraw(t *testing.T) {
 		resp := map[string]string{}
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
 		as.Nil(err)
-		as.Contains(resp, "balance")
+		as.Equal(resp["amount"], "100")
+		as.Equal(resp["fee"], "1.5")
 		as.Equal(resp["balance"], "1234")
 	})
 
+	t.Run("Withdraw returns 429 with Retry-After when rate limited", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			Withdraw(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.ChargeReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.ChargeReq) (*bankxgo.Receipt, error) {
+				as.Equal("mobile-app", r.Client)
+				return nil, bankxgo.ErrRateLimited{RetryAfter: 1500 * time.Millisecond}
+			}).
+			Times(1)
+
+		// httptest requests come from 192.0.2.1
+		trusted, err := bankxgo.ParseTrustedProxies([]string{"192.0.2.0/24"})
+		require.Nil(tt, err)
+		hndlr := bankxgo.NewClientMiddleware(trusted)(bankxgo.NewHTTPHandler(svc, &nooplog))
+		body := bytes.NewBufferString(`{"amount":1234.00}`)
+		req := httptest.NewRequest(http.MethodPost, "/accounts/1834563581361305763/withdraw", body)
+		req.Header.Set("email", "arhyth@gmail.com")
+		req.Header.Set("X-Client-ID", "mobile-app")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusTooManyRequests, w.Code)
+		as.Equal("2", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("Withdraw ignores X-Client-ID of callers other than trusted proxies", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			Withdraw(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.ChargeReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.ChargeReq) (*bankxgo.Receipt, error) {
+				as.Equal("192.0.2.1", r.Client)
+				return nil, bankxgo.ErrRateLimited{RetryAfter: time.Second}
+			}).
+			Times(1)
+
+		trusted, err := bankxgo.ParseTrustedProxies([]string{"10.0.0.1"})
+		require.Nil(tt, err)
+		hndlr := bankxgo.NewClientMiddleware(trusted)(bankxgo.NewHTTPHandler(svc, &nooplog))
+		body := bytes.NewBufferString(`{"amount":1234.00}`)
+		req := httptest.NewRequest(http.MethodPost, "/accounts/1834563581361305763/withdraw", body)
+		req.Header.Set("email", "arhyth@gmail.com")
+		req.Header.Set("X-Client-ID", "mobile-app")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusTooManyRequests, w.Code)
+	})
+
 	t.Run("/accounts/{acctID}/withdraw returns error on invalid account ID", func(tt *testing.T) {
 		as := assert.New(tt)
 		reqrd := require.New(tt)
@@ -151,10 +217,11 @@ func TestHTTPWithdraw(t *testing.T) {
 		hndlr.ServeHTTP(w, req)
 
 		as.Equal(http.StatusNotFound, w.Code)
-		resp := map[string]string{}
+		resp := bankxgo.Problem{}
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
 		reqrd.Nil(err)
-		as.Contains(resp, "path")
+		as.Equal(bankxgo.CodeNotFound, resp.Code)
+		as.Equal(req.URL.Path, resp.Instance)
 	})
 
 	t.Run("/accounts/{acctID}/withdraw returns error on missing email header", func(tt *testing.T) {
@@ -170,11 +237,12 @@ func TestHTTPWithdraw(t *testing.T) {
 		hndlr.ServeHTTP(w, req)
 
 		as.Equal(http.StatusBadRequest, w.Code)
-		resp := map[string]map[string]string{}
+		as.Equal("application/problem+json", w.Header().Get("Content-Type"))
+		resp := bankxgo.Problem{}
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
 		reqrd.Nil(err)
-		as.Contains(resp, "fields")
-		as.Contains(resp["fields"], "email")
+		as.Equal(bankxgo.CodeBadRequest, resp.Code)
+		as.Contains(resp.Fields, "email")
 	})
 
 	t.Run("/accounts/{acctID}/withdraw returns error on malformed request body", func(tt *testing.T) {
@@ -191,11 +259,12 @@ func TestHTTPWithdraw(t *testing.T) {
 		hndlr.ServeHTTP(w, req)
 
 		as.Equal(http.StatusBadRequest, w.Code)
-		resp := map[string]map[string]string{}
+		as.Equal("application/problem+json", w.Header().Get("Content-Type"))
+		resp := bankxgo.Problem{}
 		err := json.Unmarshal(w.Body.Bytes(), &resp)
 		reqrd.Nil(err)
-		as.Contains(resp, "fields")
-		as.Contains(resp["fields"], "request body")
+		as.Equal(bankxgo.CodeBadRequest, resp.Code)
+		as.Contains(resp.Fields, "request body")
 	})
 }
 
@@ -205,11 +274,11 @@ func TestHTTPBalance(t *testing.T) {
 		as := assert.New(tt)
 		ctrl := gomock.NewController(tt)
 		svc := mocks.NewMockService(ctrl)
-		balance := decimal.NewFromFloat(123.45)
+		balance := decimal.NewFromFloat(-123.45)
 		svc.EXPECT().
-			Balance(gomock.AssignableToTypeOf(bankxgo.BalanceReq{})).
-			DoAndReturn(func(r bankxgo.BalanceReq) (*decimal.Decimal, error) {
-				return &balance, nil
+			Balance(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.BalanceReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.BalanceReq) (*bankxgo.Balances, error) {
+				return bankxgo.NewBalances(&bankxgo.Account{Balance: balance, OverdraftLimit: decimal.NewFromInt(500)}), nil
 			}).
 			Times(1)
 
@@ -225,5 +294,658 @@ func TestHTTPBalance(t *testing.T) {
 		as.Nil(err)
 		as.Contains(resp, "balance")
 		as.Equal(resp["balance"], balance.String())
+		as.Equal("500", resp["overdraftLimit"])
+		as.Equal("376.55", resp["availableCredit"])
+		as.Equal("376.55", resp["available"])
+		as.Equal("0", resp["pending"])
+	})
+}
+
+func TestHTTPStatement(t *testing.T) {
+	nooplog := zerolog.Nop()
+	t.Run("picks the format from the format query parameter over the Accept header", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			Statement(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementReq{})).
+			DoAndReturn(func(_ context.Context, w io.Writer, r bankxgo.StatementReq) error {
+				as.Equal("csv", r.Format)
+				as.Equal(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC), r.From)
+				as.True(r.To.IsZero())
+				return nil
+			}).
+			Times(1)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/accounts/1834563581361305763/statement?format=csv&from=2024-09-01", nil)
+		req.Header.Set("email", "arhyth@gmail.com")
+		req.Header.Set("Accept", "application/pdf")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusOK, w.Code)
+		as.Equal("text/csv", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("picks the format from the Accept header", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			Statement(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementReq{})).
+			Return(nil).
+			Times(1)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/accounts/1834563581361305763/statement", nil)
+		req.Header.Set("email", "arhyth@gmail.com")
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal("application/xml", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("returns error on unsupported format", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/accounts/1834563581361305763/statement?format=xlsx", nil)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHTTPStatementJobs(t *testing.T) {
+	nooplog := zerolog.Nop()
+
+	t.Run("POST /accounts/{acctID}/statements returns Accepted with the job location", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			RequestStatement(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.StatementReq) (*bankxgo.StatementJob, error) {
+				as.Equal("ofx", r.Format)
+				as.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), r.From)
+				as.Equal(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), r.To)
+				return &bankxgo.StatementJob{ID: 42, AcctID: r.AcctID, Status: bankxgo.StatementJobQueued}, nil
+			})
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		body := bytes.NewBufferString(`{"format":"ofx","from":"2024-01-01","to":"2024-01-31"}`)
+		req := httptest.NewRequest(http.MethodPost, "/accounts/1834563581361305763/statements", body)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusAccepted, w.Code)
+		as.Equal("/statements/42", w.Header().Get("Location"))
+	})
+
+	t.Run("POST /accounts/{acctID}/statements returns error on invalid date", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		body := bytes.NewBufferString(`{"to":"31/01/2024"}`)
+		req := httptest.NewRequest(http.MethodPost, "/accounts/1834563581361305763/statements", body)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		assert.Equal(tt, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("GET /statements/{jobID} returns Accepted while the job is pending", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			GetStatementJob(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementJobReq{})).
+			Return(&bankxgo.StatementJob{ID: 42, Status: bankxgo.StatementJobRunning}, nil, nil)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/statements/42", nil)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusAccepted, w.Code)
+		resp := map[string]any{}
+		as.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+		as.Equal("running", resp["status"])
+	})
+
+	t.Run("GET /statements/{jobID} returns the file when the job is done", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		job := &bankxgo.StatementJob{ID: 42, AcctID: 7, Format: "csv", Status: bankxgo.StatementJobDone}
+		svc.EXPECT().
+			GetStatementJob(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementJobReq{})).
+			Return(job, io.NopCloser(bytes.NewBufferString("id,date")), nil)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/statements/42", nil)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusOK, w.Code)
+		as.Contains(w.Header().Get("Content-Type"), "text/csv")
+		as.Contains(w.Header().Get("Content-Disposition"), "statement-7-42.csv")
+		as.Equal("id,date", w.Body.String())
+	})
+}
+
+func TestHTTPVerifyStatement(t *testing.T) {
+	nooplog := zerolog.Nop()
+
+	t.Run("GET /statements/verify/{code} returns the statement record", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			VerifyStatement(gomock.Any(), bankxgo.VerifyStatementReq{Code: "ABCDE-FGHIJ-KLMNO-PQRS2", Client: "192.0.2.1"}).
+			Return(&bankxgo.StatementVerification{Code: "ABCDEFGHIJKLMNOPQRS2", Closing: decimal.NewFromInt(10)}, nil)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/statements/verify/ABCDE-FGHIJ-KLMNO-PQRS2", nil)
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusOK, w.Code)
+		resp := map[string]any{}
+		as.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+		as.Equal(true, resp["valid"])
+		as.Equal("10", resp["closing"])
+	})
+
+	t.Run("GET /statements/verify/{code} returns not found for unknown codes", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().VerifyStatement(gomock.Any(), gomock.Any()).Return(nil, bankxgo.ErrNotFound{})
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/statements/verify/ABCDEFGHIJKLMNOPQRS2", nil)
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		assert.Equal(tt, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHTTPSetStatementPreference(t *testing.T) {
+	nooplog := zerolog.Nop()
+
+	t.Run("PUT /accounts/{acctID}/statement/preferences returns the stored preference", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			SetStatementPreference(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementPreferenceReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.StatementPreferenceReq) (*bankxgo.StatementPreference, error) {
+				as.Equal("europe", r.Template)
+				as.Equal("de_de", r.Locale)
+				as.Equal("arhyth@gmail.com", r.Email)
+				return &bankxgo.StatementPreference{Template: "europe", Locale: "de-DE"}, nil
+			})
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		body := bytes.NewBufferString(`{"template":"europe","locale":"de_de"}`)
+		req := httptest.NewRequest(http.MethodPut, "/accounts/1834563581361305763/statement/preferences", body)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusOK, w.Code)
+		as.JSONEq(`{"template":"europe","locale":"de-DE"}`, w.Body.String())
+	})
+}
+
+func TestHTTPSetStatementPassword(t *testing.T) {
+	nooplog := zerolog.Nop()
+
+	t.Run("PUT /accounts/{acctID}/statement/password sets the password", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			SetStatementPassword(gomock.Any(), bankxgo.StatementPasswordReq{
+				AcctID:   1834563581361305763,
+				Email:    "arhyth@gmail.com",
+				Password: "correct horse",
+				Client:   "192.0.2.1",
+			}).
+			Return(nil)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		body := bytes.NewBufferString(`{"password":"correct horse"}`)
+		req := httptest.NewRequest(http.MethodPut, "/accounts/1834563581361305763/statement/password", body)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusNoContent, w.Code)
+		as.Empty(w.Body.String())
 	})
 }
+
+func TestHTTPStatementPeriods(t *testing.T) {
+	nooplog := zerolog.Nop()
+
+	t.Run("GET /accounts/{acctID}/statements lists the closed periods", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		to := time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)
+		svc.EXPECT().
+			ListStatementPeriods(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementPeriodsReq{})).
+			Return([]bankxgo.StatementPeriod{{AcctID: 1834563581361305763, To: to, Closing: decimal.NewFromInt(800), Format: "pdf"}}, nil)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/accounts/1834563581361305763/statements", nil)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusOK, w.Code)
+		resp := []map[string]any{}
+		as.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+		as.Len(resp, 1)
+		as.Equal("2024-09-30T00:00:00Z", resp[0]["to"])
+		as.Equal("800", resp[0]["closing"])
+		as.NotContains(resp[0], "FileKey")
+	})
+
+	t.Run("GET /accounts/{acctID}/statements/{to} returns the stored document", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		to := time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)
+		svc.EXPECT().
+			GetStatementPeriod(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementPeriodReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.StatementPeriodReq) (*bankxgo.StatementPeriod, io.ReadCloser, error) {
+				as.Equal(to, r.To)
+				period := &bankxgo.StatementPeriod{AcctID: r.AcctID, To: to, Format: "pdf"}
+				return period, io.NopCloser(bytes.NewBufferString("%PDF")), nil
+			})
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/accounts/1834563581361305763/statements/2024-09-30", nil)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusOK, w.Code)
+		as.Equal("application/pdf", w.Header().Get("Content-Type"))
+		as.Contains(w.Header().Get("Content-Disposition"), "statement-1834563581361305763-2024-09-30.pdf")
+		as.Equal("%PDF", w.Body.String())
+	})
+
+	t.Run("GET /accounts/{acctID}/statements/{to} returns error on invalid date", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/accounts/1834563581361305763/statements/2024-13-45", nil)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		assert.Equal(tt, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHTTPBalanceEvents(t *testing.T) {
+	nooplog := zerolog.Nop()
+	t.Run("streams the events with heartbeats in between", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		events := make(chan bankxgo.BalanceEvent, 1)
+		svc.EXPECT().
+			BalanceEvents(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.BalanceEventsReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.BalanceEventsReq) (<-chan bankxgo.BalanceEvent, error) {
+				as.Equal(int64(41), r.LastEventID)
+				as.Equal("arhyth@gmail.com", r.Email)
+				return events, nil
+			})
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog, bankxgo.WithEventHeartbeat(10*time.Millisecond))
+		srv := httptest.NewServer(hndlr)
+		defer srv.Close()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/accounts/1834563581361305763/events", nil)
+		require.Nil(tt, err)
+		req.Header.Set("email", "arhyth@gmail.com")
+		req.Header.Set("Last-Event-ID", "41")
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(tt, err)
+		defer resp.Body.Close()
+		as.Equal(http.StatusOK, resp.StatusCode)
+		as.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+		time.Sleep(30 * time.Millisecond)
+		events <- bankxgo.BalanceEvent{ID: 42, Type: "deposit", Balance: decimal.New(100, 0)}
+		close(events)
+		body, err := io.ReadAll(resp.Body)
+		require.Nil(tt, err)
+		as.Contains(string(body), "retry: 3000\n\n")
+		as.Contains(string(body), ": heartbeat\n\n")
+		as.Contains(string(body), "id: 42\nevent: balance\ndata: {\"id\":42,")
+		as.Contains(string(body), `"balance":"100"`)
+	})
+
+	t.Run("rejects an invalid Last-Event-ID", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/accounts/1834563581361305763/events", nil)
+		req.Header.Set("email", "arhyth@gmail.com")
+		req.Header.Set("Last-Event-ID", "abc")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		assert.Equal(tt, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHTTPListTransactions(t *testing.T) {
+	nooplog := zerolog.Nop()
+	t.Run("passes the filter and returns the memos", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			ListTransactions(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.TransactionsReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.TransactionsReq) ([]bankxgo.Transaction, error) {
+				as.Equal(bankxgo.TransactionFilter{Reference: "INV-1234", Before: 99, Limit: 10}, r.TransactionFilter)
+				as.Equal("arhyth@gmail.com", r.Email)
+				return []bankxgo.Transaction{{
+					ID:     42,
+					Type:   "deposit",
+					Amount: decimal.NewFromInt(100),
+					Memo:   bankxgo.Memo{Description: "Refund", Reference: "INV-1234"},
+				}}, nil
+			})
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/accounts/1834563581361305763/transactions?reference=INV-1234&before=99&limit=10", nil)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusOK, w.Code)
+		as.Contains(w.Body.String(), `"description":"Refund","reference":"INV-1234"`)
+	})
+
+	t.Run("returns error on an invalid limit", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/accounts/1834563581361305763/transactions?limit=all", nil)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		assert.Equal(tt, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHTTPSettle(t *testing.T) {
+	nooplog := zerolog.Nop()
+	key := "0123456789abcdef-settlement"
+	body := `{"acctID":"1834563581361305763","reference":"WIRE-0001","status":"posted"}`
+
+	t.Run("settles the deposit of a signed callback", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			Settle(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.SettleReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.SettleReq) (*bankxgo.Transaction, error) {
+				as.Equal(snowflake.ParseInt64(1834563581361305763), r.AcctID)
+				as.Equal("WIRE-0001", r.Reference)
+				as.Equal(bankxgo.TxnPosted, r.Status)
+				return &bankxgo.Transaction{ID: 42, Type: "deposit", Status: bankxgo.TxnPosted, Amount: decimal.NewFromInt(500)}, nil
+			})
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog, bankxgo.WithSettlementKey(key))
+		req := httptest.NewRequest(http.MethodPost, "/settlements", bytes.NewBufferString(body))
+		req.Header.Set(bankxgo.SettlementSignatureHeader, bankxgo.SignSettlement([]byte(key), time.Now(), []byte(body)))
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusOK, w.Code)
+		as.Contains(w.Body.String(), `"status":"posted"`)
+	})
+
+	t.Run("returns forbidden on a bad signature or without a key", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog, bankxgo.WithSettlementKey(key))
+		req := httptest.NewRequest(http.MethodPost, "/settlements", bytes.NewBufferString(body))
+		req.Header.Set(bankxgo.SettlementSignatureHeader, bankxgo.SignSettlement([]byte("another-key-entirely"), time.Now(), []byte(body)))
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+		as.Equal(http.StatusForbidden, w.Code)
+
+		hndlr = bankxgo.NewHTTPHandler(svc, &nooplog)
+		req = httptest.NewRequest(http.MethodPost, "/settlements", bytes.NewBufferString(body))
+		req.Header.Set(bankxgo.SettlementSignatureHeader, bankxgo.SignSettlement(nil, time.Now(), []byte(body)))
+		w = httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+		as.Equal(http.StatusForbidden, w.Code)
+	})
+
+	t.Run("returns forbidden on replayed or tampered callbacks", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog, bankxgo.WithSettlementKey(key))
+
+		// signed too long ago, or the time of signing was changed
+		signed := time.Now().Add(-bankxgo.SettlementTolerance - time.Minute)
+		stale := bankxgo.SignSettlement([]byte(key), signed, []byte(body))
+		_, mac, _ := strings.Cut(stale, ",")
+		for _, sig := range []string{stale, fmt.Sprintf("t=%d,%s", time.Now().Unix(), mac), mac} {
+			req := httptest.NewRequest(http.MethodPost, "/settlements", bytes.NewBufferString(body))
+			req.Header.Set(bankxgo.SettlementSignatureHeader, sig)
+			w := httptest.NewRecorder()
+			hndlr.ServeHTTP(w, req)
+			as.Equal(http.StatusForbidden, w.Code, sig)
+		}
+	})
+}
+
+func TestHTTPBatches(t *testing.T) {
+	nooplog := zerolog.Nop()
+	body := `{"mode":"best_effort","items":[{"type":"deposit","acctID":"1834563581361305763","email":"arhyth@gmail.com","amount":"100"}]}`
+
+	t.Run("PostBatch creates the batch or replays the earlier one", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			PostBatch(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.BatchReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.BatchReq) (*bankxgo.Batch, error) {
+				as.Equal("payroll-2024-09", r.Key)
+				as.Equal(bankxgo.BatchBestEffort, r.Mode)
+				as.Len(r.Items, 1)
+				as.Equal("1834563581361305763", r.Items[0].AcctID.String())
+				return &bankxgo.Batch{ID: 42, Status: bankxgo.BatchPosted}, nil
+			})
+		svc.EXPECT().
+			PostBatch(gomock.Any(), gomock.Any()).
+			Return(&bankxgo.Batch{ID: 42, Status: bankxgo.BatchPosted, Replayed: true}, nil)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		for _, want := range []int{http.StatusCreated, http.StatusOK} {
+			req := httptest.NewRequest(http.MethodPost, "/batches", bytes.NewBufferString(body))
+			req.Header.Set(bankxgo.IdempotencyKeyHeader, "payroll-2024-09")
+			w := httptest.NewRecorder()
+			hndlr.ServeHTTP(w, req)
+
+			as.Equal(want, w.Code)
+			as.Equal("/batches/42", w.Header().Get("Location"))
+			as.Equal(want == http.StatusOK, w.Header().Get(bankxgo.IdempotentReplayedHeader) == "true")
+			resp := map[string]any{}
+			as.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+			as.Equal("posted", resp["status"])
+		}
+	})
+
+	t.Run("GetBatch passes the idempotency key", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			GetBatch(gomock.Any(), bankxgo.BatchReqByID{BatchID: 42, Key: "payroll-2024-09", Client: "192.0.2.1"}).
+			Return(&bankxgo.Batch{ID: 42, Status: bankxgo.BatchPartial}, nil)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/batches/42", nil)
+		req.Header.Set(bankxgo.IdempotencyKeyHeader, "payroll-2024-09")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusOK, w.Code)
+		as.Contains(w.Body.String(), `"status":"partial"`)
+	})
+}
+
+func TestHTTPScheduledPayments(t *testing.T) {
+	nooplog := zerolog.Nop()
+	acctID := snowflake.ParseInt64(1834563581361305763)
+	paymentID := snowflake.ParseInt64(1834563581361305765)
+
+	t.Run("CreateScheduledPayment passes the spec and locates the payment", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			CreateScheduledPayment(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.ScheduledPaymentReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.ScheduledPaymentReq) (*bankxgo.ScheduledPayment, error) {
+				as.Equal(acctID, r.AcctID)
+				as.Equal("arhyth@gmail.com", r.Email)
+				as.Equal("1834563581361305764", r.ToAcctID.String())
+				as.Equal("250", r.Amount.String())
+				as.Equal(bankxgo.ScheduleRule{Every: "week", Interval: 2}, r.Rule)
+				as.Equal(time.Date(2024, 10, 1, 9, 0, 0, 0, time.UTC), r.StartAt)
+				return &bankxgo.ScheduledPayment{ID: paymentID, AcctID: acctID, Status: bankxgo.ScheduledPaymentActive}, nil
+			})
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		body := `{"toAcctID":"1834563581361305764","amount":"250","rule":{"every":"week","interval":2},"startAt":"2024-10-01T09:00:00Z"}`
+		req := httptest.NewRequest(http.MethodPost, "/accounts/1834563581361305763/scheduled-payments", bytes.NewBufferString(body))
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusCreated, w.Code)
+		as.Equal("/accounts/1834563581361305763/scheduled-payments/1834563581361305765", w.Header().Get("Location"))
+		as.Contains(w.Body.String(), `"status":"active"`)
+	})
+
+	t.Run("ListScheduledPaymentRuns responds with an empty list", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			ListScheduledPaymentRuns(gomock.Any(), bankxgo.ScheduledPaymentReqByID{
+				AcctID:    acctID,
+				PaymentID: paymentID,
+				Email:     "arhyth@gmail.com",
+				Client:    "192.0.2.1",
+			}).
+			Return(nil, nil)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodGet, "/accounts/1834563581361305763/scheduled-payments/1834563581361305765/runs", nil)
+		req.Header.Set("email", "arhyth@gmail.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusOK, w.Code)
+		as.Equal("[]\n", w.Body.String())
+	})
+
+	t.Run("returns bad request without an email", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+
+		hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+		req := httptest.NewRequest(http.MethodDelete, "/accounts/1834563581361305763/scheduled-payments/1834563581361305765", nil)
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		assert.Equal(tt, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHTTPProblems(t *testing.T) {
+	nooplog := zerolog.Nop()
+	cases := []struct {
+		err    error
+		status int
+		want   bankxgo.Problem
+	}{
+		{
+			bankxgo.ErrInsufficientFunds{AcctID: 1834563581361305763},
+			http.StatusUnprocessableEntity,
+			bankxgo.Problem{Code: bankxgo.CodeInsufficientFunds, AcctID: "1834563581361305763"},
+		},
+		{
+			bankxgo.ErrAccountFrozen{AcctID: 1834563581361305763},
+			http.StatusForbidden,
+			bankxgo.Problem{Code: bankxgo.CodeAccountFrozen, AcctID: "1834563581361305763"},
+		},
+		{
+			bankxgo.ErrForbidden{Reason: "email does not match the account"},
+			http.StatusForbidden,
+			bankxgo.Problem{Code: bankxgo.CodeForbidden, Detail: "email does not match the account"},
+		},
+		{
+			bankxgo.ErrConflict{Field: "email"},
+			http.StatusConflict,
+			bankxgo.Problem{Code: bankxgo.CodeConflict, Fields: map[string]string{"email": "already exists"}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.want.Code, func(tt *testing.T) {
+			as := assert.New(tt)
+			reqrd := require.New(tt)
+			ctrl := gomock.NewController(tt)
+			svc := mocks.NewMockService(ctrl)
+			svc.EXPECT().Withdraw(gomock.Any(), gomock.Any()).Return(nil, c.err)
+			hndlr := bankxgo.NewHTTPHandler(svc, &nooplog)
+
+			body := bytes.NewBufferString(`{"amount":"100"}`)
+			req := httptest.NewRequest(http.MethodPost, "/accounts/1834563581361305763/withdraw", body)
+			req.Header.Set("email", "arhyth@gmail.com")
+			w := httptest.NewRecorder()
+			hndlr.ServeHTTP(w, req)
+
+			as.Equal(c.status, w.Code)
+			as.Equal("application/problem+json", w.Header().Get("Content-Type"))
+			resp := bankxgo.Problem{}
+			reqrd.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+			as.Equal("urn:bankxgo:problem:"+c.want.Code, resp.Type)
+			as.Equal(http.StatusText(c.status), resp.Title)
+			as.Equal(c.status, resp.Status)
+			as.Equal(c.want.Code, resp.Code)
+			as.Equal(c.want.AcctID, resp.AcctID)
+			as.Equal(c.want.Fields, resp.Fields)
+			if c.want.Detail != "" {
+				as.Equal(c.want.Detail, resp.Detail)
+			}
+		})
+	}
+}
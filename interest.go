@@ -0,0 +1,219 @@
+package bankxgo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	DayCountACT365 = "ACT/365"
+	DayCountACT360 = "ACT/360"
+	DayCountACTACT = "ACT/ACT"
+	DayCount30360  = "30/360"
+)
+
+// InterestPolicy is the interest expense account, rate and day count convention of a currency
+type InterestPolicy struct {
+	Account    snowflake.ID
+	AnnualRate decimal.Decimal
+	DayCount   string
+}
+
+// InterestAccrual is the interest earned by an account for a single day
+type InterestAccrual struct {
+	AcctID  snowflake.ID
+	Date    time.Time
+	Balance decimal.Decimal
+	Rate    decimal.Decimal
+	Amount  decimal.Decimal
+}
+
+// AccountBalance is the balance of an account at some point in time
+type AccountBalance struct {
+	AcctID snowflake.ID
+	// Type is the account type, one of the Account* constants
+	Type    string
+	Balance decimal.Decimal
+}
+
+// InterestStore is the persistence needed by the interest job
+type InterestStore interface {
+	GetAccount(ctx context.Context, id snowflake.ID) (*Account, error)
+	// EndOfDayBalances returns the balance at the end of day of every account
+	// in currency, except the excluded (system) accounts
+	EndOfDayBalances(ctx context.Context, currency string, day time.Time, exclude []snowflake.ID) ([]AccountBalance, error)
+	// InsertAccruals stores the accruals, skipping any that already exist for
+	// the same account and day, and returns the number inserted
+	InsertAccruals(ctx context.Context, accruals []InterestAccrual) (int64, error)
+	// UnpostedAccrualAccounts returns the accounts in currency with unposted accruals in [from, to)
+	UnpostedAccrualAccounts(ctx context.Context, currency string, from, to time.Time) ([]snowflake.ID, error)
+	// PostInterest books the sum of the unposted accruals of the account in [from, to)
+	// as a charge from the interest expense account and marks them posted
+	PostInterest(ctx context.Context, acctID, expenseAcct snowflake.ID, from, to time.Time) (*decimal.Decimal, error)
+}
+
+// InterestJob accrues daily interest on end of day balances and posts the
+// accruals monthly. Both runs are idempotent so a failed run can simply be rerun.
+type InterestJob struct {
+	store    InterestStore
+	policies map[string]InterestPolicy
+	exclude  []snowflake.ID
+	log      *zerolog.Logger
+}
+
+// NewInterestJob validates the policies' day count conventions and interest expense
+// accounts. Accounts in exclude, ie. system accounts, never earn interest.
+func NewInterestJob(
+	store InterestStore,
+	policies map[string]InterestPolicy,
+	exclude []snowflake.ID,
+	log *zerolog.Logger,
+) (*InterestJob, error) {
+	exclude = append([]snowflake.ID(nil), exclude...)
+	for c, p := range policies {
+		if _, err := dayFraction(p.DayCount, time.Now()); err != nil {
+			return nil, fmt.Errorf("interest.%s.day_count: %w", c, err)
+		}
+		a, err := store.GetAccount(context.Background(), p.Account)
+		if err != nil {
+			return nil, err
+		}
+		if a.Currency != c {
+			return nil, ErrNotFound{ID: p.Account.Int64()}
+		}
+		exclude = append(exclude, p.Account)
+	}
+	job := &InterestJob{
+		store:    store,
+		policies: policies,
+		exclude:  exclude,
+		log:      log,
+	}
+	return job, nil
+}
+
+// Accrue computes and stores the interest of every account for the given (UTC) day.
+// Only positive balances of deposit accounts earn interest.
+func (j *InterestJob) Accrue(day time.Time) error {
+	day = truncateDay(day)
+	if !day.Before(truncateDay(time.Now())) {
+		return ErrBadRequest{Fields: map[string]string{"date": "day has not ended yet"}}
+	}
+
+	for c, p := range j.policies {
+		frac, err := dayFraction(p.DayCount, day)
+		if err != nil {
+			return err
+		}
+		bals, err := j.store.EndOfDayBalances(context.Background(), c, day, j.exclude)
+		if err != nil {
+			return fmt.Errorf("EndOfDayBalances(%s): %w", c, err)
+		}
+		accruals := make([]InterestAccrual, 0, len(bals))
+		for _, b := range bals {
+			if !b.Balance.IsPositive() || b.Type == AccountCredit {
+				continue
+			}
+			accruals = append(accruals, InterestAccrual{
+				AcctID:  b.AcctID,
+				Date:    day,
+				Balance: b.Balance,
+				Rate:    p.AnnualRate,
+				Amount:  b.Balance.Mul(p.AnnualRate).Div(hundred).Mul(frac).Round(8),
+			})
+		}
+		n, err := j.store.InsertAccruals(context.Background(), accruals)
+		if err != nil {
+			return fmt.Errorf("InsertAccruals(%s): %w", c, err)
+		}
+		j.log.Info().
+			Str("currency", c).
+			Str("date", day.Format(time.DateOnly)).
+			Int64("inserted", n).
+			Int("accounts", len(accruals)).
+			Msg("interest accrued")
+	}
+
+	return nil
+}
+
+// Post books the accruals of the month of the given day. Each account is posted
+// in its own database transaction, so a failure only affects the remaining accounts.
+func (j *InterestJob) Post(month time.Time) error {
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	if to.After(truncateDay(time.Now())) {
+		return ErrBadRequest{Fields: map[string]string{"month": "month has not ended yet"}}
+	}
+
+	for c, p := range j.policies {
+		accts, err := j.store.UnpostedAccrualAccounts(context.Background(), c, from, to)
+		if err != nil {
+			return fmt.Errorf("UnpostedAccrualAccounts(%s): %w", c, err)
+		}
+		var failed int
+		for _, acctID := range accts {
+			amt, err := j.store.PostInterest(context.Background(), acctID, p.Account, from, to)
+			if err != nil {
+				failed++
+				j.log.Err(err).
+					Str("acctID", acctID.String()).
+					Msg("interest posting failed")
+				continue
+			}
+			j.log.Debug().
+				Str("acctID", acctID.String()).
+				Str("amount", amt.String()).
+				Msg("interest posted")
+		}
+		if failed > 0 {
+			return fmt.Errorf("interest posting failed for %d %s account(s)", failed, c)
+		}
+		j.log.Info().
+			Str("currency", c).
+			Str("month", from.Format("2006-01")).
+			Int("accounts", len(accts)).
+			Msg("interest posted")
+	}
+
+	return nil
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// dayFraction returns the fraction of a year that the given day accounts for
+func dayFraction(convention string, day time.Time) (decimal.Decimal, error) {
+	switch convention {
+	case "", DayCountACT365:
+		return decimal.NewFromInt(1).Div(decimal.NewFromInt(365)), nil
+	case DayCountACT360:
+		return decimal.NewFromInt(1).Div(decimal.NewFromInt(360)), nil
+	case DayCountACTACT:
+		y := day.Year()
+		days := time.Date(y+1, 1, 1, 0, 0, 0, 0, time.UTC).Sub(time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)).Hours() / 24
+		return decimal.NewFromInt(1).Div(decimal.NewFromFloat(days)), nil
+	case DayCount30360:
+		// 30/360 US: the days between `day` and the next with the 31st counted as
+		// the 30th, so every month accrues 30 days, February on its last day included
+		next := day.AddDate(0, 0, 1)
+		d1, d2 := day.Day(), next.Day()
+		if d1 == 31 {
+			d1 = 30
+		}
+		if d2 == 31 && d1 == 30 {
+			d2 = 30
+		}
+		days := 360*(next.Year()-day.Year()) + 30*(int(next.Month())-int(day.Month())) + d2 - d1
+		return decimal.NewFromInt(int64(days)).Div(decimal.NewFromInt(360)), nil
+	default:
+		return decimal.Zero, fmt.Errorf("unknown day count convention %q", convention)
+	}
+}
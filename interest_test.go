@@ -0,0 +1,133 @@
+package bankxgo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+func TestInterestJobAccrue(t *testing.T) {
+	sysAcct := snowflake.ParseInt64(7241301734201495552)
+	expenseAcct := snowflake.ParseInt64(7241301734201495553)
+	log := zerolog.Nop()
+
+	newJob := func(tt *testing.T, dayCount string) (*bankxgo.InterestJob, *mocks.MockInterestStore) {
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockInterestStore(ctrl)
+		store.EXPECT().
+			GetAccount(gomock.Any(), expenseAcct).
+			Return(&bankxgo.Account{AcctID: expenseAcct, Currency: "USD"}, nil)
+		policies := map[string]bankxgo.InterestPolicy{
+			"USD": {Account: expenseAcct, AnnualRate: decimal.New(365, -2), DayCount: dayCount},
+		}
+		job, err := bankxgo.NewInterestJob(store, policies, []snowflake.ID{sysAcct}, &log)
+		require.Nil(tt, err)
+		return job, store
+	}
+
+	t.Run("accrues interest on positive end of day balances of deposit accounts only", func(tt *testing.T) {
+		as := assert.New(tt)
+		job, store := newJob(tt, bankxgo.DayCountACT365)
+		day := time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)
+		store.EXPECT().
+			EndOfDayBalances(gomock.Any(), "USD", day, []snowflake.ID{sysAcct, expenseAcct}).
+			Return([]bankxgo.AccountBalance{
+				{AcctID: 1, Type: bankxgo.AccountDeposit, Balance: decimal.New(1000, 0)},
+				{AcctID: 2, Type: bankxgo.AccountDeposit, Balance: decimal.Zero},
+				{AcctID: 3, Type: bankxgo.AccountDeposit, Balance: decimal.New(-10, 0)},
+				{AcctID: 4, Type: bankxgo.AccountCredit, Balance: decimal.New(50, 0)},
+			}, nil)
+		store.EXPECT().
+			InsertAccruals(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, accruals []bankxgo.InterestAccrual) (int64, error) {
+				as.Len(accruals, 1)
+				as.Equal(snowflake.ID(1), accruals[0].AcctID)
+				as.Equal(day, accruals[0].Date)
+				// 1000 * 3.65% / 365
+				as.True(decimal.New(1, -1).Equal(accruals[0].Amount), accruals[0].Amount.String())
+				return 1, nil
+			})
+		as.Nil(job.Accrue(day.Add(13 * time.Hour)))
+	})
+
+	t.Run("30/360 accrues 30 days per month", func(tt *testing.T) {
+		as := assert.New(tt)
+		job, store := newJob(tt, bankxgo.DayCount30360)
+		var total decimal.Decimal
+		store.EXPECT().
+			EndOfDayBalances(gomock.Any(), "USD", gomock.Any(), gomock.Any()).
+			Return([]bankxgo.AccountBalance{{AcctID: 1, Balance: decimal.New(3600, 0)}}, nil).
+			AnyTimes()
+		store.EXPECT().
+			InsertAccruals(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, accruals []bankxgo.InterestAccrual) (int64, error) {
+				for _, a := range accruals {
+					total = total.Add(a.Amount)
+				}
+				return int64(len(accruals)), nil
+			}).
+			AnyTimes()
+		for d := 1; d <= 28; d++ {
+			as.Nil(job.Accrue(time.Date(2023, 2, d, 0, 0, 0, 0, time.UTC)))
+		}
+		// 3600 * 3.65% * 30/360
+		as.True(decimal.New(1095, -2).Equal(total.Round(2)), total.String())
+	})
+
+	t.Run("returns an error for a day that has not ended", func(tt *testing.T) {
+		as := assert.New(tt)
+		job, _ := newJob(tt, "")
+		err := job.Accrue(time.Now())
+		as.ErrorAs(err, &bankxgo.ErrBadRequest{})
+	})
+
+	t.Run("returns an error on unknown day count convention", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockInterestStore(ctrl)
+		policies := map[string]bankxgo.InterestPolicy{
+			"USD": {Account: expenseAcct, AnnualRate: decimal.New(1, 0), DayCount: "ACT/364"},
+		}
+		_, err := bankxgo.NewInterestJob(store, policies, nil, &log)
+		as.ErrorContains(err, "interest.USD.day_count")
+	})
+}
+
+func TestInterestJobPost(t *testing.T) {
+	expenseAcct := snowflake.ParseInt64(7241301734201495553)
+	log := zerolog.Nop()
+
+	t.Run("posts the month's accruals of every account", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockInterestStore(ctrl)
+		store.EXPECT().
+			GetAccount(gomock.Any(), expenseAcct).
+			Return(&bankxgo.Account{AcctID: expenseAcct, Currency: "USD"}, nil)
+		policies := map[string]bankxgo.InterestPolicy{
+			"USD": {Account: expenseAcct, AnnualRate: decimal.New(1, 0)},
+		}
+		job, err := bankxgo.NewInterestJob(store, policies, nil, &log)
+		as.Nil(err)
+
+		from := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+		store.EXPECT().
+			UnpostedAccrualAccounts(gomock.Any(), "USD", from, to).
+			Return([]snowflake.ID{1, 2}, nil)
+		amt := decimal.New(123, -2)
+		store.EXPECT().PostInterest(gomock.Any(), snowflake.ID(1), expenseAcct, from, to).Return(&amt, nil)
+		store.EXPECT().PostInterest(gomock.Any(), snowflake.ID(2), expenseAcct, from, to).Return(&amt, nil)
+		as.Nil(job.Post(time.Date(2024, 9, 17, 0, 0, 0, 0, time.UTC)))
+	})
+}
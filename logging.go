@@ -0,0 +1,116 @@
+package bankxgo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+)
+
+// RequestIDHeader carries the request ID, which is taken from the request if
+// the caller set one and echoed in the response either way
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds caller supplied request IDs, longer ones are replaced
+const maxRequestIDLen = 128
+
+// NewRequestLogMiddleware assigns every request an ID and a logger carrying it,
+// which the service and the repository log with through the request context.
+// Once the request is served, it writes one access log line with the route,
+// status, latency and account ID. Emails are only ever logged redacted.
+//
+// It should wrap every other HTTP middleware so that requests they reject are
+// logged too.
+func NewRequestLogMiddleware(log *zerolog.Logger) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := requestID(r)
+			w.Header().Set(RequestIDHeader, id)
+
+			reqLog := log.With().Str("requestID", id).Logger()
+			// the router fills in the route context handed to it, so the
+			// route and its parameters can be logged after the fact
+			rctx := chi.NewRouteContext()
+			ctx := context.WithValue(reqLog.WithContext(r.Context()), chi.RouteCtxKey, rctx)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			h.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := rctx.RoutePattern()
+			if route == "" {
+				route = "-"
+			}
+			evt := reqLog.Info()
+			if status >= http.StatusInternalServerError {
+				evt = reqLog.Error()
+			}
+			evt.Str("method", r.Method).
+				Str("route", route).
+				Int("status", status).
+				Int("bytes", ww.BytesWritten()).
+				Dur("latency", time.Since(start)).
+				Str("client", clientKey(r))
+			if acctID := rctx.URLParam("acctID"); acctID != "" {
+				evt.Str("acctID", acctID)
+			}
+			if email := r.Header.Get("email"); email != "" {
+				evt.Str("email", RedactEmail(email))
+			}
+			evt.Msg("http request")
+		})
+	}
+}
+
+// requestID returns the caller's request ID or, if it has none or an unusable
+// one, a new random ID
+func requestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if id != "" && len(id) <= maxRequestIDLen && isPrintableASCII(id) {
+		return id
+	}
+	b := make([]byte, 16)
+	// crypto/rand does not fail on supported platforms
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x21 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// ctxLog returns the request-scoped logger of ctx, or fallback outside of a
+// request
+func ctxLog(ctx context.Context, fallback *zerolog.Logger) *zerolog.Logger {
+	if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+	return fallback
+}
+
+// RedactEmail keeps the first character of the local part and the domain,
+// enough to tell customers apart in logs, ie. `a***@gmail.com`
+func RedactEmail(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	if at <= 0 {
+		return "***"
+	}
+	_, n := utf8.DecodeRuneInString(email)
+	return email[:n] + "***" + email[at:]
+}
@@ -0,0 +1,130 @@
+package bankxgo_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+// logLines decodes the JSON log lines written to buf
+func logLines(tt *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if l == "" {
+			continue
+		}
+		line := map[string]any{}
+		require.Nil(tt, json.Unmarshal([]byte(l), &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestRequestLogMiddleware(t *testing.T) {
+	t.Run("propagates the request ID and logger to the service", func(tt *testing.T) {
+		as := assert.New(tt)
+		buf := new(bytes.Buffer)
+		logger := zerolog.New(buf)
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		bal := decimal.NewFromInt(300)
+		svc.EXPECT().
+			Deposit(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, _ bankxgo.ChargeReq) (*decimal.Decimal, error) {
+				zerolog.Ctx(ctx).Info().Msg("depositing")
+				return &bal, nil
+			})
+		hndlr := bankxgo.NewRequestLogMiddleware(&logger)(bankxgo.NewHTTPHandler(svc, &logger))
+
+		req := httptest.NewRequest(http.MethodPost, "/accounts/1834563581361305763/deposit", bytes.NewBufferString(`{"amount":"200"}`))
+		req.Header.Set("email", "arhyth@gmail.com")
+		req.Header.Set(bankxgo.RequestIDHeader, "req-123")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusOK, w.Code)
+		as.Equal("req-123", w.Header().Get(bankxgo.RequestIDHeader))
+		lines := logLines(tt, buf)
+		require.Len(tt, lines, 2)
+		as.Equal("depositing", lines[0]["message"])
+		as.Equal("req-123", lines[0]["requestID"])
+
+		access := lines[1]
+		as.Equal("http request", access["message"])
+		as.Equal("req-123", access["requestID"])
+		as.Equal(http.MethodPost, access["method"])
+		as.Equal("/accounts/{acctID:[0-9]+}/deposit", access["route"])
+		as.Equal(float64(http.StatusOK), access["status"])
+		as.Equal("1834563581361305763", access["acctID"])
+		as.Equal("a***@gmail.com", access["email"])
+		as.Contains(access, "latency")
+		as.NotContains(buf.String(), "arhyth@gmail.com")
+	})
+
+	t.Run("assigns a request ID if there is none or it is unusable", func(tt *testing.T) {
+		as := assert.New(tt)
+		logger := zerolog.Nop()
+		hndlr := bankxgo.NewRequestLogMiddleware(&logger)(http.NotFoundHandler())
+
+		ids := map[string]bool{}
+		for _, given := range []string{"", "has spaces", strings.Repeat("x", 129)} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(bankxgo.RequestIDHeader, given)
+			w := httptest.NewRecorder()
+			hndlr.ServeHTTP(w, req)
+
+			id := w.Header().Get(bankxgo.RequestIDHeader)
+			as.Len(id, 32)
+			as.NotEqual(given, id)
+			ids[id] = true
+		}
+		as.Len(ids, 3)
+	})
+
+	t.Run("logs unrouted requests and server errors", func(tt *testing.T) {
+		as := assert.New(tt)
+		buf := new(bytes.Buffer)
+		logger := zerolog.New(buf)
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		svc.EXPECT().Balance(gomock.Any(), gomock.Any()).Return(nil, bankxgo.ErrInternalServer)
+		hndlr := bankxgo.NewRequestLogMiddleware(&logger)(bankxgo.NewHTTPHandler(svc, &logger))
+
+		for _, path := range []string{"/nowhere", "/accounts/1834563581361305763/balance"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("email", "arhyth@gmail.com")
+			hndlr.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		lines := logLines(tt, buf)
+		require.Len(tt, lines, 2)
+		as.Equal("-", lines[0]["route"])
+		as.Equal(float64(http.StatusNotFound), lines[0]["status"])
+		as.Equal("info", lines[0]["level"])
+		as.Equal(float64(http.StatusInternalServerError), lines[1]["status"])
+		as.Equal("error", lines[1]["level"])
+	})
+}
+
+func TestRedactEmail(t *testing.T) {
+	cases := map[string]string{
+		"arhyth@gmail.com":  "a***@gmail.com",
+		"é@example.com":     "é***@example.com",
+		"not-an-email":      "***",
+		"@no-local-part.io": "***",
+	}
+	for email, want := range cases {
+		assert.Equal(t, want, bankxgo.RedactEmail(email), email)
+	}
+}
@@ -2,11 +2,18 @@ package bankxgo
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
 	"regexp"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/bwmarrin/snowflake"
+	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/shopspring/decimal"
 	"golang.org/x/time/rate"
 )
@@ -20,123 +27,563 @@ var _ Service = (*validationMiddleware)(nil)
 type Middleware func(Service) Service
 
 // validationMiddleware validates the following invariants:
-// 1. The account exists in the repository [Withdraw, Deposit, Balance, Statement]
-// 2. The account is not a system acount [Withdraw, Deposit]
-// 3. The account ID and email belong to the same account [Withdraw, Deposit, Balance, Statement]
+// 1. The account exists in the repository [Withdraw, Deposit, Balance, Statement, RequestStatement, SetStatementPreference, SetStatementPassword, ListStatementPeriods, GetStatementPeriod, BalanceEvents, ListTransactions, Settle]
+// 2. The account is not a system or internal (fee revenue, interest expense) acount [Withdraw, Deposit, Settle]
+// 3. The account ID and email belong to the same account [Withdraw, Deposit, Balance, Statement, RequestStatement, SetStatementPreference, SetStatementPassword, ListStatementPeriods, GetStatementPeriod, BalanceEvents, ListTransactions]
 // 4. The currency is supported, ie. there exist a system account for it [CreateAccount]
-// 5. The email is of valid format [CreateAccount]
-// 6. The amount is not negative [Deposit, Withdraw]
-// 7. The account has sufficient balance for withdrawal [Withdraw]
+// 5. The email is of valid format and the account type is supported [CreateAccount]
+// 6. The amount is not negative and the memo is within its size limits [Deposit, Withdraw]
+// 7. The account has sufficient balance, including its overdraft limit, for withdrawal [Withdraw]
+// 8. The statement format is supported and the period is valid [Statement, RequestStatement]
+// 9. The statement job belongs to the account of the email [GetStatementJob]
+// 10. The verification code is of valid format [VerifyStatement]
+// 11. The account is not frozen [Withdraw, Deposit]
+// 12. The batch is well formed and every item satisfies 1, 2, 3, 6 and 11 [PostBatch]
+// 13. The scheduled payment is well formed, both accounts satisfy 1, 2 and 11, share the currency
+// and the source satisfies 3 [CreateScheduledPayment, UpdateScheduledPayment]
+// 14. The scheduled payment belongs to the account, which satisfies 1 and 3 [ListScheduledPayments,
+// GetScheduledPayment, UpdateScheduledPayment, CancelScheduledPayment, ListScheduledPaymentRuns]
+// 15. The reference and page of the history are valid [ListTransactions]
+// 16. Pending deposits have a reference and withdrawals are never pending [Deposit, Withdraw]
+// 17. The settlement status is posted or failed and the reference is valid [Settle]. Settlements
+// are authenticated by their signature in the transport, not by email.
 type validationMiddleware struct {
 	next     Service
 	repo     Repository
-	sysAccts map[string]snowflake.ID
+	sysAccts *SystemAccounts
 }
 
-func (v *validationMiddleware) CreateAccount(req CreateAccountReq) (*Account, error) {
+func (v *validationMiddleware) CreateAccount(ctx context.Context, req CreateAccountReq) (*Account, error) {
 	if !emailRegex.MatchString(req.Email) {
 		return nil, ErrBadRequest{Fields: map[string]string{"email": "invalid"}}
 	}
-	if _, exists := v.sysAccts[req.Currency]; !exists {
+	if _, exists := v.sysAccts.Get(req.Currency); !exists {
 		return nil, ErrBadRequest{Fields: map[string]string{"currency": "unsupported"}}
 	}
-	return v.next.CreateAccount(req)
+	switch req.Type {
+	case "":
+		req.Type = AccountDeposit
+	case AccountDeposit, AccountCredit:
+	default:
+		return nil, ErrBadRequest{Fields: map[string]string{"type": "unsupported"}}
+	}
+	return v.next.CreateAccount(ctx, req)
 }
 
-func (v *validationMiddleware) Deposit(req ChargeReq) (*decimal.Decimal, error) {
+func (v *validationMiddleware) Deposit(ctx context.Context, req ChargeReq) (*decimal.Decimal, error) {
 	if req.Amount.IsNegative() {
 		return nil, ErrBadRequest{Fields: map[string]string{"amount": "negative"}}
 	}
+	if err := validateMemo(req.Memo); err != nil {
+		return nil, err
+	}
+	// the reference is how the payment processor settles the deposit
+	if req.Pending && req.Reference == "" {
+		return nil, ErrBadRequest{Fields: map[string]string{"reference": "required for pending deposits"}}
+	}
 	if req.Email == "" {
 		return nil, ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
 	}
 
-	for _, id := range v.sysAccts {
-		if id == req.AcctID {
-			return nil, ErrBadRequest{Fields: map[string]string{"acctID": "system account not allowed"}}
-		}
+	if v.sysAccts.Contains(req.AcctID) {
+		return nil, ErrForbidden{Reason: "system account"}
 	}
 
-	acct, err := v.repo.GetAccount(req.AcctID)
+	acct, err := v.repo.GetAccount(ctx, req.AcctID)
 	if err != nil {
 		return nil, err
 	}
 	if acct.Email != req.Email {
-		return nil, ErrBadRequest{Fields: map[string]string{"email": "mismatch"}}
+		return nil, ErrForbidden{Reason: "email does not match the account"}
+	}
+	if acct.Frozen {
+		return nil, ErrAccountFrozen{AcctID: req.AcctID}
 	}
 	// this should not happen unless a system account for the currency is removed
-	if _, exists := v.sysAccts[acct.Currency]; !exists {
+	if _, exists := v.sysAccts.Get(acct.Currency); !exists {
 		return nil, ErrInternalServer
 	}
 	req.Currency = acct.Currency
 
-	return v.next.Deposit(req)
+	return v.next.Deposit(ctx, req)
 }
 
-func (v *validationMiddleware) Withdraw(req ChargeReq) (*decimal.Decimal, error) {
+func (v *validationMiddleware) Withdraw(ctx context.Context, req ChargeReq) (*Receipt, error) {
 	if req.Amount.IsNegative() {
 		return nil, ErrBadRequest{Fields: map[string]string{"amount": "negative"}}
 	}
+	if err := validateMemo(req.Memo); err != nil {
+		return nil, err
+	}
+	if req.Pending {
+		return nil, ErrBadRequest{Fields: map[string]string{"pending": "deposits only"}}
+	}
 	if req.Email == "" {
 		return nil, ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
 	}
 
-	for _, id := range v.sysAccts {
-		if id == req.AcctID {
-			return nil, ErrBadRequest{Fields: map[string]string{"acctID": "system account not allowed"}}
-		}
+	if v.sysAccts.Contains(req.AcctID) {
+		return nil, ErrForbidden{Reason: "system account"}
 	}
 
-	acct, err := v.repo.GetAccount(req.AcctID)
+	acct, err := v.repo.GetAccount(ctx, req.AcctID)
 	if err != nil {
 		return nil, err
 	}
 	if acct.Email != req.Email {
-		return nil, ErrBadRequest{Fields: map[string]string{"email": "mismatch"}}
+		return nil, ErrForbidden{Reason: "email does not match the account"}
+	}
+	if acct.Frozen {
+		return nil, ErrAccountFrozen{AcctID: req.AcctID}
 	}
-	if acct.Balance.LessThan(req.Amount) {
-		return nil, ErrBadRequest{Fields: map[string]string{"amount": "insufficient balance"}}
+	if acct.Balance.Add(acct.OverdraftLimit).LessThan(req.Amount) {
+		return nil, ErrInsufficientFunds{AcctID: req.AcctID}
 	}
 	// this should not happen unless a system account for the currency is removed
-	if _, exists := v.sysAccts[acct.Currency]; !exists {
+	if _, exists := v.sysAccts.Get(acct.Currency); !exists {
 		return nil, ErrInternalServer
 	}
 	req.Currency = acct.Currency
 
-	return v.next.Withdraw(req)
+	return v.next.Withdraw(ctx, req)
 }
 
-func (v *validationMiddleware) Balance(req BalanceReq) (*decimal.Decimal, error) {
+func (v *validationMiddleware) Balance(ctx context.Context, req BalanceReq) (*Balances, error) {
 	if req.Email == "" {
 		return nil, ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
 	}
-	acct, err := v.repo.GetAccount(req.AcctID)
+	acct, err := v.repo.GetAccount(ctx, req.AcctID)
 	if err != nil {
 		return nil, err
 	}
 	if acct.Email != req.Email {
-		return nil, ErrBadRequest{Fields: map[string]string{"email": "mismatch"}}
+		return nil, ErrForbidden{Reason: "email does not match the account"}
 	}
 
-	return v.next.Balance(req)
+	return v.next.Balance(ctx, req)
 }
 
-func (v *validationMiddleware) Statement(w io.Writer, req StatementReq) error {
+func (v *validationMiddleware) Statement(ctx context.Context, w io.Writer, req StatementReq) error {
 	if req.Email == "" {
 		return ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
 	}
-	acct, err := v.repo.GetAccount(req.AcctID)
+	if _, ok := StatementRendererFor(req.Format); !ok {
+		return ErrBadRequest{Fields: map[string]string{"format": "unsupported"}}
+	}
+	if !req.From.IsZero() && !req.To.IsZero() && req.To.Before(req.From) {
+		return ErrBadRequest{Fields: map[string]string{"to": "before from"}}
+	}
+	acct, err := v.repo.GetAccount(ctx, req.AcctID)
 	if err != nil {
 		return err
 	}
 	if acct.Email != req.Email {
-		return ErrBadRequest{Fields: map[string]string{"email": "mismatch"}}
+		return ErrForbidden{Reason: "email does not match the account"}
 	}
 
-	return v.next.Statement(w, req)
+	return v.next.Statement(ctx, w, req)
+}
+
+func (v *validationMiddleware) RequestStatement(ctx context.Context, req StatementReq) (*StatementJob, error) {
+	if req.Email == "" {
+		return nil, ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
+	}
+	if _, ok := StatementRendererFor(req.Format); !ok {
+		return nil, ErrBadRequest{Fields: map[string]string{"format": "unsupported"}}
+	}
+	if !req.From.IsZero() && !req.To.IsZero() && req.To.Before(req.From) {
+		return nil, ErrBadRequest{Fields: map[string]string{"to": "before from"}}
+	}
+	acct, err := v.repo.GetAccount(ctx, req.AcctID)
+	if err != nil {
+		return nil, err
+	}
+	if acct.Email != req.Email {
+		return nil, ErrForbidden{Reason: "email does not match the account"}
+	}
+
+	return v.next.RequestStatement(ctx, req)
+}
+
+func (v *validationMiddleware) GetStatementJob(ctx context.Context, req StatementJobReq) (*StatementJob, io.ReadCloser, error) {
+	if req.Email == "" {
+		return nil, nil, ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
+	}
+	job, err := v.repo.GetStatementJob(ctx, req.JobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	acct, err := v.repo.GetAccount(ctx, job.AcctID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// respond as if the job does not exist so job IDs cannot be probed
+	if acct.Email != req.Email {
+		return nil, nil, ErrNotFound{ID: req.JobID.Int64()}
+	}
+
+	return v.next.GetStatementJob(ctx, req)
+}
+
+func (v *validationMiddleware) VerifyStatement(ctx context.Context, req VerifyStatementReq) (*StatementVerification, error) {
+	code, err := ParseVerificationCode(req.Code)
+	if err != nil {
+		return nil, err
+	}
+	req.Code = code
+
+	return v.next.VerifyStatement(ctx, req)
+}
+
+func (v *validationMiddleware) SetStatementPreference(ctx context.Context, req StatementPreferenceReq) (*StatementPreference, error) {
+	if req.Email == "" {
+		return nil, ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
+	}
+	acct, err := v.repo.GetAccount(ctx, req.AcctID)
+	if err != nil {
+		return nil, err
+	}
+	if acct.Email != req.Email {
+		return nil, ErrForbidden{Reason: "email does not match the account"}
+	}
+
+	return v.next.SetStatementPreference(ctx, req)
+}
+
+func (v *validationMiddleware) SetStatementPassword(ctx context.Context, req StatementPasswordReq) error {
+	if req.Email == "" {
+		return ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
+	}
+	acct, err := v.repo.GetAccount(ctx, req.AcctID)
+	if err != nil {
+		return err
+	}
+	if acct.Email != req.Email {
+		return ErrForbidden{Reason: "email does not match the account"}
+	}
+
+	return v.next.SetStatementPassword(ctx, req)
+}
+
+func (v *validationMiddleware) ListStatementPeriods(ctx context.Context, req StatementPeriodsReq) ([]StatementPeriod, error) {
+	if req.Email == "" {
+		return nil, ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
+	}
+	acct, err := v.repo.GetAccount(ctx, req.AcctID)
+	if err != nil {
+		return nil, err
+	}
+	if acct.Email != req.Email {
+		return nil, ErrForbidden{Reason: "email does not match the account"}
+	}
+
+	return v.next.ListStatementPeriods(ctx, req)
+}
+
+func (v *validationMiddleware) GetStatementPeriod(ctx context.Context, req StatementPeriodReq) (*StatementPeriod, io.ReadCloser, error) {
+	if req.Email == "" {
+		return nil, nil, ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
+	}
+	acct, err := v.repo.GetAccount(ctx, req.AcctID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if acct.Email != req.Email {
+		return nil, nil, ErrForbidden{Reason: "email does not match the account"}
+	}
+
+	return v.next.GetStatementPeriod(ctx, req)
+}
+
+func (v *validationMiddleware) BalanceEvents(ctx context.Context, req BalanceEventsReq) (<-chan BalanceEvent, error) {
+	if req.Email == "" {
+		return nil, ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
+	}
+	if req.LastEventID < 0 {
+		return nil, ErrBadRequest{Fields: map[string]string{"Last-Event-ID": "negative"}}
+	}
+	acct, err := v.repo.GetAccount(ctx, req.AcctID)
+	if err != nil {
+		return nil, err
+	}
+	if acct.Email != req.Email {
+		return nil, ErrForbidden{Reason: "email does not match the account"}
+	}
+
+	return v.next.BalanceEvents(ctx, req)
+}
+
+func (v *validationMiddleware) ListTransactions(ctx context.Context, req TransactionsReq) ([]Transaction, error) {
+	if utf8.RuneCountInString(req.Reference) > MaxReferenceLen {
+		return nil, ErrBadRequest{Fields: map[string]string{"reference": fmt.Sprintf("longer than %d characters", MaxReferenceLen)}}
+	}
+	if req.Before < 0 {
+		return nil, ErrBadRequest{Fields: map[string]string{"before": "negative"}}
+	}
+	if req.Limit < 0 || req.Limit > MaxTransactionsLimit {
+		return nil, ErrBadRequest{Fields: map[string]string{"limit": fmt.Sprintf("must be between 1 and %d", MaxTransactionsLimit)}}
+	}
+	if err := v.authorizeAccount(ctx, req.AcctID, req.Email); err != nil {
+		return nil, err
+	}
+	return v.next.ListTransactions(ctx, req)
+}
+
+func (v *validationMiddleware) Settle(ctx context.Context, req SettleReq) (*Transaction, error) {
+	switch req.Status {
+	case TxnPosted, TxnFailed:
+	default:
+		return nil, ErrBadRequest{Fields: map[string]string{"status": "must be posted or failed"}}
+	}
+	if req.Reference == "" {
+		return nil, ErrBadRequest{Fields: map[string]string{"reference": "required"}}
+	}
+	if utf8.RuneCountInString(req.Reference) > MaxReferenceLen {
+		return nil, ErrBadRequest{Fields: map[string]string{"reference": fmt.Sprintf("longer than %d characters", MaxReferenceLen)}}
+	}
+	if v.sysAccts.Contains(req.AcctID) {
+		return nil, ErrForbidden{Reason: "system account"}
+	}
+	if _, err := v.repo.GetAccount(ctx, req.AcctID); err != nil {
+		return nil, err
+	}
+	return v.next.Settle(ctx, req)
+}
+
+func (v *validationMiddleware) PostBatch(ctx context.Context, req BatchReq) (*Batch, error) {
+	if err := validateIdempotencyKey(req.Key); err != nil {
+		return nil, err
+	}
+	switch req.Mode {
+	case "":
+		req.Mode = BatchAtomic
+	case BatchAtomic, BatchBestEffort:
+	default:
+		return nil, ErrBadRequest{Fields: map[string]string{"mode": "unsupported"}}
+	}
+	if len(req.Items) == 0 {
+		return nil, ErrBadRequest{Fields: map[string]string{"items": "empty"}}
+	}
+	if len(req.Items) > MaxBatchItems {
+		return nil, ErrBadRequest{Fields: map[string]string{"items": fmt.Sprintf("more than %d", MaxBatchItems)}}
+	}
+
+	fields := map[string]string{}
+	for i, it := range req.Items {
+		field := fmt.Sprintf("items[%d]", i)
+		if it.Type != BatchDeposit && it.Type != BatchWithdrawal {
+			fields[field+".type"] = "unsupported"
+		}
+		if it.Amount.IsNegative() {
+			fields[field+".amount"] = "negative"
+		}
+		if it.Email == "" {
+			fields[field+".email"] = "missing/invalid"
+		}
+	}
+	if len(fields) > 0 {
+		return nil, ErrBadRequest{Fields: fields}
+	}
+
+	// the same account is usually in many items, ie. a payroll account
+	accts := map[snowflake.ID]*Account{}
+	items := make([]BatchItemReq, len(req.Items))
+	for i, it := range req.Items {
+		if v.sysAccts.Contains(it.AcctID) {
+			return nil, ErrForbidden{Reason: fmt.Sprintf("items[%d]: system account", i)}
+		}
+		acct, ok := accts[it.AcctID]
+		if !ok {
+			var err error
+			if acct, err = v.repo.GetAccount(ctx, it.AcctID); err != nil {
+				return nil, err
+			}
+			accts[it.AcctID] = acct
+		}
+		if acct.Email != it.Email {
+			return nil, ErrForbidden{Reason: fmt.Sprintf("items[%d]: email does not match the account", i)}
+		}
+		if acct.Frozen {
+			return nil, ErrAccountFrozen{AcctID: it.AcctID}
+		}
+		// this should not happen unless a system account for the currency is removed
+		if _, exists := v.sysAccts.Get(acct.Currency); !exists {
+			return nil, ErrInternalServer
+		}
+		it.Currency = acct.Currency
+		items[i] = it
+	}
+	req.Items = items
+
+	return v.next.PostBatch(ctx, req)
+}
+
+func (v *validationMiddleware) GetBatch(ctx context.Context, req BatchReqByID) (*Batch, error) {
+	if err := validateIdempotencyKey(req.Key); err != nil {
+		return nil, err
+	}
+	return v.next.GetBatch(ctx, req)
+}
+
+func (v *validationMiddleware) CreateScheduledPayment(ctx context.Context, req ScheduledPaymentReq) (*ScheduledPayment, error) {
+	spec, err := v.validateScheduledPayment(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	req.ScheduledPaymentSpec = spec
+
+	return v.next.CreateScheduledPayment(ctx, req)
+}
+
+func (v *validationMiddleware) ListScheduledPayments(ctx context.Context, req ScheduledPaymentsReq) ([]ScheduledPayment, error) {
+	if err := v.authorizeAccount(ctx, req.AcctID, req.Email); err != nil {
+		return nil, err
+	}
+	return v.next.ListScheduledPayments(ctx, req)
+}
+
+func (v *validationMiddleware) GetScheduledPayment(ctx context.Context, req ScheduledPaymentReqByID) (*ScheduledPayment, error) {
+	if _, err := v.ownScheduledPayment(ctx, req); err != nil {
+		return nil, err
+	}
+	return v.next.GetScheduledPayment(ctx, req)
+}
+
+func (v *validationMiddleware) UpdateScheduledPayment(ctx context.Context, req ScheduledPaymentReq) (*ScheduledPayment, error) {
+	p, err := v.ownScheduledPayment(ctx, ScheduledPaymentReqByID{
+		AcctID:    req.AcctID,
+		PaymentID: req.PaymentID,
+		Email:     req.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == ScheduledPaymentCancelled {
+		return nil, ErrBadRequest{Fields: map[string]string{"paymentID": "cancelled"}}
+	}
+	spec, err := v.validateScheduledPayment(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	req.ScheduledPaymentSpec = spec
+
+	return v.next.UpdateScheduledPayment(ctx, req)
+}
+
+func (v *validationMiddleware) CancelScheduledPayment(ctx context.Context, req ScheduledPaymentReqByID) (*ScheduledPayment, error) {
+	if _, err := v.ownScheduledPayment(ctx, req); err != nil {
+		return nil, err
+	}
+	return v.next.CancelScheduledPayment(ctx, req)
+}
+
+func (v *validationMiddleware) ListScheduledPaymentRuns(ctx context.Context, req ScheduledPaymentReqByID) ([]ScheduledPaymentRun, error) {
+	if _, err := v.ownScheduledPayment(ctx, req); err != nil {
+		return nil, err
+	}
+	return v.next.ListScheduledPaymentRuns(ctx, req)
+}
+
+// authorizeAccount checks the account exists and belongs to the email
+func (v *validationMiddleware) authorizeAccount(ctx context.Context, acctID snowflake.ID, email string) error {
+	if email == "" {
+		return ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
+	}
+	acct, err := v.repo.GetAccount(ctx, acctID)
+	if err != nil {
+		return err
+	}
+	if acct.Email != email {
+		return ErrForbidden{Reason: "email does not match the account"}
+	}
+	return nil
+}
+
+// ownScheduledPayment returns the payment if it belongs to the account of the email
+func (v *validationMiddleware) ownScheduledPayment(ctx context.Context, req ScheduledPaymentReqByID) (*ScheduledPayment, error) {
+	if err := v.authorizeAccount(ctx, req.AcctID, req.Email); err != nil {
+		return nil, err
+	}
+	p, err := v.repo.GetScheduledPayment(ctx, req.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	// respond as if the payment does not exist so payment IDs cannot be probed
+	if p.AcctID != req.AcctID {
+		return nil, ErrNotFound{ID: req.PaymentID.Int64()}
+	}
+	return p, nil
+}
+
+// validateScheduledPayment returns the spec with its defaults filled in
+func (v *validationMiddleware) validateScheduledPayment(ctx context.Context, req ScheduledPaymentReq) (ScheduledPaymentSpec, error) {
+	spec := req.ScheduledPaymentSpec
+	if req.Email == "" {
+		return spec, ErrBadRequest{Fields: map[string]string{"email": "missing/invalid"}}
+	}
+	_, fields := ParseScheduleRule(spec.Rule)
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	if !spec.Amount.IsPositive() {
+		fields["amount"] = "not positive"
+	}
+	if spec.ToAcctID == 0 {
+		fields["toAcctID"] = "missing"
+	} else if spec.ToAcctID == req.AcctID {
+		fields["toAcctID"] = "same as the account"
+	}
+	if spec.EndAt != nil && !spec.StartAt.IsZero() && spec.EndAt.Before(spec.StartAt) {
+		fields["endAt"] = "before startAt"
+	}
+	switch spec.OnInsufficientFunds {
+	case "":
+		spec.OnInsufficientFunds = ScheduleSkip
+	case ScheduleSkip, ScheduleRetry:
+	default:
+		fields["onInsufficientFunds"] = "unsupported"
+	}
+	if len(fields) > 0 {
+		return spec, ErrBadRequest{Fields: fields}
+	}
+
+	if v.sysAccts.Contains(req.AcctID) || v.sysAccts.Contains(spec.ToAcctID) {
+		return spec, ErrForbidden{Reason: "system account"}
+	}
+	from, err := v.repo.GetAccount(ctx, req.AcctID)
+	if err != nil {
+		return spec, err
+	}
+	if from.Email != req.Email {
+		return spec, ErrForbidden{Reason: "email does not match the account"}
+	}
+	if from.Frozen {
+		return spec, ErrAccountFrozen{AcctID: req.AcctID}
+	}
+	to, err := v.repo.GetAccount(ctx, spec.ToAcctID)
+	if err != nil {
+		return spec, err
+	}
+	if to.Frozen {
+		return spec, ErrAccountFrozen{AcctID: spec.ToAcctID}
+	}
+	if to.Currency != from.Currency {
+		return spec, ErrBadRequest{Fields: map[string]string{"toAcctID": "currency does not match the account"}}
+	}
+	return spec, nil
+}
+
+func validateIdempotencyKey(key string) error {
+	if key == "" {
+		return ErrBadRequest{Fields: map[string]string{"Idempotency-Key": "missing"}}
+	}
+	if len(key) > maxIdempotencyKeyLen || !isPrintableASCII(key) {
+		return ErrBadRequest{Fields: map[string]string{"Idempotency-Key": "invalid"}}
+	}
+	return nil
 }
 
-func NewValidationMiddleware(repo Repository, sysAccts map[string]snowflake.ID) Middleware {
+func NewValidationMiddleware(repo Repository, sysAccts *SystemAccounts) Middleware {
 	return func(svc Service) Service {
 		return &validationMiddleware{
 			next:     svc,
@@ -150,57 +597,453 @@ func NewValidationMiddleware(repo Repository, sysAccts map[string]snowflake.ID)
 // Rate limiting middlewares
 //
 
-// limitMiddleware limits the number of in-flight requests to the service by using
-// a weighted semaphore, i.e., x/sync/semaphore.Semaphore with an acquisition timeout.
-// As limits are static and servers may be deployed to a heterogeneous set of machines,
-// hence, having to manually tune limits for each server, this solution is something
-// likely implemented very differently in a real-world application, but it is a good
-// example of load shedding.
+// limitMiddleware limits requests to the service per endpoint using one of two strategies:
+//   - "token_bucket": a token bucket rate limiter, i.e., x/time/rate.Limiter, waiting at
+//     most the endpoint SLO for a token. As these limits are static and servers may be
+//     deployed to a heterogeneous set of machines, they have to be tuned for each server.
+//   - "aimd": an adaptive cap on in-flight requests that backs off whenever a request
+//     takes longer than the endpoint SLO and probes upwards otherwise.
+//
+// Either way, optional keyed limiters per account ID and per client (API client or
+// remote IP) keep a single caller from using up the endpoint budget.
 type limitMiddleware struct {
 	next   Service
-	limits *serviceLimits
+	limits *ServiceLimits
 }
 
 var _ Service = (*limitMiddleware)(nil)
 
-// endpointLimit defines the deadline/SLO and a token bucket rate limiter
-// for a service endpoint
+const (
+	LimitStrategyTokenBucket = "token_bucket"
+	LimitStrategyAIMD        = "aimd"
+)
+
+// endpointLimit defines the deadline/SLO and either a token bucket rate limiter
+// or an adaptive concurrency limiter for a service endpoint, plus the optional
+// per account and per client limiters. Everything but the strategy can be
+// updated in place, see ServiceLimits.Update.
 type endpointLimit struct {
-	Slo time.Duration
-	Lmt *rate.Limiter
+	// sloNs is the SLO in nanoseconds
+	sloNs     atomic.Int64
+	Lmt       *rate.Limiter
+	Adaptive  *aimdLimiter
+	PerAcct   atomic.Pointer[keyedLimiter]
+	PerClient atomic.Pointer[keyedLimiter]
 }
 
-type serviceLimits struct {
+func (el *endpointLimit) slo() time.Duration {
+	return time.Duration(el.sloNs.Load())
+}
+
+// ServiceLimits holds the limiters of every service endpoint. It is shared by the
+// limit middleware and whatever needs to observe the limits.
+type ServiceLimits struct {
 	CreateAccount *endpointLimit
 	Deposit       *endpointLimit
 	Withdraw      *endpointLimit
 	Balance       *endpointLimit
 	Statement     *endpointLimit
+	// StatementJobs limits both requesting and fetching statement jobs
+	StatementJobs *endpointLimit
+	// StatementPeriods limits both listing and fetching closed statement periods
+	StatementPeriods *endpointLimit
+	VerifyStatement  *endpointLimit
+	Preferences      *endpointLimit
+	// BalanceEvents limits opening balance event streams, not their duration
+	BalanceEvents *endpointLimit
+	// BalanceEventStreams caps the balance event streams held open at once
+	BalanceEventStreams *streamLimiter
+	// Batches limits both posting and fetching batches
+	Batches *endpointLimit
+	// ScheduledPayments limits managing scheduled payments, not their runs
+	ScheduledPayments *endpointLimit
+	Transactions      *endpointLimit
+	Settlements       *endpointLimit
+}
+
+func NewServiceLimits(cfg *ServiceLimitsCfg) (*ServiceLimits, error) {
+	limits := &ServiceLimits{}
+	eps := limits.endpoints()
+	for name, epCfg := range cfg.endpoints() {
+		el, err := newEndpointLimit(name, epCfg)
+		if err != nil {
+			return nil, err
+		}
+		*eps[name] = el
+	}
+	limits.BalanceEventStreams = newStreamLimiter(cfg.BalanceEventStreams)
+	return limits, nil
 }
 
-func NewlimitMiddleware(cfg *ServiceLimitsCfg) Middleware {
-	limits := &serviceLimits{
-		CreateAccount: &endpointLimit{
-			Slo: time.Duration(cfg.CreateAccount.SloMs) * time.Millisecond,
-			Lmt: rate.NewLimiter(rate.Limit(cfg.CreateAccount.Rate), cfg.CreateAccount.Burst),
-		},
-		Deposit: &endpointLimit{
-			Slo: time.Duration(cfg.Deposit.SloMs) * time.Millisecond,
-			Lmt: rate.NewLimiter(rate.Limit(cfg.Deposit.Rate), cfg.Deposit.Burst),
-		},
-		Withdraw: &endpointLimit{
-			Slo: time.Duration(cfg.Withdraw.SloMs) * time.Millisecond,
-			Lmt: rate.NewLimiter(rate.Limit(cfg.Withdraw.Rate), cfg.Withdraw.Burst),
-		},
-		Balance: &endpointLimit{
-			Slo: time.Duration(cfg.Balance.SloMs) * time.Millisecond,
-			Lmt: rate.NewLimiter(rate.Limit(cfg.Balance.Rate), cfg.Balance.Burst),
-		},
-		Statement: &endpointLimit{
-			Slo: time.Duration(cfg.Statement.SloMs) * time.Millisecond,
-			Lmt: rate.NewLimiter(rate.Limit(cfg.Statement.Rate), cfg.Statement.Burst),
-		},
+// endpoints returns the limits of each endpoint keyed by their config key
+func (sl *ServiceLimits) endpoints() map[string]**endpointLimit {
+	return map[string]**endpointLimit{
+		"create_account":     &sl.CreateAccount,
+		"deposit":            &sl.Deposit,
+		"withdraw":           &sl.Withdraw,
+		"balance":            &sl.Balance,
+		"statement":          &sl.Statement,
+		"statement_jobs":     &sl.StatementJobs,
+		"statement_periods":  &sl.StatementPeriods,
+		"verify_statement":   &sl.VerifyStatement,
+		"preferences":        &sl.Preferences,
+		"balance_events":     &sl.BalanceEvents,
+		"batches":            &sl.Batches,
+		"scheduled_payments": &sl.ScheduledPayments,
+		"transactions":       &sl.Transactions,
+		"settlements":        &sl.Settlements,
+	}
+}
+
+func (cfg *ServiceLimitsCfg) endpoints() map[string]EndpointLimitCfg {
+	return map[string]EndpointLimitCfg{
+		"create_account":     cfg.CreateAccount,
+		"deposit":            cfg.Deposit,
+		"withdraw":           cfg.Withdraw,
+		"balance":            cfg.Balance,
+		"statement":          cfg.Statement,
+		"statement_jobs":     cfg.StatementJobs,
+		"statement_periods":  cfg.StatementPeriods,
+		"verify_statement":   cfg.VerifyStatement,
+		"preferences":        cfg.Preferences,
+		"balance_events":     cfg.BalanceEvents,
+		"batches":            cfg.Batches,
+		"scheduled_payments": cfg.ScheduledPayments,
+		"transactions":       cfg.Transactions,
+		"settlements":        cfg.Settlements,
+	}
+}
+
+func newEndpointLimit(name string, cfg EndpointLimitCfg) (*endpointLimit, error) {
+	el := &endpointLimit{}
+	el.sloNs.Store(int64(time.Duration(cfg.SloMs) * time.Millisecond))
+	el.PerAcct.Store(newKeyedLimiter(cfg.PerAccount))
+	el.PerClient.Store(newKeyedLimiter(cfg.PerClient))
+	switch cfg.Strategy {
+	case "", LimitStrategyTokenBucket:
+		el.Lmt = rate.NewLimiter(rate.Limit(cfg.Rate), cfg.Burst)
+	case LimitStrategyAIMD:
+		el.Adaptive = newAIMDLimiter(el.slo(), cfg.Adaptive)
+	default:
+		return nil, fmt.Errorf("service_limits.%s.strategy: unknown strategy %q", name, cfg.Strategy)
+	}
+	return el, nil
+}
+
+// Update applies cfg to the limiters in place, so requests waiting on them
+// are not dropped. Nothing is updated if the strategy of an endpoint changed,
+// which requires a restart.
+func (sl *ServiceLimits) Update(cfg *ServiceLimitsCfg) error {
+	eps := sl.endpoints()
+	cfgs := cfg.endpoints()
+	for name, epCfg := range cfgs {
+		el := *eps[name]
+		strategy := epCfg.Strategy
+		if strategy == "" {
+			strategy = LimitStrategyTokenBucket
+		}
+		if strategy != el.status().Strategy {
+			return ConfigError{Key: "service_limits." + name + ".strategy", Msg: "changing the strategy requires a restart"}
+		}
+	}
+	for name, epCfg := range cfgs {
+		(*eps[name]).update(epCfg)
+	}
+	sl.BalanceEventStreams.update(cfg.BalanceEventStreams)
+	return nil
+}
+
+func (el *endpointLimit) update(cfg EndpointLimitCfg) {
+	slo := time.Duration(cfg.SloMs) * time.Millisecond
+	el.sloNs.Store(int64(slo))
+	if el.Lmt != nil {
+		el.Lmt.SetLimit(rate.Limit(cfg.Rate))
+		el.Lmt.SetBurst(cfg.Burst)
+	}
+	if el.Adaptive != nil {
+		el.Adaptive.update(slo, cfg.Adaptive)
+	}
+	for _, kl := range []struct {
+		ptr *atomic.Pointer[keyedLimiter]
+		cfg KeyedLimitCfg
+	}{{&el.PerAcct, cfg.PerAccount}, {&el.PerClient, cfg.PerClient}} {
+		// keyed limiters being enabled or disabled are swapped, keeping the
+		// buckets of existing keys otherwise
+		if cur := kl.ptr.Load(); cur != nil && kl.cfg.Rate > 0 {
+			cur.update(kl.cfg)
+		} else {
+			kl.ptr.Store(newKeyedLimiter(kl.cfg))
+		}
 	}
+}
+
+// EndpointLimitStatus is a point in time view of an endpoint's limits. For the
+// token bucket strategy Limit is the rate per second, for the AIMD strategy it
+// is the current in-flight cap.
+type EndpointLimitStatus struct {
+	Strategy string  `json:"strategy"`
+	SloMs    int64   `json:"sloMs"`
+	Limit    float64 `json:"limit"`
+	Burst    int     `json:"burst,omitempty"`
+	InFlight int     `json:"inFlight"`
+}
+
+// Status returns the current limits keyed by endpoint
+func (sl *ServiceLimits) Status() map[string]EndpointLimitStatus {
+	return map[string]EndpointLimitStatus{
+		"create_account":     sl.CreateAccount.status(),
+		"deposit":            sl.Deposit.status(),
+		"withdraw":           sl.Withdraw.status(),
+		"balance":            sl.Balance.status(),
+		"statement":          sl.Statement.status(),
+		"statement_jobs":     sl.StatementJobs.status(),
+		"statement_periods":  sl.StatementPeriods.status(),
+		"verify_statement":   sl.VerifyStatement.status(),
+		"preferences":        sl.Preferences.status(),
+		"balance_events":     sl.BalanceEvents.status(),
+		"batches":            sl.Batches.status(),
+		"scheduled_payments": sl.ScheduledPayments.status(),
+		"transactions":       sl.Transactions.status(),
+		"settlements":        sl.Settlements.status(),
+	}
+}
+
+// ServeHTTP writes the current limits as JSON for observability
+func (sl *ServiceLimits) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sl.Status()); err != nil {
+		WriteHTTPError(w, err)
+	}
+}
+
+func (el *endpointLimit) status() EndpointLimitStatus {
+	st := EndpointLimitStatus{SloMs: el.slo().Milliseconds()}
+	if el.Adaptive != nil {
+		st.Strategy = LimitStrategyAIMD
+		st.Limit, st.InFlight = el.Adaptive.status()
+		return st
+	}
+	st.Strategy = LimitStrategyTokenBucket
+	st.Limit = float64(el.Lmt.Limit())
+	st.Burst = el.Lmt.Burst()
+	return st
+}
+
+// acquire admits a request through the keyed limiters and the endpoint strategy.
+// The returned func must be called once the request completes.
+func (el *endpointLimit) acquire(acctID snowflake.ID, client string) (func(), error) {
+	now := time.Now()
+	rsvs, delay, err := el.reserve(acctID, client, now)
+	if err != nil {
+		return nil, err
+	}
+	release := func() {}
+	if el.Adaptive != nil {
+		var ok bool
+		if release, ok = el.Adaptive.acquire(delay); !ok {
+			// the request is not served, so it gives back the tokens it took
+			cancelReservations(rsvs, now)
+			return nil, ErrRateLimited{RetryAfter: el.slo()}
+		}
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return release, nil
+}
+
+// reserve reserves a token from every applicable limiter and returns how long
+// after now the request has to wait until all of them admit it. If that would
+// take longer than the SLO, all reservations are cancelled and an
+// ErrRateLimited carrying the longest delay is returned instead.
+func (el *endpointLimit) reserve(acctID snowflake.ID, client string, now time.Time) ([]*rate.Reservation, time.Duration, error) {
+	rsvs := make([]*rate.Reservation, 0, 3)
+	if perClient := el.PerClient.Load(); perClient != nil && client != "" {
+		rsvs = append(rsvs, perClient.get(client).Reserve())
+	}
+	if perAcct := el.PerAcct.Load(); perAcct != nil && acctID != 0 {
+		rsvs = append(rsvs, perAcct.get(acctID.String()).Reserve())
+	}
+	if el.Lmt != nil {
+		rsvs = append(rsvs, el.Lmt.Reserve())
+	}
+
+	slo := el.slo()
+	var delay time.Duration
+	for _, r := range rsvs {
+		if !r.OK() {
+			// burst is smaller than a single token, the request can never be admitted
+			delay = slo + time.Second
+			break
+		}
+		if d := r.DelayFrom(now); d > delay {
+			delay = d
+		}
+	}
+	if delay > slo {
+		cancelReservations(rsvs, now)
+		return nil, 0, ErrRateLimited{RetryAfter: delay}
+	}
+	return rsvs, delay, nil
+}
+
+// reserveBatchItems reserves a token per item from the per account limit of
+// deposits or withdrawals and returns how long after now the batch has to
+// wait until all of them admit it. If any item would wait longer than the SLO
+// of its endpoint, all reservations are cancelled and ErrRateLimited returned.
+func (sl *ServiceLimits) reserveBatchItems(items []BatchItemReq, now time.Time) ([]*rate.Reservation, time.Duration, error) {
+	var (
+		rsvs  []*rate.Reservation
+		delay time.Duration
+	)
+	for _, item := range items {
+		el := sl.Deposit
+		if item.Type == BatchWithdrawal {
+			el = sl.Withdraw
+		}
+		perAcct := el.PerAcct.Load()
+		if perAcct == nil || item.AcctID == 0 {
+			continue
+		}
+		r := perAcct.get(item.AcctID.String()).Reserve()
+		rsvs = append(rsvs, r)
+		slo := el.slo()
+		d := slo + time.Second
+		if r.OK() {
+			d = r.DelayFrom(now)
+		}
+		if d > slo {
+			cancelReservations(rsvs, now)
+			return nil, 0, ErrRateLimited{RetryAfter: d}
+		}
+		delay = max(delay, d)
+	}
+	return rsvs, delay, nil
+}
+
+// cancelReservations gives back the tokens of rsvs as of now, the time they
+// were reserved at, as tokens are only given back before their time to act.
+// They are cancelled newest first, as a limiter only gives back all tokens of
+// a reservation no later one depends on.
+func cancelReservations(rsvs []*rate.Reservation, now time.Time) {
+	for i := len(rsvs) - 1; i >= 0; i-- {
+		rsvs[i].CancelAt(now)
+	}
+}
+
+// keyedLimiter holds a token bucket per key in a bounded LRU cache
+type keyedLimiter struct {
+	mu    sync.Mutex
+	rate  rate.Limit
+	burst int
+	lmts  *lru.Cache[string, *rate.Limiter]
+}
+
+const defaultMaxKeys = 10000
+
+// newKeyedLimiter returns nil if the keyed limit is not configured
+func newKeyedLimiter(cfg KeyedLimitCfg) *keyedLimiter {
+	if cfg.Rate <= 0 {
+		return nil
+	}
+	size := cfg.MaxKeys
+	if size <= 0 {
+		size = defaultMaxKeys
+	}
+	// lru.New only errors on a non-positive size
+	cache, _ := lru.New[string, *rate.Limiter](size)
+	return &keyedLimiter{
+		rate:  rate.Limit(cfg.Rate),
+		burst: cfg.Burst,
+		lmts:  cache,
+	}
+}
+
+// update applies the rate and burst to every bucket and resizes the cache
+func (kl *keyedLimiter) update(cfg KeyedLimitCfg) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	kl.rate, kl.burst = rate.Limit(cfg.Rate), cfg.Burst
+	size := cfg.MaxKeys
+	if size <= 0 {
+		size = defaultMaxKeys
+	}
+	kl.lmts.Resize(size)
+	for _, lmt := range kl.lmts.Values() {
+		lmt.SetLimit(kl.rate)
+		lmt.SetBurst(kl.burst)
+	}
+}
+
+func (kl *keyedLimiter) get(key string) *rate.Limiter {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	if lmt, ok := kl.lmts.Get(key); ok {
+		return lmt
+	}
+	lmt := rate.NewLimiter(kl.rate, kl.burst)
+	kl.lmts.Add(key, lmt)
+	return lmt
+}
+
+// streamRetryAfter is when callers over their stream cap may retry, there is
+// no telling when one of their streams closes
+const streamRetryAfter = 5 * time.Second
+
+// streamLimiter counts the streams held open per account and per API client
+type streamLimiter struct {
+	mu        sync.Mutex
+	perAcct   int
+	perClient int
+	accts     map[snowflake.ID]int
+	clients   map[string]int
+}
+
+func newStreamLimiter(cfg StreamLimitCfg) *streamLimiter {
+	return &streamLimiter{
+		perAcct:   cfg.PerAccount,
+		perClient: cfg.PerClient,
+		accts:     make(map[snowflake.ID]int),
+		clients:   make(map[string]int),
+	}
+}
+
+// update applies the caps to streams opened from now on, open streams are
+// not closed if they exceed them
+func (sl *streamLimiter) update(cfg StreamLimitCfg) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	sl.perAcct, sl.perClient = cfg.PerAccount, cfg.PerClient
+}
+
+// acquire admits a stream unless the account or client already holds its cap.
+// The returned func must be called once the stream is closed.
+func (sl *streamLimiter) acquire(acctID snowflake.ID, client string) (func(), bool) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if sl.perAcct > 0 && acctID != 0 && sl.accts[acctID] >= sl.perAcct {
+		return nil, false
+	}
+	if sl.perClient > 0 && client != "" && sl.clients[client] >= sl.perClient {
+		return nil, false
+	}
+	// streams are counted even without a cap, so one configured later
+	// applies to the streams already open
+	sl.accts[acctID]++
+	sl.clients[client]++
+	return func() {
+		sl.mu.Lock()
+		defer sl.mu.Unlock()
+		if sl.accts[acctID]--; sl.accts[acctID] <= 0 {
+			delete(sl.accts, acctID)
+		}
+		if sl.clients[client]--; sl.clients[client] <= 0 {
+			delete(sl.clients, client)
+		}
+	}, true
+}
+
+func NewlimitMiddleware(limits *ServiceLimits) Middleware {
 	return func(next Service) Service {
 		return &limitMiddleware{
 			next:   next,
@@ -209,42 +1052,265 @@ func NewlimitMiddleware(cfg *ServiceLimitsCfg) Middleware {
 	}
 }
 
-func (l *limitMiddleware) CreateAccount(req CreateAccountReq) (*Account, error) {
-	ctx, _ := context.WithDeadline(context.Background(), time.Now().Add(l.limits.CreateAccount.Slo))
-	if err := l.limits.CreateAccount.Lmt.Wait(ctx); err != nil {
-		return nil, ErrServiceUnavailable
+func (l *limitMiddleware) CreateAccount(ctx context.Context, req CreateAccountReq) (*Account, error) {
+	release, err := l.limits.CreateAccount.acquire(0, req.Client)
+	if err != nil {
+		return nil, err
 	}
-	return l.next.CreateAccount(req)
+	defer release()
+	return l.next.CreateAccount(ctx, req)
 }
 
-func (l *limitMiddleware) Deposit(req ChargeReq) (*decimal.Decimal, error) {
-	ctx, _ := context.WithDeadline(context.Background(), time.Now().Add(l.limits.Deposit.Slo))
-	if err := l.limits.Deposit.Lmt.Wait(ctx); err != nil {
-		return nil, ErrServiceUnavailable
+func (l *limitMiddleware) Deposit(ctx context.Context, req ChargeReq) (*decimal.Decimal, error) {
+	release, err := l.limits.Deposit.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
 	}
-	return l.next.Deposit(req)
+	defer release()
+	return l.next.Deposit(ctx, req)
 }
 
-func (l *limitMiddleware) Withdraw(req ChargeReq) (*decimal.Decimal, error) {
-	ctx, _ := context.WithDeadline(context.Background(), time.Now().Add(l.limits.Withdraw.Slo))
-	if err := l.limits.Withdraw.Lmt.Wait(ctx); err != nil {
-		return nil, ErrServiceUnavailable
+func (l *limitMiddleware) Withdraw(ctx context.Context, req ChargeReq) (*Receipt, error) {
+	release, err := l.limits.Withdraw.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
 	}
-	return l.next.Withdraw(req)
+	defer release()
+	return l.next.Withdraw(ctx, req)
 }
 
-func (l *limitMiddleware) Balance(req BalanceReq) (*decimal.Decimal, error) {
-	ctx, _ := context.WithDeadline(context.Background(), time.Now().Add(l.limits.Balance.Slo))
-	if err := l.limits.Balance.Lmt.Wait(ctx); err != nil {
-		return nil, ErrServiceUnavailable
+func (l *limitMiddleware) Balance(ctx context.Context, req BalanceReq) (*Balances, error) {
+	release, err := l.limits.Balance.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
 	}
-	return l.next.Balance(req)
+	defer release()
+	return l.next.Balance(ctx, req)
 }
 
-func (l *limitMiddleware) Statement(w io.Writer, req StatementReq) error {
-	ctx, _ := context.WithDeadline(context.Background(), time.Now().Add(l.limits.Statement.Slo))
-	if err := l.limits.Statement.Lmt.Wait(ctx); err != nil {
-		return ErrServiceUnavailable
+func (l *limitMiddleware) Statement(ctx context.Context, w io.Writer, req StatementReq) error {
+	release, err := l.limits.Statement.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return l.next.Statement(ctx, w, req)
+}
+
+func (l *limitMiddleware) RequestStatement(ctx context.Context, req StatementReq) (*StatementJob, error) {
+	release, err := l.limits.StatementJobs.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.next.RequestStatement(ctx, req)
+}
+
+func (l *limitMiddleware) GetStatementJob(ctx context.Context, req StatementJobReq) (*StatementJob, io.ReadCloser, error) {
+	release, err := l.limits.StatementJobs.acquire(0, req.Client)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	return l.next.GetStatementJob(ctx, req)
+}
+
+func (l *limitMiddleware) VerifyStatement(ctx context.Context, req VerifyStatementReq) (*StatementVerification, error) {
+	release, err := l.limits.VerifyStatement.acquire(0, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.next.VerifyStatement(ctx, req)
+}
+
+func (l *limitMiddleware) SetStatementPreference(ctx context.Context, req StatementPreferenceReq) (*StatementPreference, error) {
+	release, err := l.limits.Preferences.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.next.SetStatementPreference(ctx, req)
+}
+
+func (l *limitMiddleware) SetStatementPassword(ctx context.Context, req StatementPasswordReq) error {
+	release, err := l.limits.Preferences.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return l.next.SetStatementPassword(ctx, req)
+}
+
+func (l *limitMiddleware) ListStatementPeriods(ctx context.Context, req StatementPeriodsReq) ([]StatementPeriod, error) {
+	release, err := l.limits.StatementPeriods.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.next.ListStatementPeriods(ctx, req)
+}
+
+func (l *limitMiddleware) GetStatementPeriod(ctx context.Context, req StatementPeriodReq) (*StatementPeriod, io.ReadCloser, error) {
+	release, err := l.limits.StatementPeriods.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	return l.next.GetStatementPeriod(ctx, req)
+}
+
+func (l *limitMiddleware) BalanceEvents(ctx context.Context, req BalanceEventsReq) (<-chan BalanceEvent, error) {
+	release, err := l.limits.BalanceEvents.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	closeStream, ok := l.limits.BalanceEventStreams.acquire(req.AcctID, req.Client)
+	if !ok {
+		return nil, ErrRateLimited{RetryAfter: streamRetryAfter}
+	}
+	events, err := l.next.BalanceEvents(ctx, req)
+	if err != nil {
+		closeStream()
+		return nil, err
+	}
+
+	// the stream holds its slot until the service closes it, which it does
+	// once ctx is done
+	out := make(chan BalanceEvent)
+	go func() {
+		defer closeStream()
+		defer close(out)
+		for ev := range events {
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (l *limitMiddleware) ListTransactions(ctx context.Context, req TransactionsReq) ([]Transaction, error) {
+	release, err := l.limits.Transactions.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.next.ListTransactions(ctx, req)
+}
+
+func (l *limitMiddleware) Settle(ctx context.Context, req SettleReq) (*Transaction, error) {
+	release, err := l.limits.Settlements.acquire(0, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.next.Settle(ctx, req)
+}
+
+// internalCallerCtxKey marks the requests of the jobs of the service itself,
+// see withInternalCaller
+type internalCallerCtxKey struct{}
+
+// withInternalCaller marks ctx as a request of a job of the service, ie. the
+// scheduler, which the limit middleware lets past the limits of the batches
+// endpoint. Unlike the client of a request it is set by this package only, so
+// API callers cannot claim it.
+func withInternalCaller(ctx context.Context) context.Context {
+	return context.WithValue(ctx, internalCallerCtxKey{}, true)
+}
+
+func isInternalCaller(ctx context.Context) bool {
+	internal, _ := ctx.Value(internalCallerCtxKey{}).(bool)
+	return internal
+}
+
+func (l *limitMiddleware) PostBatch(ctx context.Context, req BatchReq) (*Batch, error) {
+	// items count against the per account limits of deposits and withdrawals
+	// so batches are no way around them, not even those of internal callers
+	now := time.Now()
+	rsvs, delay, err := l.limits.reserveBatchItems(req.Items, now)
+	if err != nil {
+		return nil, err
+	}
+	// internal callers post all their batches as one client, which API
+	// callers draining the endpoint could hold up
+	if !isInternalCaller(ctx) {
+		release, err := l.limits.Batches.acquire(0, req.Client)
+		if err != nil {
+			cancelReservations(rsvs, now)
+			return nil, err
+		}
+		defer release()
+	}
+	if wait := time.Until(now.Add(delay)); wait > 0 {
+		time.Sleep(wait)
+	}
+	return l.next.PostBatch(ctx, req)
+}
+
+func (l *limitMiddleware) GetBatch(ctx context.Context, req BatchReqByID) (*Batch, error) {
+	release, err := l.limits.Batches.acquire(0, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.next.GetBatch(ctx, req)
+}
+
+func (l *limitMiddleware) CreateScheduledPayment(ctx context.Context, req ScheduledPaymentReq) (*ScheduledPayment, error) {
+	release, err := l.limits.ScheduledPayments.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.next.CreateScheduledPayment(ctx, req)
+}
+
+func (l *limitMiddleware) ListScheduledPayments(ctx context.Context, req ScheduledPaymentsReq) ([]ScheduledPayment, error) {
+	release, err := l.limits.ScheduledPayments.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.next.ListScheduledPayments(ctx, req)
+}
+
+func (l *limitMiddleware) GetScheduledPayment(ctx context.Context, req ScheduledPaymentReqByID) (*ScheduledPayment, error) {
+	release, err := l.limits.ScheduledPayments.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.next.GetScheduledPayment(ctx, req)
+}
+
+func (l *limitMiddleware) UpdateScheduledPayment(ctx context.Context, req ScheduledPaymentReq) (*ScheduledPayment, error) {
+	release, err := l.limits.ScheduledPayments.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.next.UpdateScheduledPayment(ctx, req)
+}
+
+func (l *limitMiddleware) CancelScheduledPayment(ctx context.Context, req ScheduledPaymentReqByID) (*ScheduledPayment, error) {
+	release, err := l.limits.ScheduledPayments.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.next.CancelScheduledPayment(ctx, req)
+}
+
+func (l *limitMiddleware) ListScheduledPaymentRuns(ctx context.Context, req ScheduledPaymentReqByID) ([]ScheduledPaymentRun, error) {
+	release, err := l.limits.ScheduledPayments.acquire(req.AcctID, req.Client)
+	if err != nil {
+		return nil, err
 	}
-	return l.next.Statement(w, req)
+	defer release()
+	return l.next.ListScheduledPaymentRuns(ctx, req)
 }
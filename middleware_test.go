@@ -2,11 +2,16 @@ package bankxgo_test
 
 import (
 	"bytes"
+	"context"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bwmarrin/snowflake"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 
 	"github.com/arhyth/bankxgo"
@@ -21,7 +26,7 @@ func TestValidationMWCreateAccount(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := "nopass@jpy.com"
@@ -30,7 +35,7 @@ func TestValidationMWCreateAccount(t *testing.T) {
 			Email:    userEmail,
 			Currency: "JPY",
 		}
-		acct, err := v.CreateAccount(dep)
+		acct, err := v.CreateAccount(context.Background(), dep)
 		as.NotNil(err)
 		as.Nil(acct)
 	})
@@ -40,16 +45,45 @@ func TestValidationMWCreateAccount(t *testing.T) {
 		ctrl := gomock.NewController(tt)
 		repo := mocks.NewMockRepository(ctrl)
 		svc := mocks.NewMockService(ctrl)
-		v := bankxgo.NewValidationMiddleware(repo, nil)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(nil, nil))(svc)
 		userEmail := "g!bberis#"
 		req := bankxgo.CreateAccountReq{
 			Email:    userEmail,
 			Currency: "PHP",
 		}
-		acct, err := v.CreateAccount(req)
+		acct, err := v.CreateAccount(context.Background(), req)
 		as.NotNil(err)
 		as.Nil(acct)
 	})
+
+	t.Run("returns an error on an unsupported account type and defaults to deposit", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
+		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		req := bankxgo.CreateAccountReq{
+			Email:    "loan@shark.com",
+			Currency: "USD",
+			Type:     "loan",
+		}
+		acct, err := v.CreateAccount(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"type": "unsupported"}}, err)
+		as.Nil(acct)
+
+		svc.EXPECT().
+			CreateAccount(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, r bankxgo.CreateAccountReq) (*bankxgo.Account, error) {
+				as.Equal(bankxgo.AccountDeposit, r.Type)
+				return &bankxgo.Account{}, nil
+			})
+		req.Type = ""
+		_, err = v.CreateAccount(context.Background(), req)
+		as.Nil(err)
+	})
 }
 
 func TestValidationMWWithdraw(t *testing.T) {
@@ -60,18 +94,18 @@ func TestValidationMWWithdraw(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := "noaccount@bank.com"
 		repo.EXPECT().
-			GetAccount(userAcctID).
+			GetAccount(gomock.Any(), userAcctID).
 			Return(nil, bankxgo.ErrNotFound{ID: userAcctID.Int64()})
 		req := bankxgo.ChargeReq{
 			Amount: decimal.NewFromInt(123),
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
-		bal, err := v.Withdraw(req)
+		bal, err := v.Withdraw(context.Background(), req)
 		as.NotNil(err)
 		as.ErrorAs(err, &bankxgo.ErrNotFound{})
 		as.Nil(bal)
@@ -84,17 +118,47 @@ func TestValidationMWWithdraw(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 		req := bankxgo.ChargeReq{
 			Amount: decimal.NewFromInt(123),
 			AcctID: usdSysAcct,
 			Email:  "attacker@maybe.com",
 		}
-		bal, err := v.Withdraw(req)
+		bal, err := v.Withdraw(context.Background(), req)
 		as.NotNil(err)
 		as.Nil(bal)
 	})
 
+	t.Run("returns error on frozen account", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
+		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		userAcctID := snowflake.ParseInt64(7241722241547767808)
+		userEmail := "frozen@email.com"
+		repo.EXPECT().
+			GetAccount(gomock.Any(), userAcctID).
+			Return(&bankxgo.Account{
+				AcctID:   userAcctID,
+				Email:    userEmail,
+				Currency: "USD",
+				Balance:  decimal.NewFromInt(1000),
+				Frozen:   true,
+			}, nil)
+		req := bankxgo.ChargeReq{
+			Amount: decimal.NewFromInt(123),
+			AcctID: userAcctID,
+			Email:  userEmail,
+		}
+		rcpt, err := v.Withdraw(context.Background(), req)
+		as.Equal(bankxgo.ErrAccountFrozen{AcctID: userAcctID}, err)
+		as.Nil(rcpt)
+	})
+
 	t.Run("returns error on mismatched email", func(tt *testing.T) {
 		as := assert.New(tt)
 		ctrl := gomock.NewController(tt)
@@ -102,12 +166,12 @@ func TestValidationMWWithdraw(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := "mismatched@email.com"
 		repo.EXPECT().
-			GetAccount(userAcctID).
+			GetAccount(gomock.Any(), userAcctID).
 			Return(&bankxgo.Account{
 				AcctID: userAcctID,
 				Email:  "correct@email.com",
@@ -117,8 +181,8 @@ func TestValidationMWWithdraw(t *testing.T) {
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
-		bal, err := v.Withdraw(req)
-		as.NotNil(err)
+		bal, err := v.Withdraw(context.Background(), req)
+		as.IsType(bankxgo.ErrForbidden{}, err)
 		as.Nil(bal)
 	})
 
@@ -129,7 +193,7 @@ func TestValidationMWWithdraw(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := ""
@@ -138,7 +202,7 @@ func TestValidationMWWithdraw(t *testing.T) {
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
-		bal, err := v.Withdraw(req)
+		bal, err := v.Withdraw(context.Background(), req)
 		as.NotNil(err)
 		as.Nil(bal)
 	})
@@ -150,7 +214,7 @@ func TestValidationMWWithdraw(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := "negative@amount.com"
@@ -159,7 +223,7 @@ func TestValidationMWWithdraw(t *testing.T) {
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
-		bal, err := v.Withdraw(dep)
+		bal, err := v.Withdraw(context.Background(), dep)
 		as.NotNil(err)
 		as.Nil(bal)
 	})
@@ -171,12 +235,12 @@ func TestValidationMWWithdraw(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := "tinimbangpero@kulang.com"
 		repo.EXPECT().
-			GetAccount(userAcctID).
+			GetAccount(gomock.Any(), userAcctID).
 			Return(&bankxgo.Account{
 				AcctID:  userAcctID,
 				Email:   "tinimbangpero@kulang.com",
@@ -187,10 +251,46 @@ func TestValidationMWWithdraw(t *testing.T) {
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
-		bal, err := v.Withdraw(req)
-		as.NotNil(err)
+		bal, err := v.Withdraw(context.Background(), req)
+		as.Equal(bankxgo.ErrInsufficientFunds{AcctID: userAcctID}, err)
 		as.Nil(bal)
 	})
+
+	t.Run("allows withdrawing into the overdraft limit but not beyond", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
+		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		userAcctID := snowflake.ParseInt64(7241722241547767808)
+		userEmail := "utang@kulang.com"
+		repo.EXPECT().
+			GetAccount(gomock.Any(), userAcctID).
+			Return(&bankxgo.Account{
+				AcctID:         userAcctID,
+				Email:          userEmail,
+				Currency:       "USD",
+				Balance:        decimal.NewFromInt(-20),
+				OverdraftLimit: decimal.NewFromInt(100),
+			}, nil).
+			Times(2)
+		svc.EXPECT().Withdraw(gomock.Any(), gomock.Any()).Return(&bankxgo.Receipt{}, nil)
+
+		req := bankxgo.ChargeReq{
+			Amount: decimal.NewFromInt(80),
+			AcctID: userAcctID,
+			Email:  userEmail,
+		}
+		_, err := v.Withdraw(context.Background(), req)
+		as.Nil(err)
+
+		req.Amount = decimal.New(8001, -2)
+		_, err = v.Withdraw(context.Background(), req)
+		as.Equal(bankxgo.ErrInsufficientFunds{AcctID: userAcctID}, err)
+	})
 }
 
 func TestValidationMWDeposit(t *testing.T) {
@@ -201,18 +301,18 @@ func TestValidationMWDeposit(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := "noaccount@bank.com"
 		repo.EXPECT().
-			GetAccount(userAcctID).
+			GetAccount(gomock.Any(), userAcctID).
 			Return(nil, bankxgo.ErrNotFound{ID: userAcctID.Int64()})
 		req := bankxgo.ChargeReq{
 			Amount: decimal.NewFromInt(123),
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
-		bal, err := v.Deposit(req)
+		bal, err := v.Deposit(context.Background(), req)
 		as.NotNil(err)
 		as.ErrorAs(err, &bankxgo.ErrNotFound{})
 		as.Nil(bal)
@@ -225,17 +325,46 @@ func TestValidationMWDeposit(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 		req := bankxgo.ChargeReq{
 			Amount: decimal.NewFromInt(123),
 			AcctID: usdSysAcct,
 			Email:  "attacker@maybe.com",
 		}
-		bal, err := v.Deposit(req)
+		bal, err := v.Deposit(context.Background(), req)
 		as.NotNil(err)
 		as.Nil(bal)
 	})
 
+	t.Run("returns error on frozen account", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
+		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		userAcctID := snowflake.ParseInt64(7241722241547767808)
+		userEmail := "frozen@email.com"
+		repo.EXPECT().
+			GetAccount(gomock.Any(), userAcctID).
+			Return(&bankxgo.Account{
+				AcctID:   userAcctID,
+				Email:    userEmail,
+				Currency: "USD",
+				Frozen:   true,
+			}, nil)
+		req := bankxgo.ChargeReq{
+			Amount: decimal.NewFromInt(123),
+			AcctID: userAcctID,
+			Email:  userEmail,
+		}
+		bal, err := v.Deposit(context.Background(), req)
+		as.Equal(bankxgo.ErrAccountFrozen{AcctID: userAcctID}, err)
+		as.Nil(bal)
+	})
+
 	t.Run("returns error on mismatched email", func(tt *testing.T) {
 		as := assert.New(tt)
 		ctrl := gomock.NewController(tt)
@@ -243,12 +372,12 @@ func TestValidationMWDeposit(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := "mismatched@email.com"
 		repo.EXPECT().
-			GetAccount(userAcctID).
+			GetAccount(gomock.Any(), userAcctID).
 			Return(&bankxgo.Account{
 				AcctID: userAcctID,
 				Email:  "correct@email.com",
@@ -258,8 +387,8 @@ func TestValidationMWDeposit(t *testing.T) {
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
-		bal, err := v.Deposit(req)
-		as.NotNil(err)
+		bal, err := v.Deposit(context.Background(), req)
+		as.IsType(bankxgo.ErrForbidden{}, err)
 		as.Nil(bal)
 	})
 
@@ -270,7 +399,7 @@ func TestValidationMWDeposit(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := ""
@@ -279,7 +408,7 @@ func TestValidationMWDeposit(t *testing.T) {
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
-		bal, err := v.Deposit(req)
+		bal, err := v.Deposit(context.Background(), req)
 		as.NotNil(err)
 		as.Nil(bal)
 	})
@@ -291,7 +420,7 @@ func TestValidationMWDeposit(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := "negative@amount.com"
@@ -300,10 +429,157 @@ func TestValidationMWDeposit(t *testing.T) {
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
-		bal, err := v.Deposit(dep)
+		bal, err := v.Deposit(context.Background(), dep)
 		as.NotNil(err)
 		as.Nil(bal)
 	})
+	t.Run("returns error on an oversized memo", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(nil, nil))(svc)
+		metadata := map[string]any{}
+		for i := 0; i <= bankxgo.MaxMetadataKeys; i++ {
+			metadata[strconv.Itoa(i)] = i
+		}
+		req := bankxgo.ChargeReq{
+			Amount: decimal.NewFromInt(123),
+			AcctID: snowflake.ParseInt64(7241722241547767808),
+			Email:  "user@email.com",
+			Memo: bankxgo.Memo{
+				Description: strings.Repeat("x", bankxgo.MaxDescriptionLen+1),
+				Reference:   strings.Repeat("é", bankxgo.MaxReferenceLen),
+				Metadata:    metadata,
+			},
+		}
+		bal, err := v.Deposit(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{
+			"description": "longer than 140 characters",
+			"metadata":    "more than 20 keys",
+		}}, err)
+		as.Nil(bal)
+
+		req.Memo = bankxgo.Memo{Metadata: map[string]any{"note": strings.Repeat("x", bankxgo.MaxMetadataSize)}}
+		_, err = v.Deposit(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"metadata": "larger than 2048 bytes"}}, err)
+	})
+}
+
+func TestValidationMWPending(t *testing.T) {
+	t.Run("requires a reference of pending deposits and rejects pending withdrawals", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(nil, nil))(svc)
+		req := bankxgo.ChargeReq{
+			Amount:  decimal.NewFromInt(123),
+			Pending: true,
+			AcctID:  snowflake.ParseInt64(7241722241547767808),
+			Email:   "user@email.com",
+		}
+		bal, err := v.Deposit(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"reference": "required for pending deposits"}}, err)
+		as.Nil(bal)
+
+		req.Reference = "WIRE-0001"
+		rcpt, err := v.Withdraw(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"pending": "deposits only"}}, err)
+		as.Nil(rcpt)
+	})
+}
+
+func TestValidationMWSettle(t *testing.T) {
+	t.Run("returns error on an invalid status or reference", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(nil, nil))(svc)
+
+		req := bankxgo.SettleReq{
+			AcctID:    snowflake.ParseInt64(7241722241547767808),
+			Reference: "WIRE-0001",
+			Status:    bankxgo.TxnPending,
+		}
+		txn, err := v.Settle(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"status": "must be posted or failed"}}, err)
+		as.Nil(txn)
+
+		req.Status = bankxgo.TxnFailed
+		req.Reference = ""
+		_, err = v.Settle(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"reference": "required"}}, err)
+	})
+
+	t.Run("returns error on a system account", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
+		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		req := bankxgo.SettleReq{
+			AcctID:    usdSysAcct,
+			Reference: "WIRE-0001",
+			Status:    bankxgo.TxnPosted,
+		}
+		_, err := v.Settle(context.Background(), req)
+		as.ErrorAs(err, &bankxgo.ErrForbidden{})
+	})
+}
+
+func TestValidationMWListTransactions(t *testing.T) {
+	t.Run("returns error on an invalid page", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(nil, nil))(svc)
+
+		req := bankxgo.TransactionsReq{
+			AcctID: snowflake.ParseInt64(7241722241547767808),
+			Email:  "user@email.com",
+			TransactionFilter: bankxgo.TransactionFilter{
+				Before: -1,
+				Limit:  bankxgo.MaxTransactionsLimit + 1,
+			},
+		}
+		txns, err := v.ListTransactions(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"before": "negative"}}, err)
+		as.Nil(txns)
+
+		req.Before = 0
+		_, err = v.ListTransactions(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"limit": "must be between 1 and 500"}}, err)
+	})
+
+	t.Run("passes the filter through for the account holder", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(nil, nil))(svc)
+
+		userAcctID := snowflake.ParseInt64(7241722241547767808)
+		req := bankxgo.TransactionsReq{
+			AcctID:            userAcctID,
+			Email:             "user@email.com",
+			TransactionFilter: bankxgo.TransactionFilter{Reference: "INV-1234"},
+		}
+		repo.EXPECT().
+			GetAccount(gomock.Any(), userAcctID).
+			Return(&bankxgo.Account{AcctID: userAcctID, Email: "user@email.com"}, nil)
+		svc.EXPECT().
+			ListTransactions(gomock.Any(), req).
+			Return([]bankxgo.Transaction{}, nil)
+		txns, err := v.ListTransactions(context.Background(), req)
+		as.Nil(err)
+		as.Empty(txns)
+	})
 }
 
 func TestValidationMWBalance(t *testing.T) {
@@ -314,17 +590,17 @@ func TestValidationMWBalance(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := "noaccount@bank.com"
 		repo.EXPECT().
-			GetAccount(userAcctID).
+			GetAccount(gomock.Any(), userAcctID).
 			Return(nil, bankxgo.ErrNotFound{ID: userAcctID.Int64()})
 		req := bankxgo.BalanceReq{
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
-		bal, err := v.Balance(req)
+		bal, err := v.Balance(context.Background(), req)
 		as.NotNil(err)
 		as.ErrorAs(err, &bankxgo.ErrNotFound{})
 		as.Nil(bal)
@@ -337,12 +613,12 @@ func TestValidationMWBalance(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := "mismatched@email.com"
 		repo.EXPECT().
-			GetAccount(userAcctID).
+			GetAccount(gomock.Any(), userAcctID).
 			Return(&bankxgo.Account{
 				AcctID: userAcctID,
 				Email:  "correct@email.com",
@@ -351,7 +627,7 @@ func TestValidationMWBalance(t *testing.T) {
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
-		bal, err := v.Balance(req)
+		bal, err := v.Balance(context.Background(), req)
 		as.NotNil(err)
 		as.Nil(bal)
 	})
@@ -363,7 +639,7 @@ func TestValidationMWBalance(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := ""
@@ -371,12 +647,278 @@ func TestValidationMWBalance(t *testing.T) {
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
-		bal, err := v.Balance(req)
+		bal, err := v.Balance(context.Background(), req)
 		as.NotNil(err)
 		as.Nil(bal)
 	})
 }
 
+func TestValidationMWBalanceEvents(t *testing.T) {
+	t.Run("returns forbidden on mismatched email", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
+		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		userAcctID := snowflake.ParseInt64(7241722241547767808)
+		repo.EXPECT().
+			GetAccount(gomock.Any(), userAcctID).
+			Return(&bankxgo.Account{
+				AcctID: userAcctID,
+				Email:  "correct@email.com",
+			}, nil)
+		req := bankxgo.BalanceEventsReq{
+			AcctID: userAcctID,
+			Email:  "mismatched@email.com",
+		}
+		events, err := v.BalanceEvents(context.Background(), req)
+		as.ErrorAs(err, &bankxgo.ErrForbidden{})
+		as.Nil(events)
+	})
+
+	t.Run("returns error on a negative Last-Event-ID", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(nil, nil))(svc)
+
+		req := bankxgo.BalanceEventsReq{
+			AcctID:      snowflake.ParseInt64(7241722241547767808),
+			Email:       "user@email.com",
+			LastEventID: -1,
+		}
+		_, err := v.BalanceEvents(context.Background(), req)
+		as.ErrorAs(err, &bankxgo.ErrBadRequest{})
+	})
+}
+
+func TestValidationMWPostBatch(t *testing.T) {
+	usdSysAcct := snowflake.ParseInt64(7241720446024945664)
+	sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
+	userAcctID := snowflake.ParseInt64(7241722241547767808)
+
+	t.Run("returns error on malformed items", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		req := bankxgo.BatchReq{
+			Key: "payroll-2024-09",
+			Items: []bankxgo.BatchItemReq{
+				{Type: "transfer", AcctID: userAcctID, Email: "user@bank.com", Amount: decimal.New(1, 0)},
+				{Type: bankxgo.BatchDeposit, AcctID: userAcctID, Amount: decimal.New(-1, 0)},
+			},
+		}
+		_, err := v.PostBatch(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{
+			"items[0].type":   "unsupported",
+			"items[1].amount": "negative",
+			"items[1].email":  "missing/invalid",
+		}}, err)
+
+		req.Key = ""
+		_, err = v.PostBatch(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"Idempotency-Key": "missing"}}, err)
+	})
+
+	t.Run("returns forbidden when an item is of another account", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		repo.EXPECT().
+			GetAccount(gomock.Any(), userAcctID).
+			Return(&bankxgo.Account{AcctID: userAcctID, Email: "user@bank.com", Currency: "USD"}, nil)
+		req := bankxgo.BatchReq{
+			Key: "payroll-2024-09",
+			Items: []bankxgo.BatchItemReq{
+				{Type: bankxgo.BatchDeposit, AcctID: userAcctID, Email: "user@bank.com", Amount: decimal.New(1, 0)},
+				{Type: bankxgo.BatchDeposit, AcctID: userAcctID, Email: "other@bank.com", Amount: decimal.New(1, 0)},
+			},
+		}
+		_, err := v.PostBatch(context.Background(), req)
+		as.Equal(bankxgo.ErrForbidden{Reason: "items[1]: email does not match the account"}, err)
+	})
+
+	t.Run("defaults to atomic and sets the currency of the items", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		// looked up once however many items it has
+		repo.EXPECT().
+			GetAccount(gomock.Any(), userAcctID).
+			Return(&bankxgo.Account{AcctID: userAcctID, Email: "user@bank.com", Currency: "USD"}, nil).
+			Times(1)
+		svc.EXPECT().
+			PostBatch(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req bankxgo.BatchReq) (*bankxgo.Batch, error) {
+				as.Equal(bankxgo.BatchAtomic, req.Mode)
+				for _, it := range req.Items {
+					as.Equal("USD", it.Currency)
+				}
+				return &bankxgo.Batch{}, nil
+			})
+		req := bankxgo.BatchReq{
+			Key: "payroll-2024-09",
+			Items: []bankxgo.BatchItemReq{
+				{Type: bankxgo.BatchDeposit, AcctID: userAcctID, Email: "user@bank.com", Amount: decimal.New(1, 0)},
+				{Type: bankxgo.BatchWithdrawal, AcctID: userAcctID, Email: "user@bank.com", Amount: decimal.New(1, 0)},
+			},
+		}
+		_, err := v.PostBatch(context.Background(), req)
+		as.Nil(err)
+	})
+}
+
+func TestValidationMWScheduledPayment(t *testing.T) {
+	usdSysAcct := snowflake.ParseInt64(7241720446024945664)
+	sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
+	payer := snowflake.ParseInt64(7241722241547767808)
+	payee := snowflake.ParseInt64(7241722241547767809)
+	paymentID := snowflake.ParseInt64(7241722241547767810)
+	spec := bankxgo.ScheduledPaymentSpec{
+		ToAcctID: payee,
+		Amount:   decimal.New(250, 0),
+		Rule:     bankxgo.ScheduleRule{Cron: "0 9 1 * *"},
+	}
+
+	t.Run("returns error on a malformed payment", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		start := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+		end := start.Add(-time.Hour)
+		req := bankxgo.ScheduledPaymentReq{
+			AcctID: payer,
+			Email:  "payer@bank.com",
+			ScheduledPaymentSpec: bankxgo.ScheduledPaymentSpec{
+				ToAcctID:            payer,
+				Amount:              decimal.Zero,
+				Rule:                bankxgo.ScheduleRule{Every: "fortnight"},
+				StartAt:             start,
+				EndAt:               &end,
+				OnInsufficientFunds: "overdraw",
+			},
+		}
+		_, err := v.CreateScheduledPayment(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{
+			"rule.every":          "unsupported",
+			"amount":              "not positive",
+			"toAcctID":            "same as the account",
+			"endAt":               "before startAt",
+			"onInsufficientFunds": "unsupported",
+		}}, err)
+	})
+
+	t.Run("returns error when the accounts differ in currency", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		repo.EXPECT().
+			GetAccount(gomock.Any(), payer).
+			Return(&bankxgo.Account{AcctID: payer, Email: "payer@bank.com", Currency: "USD"}, nil)
+		repo.EXPECT().
+			GetAccount(gomock.Any(), payee).
+			Return(&bankxgo.Account{AcctID: payee, Email: "payee@bank.com", Currency: "EUR"}, nil)
+		req := bankxgo.ScheduledPaymentReq{AcctID: payer, Email: "payer@bank.com", ScheduledPaymentSpec: spec}
+		_, err := v.CreateScheduledPayment(context.Background(), req)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"toAcctID": "currency does not match the account"}}, err)
+	})
+
+	t.Run("returns forbidden when paying a system account", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		toSys := spec
+		toSys.ToAcctID = usdSysAcct
+		req := bankxgo.ScheduledPaymentReq{AcctID: payer, Email: "payer@bank.com", ScheduledPaymentSpec: toSys}
+		_, err := v.CreateScheduledPayment(context.Background(), req)
+		assert.Equal(tt, bankxgo.ErrForbidden{Reason: "system account"}, err)
+	})
+
+	t.Run("defaults to skipping on insufficient funds", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		repo.EXPECT().
+			GetAccount(gomock.Any(), payer).
+			Return(&bankxgo.Account{AcctID: payer, Email: "payer@bank.com", Currency: "USD"}, nil)
+		repo.EXPECT().
+			GetAccount(gomock.Any(), payee).
+			Return(&bankxgo.Account{AcctID: payee, Email: "payee@bank.com", Currency: "USD"}, nil)
+		svc.EXPECT().
+			CreateScheduledPayment(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req bankxgo.ScheduledPaymentReq) (*bankxgo.ScheduledPayment, error) {
+				as.Equal(bankxgo.ScheduleSkip, req.OnInsufficientFunds)
+				return &bankxgo.ScheduledPayment{}, nil
+			})
+		req := bankxgo.ScheduledPaymentReq{AcctID: payer, Email: "payer@bank.com", ScheduledPaymentSpec: spec}
+		_, err := v.CreateScheduledPayment(context.Background(), req)
+		as.Nil(err)
+	})
+
+	t.Run("returns not found for the payment of another account", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		repo.EXPECT().
+			GetAccount(gomock.Any(), payer).
+			Return(&bankxgo.Account{AcctID: payer, Email: "payer@bank.com", Currency: "USD"}, nil).
+			Times(2)
+		repo.EXPECT().
+			GetScheduledPayment(gomock.Any(), paymentID).
+			Return(&bankxgo.ScheduledPayment{ID: paymentID, AcctID: payee}, nil).
+			Times(2)
+		req := bankxgo.ScheduledPaymentReqByID{AcctID: payer, PaymentID: paymentID, Email: "payer@bank.com"}
+		_, err := v.CancelScheduledPayment(context.Background(), req)
+		as.Equal(bankxgo.ErrNotFound{ID: paymentID.Int64()}, err)
+		_, err = v.ListScheduledPaymentRuns(context.Background(), req)
+		as.Equal(bankxgo.ErrNotFound{ID: paymentID.Int64()}, err)
+	})
+
+	t.Run("returns error when updating a cancelled payment", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
+
+		repo.EXPECT().
+			GetAccount(gomock.Any(), payer).
+			Return(&bankxgo.Account{AcctID: payer, Email: "payer@bank.com", Currency: "USD"}, nil)
+		repo.EXPECT().
+			GetScheduledPayment(gomock.Any(), paymentID).
+			Return(&bankxgo.ScheduledPayment{ID: paymentID, AcctID: payer, Status: bankxgo.ScheduledPaymentCancelled}, nil)
+		req := bankxgo.ScheduledPaymentReq{AcctID: payer, PaymentID: paymentID, Email: "payer@bank.com", ScheduledPaymentSpec: spec}
+		_, err := v.UpdateScheduledPayment(context.Background(), req)
+		assert.Equal(tt, bankxgo.ErrBadRequest{Fields: map[string]string{"paymentID": "cancelled"}}, err)
+	})
+}
+
 func TestValidationMWStatement(t *testing.T) {
 	t.Run("returns error on non-existent account", func(tt *testing.T) {
 		as := assert.New(tt)
@@ -385,18 +927,18 @@ func TestValidationMWStatement(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := "noaccount@bank.com"
 		repo.EXPECT().
-			GetAccount(userAcctID).
+			GetAccount(gomock.Any(), userAcctID).
 			Return(nil, bankxgo.ErrNotFound{ID: userAcctID.Int64()})
 		req := bankxgo.StatementReq{
 			AcctID: userAcctID,
 			Email:  userEmail,
 		}
 		w := &bytes.Buffer{}
-		err := v.Statement(w, req)
+		err := v.Statement(context.Background(), w, req)
 		as.NotNil(err)
 		as.ErrorAs(err, &bankxgo.ErrNotFound{})
 	})
@@ -408,12 +950,12 @@ func TestValidationMWStatement(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := "mismatched@email.com"
 		repo.EXPECT().
-			GetAccount(userAcctID).
+			GetAccount(gomock.Any(), userAcctID).
 			Return(&bankxgo.Account{
 				AcctID: userAcctID,
 				Email:  "correct@email.com",
@@ -423,7 +965,7 @@ func TestValidationMWStatement(t *testing.T) {
 			Email:  userEmail,
 		}
 		w := &bytes.Buffer{}
-		err := v.Statement(w, req)
+		err := v.Statement(context.Background(), w, req)
 		as.NotNil(err)
 	})
 
@@ -434,7 +976,7 @@ func TestValidationMWStatement(t *testing.T) {
 		svc := mocks.NewMockService(ctrl)
 		usdSysAcct := snowflake.ParseInt64(7241720446024945664)
 		sysAccts := map[string]snowflake.ID{"USD": usdSysAcct}
-		v := bankxgo.NewValidationMiddleware(repo, sysAccts)(svc)
+		v := bankxgo.NewValidationMiddleware(repo, bankxgo.NewSystemAccounts(sysAccts, nil))(svc)
 
 		userAcctID := snowflake.ParseInt64(7241722241547767808)
 		userEmail := ""
@@ -443,7 +985,291 @@ func TestValidationMWStatement(t *testing.T) {
 			Email:  userEmail,
 		}
 		w := &bytes.Buffer{}
-		err := v.Statement(w, req)
+		err := v.Statement(context.Background(), w, req)
 		as.NotNil(err)
 	})
 }
+
+func TestLimitMWWithdraw(t *testing.T) {
+	cfg := &bankxgo.ServiceLimitsCfg{
+		Withdraw: bankxgo.EndpointLimitCfg{
+			SloMs:      10,
+			Rate:       1000,
+			Burst:      1000,
+			PerAccount: bankxgo.KeyedLimitCfg{Rate: 1, Burst: 1, MaxKeys: 10},
+			PerClient:  bankxgo.KeyedLimitCfg{Rate: 1, Burst: 2, MaxKeys: 10},
+		},
+	}
+
+	t.Run("returns ErrRateLimited once an account exhausts its budget", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		rcpt := bankxgo.Receipt{Balance: decimal.NewFromInt(100)}
+		svc.EXPECT().
+			Withdraw(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.ChargeReq{})).
+			Return(&rcpt, nil).
+			Times(2)
+		limits, err := bankxgo.NewServiceLimits(cfg)
+		as.Nil(err)
+		l := bankxgo.NewlimitMiddleware(limits)(svc)
+
+		abuser := snowflake.ParseInt64(7241722241547767808)
+		req := bankxgo.ChargeReq{Amount: decimal.NewFromInt(1), AcctID: abuser, Client: "a"}
+		_, err = l.Withdraw(context.Background(), req)
+		as.Nil(err)
+		_, err = l.Withdraw(context.Background(), req)
+		as.ErrorAs(err, &bankxgo.ErrRateLimited{})
+
+		// other accounts are not affected
+		other := bankxgo.ChargeReq{Amount: decimal.NewFromInt(1), AcctID: abuser + 1, Client: "b"}
+		_, err = l.Withdraw(context.Background(), other)
+		as.Nil(err)
+	})
+
+	t.Run("returns ErrRateLimited once a client exhausts its budget", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		rcpt := bankxgo.Receipt{Balance: decimal.NewFromInt(100)}
+		svc.EXPECT().
+			Withdraw(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.ChargeReq{})).
+			Return(&rcpt, nil).
+			Times(2)
+		limits, err := bankxgo.NewServiceLimits(cfg)
+		as.Nil(err)
+		l := bankxgo.NewlimitMiddleware(limits)(svc)
+
+		for i := int64(0); i < 2; i++ {
+			req := bankxgo.ChargeReq{Amount: decimal.NewFromInt(1), AcctID: snowflake.ID(100 + i), Client: "10.0.0.1"}
+			_, err := l.Withdraw(context.Background(), req)
+			as.Nil(err)
+		}
+		req := bankxgo.ChargeReq{Amount: decimal.NewFromInt(1), AcctID: snowflake.ID(200), Client: "10.0.0.1"}
+		_, err = l.Withdraw(context.Background(), req)
+		rlerr := bankxgo.ErrRateLimited{}
+		as.ErrorAs(err, &rlerr)
+		as.Greater(rlerr.RetryAfter, time.Duration(0))
+	})
+}
+
+func TestLimitMWPostBatch(t *testing.T) {
+	cfg := &bankxgo.ServiceLimitsCfg{
+		Deposit: bankxgo.EndpointLimitCfg{
+			SloMs:      10,
+			Rate:       1000,
+			Burst:      1000,
+			PerAccount: bankxgo.KeyedLimitCfg{Rate: 1, Burst: 2, MaxKeys: 10},
+		},
+		Withdraw: bankxgo.EndpointLimitCfg{
+			SloMs:      10,
+			Rate:       1000,
+			Burst:      1000,
+			PerAccount: bankxgo.KeyedLimitCfg{Rate: 1, Burst: 1, MaxKeys: 10},
+		},
+		Batches: bankxgo.EndpointLimitCfg{SloMs: 10, Rate: 1000, Burst: 1000},
+	}
+	acct := snowflake.ParseInt64(7241722241547767808)
+
+	t.Run("charges items against the per account limits", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		svc.EXPECT().
+			PostBatch(gomock.Any(), gomock.Any()).
+			Return(&bankxgo.Batch{}, nil)
+		limits, err := bankxgo.NewServiceLimits(cfg)
+		as.Nil(err)
+		l := bankxgo.NewlimitMiddleware(limits)(svc)
+
+		withdrawal := bankxgo.BatchItemReq{Type: bankxgo.BatchWithdrawal, AcctID: acct, Amount: decimal.NewFromInt(1)}
+		_, err = l.PostBatch(context.Background(), bankxgo.BatchReq{Items: []bankxgo.BatchItemReq{withdrawal}, Client: "a"})
+		as.Nil(err)
+
+		// the account spent its only withdrawal
+		_, err = l.Withdraw(context.Background(), bankxgo.ChargeReq{Amount: decimal.NewFromInt(1), AcctID: acct})
+		as.ErrorAs(err, &bankxgo.ErrRateLimited{})
+	})
+
+	t.Run("rejects batches with more items of an account than its budget", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		bal := decimal.NewFromInt(1)
+		svc.EXPECT().
+			Deposit(gomock.Any(), gomock.Any()).
+			Return(&bal, nil).
+			Times(2)
+		limits, err := bankxgo.NewServiceLimits(cfg)
+		as.Nil(err)
+		l := bankxgo.NewlimitMiddleware(limits)(svc)
+
+		deposit := bankxgo.BatchItemReq{Type: bankxgo.BatchDeposit, AcctID: acct, Amount: decimal.NewFromInt(1)}
+		items := []bankxgo.BatchItemReq{deposit, deposit, deposit}
+		_, err = l.PostBatch(context.Background(), bankxgo.BatchReq{Items: items, Client: "a"})
+		as.ErrorAs(err, &bankxgo.ErrRateLimited{})
+
+		// the rejected batch gave back the tokens of its items
+		for i := 0; i < 2; i++ {
+			_, err = l.Deposit(context.Background(), bankxgo.ChargeReq{Amount: decimal.NewFromInt(1), AcctID: acct})
+			as.Nil(err)
+		}
+	})
+}
+
+func TestLimitMWBalanceEvents(t *testing.T) {
+	cfg := &bankxgo.ServiceLimitsCfg{
+		BalanceEvents:       bankxgo.EndpointLimitCfg{SloMs: 10, Rate: 1000, Burst: 1000},
+		BalanceEventStreams: bankxgo.StreamLimitCfg{PerAccount: 1},
+	}
+	acct := snowflake.ParseInt64(7241722241547767808)
+
+	t.Run("caps the streams an account holds open until they close", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		first := make(chan bankxgo.BalanceEvent, 1)
+		svc.EXPECT().
+			BalanceEvents(gomock.Any(), gomock.Any()).
+			Return(first, nil)
+		limits, err := bankxgo.NewServiceLimits(cfg)
+		as.Nil(err)
+		l := bankxgo.NewlimitMiddleware(limits)(svc)
+
+		req := bankxgo.BalanceEventsReq{AcctID: acct, Client: "a"}
+		events, err := l.BalanceEvents(context.Background(), req)
+		as.Nil(err)
+		first <- bankxgo.BalanceEvent{ID: 1}
+		as.Equal(int64(1), (<-events).ID)
+
+		_, err = l.BalanceEvents(context.Background(), req)
+		as.ErrorAs(err, &bankxgo.ErrRateLimited{})
+
+		// other accounts are not affected
+		svc.EXPECT().
+			BalanceEvents(gomock.Any(), gomock.Any()).
+			Return(make(chan bankxgo.BalanceEvent), nil)
+		_, err = l.BalanceEvents(context.Background(), bankxgo.BalanceEventsReq{AcctID: acct + 1, Client: "a"})
+		as.Nil(err)
+
+		close(first)
+		_, open := <-events
+		as.False(open)
+		svc.EXPECT().
+			BalanceEvents(gomock.Any(), gomock.Any()).
+			Return(make(chan bankxgo.BalanceEvent), nil)
+		as.Eventually(func() bool {
+			_, err := l.BalanceEvents(context.Background(), req)
+			return err == nil
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestLimitMWAdaptive(t *testing.T) {
+	t.Run("rejects requests above the in-flight cap and backs off on SLO overrun", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		cfg := &bankxgo.ServiceLimitsCfg{
+			Balance: bankxgo.EndpointLimitCfg{
+				SloMs:    5,
+				Strategy: bankxgo.LimitStrategyAIMD,
+				Adaptive: bankxgo.AdaptiveLimitCfg{InitialLimit: 2, MinLimit: 1, MaxLimit: 10, BackoffRatio: 0.5},
+			},
+		}
+		limits, err := bankxgo.NewServiceLimits(cfg)
+		reqrd.Nil(err)
+		l := bankxgo.NewlimitMiddleware(limits)(svc)
+
+		bal := decimal.NewFromInt(1)
+		unblock := make(chan struct{})
+		entered := make(chan struct{}, 2)
+		svc.EXPECT().
+			Balance(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.BalanceReq{})).
+			DoAndReturn(func(_ context.Context, r bankxgo.BalanceReq) (*decimal.Decimal, error) {
+				entered <- struct{}{}
+				<-unblock
+				return &bal, nil
+			}).
+			Times(2)
+
+		done := make(chan struct{}, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				l.Balance(context.Background(), bankxgo.BalanceReq{AcctID: snowflake.ID(i + 1)})
+				done <- struct{}{}
+			}()
+		}
+		<-entered
+		<-entered
+		as.Equal(float64(2), limits.Status()["balance"].Limit)
+		as.Equal(2, limits.Status()["balance"].InFlight)
+
+		_, err = l.Balance(context.Background(), bankxgo.BalanceReq{AcctID: snowflake.ID(3)})
+		as.ErrorAs(err, &bankxgo.ErrRateLimited{})
+
+		time.Sleep(10 * time.Millisecond)
+		close(unblock)
+		<-done
+		<-done
+		as.Equal(float64(1), limits.Status()["balance"].Limit)
+		as.Equal(0, limits.Status()["balance"].InFlight)
+	})
+
+	t.Run("gives back the keyed tokens of requests above the in-flight cap", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		ctrl := gomock.NewController(tt)
+		svc := mocks.NewMockService(ctrl)
+		cfg := &bankxgo.ServiceLimitsCfg{
+			Balance: bankxgo.EndpointLimitCfg{
+				SloMs:      50,
+				Strategy:   bankxgo.LimitStrategyAIMD,
+				Adaptive:   bankxgo.AdaptiveLimitCfg{InitialLimit: 1, MinLimit: 1, MaxLimit: 1},
+				PerAccount: bankxgo.KeyedLimitCfg{Rate: 1, Burst: 1, MaxKeys: 10},
+			},
+		}
+		limits, err := bankxgo.NewServiceLimits(cfg)
+		reqrd.Nil(err)
+		l := bankxgo.NewlimitMiddleware(limits)(svc)
+
+		bal := bankxgo.Balances{Balance: decimal.NewFromInt(1)}
+		unblock := make(chan struct{})
+		entered := make(chan struct{}, 1)
+		svc.EXPECT().
+			Balance(gomock.Any(), bankxgo.BalanceReq{AcctID: snowflake.ID(1)}).
+			DoAndReturn(func(_ context.Context, r bankxgo.BalanceReq) (*bankxgo.Balances, error) {
+				entered <- struct{}{}
+				<-unblock
+				return &bal, nil
+			})
+		svc.EXPECT().
+			Balance(gomock.Any(), bankxgo.BalanceReq{AcctID: snowflake.ID(2)}).
+			Return(&bal, nil)
+
+		done := make(chan struct{})
+		go func() {
+			l.Balance(context.Background(), bankxgo.BalanceReq{AcctID: snowflake.ID(1)})
+			close(done)
+		}()
+		<-entered
+		_, err = l.Balance(context.Background(), bankxgo.BalanceReq{AcctID: snowflake.ID(2)})
+		as.ErrorAs(err, &bankxgo.ErrRateLimited{})
+		close(unblock)
+		<-done
+
+		// the rejected request did not spend the account's only token
+		_, err = l.Balance(context.Background(), bankxgo.BalanceReq{AcctID: snowflake.ID(2)})
+		as.Nil(err)
+	})
+
+	t.Run("returns an error on unknown strategy", func(tt *testing.T) {
+		as := assert.New(tt)
+		cfg := &bankxgo.ServiceLimitsCfg{
+			Deposit: bankxgo.EndpointLimitCfg{Strategy: "vibes"},
+		}
+		_, err := bankxgo.NewServiceLimits(cfg)
+		as.ErrorContains(err, "service_limits.deposit.strategy")
+	})
+}
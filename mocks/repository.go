@@ -10,7 +10,9 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	bankxgo "github.com/arhyth/bankxgo"
 	snowflake "github.com/bwmarrin/snowflake"
@@ -41,76 +43,458 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// BalanceEventsAfter mocks base method.
+func (m *MockRepository) BalanceEventsAfter(ctx context.Context, acctID snowflake.ID, after int64) ([]bankxgo.BalanceEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BalanceEventsAfter", ctx, acctID, after)
+	ret0, _ := ret[0].([]bankxgo.BalanceEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BalanceEventsAfter indicates an expected call of BalanceEventsAfter.
+func (mr *MockRepositoryMockRecorder) BalanceEventsAfter(ctx, acctID, after any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BalanceEventsAfter", reflect.TypeOf((*MockRepository)(nil).BalanceEventsAfter), ctx, acctID, after)
+}
+
+// ClaimStatementJob mocks base method.
+func (m *MockRepository) ClaimStatementJob(ctx context.Context, lease time.Duration, maxAttempts int) (*bankxgo.StatementJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimStatementJob", ctx, lease, maxAttempts)
+	ret0, _ := ret[0].(*bankxgo.StatementJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimStatementJob indicates an expected call of ClaimStatementJob.
+func (mr *MockRepositoryMockRecorder) ClaimStatementJob(ctx, lease, maxAttempts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimStatementJob", reflect.TypeOf((*MockRepository)(nil).ClaimStatementJob), ctx, lease, maxAttempts)
+}
+
+// CompleteStatementJob mocks base method.
+func (m *MockRepository) CompleteStatementJob(ctx context.Context, id snowflake.ID, fileKey string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteStatementJob", ctx, id, fileKey)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteStatementJob indicates an expected call of CompleteStatementJob.
+func (mr *MockRepositoryMockRecorder) CompleteStatementJob(ctx, id, fileKey any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteStatementJob", reflect.TypeOf((*MockRepository)(nil).CompleteStatementJob), ctx, id, fileKey)
+}
+
 // CreateAccount mocks base method.
-func (m *MockRepository) CreateAccount(req bankxgo.CreateAccountReq) error {
+func (m *MockRepository) CreateAccount(ctx context.Context, req bankxgo.CreateAccountReq) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateAccount", req)
+	ret := m.ctrl.Call(m, "CreateAccount", ctx, req)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateAccount indicates an expected call of CreateAccount.
-func (mr *MockRepositoryMockRecorder) CreateAccount(req any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) CreateAccount(ctx, req any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccount", reflect.TypeOf((*MockRepository)(nil).CreateAccount), req)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccount", reflect.TypeOf((*MockRepository)(nil).CreateAccount), ctx, req)
+}
+
+// CreateBatch mocks base method.
+func (m *MockRepository) CreateBatch(ctx context.Context, b bankxgo.Batch) (*bankxgo.Batch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBatch", ctx, b)
+	ret0, _ := ret[0].(*bankxgo.Batch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBatch indicates an expected call of CreateBatch.
+func (mr *MockRepositoryMockRecorder) CreateBatch(ctx, b any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBatch", reflect.TypeOf((*MockRepository)(nil).CreateBatch), ctx, b)
+}
+
+// CreateScheduledPayment mocks base method.
+func (m *MockRepository) CreateScheduledPayment(ctx context.Context, p bankxgo.ScheduledPayment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateScheduledPayment", ctx, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateScheduledPayment indicates an expected call of CreateScheduledPayment.
+func (mr *MockRepositoryMockRecorder) CreateScheduledPayment(ctx, p any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateScheduledPayment", reflect.TypeOf((*MockRepository)(nil).CreateScheduledPayment), ctx, p)
+}
+
+// CreateStatementJob mocks base method.
+func (m *MockRepository) CreateStatementJob(ctx context.Context, job bankxgo.StatementJob) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateStatementJob", ctx, job)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateStatementJob indicates an expected call of CreateStatementJob.
+func (mr *MockRepositoryMockRecorder) CreateStatementJob(ctx, job any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateStatementJob", reflect.TypeOf((*MockRepository)(nil).CreateStatementJob), ctx, job)
+}
+
+// CreateStatementVerification mocks base method.
+func (m *MockRepository) CreateStatementVerification(ctx context.Context, v bankxgo.StatementVerification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateStatementVerification", ctx, v)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateStatementVerification indicates an expected call of CreateStatementVerification.
+func (mr *MockRepositoryMockRecorder) CreateStatementVerification(ctx, v any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateStatementVerification", reflect.TypeOf((*MockRepository)(nil).CreateStatementVerification), ctx, v)
 }
 
 // CreditUser mocks base method.
-func (m *MockRepository) CreditUser(amount decimal.Decimal, userAcct, systemAcct snowflake.ID) (*decimal.Decimal, error) {
+func (m *MockRepository) CreditUser(ctx context.Context, amount decimal.Decimal, userAcct, systemAcct snowflake.ID, limits bankxgo.WithdrawalLimits, fee bankxgo.Fee, memo bankxgo.Memo) (*decimal.Decimal, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreditUser", amount, userAcct, systemAcct)
+	ret := m.ctrl.Call(m, "CreditUser", ctx, amount, userAcct, systemAcct, limits, fee, memo)
 	ret0, _ := ret[0].(*decimal.Decimal)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreditUser indicates an expected call of CreditUser.
-func (mr *MockRepositoryMockRecorder) CreditUser(amount, userAcct, systemAcct any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) CreditUser(ctx, amount, userAcct, systemAcct, limits, fee, memo any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreditUser", reflect.TypeOf((*MockRepository)(nil).CreditUser), amount, userAcct, systemAcct)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreditUser", reflect.TypeOf((*MockRepository)(nil).CreditUser), ctx, amount, userAcct, systemAcct, limits, fee, memo)
 }
 
 // DebitUser mocks base method.
-func (m *MockRepository) DebitUser(amount decimal.Decimal, userAcct, systemAcct snowflake.ID) (*decimal.Decimal, error) {
+func (m *MockRepository) DebitUser(ctx context.Context, amount decimal.Decimal, userAcct, systemAcct snowflake.ID, memo bankxgo.Memo) (*decimal.Decimal, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DebitUser", amount, userAcct, systemAcct)
+	ret := m.ctrl.Call(m, "DebitUser", ctx, amount, userAcct, systemAcct, memo)
 	ret0, _ := ret[0].(*decimal.Decimal)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DebitUser indicates an expected call of DebitUser.
-func (mr *MockRepositoryMockRecorder) DebitUser(amount, userAcct, systemAcct any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) DebitUser(ctx, amount, userAcct, systemAcct, memo any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DebitUser", reflect.TypeOf((*MockRepository)(nil).DebitUser), ctx, amount, userAcct, systemAcct, memo)
+}
+
+// DebitUserPending mocks base method.
+func (m *MockRepository) DebitUserPending(ctx context.Context, amount decimal.Decimal, userAcct, systemAcct snowflake.ID, memo bankxgo.Memo) (*decimal.Decimal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DebitUserPending", ctx, amount, userAcct, systemAcct, memo)
+	ret0, _ := ret[0].(*decimal.Decimal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DebitUserPending indicates an expected call of DebitUserPending.
+func (mr *MockRepositoryMockRecorder) DebitUserPending(ctx, amount, userAcct, systemAcct, memo any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DebitUserPending", reflect.TypeOf((*MockRepository)(nil).DebitUserPending), ctx, amount, userAcct, systemAcct, memo)
+}
+
+// FailStatementJob mocks base method.
+func (m *MockRepository) FailStatementJob(ctx context.Context, id snowflake.ID, errMsg string, retry bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailStatementJob", ctx, id, errMsg, retry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailStatementJob indicates an expected call of FailStatementJob.
+func (mr *MockRepositoryMockRecorder) FailStatementJob(ctx, id, errMsg, retry any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailStatementJob", reflect.TypeOf((*MockRepository)(nil).FailStatementJob), ctx, id, errMsg, retry)
+}
+
+// FindDoneStatementJob mocks base method.
+func (m *MockRepository) FindDoneStatementJob(ctx context.Context, acctID snowflake.ID, format string, from, to time.Time) (*bankxgo.StatementJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindDoneStatementJob", ctx, acctID, format, from, to)
+	ret0, _ := ret[0].(*bankxgo.StatementJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDoneStatementJob indicates an expected call of FindDoneStatementJob.
+func (mr *MockRepositoryMockRecorder) FindDoneStatementJob(ctx, acctID, format, from, to any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DebitUser", reflect.TypeOf((*MockRepository)(nil).DebitUser), amount, userAcct, systemAcct)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDoneStatementJob", reflect.TypeOf((*MockRepository)(nil).FindDoneStatementJob), ctx, acctID, format, from, to)
 }
 
 // GetAccount mocks base method.
-func (m *MockRepository) GetAccount(id snowflake.ID) (*bankxgo.Account, error) {
+func (m *MockRepository) GetAccount(ctx context.Context, id snowflake.ID) (*bankxgo.Account, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccount", id)
+	ret := m.ctrl.Call(m, "GetAccount", ctx, id)
 	ret0, _ := ret[0].(*bankxgo.Account)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAccount indicates an expected call of GetAccount.
-func (mr *MockRepositoryMockRecorder) GetAccount(id any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetAccount(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockRepository)(nil).GetAccount), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockRepository)(nil).GetAccount), ctx, id)
 }
 
 // GetAccountCharges mocks base method.
-func (m *MockRepository) GetAccountCharges(id snowflake.ID) ([]bankxgo.Charge, error) {
+func (m *MockRepository) GetAccountCharges(ctx context.Context, id snowflake.ID) ([]bankxgo.Charge, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccountCharges", id)
+	ret := m.ctrl.Call(m, "GetAccountCharges", ctx, id)
 	ret0, _ := ret[0].([]bankxgo.Charge)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAccountCharges indicates an expected call of GetAccountCharges.
-func (mr *MockRepositoryMockRecorder) GetAccountCharges(id any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetAccountCharges(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountCharges", reflect.TypeOf((*MockRepository)(nil).GetAccountCharges), ctx, id)
+}
+
+// GetBatch mocks base method.
+func (m *MockRepository) GetBatch(ctx context.Context, id snowflake.ID) (*bankxgo.Batch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBatch", ctx, id)
+	ret0, _ := ret[0].(*bankxgo.Batch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBatch indicates an expected call of GetBatch.
+func (mr *MockRepositoryMockRecorder) GetBatch(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBatch", reflect.TypeOf((*MockRepository)(nil).GetBatch), ctx, id)
+}
+
+// GetScheduledPayment mocks base method.
+func (m *MockRepository) GetScheduledPayment(ctx context.Context, id snowflake.ID) (*bankxgo.ScheduledPayment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetScheduledPayment", ctx, id)
+	ret0, _ := ret[0].(*bankxgo.ScheduledPayment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetScheduledPayment indicates an expected call of GetScheduledPayment.
+func (mr *MockRepositoryMockRecorder) GetScheduledPayment(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScheduledPayment", reflect.TypeOf((*MockRepository)(nil).GetScheduledPayment), ctx, id)
+}
+
+// GetStatementJob mocks base method.
+func (m *MockRepository) GetStatementJob(ctx context.Context, id snowflake.ID) (*bankxgo.StatementJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatementJob", ctx, id)
+	ret0, _ := ret[0].(*bankxgo.StatementJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatementJob indicates an expected call of GetStatementJob.
+func (mr *MockRepositoryMockRecorder) GetStatementJob(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatementJob", reflect.TypeOf((*MockRepository)(nil).GetStatementJob), ctx, id)
+}
+
+// GetStatementPassword mocks base method.
+func (m *MockRepository) GetStatementPassword(ctx context.Context, acctID snowflake.ID) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatementPassword", ctx, acctID)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatementPassword indicates an expected call of GetStatementPassword.
+func (mr *MockRepositoryMockRecorder) GetStatementPassword(ctx, acctID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatementPassword", reflect.TypeOf((*MockRepository)(nil).GetStatementPassword), ctx, acctID)
+}
+
+// GetStatementPeriod mocks base method.
+func (m *MockRepository) GetStatementPeriod(ctx context.Context, acctID snowflake.ID, to time.Time) (*bankxgo.StatementPeriod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatementPeriod", ctx, acctID, to)
+	ret0, _ := ret[0].(*bankxgo.StatementPeriod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatementPeriod indicates an expected call of GetStatementPeriod.
+func (mr *MockRepositoryMockRecorder) GetStatementPeriod(ctx, acctID, to any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatementPeriod", reflect.TypeOf((*MockRepository)(nil).GetStatementPeriod), ctx, acctID, to)
+}
+
+// GetStatementPreference mocks base method.
+func (m *MockRepository) GetStatementPreference(ctx context.Context, acctID snowflake.ID) (*bankxgo.StatementPreference, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatementPreference", ctx, acctID)
+	ret0, _ := ret[0].(*bankxgo.StatementPreference)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatementPreference indicates an expected call of GetStatementPreference.
+func (mr *MockRepositoryMockRecorder) GetStatementPreference(ctx, acctID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatementPreference", reflect.TypeOf((*MockRepository)(nil).GetStatementPreference), ctx, acctID)
+}
+
+// GetStatementVerification mocks base method.
+func (m *MockRepository) GetStatementVerification(ctx context.Context, code string) (*bankxgo.StatementVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatementVerification", ctx, code)
+	ret0, _ := ret[0].(*bankxgo.StatementVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatementVerification indicates an expected call of GetStatementVerification.
+func (mr *MockRepositoryMockRecorder) GetStatementVerification(ctx, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatementVerification", reflect.TypeOf((*MockRepository)(nil).GetStatementVerification), ctx, code)
+}
+
+// ListScheduledPaymentRuns mocks base method.
+func (m *MockRepository) ListScheduledPaymentRuns(ctx context.Context, paymentID snowflake.ID) ([]bankxgo.ScheduledPaymentRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScheduledPaymentRuns", ctx, paymentID)
+	ret0, _ := ret[0].([]bankxgo.ScheduledPaymentRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScheduledPaymentRuns indicates an expected call of ListScheduledPaymentRuns.
+func (mr *MockRepositoryMockRecorder) ListScheduledPaymentRuns(ctx, paymentID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScheduledPaymentRuns", reflect.TypeOf((*MockRepository)(nil).ListScheduledPaymentRuns), ctx, paymentID)
+}
+
+// ListScheduledPayments mocks base method.
+func (m *MockRepository) ListScheduledPayments(ctx context.Context, acctID snowflake.ID) ([]bankxgo.ScheduledPayment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScheduledPayments", ctx, acctID)
+	ret0, _ := ret[0].([]bankxgo.ScheduledPayment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScheduledPayments indicates an expected call of ListScheduledPayments.
+func (mr *MockRepositoryMockRecorder) ListScheduledPayments(ctx, acctID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScheduledPayments", reflect.TypeOf((*MockRepository)(nil).ListScheduledPayments), ctx, acctID)
+}
+
+// ListStatementPeriods mocks base method.
+func (m *MockRepository) ListStatementPeriods(ctx context.Context, acctID snowflake.ID) ([]bankxgo.StatementPeriod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListStatementPeriods", ctx, acctID)
+	ret0, _ := ret[0].([]bankxgo.StatementPeriod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListStatementPeriods indicates an expected call of ListStatementPeriods.
+func (mr *MockRepositoryMockRecorder) ListStatementPeriods(ctx, acctID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStatementPeriods", reflect.TypeOf((*MockRepository)(nil).ListStatementPeriods), ctx, acctID)
+}
+
+// ListTransactions mocks base method.
+func (m *MockRepository) ListTransactions(ctx context.Context, acctID snowflake.ID, filter bankxgo.TransactionFilter) ([]bankxgo.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransactions", ctx, acctID, filter)
+	ret0, _ := ret[0].([]bankxgo.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransactions indicates an expected call of ListTransactions.
+func (mr *MockRepositoryMockRecorder) ListTransactions(ctx, acctID, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransactions", reflect.TypeOf((*MockRepository)(nil).ListTransactions), ctx, acctID, filter)
+}
+
+// PostBatch mocks base method.
+func (m *MockRepository) PostBatch(ctx context.Context, id snowflake.ID, mode string, postings []bankxgo.BatchPosting) (*bankxgo.Batch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PostBatch", ctx, id, mode, postings)
+	ret0, _ := ret[0].(*bankxgo.Batch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PostBatch indicates an expected call of PostBatch.
+func (mr *MockRepositoryMockRecorder) PostBatch(ctx, id, mode, postings any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostBatch", reflect.TypeOf((*MockRepository)(nil).PostBatch), ctx, id, mode, postings)
+}
+
+// SetStatementPassword mocks base method.
+func (m *MockRepository) SetStatementPassword(ctx context.Context, acctID snowflake.ID, sealed []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetStatementPassword", ctx, acctID, sealed)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetStatementPassword indicates an expected call of SetStatementPassword.
+func (mr *MockRepositoryMockRecorder) SetStatementPassword(ctx, acctID, sealed any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStatementPassword", reflect.TypeOf((*MockRepository)(nil).SetStatementPassword), ctx, acctID, sealed)
+}
+
+// SetStatementPreference mocks base method.
+func (m *MockRepository) SetStatementPreference(ctx context.Context, acctID snowflake.ID, pref bankxgo.StatementPreference) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetStatementPreference", ctx, acctID, pref)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetStatementPreference indicates an expected call of SetStatementPreference.
+func (mr *MockRepositoryMockRecorder) SetStatementPreference(ctx, acctID, pref any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStatementPreference", reflect.TypeOf((*MockRepository)(nil).SetStatementPreference), ctx, acctID, pref)
+}
+
+// SettleTransaction mocks base method.
+func (m *MockRepository) SettleTransaction(ctx context.Context, acctID snowflake.ID, reference, status string) (*bankxgo.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SettleTransaction", ctx, acctID, reference, status)
+	ret0, _ := ret[0].(*bankxgo.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SettleTransaction indicates an expected call of SettleTransaction.
+func (mr *MockRepositoryMockRecorder) SettleTransaction(ctx, acctID, reference, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SettleTransaction", reflect.TypeOf((*MockRepository)(nil).SettleTransaction), ctx, acctID, reference, status)
+}
+
+// UpdateScheduledPayment mocks base method.
+func (m *MockRepository) UpdateScheduledPayment(ctx context.Context, p bankxgo.ScheduledPayment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateScheduledPayment", ctx, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateScheduledPayment indicates an expected call of UpdateScheduledPayment.
+func (mr *MockRepositoryMockRecorder) UpdateScheduledPayment(ctx, p any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountCharges", reflect.TypeOf((*MockRepository)(nil).GetAccountCharges), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateScheduledPayment", reflect.TypeOf((*MockRepository)(nil).UpdateScheduledPayment), ctx, p)
 }
@@ -10,6 +10,7 @@
 package mocks
 
 import (
+	context "context"
 	io "io"
 	reflect "reflect"
 
@@ -42,75 +43,346 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 }
 
 // Balance mocks base method.
-func (m *MockService) Balance(arg0 bankxgo.BalanceReq) (*decimal.Decimal, error) {
+func (m *MockService) Balance(arg0 context.Context, arg1 bankxgo.BalanceReq) (*bankxgo.Balances, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Balance", arg0)
-	ret0, _ := ret[0].(*decimal.Decimal)
+	ret := m.ctrl.Call(m, "Balance", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.Balances)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Balance indicates an expected call of Balance.
-func (mr *MockServiceMockRecorder) Balance(arg0 any) *gomock.Call {
+func (mr *MockServiceMockRecorder) Balance(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Balance", reflect.TypeOf((*MockService)(nil).Balance), arg0, arg1)
+}
+
+// BalanceEvents mocks base method.
+func (m *MockService) BalanceEvents(arg0 context.Context, arg1 bankxgo.BalanceEventsReq) (<-chan bankxgo.BalanceEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BalanceEvents", arg0, arg1)
+	ret0, _ := ret[0].(<-chan bankxgo.BalanceEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BalanceEvents indicates an expected call of BalanceEvents.
+func (mr *MockServiceMockRecorder) BalanceEvents(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Balance", reflect.TypeOf((*MockService)(nil).Balance), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BalanceEvents", reflect.TypeOf((*MockService)(nil).BalanceEvents), arg0, arg1)
+}
+
+// CancelScheduledPayment mocks base method.
+func (m *MockService) CancelScheduledPayment(arg0 context.Context, arg1 bankxgo.ScheduledPaymentReqByID) (*bankxgo.ScheduledPayment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelScheduledPayment", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.ScheduledPayment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelScheduledPayment indicates an expected call of CancelScheduledPayment.
+func (mr *MockServiceMockRecorder) CancelScheduledPayment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelScheduledPayment", reflect.TypeOf((*MockService)(nil).CancelScheduledPayment), arg0, arg1)
 }
 
 // CreateAccount mocks base method.
-func (m *MockService) CreateAccount(arg0 bankxgo.CreateAccountReq) (*bankxgo.Account, error) {
+func (m *MockService) CreateAccount(arg0 context.Context, arg1 bankxgo.CreateAccountReq) (*bankxgo.Account, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateAccount", arg0)
+	ret := m.ctrl.Call(m, "CreateAccount", arg0, arg1)
 	ret0, _ := ret[0].(*bankxgo.Account)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateAccount indicates an expected call of CreateAccount.
-func (mr *MockServiceMockRecorder) CreateAccount(arg0 any) *gomock.Call {
+func (mr *MockServiceMockRecorder) CreateAccount(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccount", reflect.TypeOf((*MockService)(nil).CreateAccount), arg0, arg1)
+}
+
+// CreateScheduledPayment mocks base method.
+func (m *MockService) CreateScheduledPayment(arg0 context.Context, arg1 bankxgo.ScheduledPaymentReq) (*bankxgo.ScheduledPayment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateScheduledPayment", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.ScheduledPayment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateScheduledPayment indicates an expected call of CreateScheduledPayment.
+func (mr *MockServiceMockRecorder) CreateScheduledPayment(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccount", reflect.TypeOf((*MockService)(nil).CreateAccount), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateScheduledPayment", reflect.TypeOf((*MockService)(nil).CreateScheduledPayment), arg0, arg1)
 }
 
 // Deposit mocks base method.
-func (m *MockService) Deposit(arg0 bankxgo.ChargeReq) (*decimal.Decimal, error) {
+func (m *MockService) Deposit(arg0 context.Context, arg1 bankxgo.ChargeReq) (*decimal.Decimal, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Deposit", arg0)
+	ret := m.ctrl.Call(m, "Deposit", arg0, arg1)
 	ret0, _ := ret[0].(*decimal.Decimal)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Deposit indicates an expected call of Deposit.
-func (mr *MockServiceMockRecorder) Deposit(arg0 any) *gomock.Call {
+func (mr *MockServiceMockRecorder) Deposit(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deposit", reflect.TypeOf((*MockService)(nil).Deposit), arg0, arg1)
+}
+
+// GetBatch mocks base method.
+func (m *MockService) GetBatch(arg0 context.Context, arg1 bankxgo.BatchReqByID) (*bankxgo.Batch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBatch", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.Batch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBatch indicates an expected call of GetBatch.
+func (mr *MockServiceMockRecorder) GetBatch(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBatch", reflect.TypeOf((*MockService)(nil).GetBatch), arg0, arg1)
+}
+
+// GetScheduledPayment mocks base method.
+func (m *MockService) GetScheduledPayment(arg0 context.Context, arg1 bankxgo.ScheduledPaymentReqByID) (*bankxgo.ScheduledPayment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetScheduledPayment", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.ScheduledPayment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetScheduledPayment indicates an expected call of GetScheduledPayment.
+func (mr *MockServiceMockRecorder) GetScheduledPayment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScheduledPayment", reflect.TypeOf((*MockService)(nil).GetScheduledPayment), arg0, arg1)
+}
+
+// GetStatementJob mocks base method.
+func (m *MockService) GetStatementJob(arg0 context.Context, arg1 bankxgo.StatementJobReq) (*bankxgo.StatementJob, io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatementJob", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.StatementJob)
+	ret1, _ := ret[1].(io.ReadCloser)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetStatementJob indicates an expected call of GetStatementJob.
+func (mr *MockServiceMockRecorder) GetStatementJob(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatementJob", reflect.TypeOf((*MockService)(nil).GetStatementJob), arg0, arg1)
+}
+
+// GetStatementPeriod mocks base method.
+func (m *MockService) GetStatementPeriod(arg0 context.Context, arg1 bankxgo.StatementPeriodReq) (*bankxgo.StatementPeriod, io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatementPeriod", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.StatementPeriod)
+	ret1, _ := ret[1].(io.ReadCloser)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetStatementPeriod indicates an expected call of GetStatementPeriod.
+func (mr *MockServiceMockRecorder) GetStatementPeriod(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatementPeriod", reflect.TypeOf((*MockService)(nil).GetStatementPeriod), arg0, arg1)
+}
+
+// ListScheduledPaymentRuns mocks base method.
+func (m *MockService) ListScheduledPaymentRuns(arg0 context.Context, arg1 bankxgo.ScheduledPaymentReqByID) ([]bankxgo.ScheduledPaymentRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScheduledPaymentRuns", arg0, arg1)
+	ret0, _ := ret[0].([]bankxgo.ScheduledPaymentRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScheduledPaymentRuns indicates an expected call of ListScheduledPaymentRuns.
+func (mr *MockServiceMockRecorder) ListScheduledPaymentRuns(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScheduledPaymentRuns", reflect.TypeOf((*MockService)(nil).ListScheduledPaymentRuns), arg0, arg1)
+}
+
+// ListScheduledPayments mocks base method.
+func (m *MockService) ListScheduledPayments(arg0 context.Context, arg1 bankxgo.ScheduledPaymentsReq) ([]bankxgo.ScheduledPayment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScheduledPayments", arg0, arg1)
+	ret0, _ := ret[0].([]bankxgo.ScheduledPayment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScheduledPayments indicates an expected call of ListScheduledPayments.
+func (mr *MockServiceMockRecorder) ListScheduledPayments(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScheduledPayments", reflect.TypeOf((*MockService)(nil).ListScheduledPayments), arg0, arg1)
+}
+
+// ListStatementPeriods mocks base method.
+func (m *MockService) ListStatementPeriods(arg0 context.Context, arg1 bankxgo.StatementPeriodsReq) ([]bankxgo.StatementPeriod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListStatementPeriods", arg0, arg1)
+	ret0, _ := ret[0].([]bankxgo.StatementPeriod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListStatementPeriods indicates an expected call of ListStatementPeriods.
+func (mr *MockServiceMockRecorder) ListStatementPeriods(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStatementPeriods", reflect.TypeOf((*MockService)(nil).ListStatementPeriods), arg0, arg1)
+}
+
+// ListTransactions mocks base method.
+func (m *MockService) ListTransactions(arg0 context.Context, arg1 bankxgo.TransactionsReq) ([]bankxgo.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransactions", arg0, arg1)
+	ret0, _ := ret[0].([]bankxgo.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransactions indicates an expected call of ListTransactions.
+func (mr *MockServiceMockRecorder) ListTransactions(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransactions", reflect.TypeOf((*MockService)(nil).ListTransactions), arg0, arg1)
+}
+
+// PostBatch mocks base method.
+func (m *MockService) PostBatch(arg0 context.Context, arg1 bankxgo.BatchReq) (*bankxgo.Batch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PostBatch", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.Batch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PostBatch indicates an expected call of PostBatch.
+func (mr *MockServiceMockRecorder) PostBatch(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostBatch", reflect.TypeOf((*MockService)(nil).PostBatch), arg0, arg1)
+}
+
+// RequestStatement mocks base method.
+func (m *MockService) RequestStatement(arg0 context.Context, arg1 bankxgo.StatementReq) (*bankxgo.StatementJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestStatement", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.StatementJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestStatement indicates an expected call of RequestStatement.
+func (mr *MockServiceMockRecorder) RequestStatement(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deposit", reflect.TypeOf((*MockService)(nil).Deposit), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestStatement", reflect.TypeOf((*MockService)(nil).RequestStatement), arg0, arg1)
+}
+
+// SetStatementPassword mocks base method.
+func (m *MockService) SetStatementPassword(arg0 context.Context, arg1 bankxgo.StatementPasswordReq) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetStatementPassword", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetStatementPassword indicates an expected call of SetStatementPassword.
+func (mr *MockServiceMockRecorder) SetStatementPassword(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStatementPassword", reflect.TypeOf((*MockService)(nil).SetStatementPassword), arg0, arg1)
+}
+
+// SetStatementPreference mocks base method.
+func (m *MockService) SetStatementPreference(arg0 context.Context, arg1 bankxgo.StatementPreferenceReq) (*bankxgo.StatementPreference, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetStatementPreference", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.StatementPreference)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetStatementPreference indicates an expected call of SetStatementPreference.
+func (mr *MockServiceMockRecorder) SetStatementPreference(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStatementPreference", reflect.TypeOf((*MockService)(nil).SetStatementPreference), arg0, arg1)
+}
+
+// Settle mocks base method.
+func (m *MockService) Settle(arg0 context.Context, arg1 bankxgo.SettleReq) (*bankxgo.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Settle", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Settle indicates an expected call of Settle.
+func (mr *MockServiceMockRecorder) Settle(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Settle", reflect.TypeOf((*MockService)(nil).Settle), arg0, arg1)
 }
 
 // Statement mocks base method.
-func (m *MockService) Statement(arg0 io.Writer, arg1 bankxgo.StatementReq) error {
+func (m *MockService) Statement(arg0 context.Context, arg1 io.Writer, arg2 bankxgo.StatementReq) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Statement", arg0, arg1)
+	ret := m.ctrl.Call(m, "Statement", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Statement indicates an expected call of Statement.
-func (mr *MockServiceMockRecorder) Statement(arg0, arg1 any) *gomock.Call {
+func (mr *MockServiceMockRecorder) Statement(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Statement", reflect.TypeOf((*MockService)(nil).Statement), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Statement", reflect.TypeOf((*MockService)(nil).Statement), arg0, arg1, arg2)
+}
+
+// UpdateScheduledPayment mocks base method.
+func (m *MockService) UpdateScheduledPayment(arg0 context.Context, arg1 bankxgo.ScheduledPaymentReq) (*bankxgo.ScheduledPayment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateScheduledPayment", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.ScheduledPayment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateScheduledPayment indicates an expected call of UpdateScheduledPayment.
+func (mr *MockServiceMockRecorder) UpdateScheduledPayment(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateScheduledPayment", reflect.TypeOf((*MockService)(nil).UpdateScheduledPayment), arg0, arg1)
+}
+
+// VerifyStatement mocks base method.
+func (m *MockService) VerifyStatement(arg0 context.Context, arg1 bankxgo.VerifyStatementReq) (*bankxgo.StatementVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyStatement", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.StatementVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyStatement indicates an expected call of VerifyStatement.
+func (mr *MockServiceMockRecorder) VerifyStatement(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyStatement", reflect.TypeOf((*MockService)(nil).VerifyStatement), arg0, arg1)
 }
 
 // Withdraw mocks base method.
-func (m *MockService) Withdraw(arg0 bankxgo.ChargeReq) (*decimal.Decimal, error) {
+func (m *MockService) Withdraw(arg0 context.Context, arg1 bankxgo.ChargeReq) (*bankxgo.Receipt, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Withdraw", arg0)
-	ret0, _ := ret[0].(*decimal.Decimal)
+	ret := m.ctrl.Call(m, "Withdraw", arg0, arg1)
+	ret0, _ := ret[0].(*bankxgo.Receipt)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Withdraw indicates an expected call of Withdraw.
-func (mr *MockServiceMockRecorder) Withdraw(arg0 any) *gomock.Call {
+func (mr *MockServiceMockRecorder) Withdraw(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Withdraw", reflect.TypeOf((*MockService)(nil).Withdraw), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Withdraw", reflect.TypeOf((*MockService)(nil).Withdraw), arg0, arg1)
 }
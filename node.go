@@ -0,0 +1,275 @@
+package bankxgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+)
+
+// Snowflake nodes reserved for the command line tools, server instances are
+// never assigned these
+const (
+	StatementsNode = 1021
+	SeederNode     = 1022
+	AdminNode      = 1023
+	// maxInstanceNode is the highest node of server instances
+	maxInstanceNode = 1020
+)
+
+const (
+	NodeStrategyStatic   = "static"
+	NodeStrategyEnv      = "env"
+	NodeStrategyHostname = "hostname"
+	NodeStrategyLease    = "lease"
+
+	defaultNodeEnv = "BANKXGO_NODE_ID"
+)
+
+// IDGenerator mints unique IDs. It fails once its node may no longer be
+// its own, ie. when the node lease lapsed.
+type IDGenerator interface {
+	Generate() (snowflake.ID, error)
+}
+
+// NodeIDGenerator generates the IDs of a node that is not leased, ie. one of
+// the nodes reserved for the tools. It never fails.
+func NodeIDGenerator(node *snowflake.Node) IDGenerator {
+	return staticNode{node: node}
+}
+
+type staticNode struct {
+	node *snowflake.Node
+}
+
+func (n staticNode) Generate() (snowflake.ID, error) {
+	return n.node.Generate(), nil
+}
+
+// SequenceIDGenerator generates consecutive IDs starting from Next, ie. for
+// tests that need deterministic IDs
+type SequenceIDGenerator struct {
+	mu   sync.Mutex
+	Next int64
+}
+
+func (g *SequenceIDGenerator) Generate() (snowflake.ID, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	id := snowflake.ID(g.Next)
+	g.Next++
+	return id, nil
+}
+
+// NodeID resolves the node of the instance with the static, env or hostname
+// strategy. Nodes of the lease strategy are handed out by AcquireNodeLease.
+func NodeID(cfg NodeCfg) (int64, error) {
+	switch cfg.Strategy {
+	case NodeStrategyStatic, "":
+		return checkNodeID(cfg.ID)
+	case NodeStrategyEnv:
+		env := cfg.Env
+		if env == "" {
+			env = defaultNodeEnv
+		}
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			return 0, fmt.Errorf("node: environment variable %s is not set", env)
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("node: environment variable %s: %w", env, err)
+		}
+		return checkNodeID(id)
+	case NodeStrategyHostname:
+		hostname, err := os.Hostname()
+		if err != nil {
+			return 0, fmt.Errorf("node: %w", err)
+		}
+		return hostnameNode(hostname), nil
+	default:
+		return 0, fmt.Errorf("node: unknown strategy %q", cfg.Strategy)
+	}
+}
+
+func checkNodeID(id int64) (int64, error) {
+	if id < 0 || id > maxInstanceNode {
+		return 0, fmt.Errorf("node: %d is out of range 0-%d", id, maxInstanceNode)
+	}
+	return id, nil
+}
+
+// hostnameNode hashes the hostname to a node. Different hosts may hash to the
+// same node, so prefer leases when running more than a few instances.
+func hostnameNode(hostname string) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(hostname))
+	return int64(h.Sum32() % (maxInstanceNode + 1))
+}
+
+// NodeLeaseStore hands out nodes to running instances, see the `node_leases` table
+type NodeLeaseStore interface {
+	// AcquireNodeLease leases the lowest node up to maxNode that is free or
+	// whose lease expired, ErrServiceUnavailable if every node is taken
+	AcquireNodeLease(ctx context.Context, holder string, ttl time.Duration, maxNode int64) (int64, error)
+	// RenewNodeLease extends the lease, ErrNotFound if the holder lost it
+	RenewNodeLease(ctx context.Context, node int64, holder string, ttl time.Duration) error
+	ReleaseNodeLease(ctx context.Context, node int64, holder string) error
+}
+
+// NodeLease is a node leased by the instance. The lease expires unless renewed
+// by Run, after which another instance may be handed the same node.
+type NodeLease struct {
+	store  NodeLeaseStore
+	node   int64
+	holder string
+	ttl    time.Duration
+	lost   chan struct{}
+	log    *zerolog.Logger
+	// heldUntil is when the lease expires less a margin, in Unix nanoseconds
+	heldUntil atomic.Int64
+}
+
+// AcquireNodeLease leases a node for the holder, which should identify the
+// instance, ie. its hostname and PID
+func AcquireNodeLease(store NodeLeaseStore, holder string, ttl time.Duration, log *zerolog.Logger) (*NodeLease, error) {
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	acquiredAt := time.Now()
+	node, err := store.AcquireNodeLease(context.Background(), holder, ttl, maxInstanceNode)
+	if err != nil {
+		return nil, fmt.Errorf("node: acquiring lease: %w", err)
+	}
+	nl := &NodeLease{
+		store:  store,
+		node:   node,
+		holder: holder,
+		ttl:    ttl,
+		lost:   make(chan struct{}),
+		log:    log,
+	}
+	nl.extend(acquiredAt)
+	return nl, nil
+}
+
+// extend holds the lease until it expires less a third of its duration, as of
+// a renewal started at t. The margin covers clock drift between the instance
+// and the database and the time it takes to stop generating IDs.
+func (nl *NodeLease) extend(t time.Time) {
+	nl.heldUntil.Store(t.Add(nl.ttl - nl.ttl/3).UnixNano())
+}
+
+// Held reports whether the lease is still held with a margin to spare. IDs
+// must not be generated once it is not.
+func (nl *NodeLease) Held() bool {
+	select {
+	case <-nl.lost:
+		return false
+	default:
+	}
+	return time.Now().UnixNano() < nl.heldUntil.Load()
+}
+
+func (nl *NodeLease) Node() int64 {
+	return nl.node
+}
+
+// Lost is closed when the lease was taken over or could not be renewed in time
+// to keep it held, ahead of its expiry. The instance must stop generating IDs
+// then, as the node may be handed out again once it expires.
+func (nl *NodeLease) Lost() <-chan struct{} {
+	return nl.lost
+}
+
+// Run renews the lease every third of its duration until ctx is done, then
+// releases it. It returns early if the lease is lost.
+func (nl *NodeLease) Run(ctx context.Context) {
+	ticker := time.NewTicker(nl.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// ctx is done already, the release gets a context of its own
+			if err := nl.store.ReleaseNodeLease(context.Background(), nl.node, nl.holder); err != nil {
+				nl.log.Err(err).Int64("node", nl.node).Msg("releasing node lease failed")
+			}
+			return
+		case <-ticker.C:
+		}
+
+		renewedAt := time.Now()
+		err := nl.store.RenewNodeLease(ctx, nl.node, nl.holder, nl.ttl)
+		switch {
+		case err == nil:
+			nl.extend(renewedAt)
+			continue
+		case errors.As(err, &ErrNotFound{}):
+			// taken over by another instance
+		case nl.Held():
+			nl.log.Err(err).Int64("node", nl.node).Msg("renewing node lease failed, retrying")
+			continue
+		}
+		nl.log.Error().Err(err).Int64("node", nl.node).Msg("node lease lost")
+		close(nl.lost)
+		return
+	}
+}
+
+// NewIDGenerator returns the snowflake node of the instance as configured. With
+// the lease strategy the lease is returned as well, it must be kept by running
+// it, see NodeLease.Run. The store is only needed for leases.
+func NewIDGenerator(cfg NodeCfg, store NodeLeaseStore, log *zerolog.Logger) (IDGenerator, *NodeLease, error) {
+	var (
+		id    int64
+		lease *NodeLease
+		err   error
+	)
+	if cfg.Strategy == NodeStrategyLease {
+		if store == nil {
+			return nil, nil, errors.New("node: lease strategy needs a lease store")
+		}
+		hostname, _ := os.Hostname()
+		holder := fmt.Sprintf("%s/%d", hostname, os.Getpid())
+		lease, err = AcquireNodeLease(store, holder, time.Duration(cfg.LeaseSec)*time.Second, log)
+		if err != nil {
+			return nil, nil, err
+		}
+		id = lease.Node()
+	} else if id, err = NodeID(cfg); err != nil {
+		return nil, nil, err
+	}
+
+	node, err := snowflake.NewNode(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Info().Int64("node", id).Str("strategy", cfg.Strategy).Msg("snowflake node assigned")
+	if lease != nil {
+		return &leasedNode{node: node, lease: lease}, lease, nil
+	}
+	return NodeIDGenerator(node), nil, nil
+}
+
+// leasedNode generates IDs only while its node lease is held
+type leasedNode struct {
+	node  *snowflake.Node
+	lease *NodeLease
+}
+
+func (n *leasedNode) Generate() (snowflake.ID, error) {
+	// the node may be handed out again, an ID now could collide with one of
+	// the next holder, which is worse than failing the request
+	if !n.lease.Held() {
+		return 0, fmt.Errorf("snowflake node %d: lease lapsed", n.lease.Node())
+	}
+	return n.node.Generate(), nil
+}
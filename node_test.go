@@ -0,0 +1,175 @@
+package bankxgo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+func TestNodeID(t *testing.T) {
+	t.Run("returns the static node", func(tt *testing.T) {
+		id, err := bankxgo.NodeID(bankxgo.NodeCfg{ID: 42})
+		require.Nil(tt, err)
+		assert.Equal(tt, int64(42), id)
+	})
+
+	t.Run("returns error on nodes reserved or out of range", func(tt *testing.T) {
+		for _, id := range []int64{-1, bankxgo.StatementsNode, bankxgo.SeederNode, bankxgo.AdminNode, 4096} {
+			_, err := bankxgo.NodeID(bankxgo.NodeCfg{Strategy: bankxgo.NodeStrategyStatic, ID: id})
+			assert.Error(tt, err, id)
+		}
+	})
+
+	t.Run("reads the node from the environment", func(tt *testing.T) {
+		tt.Setenv("BANKXGO_NODE_ID", "7")
+		id, err := bankxgo.NodeID(bankxgo.NodeCfg{Strategy: bankxgo.NodeStrategyEnv})
+		require.Nil(tt, err)
+		assert.Equal(tt, int64(7), id)
+
+		tt.Setenv("POD_ORDINAL", "not a number")
+		_, err = bankxgo.NodeID(bankxgo.NodeCfg{Strategy: bankxgo.NodeStrategyEnv, Env: "POD_ORDINAL"})
+		assert.Error(tt, err)
+
+		_, err = bankxgo.NodeID(bankxgo.NodeCfg{Strategy: bankxgo.NodeStrategyEnv, Env: "BANKXGO_UNSET_NODE_ID"})
+		assert.Error(tt, err)
+	})
+
+	t.Run("hashes the hostname to the same instance node", func(tt *testing.T) {
+		cfg := bankxgo.NodeCfg{Strategy: bankxgo.NodeStrategyHostname}
+		id, err := bankxgo.NodeID(cfg)
+		require.Nil(tt, err)
+		again, err := bankxgo.NodeID(cfg)
+		require.Nil(tt, err)
+		assert.Equal(tt, id, again)
+		assert.Less(tt, id, int64(bankxgo.StatementsNode))
+	})
+
+	t.Run("returns error on unknown strategy", func(tt *testing.T) {
+		_, err := bankxgo.NodeID(bankxgo.NodeCfg{Strategy: "random"})
+		assert.Error(tt, err)
+	})
+}
+
+func TestNodeLease(t *testing.T) {
+	log := zerolog.Nop()
+	ttl := 30 * time.Millisecond
+
+	t.Run("renews until stopped then releases", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockNodeLeaseStore(ctrl)
+		store.EXPECT().AcquireNodeLease(gomock.Any(), "host/1", ttl, int64(1020)).Return(int64(3), nil)
+		store.EXPECT().RenewNodeLease(gomock.Any(), int64(3), "host/1", ttl).Return(nil).MinTimes(2)
+		store.EXPECT().ReleaseNodeLease(gomock.Any(), int64(3), "host/1").Return(nil)
+
+		lease, err := bankxgo.AcquireNodeLease(store, "host/1", ttl, &log)
+		require.Nil(tt, err)
+		assert.Equal(tt, int64(3), lease.Node())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 4*ttl)
+		defer cancel()
+		lease.Run(ctx)
+		select {
+		case <-lease.Lost():
+			tt.Fatal("lease lost")
+		default:
+		}
+	})
+
+	t.Run("is lost when taken over", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockNodeLeaseStore(ctrl)
+		store.EXPECT().AcquireNodeLease(gomock.Any(), "host/1", ttl, int64(1020)).Return(int64(3), nil)
+		store.EXPECT().RenewNodeLease(gomock.Any(), int64(3), "host/1", ttl).Return(bankxgo.ErrNotFound{ID: 3})
+
+		lease, err := bankxgo.AcquireNodeLease(store, "host/1", ttl, &log)
+		require.Nil(tt, err)
+		lease.Run(context.Background())
+		<-lease.Lost()
+	})
+
+	t.Run("is lost when it cannot be renewed before it expires", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockNodeLeaseStore(ctrl)
+		store.EXPECT().AcquireNodeLease(gomock.Any(), "host/1", ttl, int64(1020)).Return(int64(3), nil)
+		store.EXPECT().RenewNodeLease(gomock.Any(), int64(3), "host/1", ttl).Return(errors.New("connection refused")).MinTimes(2)
+
+		lease, err := bankxgo.AcquireNodeLease(store, "host/1", ttl, &log)
+		require.Nil(tt, err)
+		lease.Run(context.Background())
+		<-lease.Lost()
+	})
+
+	t.Run("is lost a third of its duration before it expires", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockNodeLeaseStore(ctrl)
+		store.EXPECT().AcquireNodeLease(gomock.Any(), "host/1", ttl, int64(1020)).Return(int64(3), nil)
+		store.EXPECT().RenewNodeLease(gomock.Any(), int64(3), "host/1", ttl).Return(errors.New("connection refused")).AnyTimes()
+
+		start := time.Now()
+		lease, err := bankxgo.AcquireNodeLease(store, "host/1", ttl, &log)
+		require.Nil(tt, err)
+		as.True(lease.Held())
+		lease.Run(context.Background())
+		<-lease.Lost()
+		as.False(lease.Held())
+		as.Less(time.Since(start), ttl)
+	})
+}
+
+func TestNewIDGenerator(t *testing.T) {
+	log := zerolog.Nop()
+
+	t.Run("uses the leased node", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockNodeLeaseStore(ctrl)
+		store.EXPECT().
+			AcquireNodeLease(gomock.Any(), gomock.Any(), time.Minute, int64(1020)).
+			Return(int64(5), nil)
+
+		ids, lease, err := bankxgo.NewIDGenerator(bankxgo.NodeCfg{Strategy: bankxgo.NodeStrategyLease}, store, &log)
+		require.Nil(tt, err)
+		require.NotNil(tt, lease)
+		id, err := ids.Generate()
+		require.Nil(tt, err)
+		assert.Equal(tt, int64(5), id.Node())
+	})
+
+	t.Run("stops generating once the lease is lost", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockNodeLeaseStore(ctrl)
+		store.EXPECT().
+			AcquireNodeLease(gomock.Any(), gomock.Any(), time.Second, int64(1020)).
+			Return(int64(5), nil)
+		store.EXPECT().
+			RenewNodeLease(gomock.Any(), int64(5), gomock.Any(), time.Second).
+			Return(bankxgo.ErrNotFound{ID: 5})
+
+		cfg := bankxgo.NodeCfg{Strategy: bankxgo.NodeStrategyLease, LeaseSec: 1}
+		ids, lease, err := bankxgo.NewIDGenerator(cfg, store, &log)
+		require.Nil(tt, err)
+		_, err = ids.Generate()
+		require.Nil(tt, err)
+		lease.Run(context.Background())
+		_, err = ids.Generate()
+		assert.NotNil(tt, err)
+	})
+
+	t.Run("needs no lease otherwise", func(tt *testing.T) {
+		ids, lease, err := bankxgo.NewIDGenerator(bankxgo.NodeCfg{ID: 9}, nil, &log)
+		require.Nil(tt, err)
+		assert.Nil(tt, lease)
+		id, err := ids.Generate()
+		require.Nil(tt, err)
+		assert.Equal(tt, int64(9), id.Node())
+	})
+}
@@ -0,0 +1,780 @@
+package bankxgo
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+// OpenAPISpec is the OpenAPI 3.1 document of the routes of NewHTTPHandler,
+// served at `/openapi.json`
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+// ServeOpenAPI writes OpenAPISpec
+func ServeOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(OpenAPISpec)
+}
+
+// OpenAPIValidationCfg turns on validation against OpenAPISpec, see
+// NewOpenAPIMiddleware
+type OpenAPIValidationCfg struct {
+	// Requests rejects requests that do not conform with 400 Bad Request
+	Requests bool `yaml:"requests"`
+	// Responses logs responses that do not conform, they are sent regardless
+	Responses bool `yaml:"responses"`
+}
+
+// OpenAPIValidator validates requests and responses against an OpenAPI 3.1
+// document. Only the parts of OpenAPI and JSON Schema used by OpenAPISpec are
+// supported: local `$ref`s, path, query and header parameters, JSON bodies and
+// the schema keywords of openAPISchemaKeywords. Documents using anything else
+// are rejected by NewOpenAPIValidator rather than partly validated.
+type OpenAPIValidator struct {
+	routes []*openAPIRoute
+}
+
+type openAPIRoute struct {
+	method   string
+	path     string
+	segments []string
+	params   []*openAPIParameter
+	op       *openAPIOperation
+}
+
+type openAPIDoc struct {
+	Paths      map[string]*openAPIPathItem `json:"paths"`
+	Components struct {
+		Schemas    map[string]*jsonSchema       `json:"schemas"`
+		Parameters map[string]*openAPIParameter `json:"parameters"`
+		Headers    map[string]*openAPIHeader    `json:"headers"`
+		Responses  map[string]*openAPIResponse  `json:"responses"`
+	} `json:"components"`
+}
+
+type openAPIPathItem struct {
+	Parameters []*openAPIParameter `json:"parameters"`
+	Get        *openAPIOperation   `json:"get"`
+	Put        *openAPIOperation   `json:"put"`
+	Post       *openAPIOperation   `json:"post"`
+	Delete     *openAPIOperation   `json:"delete"`
+}
+
+// openAPIPathItemFields are the fields of path items that are supported, or
+// that are only documentation
+var openAPIPathItemFields = map[string]bool{
+	"summary": true, "description": true, "parameters": true,
+	"get": true, "put": true, "post": true, "delete": true,
+}
+
+func (item *openAPIPathItem) UnmarshalJSON(b []byte) error {
+	if err := checkOpenAPIFields(b, openAPIPathItemFields, "operation"); err != nil {
+		return err
+	}
+	type plain openAPIPathItem
+	return json.Unmarshal(b, (*plain)(item))
+}
+
+type openAPIOperation struct {
+	Parameters  []*openAPIParameter         `json:"parameters"`
+	RequestBody *openAPIRequestBody         `json:"requestBody"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Ref      string      `json:"$ref"`
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required"`
+	Schema   *jsonSchema `json:"schema"`
+}
+
+type openAPIHeader struct {
+	Ref      string      `json:"$ref"`
+	Required bool        `json:"required"`
+	Schema   *jsonSchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Ref     string                       `json:"$ref"`
+	Headers map[string]*openAPIHeader    `json:"headers"`
+	Content map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema *jsonSchema `json:"schema"`
+}
+
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 jsonSchemaType         `json:"type"`
+	Enum                 []any                  `json:"enum"`
+	Pattern              string                 `json:"pattern"`
+	Format               string                 `json:"format"`
+	MaxLength            *int                   `json:"maxLength"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+
+	// set by compile
+	ref          *jsonSchema
+	pattern      *regexp.Regexp
+	additional   *jsonSchema
+	noAdditional bool
+}
+
+// openAPISchemaKeywords are the keywords of JSON Schema that are validated,
+// or that are only annotations
+var openAPISchemaKeywords = map[string]bool{
+	"$ref": true, "type": true, "enum": true, "pattern": true, "format": true,
+	"maxLength": true, "minimum": true, "maximum": true, "properties": true,
+	"required": true, "additionalProperties": true, "items": true,
+	"title": true, "description": true,
+}
+
+// openAPIFormats are the validated formats, int64 and binary are annotations
+var openAPIFormats = map[string]bool{
+	"date": true, "date-time": true, "int64": true, "binary": true,
+}
+
+func (s *jsonSchema) UnmarshalJSON(b []byte) error {
+	if err := checkOpenAPIFields(b, openAPISchemaKeywords, "keyword"); err != nil {
+		return err
+	}
+	type plain jsonSchema
+	return json.Unmarshal(b, (*plain)(s))
+}
+
+// checkOpenAPIFields returns an error naming the first field of the object b
+// that is not supported
+func checkOpenAPIFields(b []byte, supported map[string]bool, what string) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		if !supported[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return fmt.Errorf("unsupported %s %s", what, names[0])
+	}
+	return nil
+}
+
+// jsonSchemaType is either a single type or a list of types
+type jsonSchemaType []string
+
+func (t *jsonSchemaType) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*t = jsonSchemaType{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*t = many
+	return nil
+}
+
+// NewOpenAPIValidator parses spec and resolves its references
+func NewOpenAPIValidator(spec []byte) (*OpenAPIValidator, error) {
+	var doc openAPIDoc
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	for name, s := range doc.Components.Schemas {
+		if err := doc.compileSchema(s); err != nil {
+			return nil, fmt.Errorf("openapi: components.schemas.%s: %w", name, err)
+		}
+	}
+
+	v := &OpenAPIValidator{}
+	for path, item := range doc.Paths {
+		ops := map[string]*openAPIOperation{
+			http.MethodGet:    item.Get,
+			http.MethodPut:    item.Put,
+			http.MethodPost:   item.Post,
+			http.MethodDelete: item.Delete,
+		}
+		for method, op := range ops {
+			if op == nil {
+				continue
+			}
+			route := &openAPIRoute{
+				method:   method,
+				path:     path,
+				segments: strings.Split(strings.Trim(path, "/"), "/"),
+				op:       op,
+			}
+			// operation parameters override those of the path by location and name
+			for _, p := range append(slices.Clone(item.Parameters), op.Parameters...) {
+				rp, err := doc.resolveParameter(p)
+				if err != nil {
+					return nil, fmt.Errorf("openapi: %s %s: %w", method, path, err)
+				}
+				route.params = slices.DeleteFunc(route.params, func(q *openAPIParameter) bool {
+					return q.In == rp.In && q.Name == rp.Name
+				})
+				route.params = append(route.params, rp)
+			}
+			if err := doc.compileOperation(op); err != nil {
+				return nil, fmt.Errorf("openapi: %s %s: %w", method, path, err)
+			}
+			v.routes = append(v.routes, route)
+		}
+	}
+	// literal segments take precedence over parameters should two routes match
+	sort.Slice(v.routes, func(i, j int) bool {
+		li, lj := v.routes[i].literals(), v.routes[j].literals()
+		if li != lj {
+			return li > lj
+		}
+		return v.routes[i].path < v.routes[j].path
+	})
+	return v, nil
+}
+
+func (doc *openAPIDoc) resolveParameter(p *openAPIParameter) (*openAPIParameter, error) {
+	if p.Ref != "" {
+		name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/")
+		rp := doc.Components.Parameters[name]
+		if !ok || rp == nil {
+			return nil, fmt.Errorf("unresolved reference %s", p.Ref)
+		}
+		p = rp
+	}
+	if p.In != "path" && p.In != "query" && p.In != "header" {
+		return nil, fmt.Errorf("parameter %s: unsupported location %q", p.Name, p.In)
+	}
+	return p, doc.compileSchema(p.Schema)
+}
+
+func (doc *openAPIDoc) compileOperation(op *openAPIOperation) error {
+	if op.RequestBody != nil {
+		for _, mt := range op.RequestBody.Content {
+			if err := doc.compileSchema(mt.Schema); err != nil {
+				return err
+			}
+		}
+	}
+	for status, resp := range op.Responses {
+		if resp.Ref != "" {
+			name, ok := strings.CutPrefix(resp.Ref, "#/components/responses/")
+			rr := doc.Components.Responses[name]
+			if !ok || rr == nil {
+				return fmt.Errorf("unresolved reference %s", resp.Ref)
+			}
+			resp = rr
+			op.Responses[status] = rr
+		}
+		for name, h := range resp.Headers {
+			if h.Ref != "" {
+				hname, ok := strings.CutPrefix(h.Ref, "#/components/headers/")
+				rh := doc.Components.Headers[hname]
+				if !ok || rh == nil {
+					return fmt.Errorf("unresolved reference %s", h.Ref)
+				}
+				resp.Headers[name] = rh
+			}
+		}
+		for _, mt := range resp.Content {
+			if err := doc.compileSchema(mt.Schema); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (doc *openAPIDoc) compileSchema(s *jsonSchema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		s.ref = doc.Components.Schemas[name]
+		if !ok || s.ref == nil {
+			return fmt.Errorf("unresolved reference %s", s.Ref)
+		}
+		// components are compiled on their own
+		return nil
+	}
+	if s.Format != "" && !openAPIFormats[s.Format] {
+		return fmt.Errorf("unsupported format %s", s.Format)
+	}
+	if s.Pattern != "" && s.pattern == nil {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern: %w", err)
+		}
+		s.pattern = re
+	}
+	switch ap := bytes.TrimSpace(s.AdditionalProperties); {
+	case len(ap) == 0, string(ap) == "true":
+	case string(ap) == "false":
+		s.noAdditional = true
+	default:
+		if s.additional == nil {
+			s.additional = &jsonSchema{}
+			if err := json.Unmarshal(ap, s.additional); err != nil {
+				return fmt.Errorf("additionalProperties: %w", err)
+			}
+		}
+		if err := doc.compileSchema(s.additional); err != nil {
+			return err
+		}
+	}
+	for name, p := range s.Properties {
+		if err := doc.compileSchema(p); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return doc.compileSchema(s.Items)
+}
+
+func (r *openAPIRoute) literals() int {
+	n := 0
+	for _, s := range r.segments {
+		if !strings.HasPrefix(s, "{") {
+			n++
+		}
+	}
+	return n
+}
+
+// match returns the path parameters if the route matches path
+func (r *openAPIRoute) match(path string) (map[string]string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, s := range r.segments {
+		if name, ok := strings.CutPrefix(s, "{"); ok {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[strings.TrimSuffix(name, "}")] = segments[i]
+			continue
+		}
+		if s != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Routes returns the documented operations as `METHOD /path`, sorted
+func (v *OpenAPIValidator) Routes() []string {
+	routes := make([]string, 0, len(v.routes))
+	for _, r := range v.routes {
+		routes = append(routes, r.method+" "+r.path)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// route finds the operation of the request, nil if it is not documented
+func (v *OpenAPIValidator) route(method, path string) (*openAPIRoute, map[string]string) {
+	for _, r := range v.routes {
+		if r.method != method {
+			continue
+		}
+		if params, ok := r.match(path); ok {
+			return r, params
+		}
+	}
+	return nil, nil
+}
+
+// ValidateRequest returns ErrBadRequest with the offending parameters and
+// fields if the request does not conform. Undocumented operations are left to
+// the router.
+func (v *OpenAPIValidator) ValidateRequest(r *http.Request, body []byte) error {
+	route, pathParams := v.route(r.Method, r.URL.Path)
+	if route == nil {
+		return nil
+	}
+	errs := make(map[string]string)
+	query := r.URL.Query()
+	for _, p := range route.params {
+		var (
+			value string
+			ok    bool
+		)
+		switch p.In {
+		case "path":
+			value, ok = pathParams[p.Name]
+		case "query":
+			ok = query.Has(p.Name)
+			value = query.Get(p.Name)
+		case "header":
+			value = r.Header.Get(p.Name)
+			ok = value != ""
+		}
+		if !ok {
+			if p.Required {
+				errs[p.Name] = "required"
+			}
+			continue
+		}
+		validateJSONSchema(p.Schema, paramValue(p.Schema, value), "", p.Name, errs)
+	}
+
+	if rb := route.op.RequestBody; rb != nil {
+		mt := rb.Content["application/json"]
+		switch {
+		case len(bytes.TrimSpace(body)) == 0:
+			if rb.Required {
+				errs["request body"] = "required"
+			}
+		case mt != nil:
+			val, err := decodeJSONValue(body)
+			if err != nil {
+				errs["request body"] = "malformed JSON"
+				break
+			}
+			validateJSONSchema(mt.Schema, val, "", "request body", errs)
+		}
+	}
+
+	if len(errs) > 0 {
+		return ErrBadRequest{Fields: errs}
+	}
+	return nil
+}
+
+// ValidateResponse returns an error listing how the response to r does not
+// conform. Only JSON bodies are validated against their schema.
+func (v *OpenAPIValidator) ValidateResponse(r *http.Request, status int, header http.Header, body []byte) error {
+	route, _ := v.route(r.Method, r.URL.Path)
+	if route == nil {
+		return nil
+	}
+	errs := make(map[string]string)
+	resp, ok := route.op.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = route.op.Responses["default"]
+	}
+	if !ok {
+		errs["status"] = fmt.Sprintf("%d is not documented", status)
+		return openAPIResponseError(r, status, errs)
+	}
+
+	for name, h := range resp.Headers {
+		if h.Required && header.Get(name) == "" {
+			errs["header "+name] = "required"
+		}
+	}
+
+	if len(resp.Content) == 0 {
+		if len(body) > 0 {
+			errs["response body"] = "not documented"
+		}
+		return openAPIResponseError(r, status, errs)
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	mt, ok := resp.Content[mediaType]
+	if err != nil || !ok {
+		types := make([]string, 0, len(resp.Content))
+		for t := range resp.Content {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		errs["header Content-Type"] = fmt.Sprintf("expected one of %s, got %q", strings.Join(types, ", "), header.Get("Content-Type"))
+		return openAPIResponseError(r, status, errs)
+	}
+	if isJSONMediaType(mediaType) {
+		val, err := decodeJSONValue(body)
+		if err != nil {
+			errs["response body"] = "malformed JSON"
+		} else {
+			validateJSONSchema(mt.Schema, val, "", "response body", errs)
+		}
+	}
+	return openAPIResponseError(r, status, errs)
+}
+
+// isJSONMediaType is true of application/json and its structured syntax
+// suffix, as in application/problem+json
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func openAPIResponseError(r *http.Request, status int, errs map[string]string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(errs))
+	for k := range errs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	problems := make([]string, 0, len(keys))
+	for _, k := range keys {
+		problems = append(problems, k+": "+errs[k])
+	}
+	return fmt.Errorf(
+		"response %d to %s %s does not conform to the OpenAPI spec: %s",
+		status, r.Method, r.URL.Path, strings.Join(problems, "; "),
+	)
+}
+
+func decodeJSONValue(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var val any
+	if err := dec.Decode(&val); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("trailing data")
+	}
+	return val, nil
+}
+
+// paramValue converts a parameter to the type of its schema, parameters being
+// strings on the wire
+func paramValue(s *jsonSchema, value string) any {
+	for s != nil && s.ref != nil {
+		s = s.ref
+	}
+	if s == nil || slices.Contains(s.Type, "string") {
+		return value
+	}
+	if slices.Contains(s.Type, "integer") || slices.Contains(s.Type, "number") {
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	}
+	if slices.Contains(s.Type, "boolean") {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// validateJSONSchema adds the problems of val to errs keyed by the path of the
+// field, or root for val itself
+func validateJSONSchema(s *jsonSchema, val any, path, root string, errs map[string]string) {
+	for s != nil && s.ref != nil {
+		s = s.ref
+	}
+	if s == nil {
+		return
+	}
+	key := path
+	if key == "" {
+		key = root
+	}
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return isJSONType(val, t) }) {
+		errs[key] = "expected " + strings.Join(s.Type, " or ")
+		return
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(val) }) {
+		opts := make([]string, 0, len(s.Enum))
+		for _, e := range s.Enum {
+			opts = append(opts, fmt.Sprint(e))
+		}
+		errs[key] = "must be one of " + strings.Join(opts, ", ")
+		return
+	}
+
+	switch v := val.(type) {
+	case string:
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			errs[key] = "invalid format"
+			return
+		}
+		if s.MaxLength != nil && utf8.RuneCountInString(v) > *s.MaxLength {
+			errs[key] = fmt.Sprintf("longer than %d characters", *s.MaxLength)
+			return
+		}
+		switch s.Format {
+		case "date":
+			if _, err := time.Parse(time.DateOnly, v); err != nil {
+				errs[key] = "invalid date, expected YYYY-MM-DD"
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				errs[key] = "invalid date-time, expected RFC 3339"
+			}
+		}
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			errs[key] = "invalid number"
+			return
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			errs[key] = fmt.Sprintf("must be at least %v", *s.Minimum)
+		} else if s.Maximum != nil && n > *s.Maximum {
+			errs[key] = fmt.Sprintf("must be at most %v", *s.Maximum)
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				errs[joinFieldPath(path, name)] = "required"
+			}
+		}
+		for name, fv := range v {
+			if ps, ok := s.Properties[name]; ok {
+				validateJSONSchema(ps, fv, joinFieldPath(path, name), root, errs)
+			} else if s.noAdditional {
+				errs[joinFieldPath(path, name)] = "unknown field"
+			} else if s.additional != nil {
+				validateJSONSchema(s.additional, fv, joinFieldPath(path, name), root, errs)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			validateJSONSchema(s.Items, item, fmt.Sprintf("%s[%d]", path, i), root, errs)
+		}
+	}
+}
+
+func isJSONType(val any, typ string) bool {
+	switch v := val.(type) {
+	case nil:
+		return typ == "null"
+	case bool:
+		return typ == "boolean"
+	case string:
+		return typ == "string"
+	case json.Number:
+		if typ == "number" {
+			return true
+		}
+		if typ == "integer" {
+			_, err := v.Int64()
+			return err == nil
+		}
+		return false
+	case map[string]any:
+		return typ == "object"
+	case []any:
+		return typ == "array"
+	}
+	return false
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// NewOpenAPIMiddleware validates requests and responses against v as
+// configured. Invalid requests are rejected with 400 Bad Request before they
+// reach h. Responses are only logged, as they are streamed to the client while
+// they are validated.
+func NewOpenAPIMiddleware(v *OpenAPIValidator, cfg OpenAPIValidationCfg, log *zerolog.Logger) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.Requests {
+				body, err := io.ReadAll(r.Body)
+				r.Body.Close()
+				if err != nil {
+					ctxLog(r.Context(), log).Err(err).Str("method", "openAPIMiddleware").Msg("error reading HTTP request")
+					WriteHTTPError(w, ErrInternalServer)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				if err = v.ValidateRequest(r, body); err != nil {
+					WriteHTTPError(w, err)
+					return
+				}
+			}
+			if !cfg.Responses {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			rw := &openAPIResponseWriter{ResponseWriter: w}
+			h.ServeHTTP(rw, r)
+			if err := v.ValidateResponse(r, rw.statusCode(), w.Header(), rw.body.Bytes()); err != nil {
+				ctxLog(r.Context(), log).Error().Err(err).Str("method", "openAPIMiddleware").Msg("invalid response")
+			}
+		})
+	}
+}
+
+// openAPIResponseWriter keeps the status and a copy of JSON bodies for
+// validation
+type openAPIResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+	isJSON bool
+}
+
+func (w *openAPIResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		w.isJSON = isJSONMediaType(mediaType)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *openAPIResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.isJSON {
+		w.body.Write(b)
+	} else if w.body.Len() == 0 && len(b) > 0 {
+		// only whether there is a body matters
+		w.body.WriteByte(0)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *openAPIResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *openAPIResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *openAPIResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,784 @@
+package bankxgo_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+// routePattern strips the regexps of chi's route parameters, ie.
+// `/accounts/{acctID:[0-9]+}/` becomes `/accounts/{acctID}`
+func routePattern(route string) string {
+	var b strings.Builder
+	depth := 0
+	skipping := false
+	for _, r := range route {
+		switch {
+		case r == '{':
+			depth++
+			if depth == 1 {
+				b.WriteRune(r)
+				continue
+			}
+		case r == '}':
+			depth--
+			if depth == 0 {
+				skipping = false
+				b.WriteRune(r)
+				continue
+			}
+		case r == ':' && depth == 1:
+			skipping = true
+		}
+		if !skipping {
+			b.WriteRune(r)
+		}
+	}
+	if s := b.String(); s != "/" {
+		return strings.TrimSuffix(s, "/")
+	}
+	return "/"
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	nooplog := zerolog.Nop()
+	v, err := bankxgo.NewOpenAPIValidator(bankxgo.OpenAPISpec)
+	require.Nil(t, err)
+
+	hndlr := bankxgo.NewHTTPHandler(mocks.NewMockService(gomock.NewController(t)), &nooplog)
+	var routes []string
+	err = chi.Walk(hndlr.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes = append(routes, method+" "+routePattern(route))
+		return nil
+	})
+	require.Nil(t, err)
+	sort.Strings(routes)
+
+	assert.Equal(t, routes, v.Routes(), "the routes of NewHTTPHandler and openapi.json disagree")
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	nooplog := zerolog.Nop()
+	v, err := bankxgo.NewOpenAPIValidator(bankxgo.OpenAPISpec)
+	require.Nil(t, err)
+
+	acctID := snowflake.ParseInt64(1836378168910905344)
+	jobID := snowflake.ParseInt64(1836378168910905345)
+	from := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)
+	bal := decimal.RequireFromString("123.45")
+	period := bankxgo.StatementPeriod{
+		AcctID:   acctID,
+		From:     from,
+		To:       to,
+		Opening:  decimal.Zero,
+		Closing:  decimal.NewFromInt(800),
+		Format:   "pdf",
+		ClosedAt: to.Add(24 * time.Hour),
+	}
+	job := bankxgo.StatementJob{
+		ID:        jobID,
+		AcctID:    acctID,
+		Format:    "pdf",
+		From:      from,
+		To:        to,
+		Status:    bankxgo.StatementJobQueued,
+		CreatedAt: to,
+	}
+
+	noFee := decimal.Zero
+	finishedAt := to.Add(time.Second)
+	batch := bankxgo.Batch{
+		ID:     snowflake.ParseInt64(1836378168910905346),
+		Mode:   bankxgo.BatchBestEffort,
+		Status: bankxgo.BatchPartial,
+		Items: []bankxgo.BatchItem{
+			{
+				Type:    bankxgo.BatchDeposit,
+				AcctID:  acctID,
+				Amount:  decimal.NewFromInt(100),
+				Status:  bankxgo.BatchItemPosted,
+				Fee:     &noFee,
+				Balance: &bal,
+			},
+			{
+				Type:      bankxgo.BatchWithdrawal,
+				AcctID:    acctID,
+				Amount:    decimal.NewFromInt(500),
+				Status:    bankxgo.BatchItemFailed,
+				ErrorCode: bankxgo.CodeInsufficientFunds,
+				Error:     "insufficient funds in account 1836378168910905344",
+			},
+		},
+		CreatedAt:  to,
+		FinishedAt: &finishedAt,
+	}
+	nextRunAt := to.Add(9 * time.Hour)
+	scheduled := bankxgo.ScheduledPayment{
+		ID:     snowflake.ParseInt64(1836378168910905347),
+		AcctID: acctID,
+		ScheduledPaymentSpec: bankxgo.ScheduledPaymentSpec{
+			ToAcctID:            jobID,
+			Amount:              decimal.NewFromInt(250),
+			Rule:                bankxgo.ScheduleRule{Cron: "0 9 1 * *"},
+			StartAt:             from,
+			OnInsufficientFunds: bankxgo.ScheduleRetry,
+		},
+		Status:    bankxgo.ScheduledPaymentActive,
+		NextRunAt: &nextRunAt,
+		CreatedAt: from,
+		UpdatedAt: from,
+	}
+
+	settlementKey := "0123456789abcdef-settlement"
+	txn := bankxgo.Transaction{
+		ID:     42,
+		Type:   "withdrawal",
+		Status: bankxgo.TxnPosted,
+		Amount: decimal.NewFromInt(-100),
+		Fee:    decimal.NewFromInt(1),
+		Memo: bankxgo.Memo{
+			Description: "Rent",
+			Reference:   "INV-1234",
+			Metadata:    map[string]any{"unit": "4B"},
+		},
+		CreatedAt: to,
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		expect func(svc *mocks.MockService)
+		status int
+	}{
+		{
+			name:   "create account",
+			method: http.MethodPost,
+			path:   "/accounts",
+			body:   `{"email":"user@email.com","currency":"USD"}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Return(&bankxgo.Account{AcctID: acctID, Email: "user@email.com"}, nil)
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name:   "deposit",
+			method: http.MethodPost,
+			path:   "/accounts/1836378168910905344/deposit",
+			body:   `{"amount":200.0}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().Deposit(gomock.Any(), gomock.Any()).Return(&bal, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "withdraw",
+			method: http.MethodPost,
+			path:   "/accounts/1836378168910905344/withdraw",
+			body:   `{"amount":"100"}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().Withdraw(gomock.Any(), gomock.Any()).Return(&bankxgo.Receipt{
+					Amount:  decimal.NewFromInt(100),
+					Fee:     decimal.NewFromInt(1),
+					Balance: bal,
+				}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "withdraw rate limited",
+			method: http.MethodPost,
+			path:   "/accounts/1836378168910905344/withdraw",
+			body:   `{"amount":"100"}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().Withdraw(gomock.Any(), gomock.Any()).Return(nil, bankxgo.ErrRateLimited{RetryAfter: time.Second})
+			},
+			status: http.StatusTooManyRequests,
+		},
+		{
+			name:   "create account with a taken email",
+			method: http.MethodPost,
+			path:   "/accounts",
+			body:   `{"email":"user@email.com","currency":"USD"}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Return(nil, bankxgo.ErrConflict{Field: "email"})
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name:   "withdraw with insufficient funds",
+			method: http.MethodPost,
+			path:   "/accounts/1836378168910905344/withdraw",
+			body:   `{"amount":"100"}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().Withdraw(gomock.Any(), gomock.Any()).Return(nil, bankxgo.ErrInsufficientFunds{AcctID: acctID})
+			},
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "deposit to a frozen account",
+			method: http.MethodPost,
+			path:   "/accounts/1836378168910905344/deposit",
+			body:   `{"amount":200.0}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().Deposit(gomock.Any(), gomock.Any()).Return(nil, bankxgo.ErrAccountFrozen{AcctID: acctID})
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "balance",
+			method: http.MethodGet,
+			path:   "/accounts/1836378168910905344/balance",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().Balance(gomock.Any(), gomock.Any()).Return(&bankxgo.Balances{Balance: bal}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "balance of unknown account",
+			method: http.MethodGet,
+			path:   "/accounts/1836378168910905344/balance",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().Balance(gomock.Any(), gomock.Any()).Return(nil, bankxgo.ErrNotFound{ID: acctID.Int64()})
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "statement",
+			method: http.MethodGet,
+			path:   "/accounts/1836378168910905344/statement?format=csv&from=2024-09-01",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().Statement(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, w io.Writer, _ bankxgo.StatementReq) error {
+					_, err := w.Write([]byte("date,description,amount\n"))
+					return err
+				})
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "request statement",
+			method: http.MethodPost,
+			path:   "/accounts/1836378168910905344/statements",
+			body:   `{"format":"pdf","from":"2024-09-01","to":"2024-09-30"}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().RequestStatement(gomock.Any(), gomock.Any()).Return(&job, nil)
+			},
+			status: http.StatusAccepted,
+		},
+		{
+			name:   "request statement without statement jobs",
+			method: http.MethodPost,
+			path:   "/accounts/1836378168910905344/statements",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().RequestStatement(gomock.Any(), gomock.Any()).Return(nil, bankxgo.ErrServiceUnavailable)
+			},
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			name:   "list transactions",
+			method: http.MethodGet,
+			path:   "/accounts/1836378168910905344/transactions?reference=INV-1234&limit=10",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().ListTransactions(gomock.Any(), gomock.Any()).Return([]bankxgo.Transaction{txn}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "list statement periods",
+			method: http.MethodGet,
+			path:   "/accounts/1836378168910905344/statements",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().ListStatementPeriods(gomock.Any(), gomock.Any()).Return([]bankxgo.StatementPeriod{period}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "list statement periods of a new account",
+			method: http.MethodGet,
+			path:   "/accounts/1836378168910905344/statements",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().ListStatementPeriods(gomock.Any(), gomock.Any()).Return(nil, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "get statement period",
+			method: http.MethodGet,
+			path:   "/accounts/1836378168910905344/statements/2024-09-30",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().GetStatementPeriod(gomock.Any(), gomock.Any()).Return(&period, io.NopCloser(strings.NewReader("%PDF-1.3")), nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "set statement preference",
+			method: http.MethodPut,
+			path:   "/accounts/1836378168910905344/statement/preferences",
+			body:   `{"template":"europe","locale":"de-DE","cycleDay":15}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().SetStatementPreference(gomock.Any(), gomock.Any()).Return(&bankxgo.StatementPreference{
+					Template: "europe",
+					Locale:   "de-DE",
+					CycleDay: 15,
+				}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "set statement password",
+			method: http.MethodPut,
+			path:   "/accounts/1836378168910905344/statement/password",
+			body:   `{"password":"correct horse"}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().SetStatementPassword(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "get pending statement job",
+			method: http.MethodGet,
+			path:   "/statements/1836378168910905345",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().GetStatementJob(gomock.Any(), gomock.Any()).Return(&job, nil, nil)
+			},
+			status: http.StatusAccepted,
+		},
+		{
+			name:   "get done statement job",
+			method: http.MethodGet,
+			path:   "/statements/1836378168910905345",
+			expect: func(svc *mocks.MockService) {
+				done := job
+				done.Status = bankxgo.StatementJobDone
+				svc.EXPECT().GetStatementJob(gomock.Any(), gomock.Any()).Return(&done, io.NopCloser(strings.NewReader("%PDF-1.3")), nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "verify statement",
+			method: http.MethodGet,
+			path:   "/statements/verify/ABCD-EFGH-IJKL-MNOP-QRS2",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().VerifyStatement(gomock.Any(), gomock.Any()).Return(&bankxgo.StatementVerification{
+					Code:        "ABCDEFGHIJKLMNOPQRS2",
+					AcctID:      acctID,
+					Currency:    "PHP",
+					From:        from,
+					To:          to,
+					Opening:     decimal.Zero,
+					Closing:     decimal.NewFromInt(800),
+					Lines:       1,
+					ContentHash: strings.Repeat("9f", 32),
+					IssuedAt:    to,
+				}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "post batch",
+			method: http.MethodPost,
+			path:   "/batches",
+			body:   `{"mode":"best_effort","items":[{"type":"deposit","acctID":"1836378168910905344","email":"user@email.com","amount":"100"},{"type":"withdrawal","acctID":"1836378168910905344","email":"user@email.com","amount":500}]}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().PostBatch(gomock.Any(), gomock.Any()).Return(&batch, nil)
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name:   "post batch with a reused idempotency key",
+			method: http.MethodPost,
+			path:   "/batches",
+			body:   `{"items":[{"type":"deposit","acctID":"1836378168910905344","email":"user@email.com","amount":"100"}]}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().PostBatch(gomock.Any(), gomock.Any()).Return(nil, bankxgo.ErrConflict{Field: "Idempotency-Key"})
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name:   "settle",
+			method: http.MethodPost,
+			path:   "/settlements",
+			body:   `{"acctID":"1836378168910905344","reference":"INV-1234","status":"posted"}`,
+			expect: func(svc *mocks.MockService) {
+				settled := txn
+				settled.Type = "deposit"
+				settled.Amount = decimal.NewFromInt(100)
+				settledAt := settled.CreatedAt.Add(time.Hour)
+				settled.SettledAt = &settledAt
+				svc.EXPECT().Settle(gomock.Any(), gomock.Any()).Return(&settled, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "get batch",
+			method: http.MethodGet,
+			path:   "/batches/1836378168910905346",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().GetBatch(gomock.Any(), gomock.Any()).Return(&batch, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "create scheduled payment",
+			method: http.MethodPost,
+			path:   "/accounts/1836378168910905344/scheduled-payments",
+			body:   `{"toAcctID":"1836378168910905345","amount":250,"rule":{"every":"month","interval":1},"startAt":"2024-09-30T09:00:00Z","onInsufficientFunds":"retry"}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().CreateScheduledPayment(gomock.Any(), gomock.Any()).Return(&scheduled, nil)
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name:   "create scheduled payment with an invalid rule",
+			method: http.MethodPost,
+			path:   "/accounts/1836378168910905344/scheduled-payments",
+			body:   `{"toAcctID":"1836378168910905345","amount":"250","rule":{"cron":"0 9 L * *"}}`,
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().CreateScheduledPayment(gomock.Any(), gomock.Any()).
+					Return(nil, bankxgo.ErrBadRequest{Fields: map[string]string{"rule.cron": "invalid day of month"}})
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "list scheduled payments",
+			method: http.MethodGet,
+			path:   "/accounts/1836378168910905344/scheduled-payments",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().ListScheduledPayments(gomock.Any(), gomock.Any()).Return([]bankxgo.ScheduledPayment{scheduled}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "cancel scheduled payment",
+			method: http.MethodDelete,
+			path:   "/accounts/1836378168910905344/scheduled-payments/1836378168910905347",
+			expect: func(svc *mocks.MockService) {
+				cancelled := scheduled
+				cancelled.Status = bankxgo.ScheduledPaymentCancelled
+				cancelled.NextRunAt = nil
+				svc.EXPECT().CancelScheduledPayment(gomock.Any(), gomock.Any()).Return(&cancelled, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "list scheduled payment runs",
+			method: http.MethodGet,
+			path:   "/accounts/1836378168910905344/scheduled-payments/1836378168910905347/runs",
+			expect: func(svc *mocks.MockService) {
+				svc.EXPECT().ListScheduledPaymentRuns(gomock.Any(), gomock.Any()).Return([]bankxgo.ScheduledPaymentRun{
+					{PaymentID: scheduled.ID, DueAt: nextRunAt, Attempt: 1, Status: bankxgo.ScheduledRunPosted, BatchID: batch.ID, RanAt: nextRunAt},
+					{
+						PaymentID: scheduled.ID,
+						DueAt:     nextRunAt,
+						Status:    bankxgo.ScheduledRunRetrying,
+						BatchID:   batch.ID,
+						ErrorCode: bankxgo.CodeInsufficientFunds,
+						Error:     "insufficient funds in account 1836378168910905344",
+						RanAt:     nextRunAt,
+					},
+				}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "openapi",
+			method: http.MethodGet,
+			path:   "/openapi.json",
+			status: http.StatusOK,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name+" conforms", func(tt *testing.T) {
+			svc := mocks.NewMockService(gomock.NewController(tt))
+			if c.expect != nil {
+				c.expect(svc)
+			}
+			hndlr := bankxgo.NewHTTPHandler(svc, &nooplog, bankxgo.WithSettlementKey(settlementKey))
+
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			req.Header.Set("email", "user@email.com")
+			req.Header.Set(bankxgo.IdempotencyKeyHeader, "batch-1")
+			req.Header.Set(bankxgo.SettlementSignatureHeader, bankxgo.SignSettlement([]byte(settlementKey), time.Now(), []byte(c.body)))
+			w := httptest.NewRecorder()
+			assert.Nil(tt, v.ValidateRequest(req, []byte(c.body)))
+			hndlr.ServeHTTP(w, req)
+
+			assert.Equal(tt, c.status, w.Code)
+			assert.Nil(tt, v.ValidateResponse(req, w.Code, w.Header(), w.Body.Bytes()))
+		})
+	}
+}
+
+func TestOpenAPIMiddleware(t *testing.T) {
+	v, err := bankxgo.NewOpenAPIValidator(bankxgo.OpenAPISpec)
+	require.Nil(t, err)
+	cfg := bankxgo.OpenAPIValidationCfg{Requests: true, Responses: true}
+
+	t.Run("rejects requests that do not conform", func(tt *testing.T) {
+		as := assert.New(tt)
+		nooplog := zerolog.Nop()
+		// the service is not called
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		hndlr := bankxgo.NewOpenAPIMiddleware(v, cfg, &nooplog)(bankxgo.NewHTTPHandler(svc, &nooplog))
+
+		body := bytes.NewBufferString(`{"template":"europe","cycleDay":40,"colour":"red"}`)
+		req := httptest.NewRequest(http.MethodPut, "/accounts/1836378168910905344/statement/preferences", body)
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusBadRequest, w.Code)
+		var resp bankxgo.ErrBadRequest
+		as.Nil(json.Unmarshal(w.Body.Bytes(), &resp))
+		as.Equal(map[string]string{
+			"email":    "required",
+			"cycleDay": "must be at most 31",
+			"colour":   "unknown field",
+		}, resp.Fields)
+	})
+
+	t.Run("checks query parameters and request bodies", func(tt *testing.T) {
+		as := assert.New(tt)
+		req := httptest.NewRequest(http.MethodGet, "/accounts/1836378168910905344/statement?format=docx&to=2024-13-01", nil)
+		req.Header.Set("email", "user@email.com")
+		err := v.ValidateRequest(req, nil)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{
+			"format": "must be one of pdf, csv, ofx, camt053",
+			"to":     "invalid date, expected YYYY-MM-DD",
+		}}, err)
+
+		req = httptest.NewRequest(http.MethodPost, "/accounts/1836378168910905344/deposit", nil)
+		req.Header.Set("email", "user@email.com")
+		err = v.ValidateRequest(req, []byte(`{"amount":"12,50"}`))
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"amount": "invalid format"}}, err)
+		err = v.ValidateRequest(req, []byte(`{"amount":true}`))
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"amount": "expected string or number"}}, err)
+		err = v.ValidateRequest(req, nil)
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{"request body": "required"}}, err)
+		err = v.ValidateRequest(req, []byte(`{"amount":1,"reference":"`+strings.Repeat("x", 65)+`","metadata":[]}`))
+		as.Equal(bankxgo.ErrBadRequest{Fields: map[string]string{
+			"reference": "longer than 64 characters",
+			"metadata":  "expected object",
+		}}, err)
+	})
+
+	t.Run("passes conforming requests on with their body", func(tt *testing.T) {
+		as := assert.New(tt)
+		nooplog := zerolog.Nop()
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		bal := decimal.NewFromInt(1234)
+		svc.EXPECT().
+			Deposit(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, r bankxgo.ChargeReq) (*decimal.Decimal, error) {
+				as.Equal("1234", r.Amount.String())
+				return &bal, nil
+			})
+		hndlr := bankxgo.NewOpenAPIMiddleware(v, cfg, &nooplog)(bankxgo.NewHTTPHandler(svc, &nooplog))
+
+		req := httptest.NewRequest(http.MethodPost, "/accounts/1836378168910905344/deposit", bytes.NewBufferString(`{"amount":1234}`))
+		req.Header.Set("email", "user@email.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		as.Equal(http.StatusOK, w.Code)
+		as.JSONEq(`{"balance":"1234"}`, w.Body.String())
+	})
+
+	t.Run("logs responses that do not conform", func(tt *testing.T) {
+		as := assert.New(tt)
+		buf := new(bytes.Buffer)
+		log := zerolog.New(buf)
+		hndlr := bankxgo.NewOpenAPIMiddleware(v, cfg, &log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"balance":1234,"overdraftLimit":"0","availableCredit":"0","pending":"0","available":"0","acctId":"1836378168910905344"}`))
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/accounts/1836378168910905344/balance", nil)
+		req.Header.Set("email", "user@email.com")
+		w := httptest.NewRecorder()
+		hndlr.ServeHTTP(w, req)
+
+		// sent regardless
+		as.Equal(http.StatusOK, w.Code)
+		as.Equal(`{"balance":1234,"overdraftLimit":"0","availableCredit":"0","pending":"0","available":"0","acctId":"1836378168910905344"}`, w.Body.String())
+		as.Contains(buf.String(), "response 200 to GET /accounts/1836378168910905344/balance does not conform to the OpenAPI spec: acctId: unknown field; balance: expected string")
+	})
+}
+
+// keywordSpec documents `POST /things` with a request body of schema
+func keywordSpec(schema string) []byte {
+	return []byte(`{
+		"openapi": "3.1.0",
+		"paths": {"/things": {"post": {
+			"requestBody": {"required": true, "content": {"application/json": {"schema": ` + schema + `}}},
+			"responses": {"204": {"description": "created"}}
+		}}},
+		"components": {"schemas": {"Code": {"type": "string", "pattern": "^[A-Z]{3}$"}}}
+	}`)
+}
+
+func TestOpenAPISchemaKeywords(t *testing.T) {
+	cases := []struct {
+		keyword string
+		schema  string
+		valid   string
+		invalid string
+		fields  map[string]string
+	}{
+		{
+			keyword: "$ref",
+			schema:  `{"$ref": "#/components/schemas/Code"}`,
+			valid:   `"USD"`,
+			invalid: `"usd"`,
+			fields:  map[string]string{"request body": "invalid format"},
+		},
+		{
+			keyword: "type",
+			schema:  `{"type": "integer"}`,
+			valid:   `12`,
+			invalid: `1.5`,
+			fields:  map[string]string{"request body": "expected integer"},
+		},
+		{
+			keyword: "type list",
+			schema:  `{"type": ["string", "number"]}`,
+			valid:   `1.5`,
+			invalid: `true`,
+			fields:  map[string]string{"request body": "expected string or number"},
+		},
+		{
+			keyword: "enum",
+			schema:  `{"type": "string", "enum": ["pdf", "csv"]}`,
+			valid:   `"csv"`,
+			invalid: `"docx"`,
+			fields:  map[string]string{"request body": "must be one of pdf, csv"},
+		},
+		{
+			keyword: "pattern",
+			schema:  `{"type": "string", "pattern": "^[0-9]+$"}`,
+			valid:   `"123"`,
+			invalid: `"12a"`,
+			fields:  map[string]string{"request body": "invalid format"},
+		},
+		{
+			keyword: "format date",
+			schema:  `{"type": "string", "format": "date"}`,
+			valid:   `"2024-02-29"`,
+			invalid: `"2023-02-29"`,
+			fields:  map[string]string{"request body": "invalid date, expected YYYY-MM-DD"},
+		},
+		{
+			keyword: "format date-time",
+			schema:  `{"type": "string", "format": "date-time"}`,
+			valid:   `"2024-02-29T10:00:00+08:00"`,
+			invalid: `"2024-02-29 10:00"`,
+			fields:  map[string]string{"request body": "invalid date-time, expected RFC 3339"},
+		},
+		{
+			keyword: "maxLength",
+			schema:  `{"type": "string", "maxLength": 3}`,
+			valid:   `"äöü"`,
+			invalid: `"abcd"`,
+			fields:  map[string]string{"request body": "longer than 3 characters"},
+		},
+		{
+			keyword: "minimum",
+			schema:  `{"type": "integer", "minimum": 1}`,
+			valid:   `1`,
+			invalid: `0`,
+			fields:  map[string]string{"request body": "must be at least 1"},
+		},
+		{
+			keyword: "maximum",
+			schema:  `{"type": "integer", "maximum": 31}`,
+			valid:   `31`,
+			invalid: `32`,
+			fields:  map[string]string{"request body": "must be at most 31"},
+		},
+		{
+			keyword: "properties",
+			schema:  `{"type": "object", "properties": {"day": {"type": "integer"}}}`,
+			valid:   `{"day": 1, "other": true}`,
+			invalid: `{"day": "1"}`,
+			fields:  map[string]string{"day": "expected integer"},
+		},
+		{
+			keyword: "required",
+			schema:  `{"type": "object", "required": ["day"]}`,
+			valid:   `{"day": 1}`,
+			invalid: `{}`,
+			fields:  map[string]string{"day": "required"},
+		},
+		{
+			keyword: "additionalProperties false",
+			schema:  `{"type": "object", "properties": {"day": {}}, "additionalProperties": false}`,
+			valid:   `{"day": 1}`,
+			invalid: `{"day": 1, "colour": "red"}`,
+			fields:  map[string]string{"colour": "unknown field"},
+		},
+		{
+			keyword: "additionalProperties schema",
+			schema:  `{"type": "object", "additionalProperties": {"type": "string"}}`,
+			valid:   `{"a": "b"}`,
+			invalid: `{"a": 1}`,
+			fields:  map[string]string{"a": "expected string"},
+		},
+		{
+			keyword: "items",
+			schema:  `{"type": "array", "items": {"type": "object", "required": ["id"]}}`,
+			valid:   `[{"id": 1}]`,
+			invalid: `[{"id": 1}, {}]`,
+			fields:  map[string]string{"[1].id": "required"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.keyword, func(tt *testing.T) {
+			as := assert.New(tt)
+			v, err := bankxgo.NewOpenAPIValidator(keywordSpec(c.schema))
+			require.Nil(tt, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/things", nil)
+			as.Nil(v.ValidateRequest(req, []byte(c.valid)))
+			as.Equal(bankxgo.ErrBadRequest{Fields: c.fields}, v.ValidateRequest(req, []byte(c.invalid)))
+		})
+	}
+
+	t.Run("rejects documents using other keywords", func(tt *testing.T) {
+		for _, schema := range []string{
+			`{"type": "string", "minLength": 1}`,
+			`{"type": "object", "properties": {"day": {"type": "integer", "exclusiveMaximum": 32}}}`,
+			`{"type": "array", "items": {"oneOf": [{"type": "string"}]}}`,
+			`{"type": "object", "additionalProperties": {"const": "a"}}`,
+			`{"type": "string", "format": "email"}`,
+		} {
+			_, err := bankxgo.NewOpenAPIValidator(keywordSpec(schema))
+			assert.NotNil(tt, err, schema)
+		}
+	})
+
+	t.Run("rejects documents using other operations", func(tt *testing.T) {
+		spec := []byte(`{"openapi": "3.1.0", "paths": {"/things": {"patch": {"responses": {}}}}}`)
+		_, err := bankxgo.NewOpenAPIValidator(spec)
+		assert.NotNil(tt, err)
+	})
+}
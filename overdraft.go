@@ -0,0 +1,145 @@
+package bankxgo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+)
+
+// OverdraftPolicy is the overdraft income account, interest rate, day count
+// convention and daily fee of a currency
+type OverdraftPolicy struct {
+	Account    snowflake.ID
+	AnnualRate decimal.Decimal
+	DayCount   string
+	DailyFee   decimal.Decimal
+}
+
+// OverdraftCharge is the interest and fee charged to an account for ending a
+// day overdrawn
+type OverdraftCharge struct {
+	AcctID snowflake.ID
+	Date   time.Time
+	// Balance is the (negative) end of day balance
+	Balance  decimal.Decimal
+	Rate     decimal.Decimal
+	Interest decimal.Decimal
+	Fee      decimal.Decimal
+}
+
+// OverdraftStore is the persistence needed by the overdraft job
+type OverdraftStore interface {
+	GetAccount(ctx context.Context, id snowflake.ID) (*Account, error)
+	// EndOfDayBalances returns the balance at the end of day of every account
+	// in currency, except the excluded (system) accounts
+	EndOfDayBalances(ctx context.Context, currency string, day time.Time, exclude []snowflake.ID) ([]AccountBalance, error)
+	// ChargeOverdraft books the interest and fee of the charge from the account
+	// to the overdraft income account. ok is false if the account was already
+	// charged for the day.
+	ChargeOverdraft(ctx context.Context, charge OverdraftCharge, incomeAcct snowflake.ID) (ok bool, err error)
+}
+
+// OverdraftJob charges interest and a fee to the accounts that end a day
+// overdrawn. The interest is on the overdrawn part of the balance and, unlike
+// deposit interest, it is booked daily. Runs are idempotent so a failed run can
+// simply be rerun.
+type OverdraftJob struct {
+	store    OverdraftStore
+	policies map[string]OverdraftPolicy
+	exclude  []snowflake.ID
+	log      *zerolog.Logger
+}
+
+// NewOverdraftJob validates the policies' day count conventions and overdraft
+// income accounts. Accounts in exclude, ie. system accounts, are never charged.
+func NewOverdraftJob(
+	store OverdraftStore,
+	policies map[string]OverdraftPolicy,
+	exclude []snowflake.ID,
+	log *zerolog.Logger,
+) (*OverdraftJob, error) {
+	exclude = append([]snowflake.ID(nil), exclude...)
+	for c, p := range policies {
+		if _, err := dayFraction(p.DayCount, time.Now()); err != nil {
+			return nil, fmt.Errorf("overdraft.%s.day_count: %w", c, err)
+		}
+		a, err := store.GetAccount(context.Background(), p.Account)
+		if err != nil {
+			return nil, err
+		}
+		if a.Currency != c {
+			return nil, ErrNotFound{ID: p.Account.Int64()}
+		}
+		exclude = append(exclude, p.Account)
+	}
+	job := &OverdraftJob{
+		store:    store,
+		policies: policies,
+		exclude:  exclude,
+		log:      log,
+	}
+	return job, nil
+}
+
+// Charge charges every account that ended the given (UTC) day overdrawn. Each
+// account is charged in its own database transaction, so a failure only
+// affects the remaining accounts.
+func (j *OverdraftJob) Charge(day time.Time) error {
+	day = truncateDay(day)
+	if !day.Before(truncateDay(time.Now())) {
+		return ErrBadRequest{Fields: map[string]string{"date": "day has not ended yet"}}
+	}
+
+	for c, p := range j.policies {
+		frac, err := dayFraction(p.DayCount, day)
+		if err != nil {
+			return err
+		}
+		bals, err := j.store.EndOfDayBalances(context.Background(), c, day, j.exclude)
+		if err != nil {
+			return fmt.Errorf("EndOfDayBalances(%s): %w", c, err)
+		}
+		var charged, failed int
+		for _, b := range bals {
+			if !b.Balance.IsNegative() {
+				continue
+			}
+			charge := OverdraftCharge{
+				AcctID:   b.AcctID,
+				Date:     day,
+				Balance:  b.Balance,
+				Rate:     p.AnnualRate,
+				Interest: b.Balance.Neg().Mul(p.AnnualRate).Div(hundred).Mul(frac).RoundBank(2),
+				Fee:      p.DailyFee,
+			}
+			if charge.Interest.IsZero() && charge.Fee.IsZero() {
+				continue
+			}
+			ok, err := j.store.ChargeOverdraft(context.Background(), charge, p.Account)
+			if err != nil {
+				failed++
+				j.log.Err(err).
+					Str("acctID", b.AcctID.String()).
+					Msg("overdraft charge failed")
+				continue
+			}
+			if ok {
+				charged++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("overdraft charge failed for %d %s account(s)", failed, c)
+		}
+		j.log.Info().
+			Str("currency", c).
+			Str("date", day.Format(time.DateOnly)).
+			Int("charged", charged).
+			Msg("overdraft charged")
+	}
+
+	return nil
+}
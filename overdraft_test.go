@@ -0,0 +1,97 @@
+package bankxgo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+func TestOverdraftJobCharge(t *testing.T) {
+	sysAcct := snowflake.ParseInt64(7241301734201495552)
+	incomeAcct := snowflake.ParseInt64(7241301734201495554)
+	log := zerolog.Nop()
+
+	newJob := func(tt *testing.T, fee decimal.Decimal) (*bankxgo.OverdraftJob, *mocks.MockOverdraftStore) {
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockOverdraftStore(ctrl)
+		store.EXPECT().
+			GetAccount(gomock.Any(), incomeAcct).
+			Return(&bankxgo.Account{AcctID: incomeAcct, Currency: "USD"}, nil)
+		policies := map[string]bankxgo.OverdraftPolicy{
+			"USD": {Account: incomeAcct, AnnualRate: decimal.New(1825, -2), DayCount: bankxgo.DayCountACT365, DailyFee: fee},
+		}
+		job, err := bankxgo.NewOverdraftJob(store, policies, []snowflake.ID{sysAcct}, &log)
+		require.Nil(tt, err)
+		return job, store
+	}
+
+	t.Run("charges interest and the fee on negative end of day balances only", func(tt *testing.T) {
+		as := assert.New(tt)
+		job, store := newJob(tt, decimal.New(2, 0))
+		day := time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)
+		store.EXPECT().
+			EndOfDayBalances(gomock.Any(), "USD", day, []snowflake.ID{sysAcct, incomeAcct}).
+			Return([]bankxgo.AccountBalance{
+				{AcctID: 1, Type: bankxgo.AccountDeposit, Balance: decimal.New(1000, 0)},
+				{AcctID: 2, Type: bankxgo.AccountDeposit, Balance: decimal.Zero},
+				{AcctID: 3, Type: bankxgo.AccountCredit, Balance: decimal.New(-200, 0)},
+			}, nil)
+		store.EXPECT().
+			ChargeOverdraft(gomock.Any(), gomock.Any(), incomeAcct).
+			DoAndReturn(func(_ context.Context, c bankxgo.OverdraftCharge, _ snowflake.ID) (bool, error) {
+				as.Equal(snowflake.ID(3), c.AcctID)
+				as.Equal(day, c.Date)
+				// 200 * 18.25% / 365
+				as.True(decimal.New(1, -1).Equal(c.Interest), c.Interest.String())
+				as.True(decimal.New(2, 0).Equal(c.Fee), c.Fee.String())
+				return true, nil
+			})
+		as.Nil(job.Charge(day.Add(13 * time.Hour)))
+	})
+
+	t.Run("skips balances too small to charge", func(tt *testing.T) {
+		as := assert.New(tt)
+		job, store := newJob(tt, decimal.Zero)
+		store.EXPECT().
+			EndOfDayBalances(gomock.Any(), "USD", gomock.Any(), gomock.Any()).
+			Return([]bankxgo.AccountBalance{{AcctID: 1, Balance: decimal.New(-1, -2)}}, nil)
+		as.Nil(job.Charge(time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("charges the remaining accounts if one fails", func(tt *testing.T) {
+		as := assert.New(tt)
+		job, store := newJob(tt, decimal.New(2, 0))
+		store.EXPECT().
+			EndOfDayBalances(gomock.Any(), "USD", gomock.Any(), gomock.Any()).
+			Return([]bankxgo.AccountBalance{
+				{AcctID: 1, Balance: decimal.New(-10, 0)},
+				{AcctID: 2, Balance: decimal.New(-20, 0)},
+			}, nil)
+		store.EXPECT().
+			ChargeOverdraft(gomock.Any(), gomock.Any(), incomeAcct).
+			Return(false, errors.New("connection reset"))
+		store.EXPECT().
+			ChargeOverdraft(gomock.Any(), gomock.Any(), incomeAcct).
+			Return(false, nil)
+		err := job.Charge(time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC))
+		as.ErrorContains(err, "failed for 1 USD account(s)")
+	})
+
+	t.Run("returns an error for a day that has not ended", func(tt *testing.T) {
+		as := assert.New(tt)
+		job, _ := newJob(tt, decimal.Zero)
+		err := job.Charge(time.Now())
+		as.ErrorAs(err, &bankxgo.ErrBadRequest{})
+	})
+}
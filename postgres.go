@@ -2,11 +2,14 @@ package bankxgo
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/bwmarrin/snowflake"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 	"github.com/shopspring/decimal"
@@ -19,6 +22,12 @@ var (
 		RETURNING id;
 	`
 
+	pgInsertMemoTxnSQL = `
+		INSERT INTO transactions (id, typ, status, description, reference, metadata)
+		VALUES (DEFAULT, $1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+		RETURNING id;
+	`
+
 	pgDebitChargeSQL = `
 		INSERT INTO charges (typ, amount, tx_id, acct_id)
 		VALUES ('debit', $1, $2, $3);
@@ -29,6 +38,16 @@ var (
 		VALUES ('credit', $1, $2, $3);
 	`
 
+	pgDebitFeeChargeSQL = `
+		INSERT INTO charges (typ, amount, tx_id, acct_id, is_fee)
+		VALUES ('debit', $1, $2, $3, TRUE);
+	`
+
+	pgCreditFeeChargeSQL = `
+		INSERT INTO charges (typ, amount, tx_id, acct_id, is_fee)
+		VALUES ('credit', $1, $2, $3, TRUE);
+	`
+
 	pgSelectForUpdateAcctSQL = `
 		SELECT balance
 		FROM accounts
@@ -36,11 +55,35 @@ var (
 		FOR UPDATE;
 	`
 
+	pgSelectForUpdateAcctLimitSQL = `
+		SELECT balance, overdraft_limit
+		FROM accounts
+		WHERE pub_id = $1
+		FOR UPDATE;
+	`
+
 	pgUpdateAcctSQL = `
 		UPDATE accounts
 		SET balance = $1
 		WHERE pub_id = $2;
 	`
+
+	pgSelectWithdrawalLimitsSQL = `
+		SELECT max_per_txn, max_daily_total, max_daily_count
+		FROM withdrawal_limits
+		WHERE acct_id = $1;
+	`
+
+	pgSelectDailyWithdrawalsSQL = `
+		SELECT COALESCE(SUM(c.amount), 0), COUNT(*)
+		FROM charges c
+		JOIN transactions t ON t.id = c.tx_id
+		WHERE c.acct_id = $1
+			AND c.typ = 'credit'
+			AND NOT c.is_fee
+			AND t.typ = 'withdrawal'
+			AND c.created_at >= date_trunc('day', LOCALTIMESTAMP);
+	`
 )
 
 type PostgresEndpoint struct {
@@ -52,12 +95,13 @@ var (
 	_ Repository = (*PostgresEndpoint)(nil)
 )
 
-func NewPostgresEndpoint(connStr string, log *zerolog.Logger) (*PostgresEndpoint, error) {
-	cfg, err := pgxpool.ParseConfig(connStr)
+func NewPostgresEndpoint(dbCfg DatabaseCfg, log *zerolog.Logger) (*PostgresEndpoint, error) {
+	cfg, err := pgxpool.ParseConfig(dbCfg.ConnStr)
 	if err != nil {
 		return nil, err
 	}
-	cfg.MaxConns = 10
+	cfg.MaxConns = dbCfg.maxConns()
+	cfg.MinConns = dbCfg.MinConns
 	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
 	if err != nil {
 		return nil, err
@@ -74,18 +118,51 @@ func NewPostgresEndpoint(connStr string, log *zerolog.Logger) (*PostgresEndpoint
 	return endpt, err
 }
 
+// pgUniqueViolation is the SQLSTATE of unique constraint violations
+const pgUniqueViolation = "23505"
+
+// pgUniqueFields are the fields reported by ErrConflict by unique constraint
+var pgUniqueFields = map[string]string{
+	"accounts_email_key":  "email",
+	"accounts_pub_id_key": "acctID",
+}
+
+// pgError maps the Postgres errors callers can act upon to domain errors,
+// others are returned as is
+func pgError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		field, ok := pgUniqueFields[pgErr.ConstraintName]
+		if !ok {
+			field = pgErr.ConstraintName
+		}
+		return ErrConflict{Field: field}
+	}
+	return err
+}
+
 func (pg *PostgresEndpoint) CreditUser(
+	ctx context.Context,
 	amount decimal.Decimal,
 	userAcct,
 	sysAcct snowflake.ID,
+	limits WithdrawalLimits,
+	fee Fee,
+	memo Memo,
 ) (*decimal.Decimal, error) {
 	// smoke test in case the service validation middleware
 	// somehow is not wired up correctly
 	if sysAcct == 0 {
 		return nil, ErrInternalServer
 	}
+	if fee.Amount.IsPositive() && fee.Account == 0 {
+		return nil, ErrInternalServer
+	}
 
-	ctx := context.Background()
 	conn, err := pg.pool.Acquire(ctx)
 	if err != nil {
 		return nil, err
@@ -97,72 +174,254 @@ func (pg *PostgresEndpoint) CreditUser(
 		return nil, err
 	}
 
-	row := tx.QueryRow(ctx, pgInsertTxnSQL, "withdrawal")
-	var itxn int64
-	if err = row.Scan(&itxn); err != nil {
+	itxn, newbal, err := pgWithdraw(ctx, tx, amount, userAcct, sysAcct, limits, fee, memo)
+	if err != nil {
+		if rerr := tx.Rollback(ctx); rerr != nil {
+			ctxLog(ctx, pg.log).Err(rerr).Msgf("transaction `%v` rollback fail", itxn)
+		}
 		return nil, err
 	}
 
-	if _, err = tx.Exec(ctx, pgDebitChargeSQL, amount, itxn, sysAcct); err != nil {
-		if rerr := tx.Rollback(ctx); rerr != nil {
-			pg.log.
-				Err(rerr).
-				Str("sql", "pgDebitChargeSQL").
-				Msgf("transaction `%v` rollback fail", itxn)
-		}
-		return nil, fmt.Errorf("pgDebitChargeSQL: %w", err)
+	if err = tx.Commit(ctx); err != nil {
+		ctxLog(ctx, pg.log).Err(err).Msg("CreditUser: transaction commit fail")
 	}
 
-	if _, err = tx.Exec(ctx, pgCreditChargeSQL, amount, itxn, userAcct); err != nil {
-		if rerr := tx.Rollback(ctx); rerr != nil {
-			pg.log.
-				Err(rerr).
-				Str("sql", "pgCreditChargeSQL").
-				Msgf("transaction `%v` rollback fail", itxn)
+	return &newbal, err
+}
+
+// pgWithdraw charges the withdrawal and its fee to the user account within tx
+// and returns the transaction and the new balance. tx must be rolled back on
+// error.
+func pgWithdraw(
+	ctx context.Context,
+	tx pgx.Tx,
+	amount decimal.Decimal,
+	userAcct,
+	sysAcct snowflake.ID,
+	limits WithdrawalLimits,
+	fee Fee,
+	memo Memo,
+) (int64, decimal.Decimal, error) {
+	itxn, err := pgInsertMemoTxn(ctx, tx, "withdrawal", TxnPosted, memo)
+	if err != nil {
+		return 0, decimal.Zero, err
+	}
+
+	if _, err := tx.Exec(ctx, pgDebitChargeSQL, amount, itxn, sysAcct); err != nil {
+		return itxn, decimal.Zero, fmt.Errorf("pgDebitChargeSQL: %w", err)
+	}
+	if _, err := tx.Exec(ctx, pgCreditChargeSQL, amount, itxn, userAcct); err != nil {
+		return itxn, decimal.Zero, fmt.Errorf("pgCreditChargeSQL: %w", err)
+	}
+
+	if fee.Amount.IsPositive() {
+		if _, err := tx.Exec(ctx, pgDebitFeeChargeSQL, fee.Amount, itxn, fee.Account); err != nil {
+			return itxn, decimal.Zero, fmt.Errorf("pgDebitFeeChargeSQL: %w", err)
+		}
+		if _, err := tx.Exec(ctx, pgCreditFeeChargeSQL, fee.Amount, itxn, userAcct); err != nil {
+			return itxn, decimal.Zero, fmt.Errorf("pgCreditFeeChargeSQL: %w", err)
 		}
-		return nil, fmt.Errorf("pgCreditChargeSQL: %w", err)
 	}
 
-	row = tx.QueryRow(ctx, pgSelectForUpdateAcctSQL, userAcct)
-	var bal decimal.Decimal
-	if err = row.Scan(&bal); err != nil {
+	row := tx.QueryRow(ctx, pgSelectForUpdateAcctLimitSQL, userAcct)
+	var bal, overdraft decimal.Decimal
+	if err := row.Scan(&bal, &overdraft); err != nil {
+		return itxn, decimal.Zero, err
+	}
+
+	total := amount.Add(fee.Amount)
+	if bal.Add(overdraft).LessThan(total) {
+		return itxn, decimal.Zero, ErrInsufficientFunds{AcctID: userAcct}
+	}
+
+	// the account row lock above serializes withdrawals per account
+	// so the daily totals cannot be raced by concurrent transactions
+	if err := checkWithdrawalLimits(ctx, tx, amount, userAcct, limits); err != nil {
+		return itxn, decimal.Zero, err
+	}
+
+	newbal := bal.Sub(total)
+	if _, err := tx.Exec(ctx, pgUpdateAcctSQL, newbal, userAcct); err != nil {
+		return itxn, decimal.Zero, err
+	}
+
+	ev := &BalanceEvent{
+		AcctID:  userAcct,
+		Type:    "withdrawal",
+		Amount:  amount.Neg(),
+		Fee:     fee.Amount,
+		Balance: newbal,
+	}
+	if err := pgRecordBalanceEvent(ctx, tx, itxn, ev); err != nil {
+		return itxn, decimal.Zero, err
+	}
+	return itxn, newbal, nil
+}
+
+// checkWithdrawalLimits applies the per account overrides, if any, on top of the
+// given (currency) limits and checks the withdrawal against them. It must be called
+// after the withdrawal charge is inserted as today's totals are expected to include it.
+func checkWithdrawalLimits(
+	ctx context.Context,
+	tx pgx.Tx,
+	amount decimal.Decimal,
+	userAcct snowflake.ID,
+	limits WithdrawalLimits,
+) error {
+	var (
+		maxPerTxn, maxDailyTotal decimal.NullDecimal
+		maxDailyCount            *int
+	)
+	row := tx.QueryRow(ctx, pgSelectWithdrawalLimitsSQL, userAcct)
+	err := row.Scan(&maxPerTxn, &maxDailyTotal, &maxDailyCount)
+	if err != nil && err != pgx.ErrNoRows {
+		return fmt.Errorf("pgSelectWithdrawalLimitsSQL: %w", err)
+	}
+	if maxPerTxn.Valid {
+		limits.MaxPerTxn = maxPerTxn.Decimal
+	}
+	if maxDailyTotal.Valid {
+		limits.MaxDailyTotal = maxDailyTotal.Decimal
+	}
+	if maxDailyCount != nil {
+		limits.MaxDailyCount = *maxDailyCount
+	}
+
+	if limits.MaxPerTxn.IsPositive() && amount.GreaterThan(limits.MaxPerTxn) {
+		return ErrBadRequest{Fields: map[string]string{"withdrawalLimit": "max_per_txn"}}
+	}
+	if !limits.MaxDailyTotal.IsPositive() && limits.MaxDailyCount <= 0 {
+		return nil
+	}
+
+	var (
+		total decimal.Decimal
+		count int
+	)
+	row = tx.QueryRow(ctx, pgSelectDailyWithdrawalsSQL, userAcct)
+	if err = row.Scan(&total, &count); err != nil {
+		return fmt.Errorf("pgSelectDailyWithdrawalsSQL: %w", err)
+	}
+	if limits.MaxDailyTotal.IsPositive() && total.GreaterThan(limits.MaxDailyTotal) {
+		return ErrBadRequest{Fields: map[string]string{"withdrawalLimit": "max_daily_total"}}
+	}
+	if limits.MaxDailyCount > 0 && count > limits.MaxDailyCount {
+		return ErrBadRequest{Fields: map[string]string{"withdrawalLimit": "max_daily_count"}}
+	}
+
+	return nil
+}
+
+func (pg *PostgresEndpoint) DebitUser(
+	ctx context.Context,
+	amount decimal.Decimal,
+	userAcct,
+	sysAcct snowflake.ID,
+	memo Memo,
+) (*decimal.Decimal, error) {
+	// smoke test in case the service validation middleware
+	// somehow is not wired up correctly
+	if sysAcct == 0 {
+		return nil, ErrInternalServer
+	}
+
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
 		return nil, err
 	}
+	defer conn.Release()
 
-	if bal.LessThan(amount) {
-		if err = tx.Rollback(ctx); err != nil {
-			pg.log.Err(err).Msgf("transaction `%v` rollback fail", itxn)
-		}
-		return nil, ErrBadRequest{Fields: map[string]string{"amount": "insufficient balance"}}
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, err
 	}
 
-	newbal := bal.Sub(amount)
-	if _, err = tx.Exec(ctx, pgUpdateAcctSQL, newbal, userAcct); err != nil {
+	itxn, newbal, err := pgDeposit(ctx, tx, amount, userAcct, sysAcct, memo)
+	if err != nil {
 		if rerr := tx.Rollback(ctx); rerr != nil {
-			pg.log.Err(rerr).Msgf("transaction `%v` rollback fail", itxn)
+			ctxLog(ctx, pg.log).Err(rerr).Msgf("transaction `%v` rollback fail", itxn)
 		}
 		return nil, err
 	}
 
 	if err = tx.Commit(ctx); err != nil {
-		pg.log.Err(err).Msg("CreditUser: transaction commit fail")
+		ctxLog(ctx, pg.log).Err(err).Msg("DebitUser: transaction commit fail")
 	}
 
 	return &newbal, err
 }
 
-func (pg *PostgresEndpoint) DebitUser(
+// pgDeposit credits the deposit to the user account within tx and returns the
+// transaction and the new balance. tx must be rolled back on error.
+func pgDeposit(
+	ctx context.Context,
+	tx pgx.Tx,
+	amount decimal.Decimal,
+	userAcct,
+	sysAcct snowflake.ID,
+	memo Memo,
+) (int64, decimal.Decimal, error) {
+	itxn, err := pgInsertMemoTxn(ctx, tx, "deposit", TxnPosted, memo)
+	if err != nil {
+		return 0, decimal.Zero, err
+	}
+
+	if _, err := tx.Exec(ctx, pgDebitChargeSQL, amount, itxn, userAcct); err != nil {
+		return itxn, decimal.Zero, fmt.Errorf("pgDebitChargeSQL: %w", err)
+	}
+	if _, err := tx.Exec(ctx, pgCreditChargeSQL, amount, itxn, sysAcct); err != nil {
+		return itxn, decimal.Zero, fmt.Errorf("pgCreditChargeSQL: %w", err)
+	}
+
+	row := tx.QueryRow(ctx, pgSelectForUpdateAcctSQL, userAcct)
+	var bal decimal.Decimal
+	if err := row.Scan(&bal); err != nil {
+		return itxn, decimal.Zero, err
+	}
+
+	newbal := bal.Add(amount)
+	if _, err := tx.Exec(ctx, pgUpdateAcctSQL, newbal, userAcct); err != nil {
+		return itxn, decimal.Zero, err
+	}
+
+	ev := &BalanceEvent{
+		AcctID:  userAcct,
+		Type:    "deposit",
+		Amount:  amount,
+		Balance: newbal,
+	}
+	if err := pgRecordBalanceEvent(ctx, tx, itxn, ev); err != nil {
+		return itxn, decimal.Zero, err
+	}
+	return itxn, newbal, nil
+}
+
+// pgInsertMemoTxn inserts a transaction of type typ and status with the memo
+// and returns its ID
+func pgInsertMemoTxn(ctx context.Context, tx pgx.Tx, typ, status string, memo Memo) (int64, error) {
+	meta, err := memo.metadataJSON()
+	if err != nil {
+		return 0, err
+	}
+	var itxn int64
+	row := tx.QueryRow(ctx, pgInsertMemoTxnSQL, typ, status, memo.Description, memo.Reference, meta)
+	if err = row.Scan(&itxn); err != nil {
+		return 0, fmt.Errorf("pgInsertMemoTxnSQL: %w", err)
+	}
+	return itxn, nil
+}
+
+func (pg *PostgresEndpoint) DebitUserPending(
+	ctx context.Context,
 	amount decimal.Decimal,
 	userAcct,
 	sysAcct snowflake.ID,
+	memo Memo,
 ) (*decimal.Decimal, error) {
-	// smoke test in case the service validation middleware
-	// somehow is not wired up correctly
 	if sysAcct == 0 {
 		return nil, ErrInternalServer
 	}
 
-	ctx := context.Background()
 	conn, err := pg.pool.Acquire(ctx)
 	if err != nil {
 		return nil, err
@@ -173,56 +432,147 @@ func (pg *PostgresEndpoint) DebitUser(
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback(ctx)
 
-	row := tx.QueryRow(ctx, pgInsertTxnSQL, "deposit")
-	var itxn int64
-	if err = row.Scan(&itxn); err != nil {
+	// the account row is locked first so that concurrent deposits with the
+	// same reference are checked one after the other
+	var bal decimal.Decimal
+	if err = tx.QueryRow(ctx, pgSelectForUpdateAcctSQL, userAcct).Scan(&bal); err != nil {
 		return nil, err
 	}
+	// references of settled deposits are not given out again either, or a
+	// replayed callback of the settled one would settle the new one
+	sql := `
+	SELECT EXISTS (
+		SELECT 1
+		FROM transactions t
+		JOIN charges c ON c.tx_id = t.id
+		WHERE c.acct_id = $1
+			AND t.reference = $2
+			AND (t.status = 'pending' OR t.settled_at IS NOT NULL)
+	);
+	`
+	var exists bool
+	if err = tx.QueryRow(ctx, sql, userAcct, memo.Reference).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrConflict{Field: "reference"}
+	}
 
+	// the charges are booked as is, the balance only changes once settled
+	itxn, err := pgInsertMemoTxn(ctx, tx, "deposit", TxnPending, memo)
+	if err != nil {
+		return nil, err
+	}
 	if _, err = tx.Exec(ctx, pgDebitChargeSQL, amount, itxn, userAcct); err != nil {
-		if rerr := tx.Rollback(ctx); rerr != nil {
-			pg.log.
-				Err(rerr).
-				Str("sql", "pgDebitChargeSQL").
-				Msgf("transaction `%v` rollback fail", itxn)
-		}
 		return nil, fmt.Errorf("pgDebitChargeSQL: %w", err)
 	}
-
 	if _, err = tx.Exec(ctx, pgCreditChargeSQL, amount, itxn, sysAcct); err != nil {
-		if rerr := tx.Rollback(ctx); rerr != nil {
-			pg.log.
-				Err(rerr).
-				Str("sql", "pgCreditChargeSQL").
-				Msgf("transaction `%v` rollback fail", itxn)
-		}
 		return nil, fmt.Errorf("pgCreditChargeSQL: %w", err)
 	}
+	if _, err = tx.Exec(ctx, `UPDATE accounts SET pending = pending + $1 WHERE pub_id = $2;`, amount, userAcct); err != nil {
+		return nil, err
+	}
 
-	row = tx.QueryRow(ctx, pgSelectForUpdateAcctSQL, userAcct)
-	var bal decimal.Decimal
-	if err = row.Scan(&bal); err != nil {
+	if err = tx.Commit(ctx); err != nil {
+		ctxLog(ctx, pg.log).Err(err).Msg("DebitUserPending: transaction commit fail")
 		return nil, err
 	}
+	return &bal, nil
+}
 
-	newbal := bal.Add(amount)
-	if _, err = tx.Exec(ctx, pgUpdateAcctSQL, newbal, userAcct); err != nil {
-		if rerr := tx.Rollback(ctx); rerr != nil {
-			pg.log.Err(rerr).Msgf("transaction `%v` rollback fail", itxn)
+func (pg *PostgresEndpoint) SettleTransaction(ctx context.Context, acctID snowflake.ID, reference, status string) (*Transaction, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var bal decimal.Decimal
+	if err = tx.QueryRow(ctx, pgSelectForUpdateAcctSQL, acctID).Scan(&bal); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound{ID: acctID.Int64()}
 		}
 		return nil, err
 	}
+	// the deposit made pending with the reference, which may have been
+	// settled already so that retried callbacks get the same answer
+	sql := `
+	SELECT t.id, t.status, c.amount,
+		COALESCE(t.description, ''), COALESCE(t.reference, ''), t.metadata, t.created_at, t.settled_at
+	FROM transactions t
+	JOIN charges c ON c.tx_id = t.id
+	WHERE c.acct_id = $1
+		AND c.typ = 'debit'
+		AND NOT c.is_fee
+		AND t.typ = 'deposit'
+		AND t.reference = $2
+		AND (t.status = 'pending' OR t.settled_at IS NOT NULL)
+	LIMIT 1;
+	`
+	txn := Transaction{Type: "deposit"}
+	row := tx.QueryRow(ctx, sql, acctID, reference)
+	err = row.Scan(&txn.ID, &txn.Status, &txn.Amount, &txn.Description, &txn.Reference, &txn.Metadata, &txn.CreatedAt, &txn.SettledAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if txn.Status == status {
+		return &txn, nil
+	}
+	if txn.Status != TxnPending {
+		return nil, ErrBadRequest{Fields: map[string]string{"status": "already " + txn.Status}}
+	}
+
+	txn.Status = status
+	// the charges keep the time they were booked at, end of day balances and
+	// statements go by the time of settlement
+	sql = `UPDATE transactions SET status = $1, settled_at = LOCALTIMESTAMP WHERE id = $2 RETURNING settled_at;`
+	if err = tx.QueryRow(ctx, sql, status, txn.ID).Scan(&txn.SettledAt); err != nil {
+		return nil, err
+	}
+	if status == TxnFailed {
+		if _, err = tx.Exec(ctx, `UPDATE accounts SET pending = pending - $1 WHERE pub_id = $2;`, txn.Amount, acctID); err != nil {
+			return nil, err
+		}
+	} else {
+		newbal := bal.Add(txn.Amount)
+		sql = `
+		UPDATE accounts
+		SET balance = $1, pending = pending - $2
+		WHERE pub_id = $3;
+		`
+		if _, err = tx.Exec(ctx, sql, newbal, txn.Amount, acctID); err != nil {
+			return nil, err
+		}
+		ev := &BalanceEvent{
+			AcctID:  acctID,
+			Type:    "deposit",
+			Amount:  txn.Amount,
+			Balance: newbal,
+		}
+		if err = pgRecordBalanceEvent(ctx, tx, txn.ID, ev); err != nil {
+			return nil, err
+		}
+	}
 
 	if err = tx.Commit(ctx); err != nil {
-		pg.log.Err(err).Msg("DebitUser: transaction commit fail")
+		ctxLog(ctx, pg.log).Err(err).Msg("SettleTransaction: transaction commit fail")
+		return nil, err
 	}
-
-	return &newbal, err
+	return &txn, nil
 }
 
-func (pg *PostgresEndpoint) CreateAccount(req CreateAccountReq) error {
-	ctx := context.Background()
+func (pg *PostgresEndpoint) CreateAccount(ctx context.Context, req CreateAccountReq) error {
 	conn, err := pg.pool.Acquire(ctx)
 	if err != nil {
 		return err
@@ -230,19 +580,22 @@ func (pg *PostgresEndpoint) CreateAccount(req CreateAccountReq) error {
 	defer conn.Release()
 
 	sql := `
-	INSERT INTO accounts (pub_id, email, currency)
-	VALUES ($1, $2, $3);
+	INSERT INTO accounts (pub_id, email, currency, acct_type)
+	VALUES ($1, $2, $3, $4);
 	`
 
-	if _, err = conn.Exec(ctx, sql, req.AcctID, req.Email, req.Currency); err != nil {
-		return err
+	typ := req.Type
+	if typ == "" {
+		typ = AccountDeposit
+	}
+	if _, err = conn.Exec(ctx, sql, req.AcctID, req.Email, req.Currency, typ); err != nil {
+		return pgError(err)
 	}
 
 	return err
 }
 
-func (pg *PostgresEndpoint) GetAccount(id snowflake.ID) (*Account, error) {
-	ctx := context.Background()
+func (pg *PostgresEndpoint) GetAccount(ctx context.Context, id snowflake.ID) (*Account, error) {
 	conn, err := pg.pool.Acquire(ctx)
 	if err != nil {
 		return nil, err
@@ -250,17 +603,18 @@ func (pg *PostgresEndpoint) GetAccount(id snowflake.ID) (*Account, error) {
 	defer conn.Release()
 
 	sql := `
-	SELECT email, currency, balance
+	SELECT email, currency, balance, frozen, acct_type, overdraft_limit, pending
 	FROM accounts
 	WHERE pub_id = $1;
 	`
 
 	row := conn.QueryRow(ctx, sql, id)
 	var (
-		rcur, remail string
-		rbal         decimal.Decimal
+		rcur, remail, rtyp     string
+		rbal, rlimit, rpending decimal.Decimal
+		rfrozen                bool
 	)
-	if err = row.Scan(&remail, &rcur, &rbal); err != nil {
+	if err = row.Scan(&remail, &rcur, &rbal, &rfrozen, &rtyp, &rlimit, &rpending); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound{ID: id.Int64()}
 		}
@@ -268,16 +622,19 @@ func (pg *PostgresEndpoint) GetAccount(id snowflake.ID) (*Account, error) {
 	}
 
 	acct := &Account{
-		AcctID:   id,
-		Currency: rcur,
-		Balance:  rbal,
-		Email:    remail,
+		AcctID:         id,
+		Currency:       rcur,
+		Balance:        rbal,
+		Email:          remail,
+		Frozen:         rfrozen,
+		Type:           rtyp,
+		OverdraftLimit: rlimit,
+		Pending:        rpending,
 	}
 	return acct, err
 }
 
-func (pg *PostgresEndpoint) GetAccountCharges(id snowflake.ID) ([]Charge, error) {
-	ctx := context.Background()
+func (pg *PostgresEndpoint) GetAccountCharges(ctx context.Context, id snowflake.ID) ([]Charge, error) {
 	conn, err := pg.pool.Acquire(ctx)
 	if err != nil {
 		return nil, err
@@ -285,26 +642,45 @@ func (pg *PostgresEndpoint) GetAccountCharges(id snowflake.ID) ([]Charge, error)
 	defer conn.Release()
 
 	sql := `
-	SELECT amount, typ, created_at FROM charges
-	WHERE acct_id = $1;
+	SELECT c.id, c.amount, c.typ, c.is_fee, t.id, t.typ,
+		COALESCE(t.description, ''), COALESCE(t.reference, ''), t.metadata, c.created_at, t.settled_at
+	FROM charges c
+	JOIN transactions t ON t.id = c.tx_id
+	WHERE c.acct_id = $1 AND t.status = 'posted'
+	ORDER BY COALESCE(t.settled_at, c.created_at), c.id;
 	`
 	rows, err := conn.Query(ctx, sql, id)
 	if err != nil {
 		return nil, err
 	}
 	var (
-		amt       decimal.Decimal
-		typ       string
-		createdAt time.Time
-		collected []Charge
+		cid, txID  int64
+		amt        decimal.Decimal
+		typ, txTyp string
+		isFee      bool
+		desc, ref  string
+		meta       map[string]any
+		createdAt  time.Time
+		settledAt  *time.Time
+		collected  []Charge
 	)
 	for rows.Next() {
-		rows.Scan(&amt, &typ, &createdAt)
-		collected = append(collected, Charge{
+		meta, settledAt = nil, nil
+		rows.Scan(&cid, &amt, &typ, &isFee, &txID, &txTyp, &desc, &ref, &meta, &createdAt, &settledAt)
+		c := Charge{
+			ID:        cid,
 			Amount:    amt,
 			Typ:       typ,
+			Fee:       isFee,
+			TxID:      txID,
+			TxTyp:     txTyp,
+			Memo:      Memo{Description: desc, Reference: ref, Metadata: meta},
 			CreatedAt: createdAt,
-		})
+		}
+		if settledAt != nil {
+			c.SettledAt = *settledAt
+		}
+		collected = append(collected, c)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("charges rows.Scan: %w", err)
@@ -312,3 +688,1749 @@ func (pg *PostgresEndpoint) GetAccountCharges(id snowflake.ID) ([]Charge, error)
 
 	return collected, err
 }
+
+func (pg *PostgresEndpoint) ListTransactions(ctx context.Context, acctID snowflake.ID, filter TransactionFilter) ([]Transaction, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	// debits add to the balance of the account and credits take off it
+	sql := `
+	SELECT t.id, t.typ, t.status,
+		COALESCE(SUM(CASE c.typ WHEN 'debit' THEN c.amount ELSE -c.amount END) FILTER (WHERE NOT c.is_fee), 0),
+		COALESCE(SUM(CASE c.typ WHEN 'credit' THEN c.amount ELSE -c.amount END) FILTER (WHERE c.is_fee), 0),
+		COALESCE(t.description, ''), COALESCE(t.reference, ''), t.metadata, MIN(c.created_at), t.settled_at
+	FROM charges c
+	JOIN transactions t ON t.id = c.tx_id
+	WHERE c.acct_id = $1
+		AND ($2 = '' OR t.reference = $2)
+		AND ($3 = 0 OR t.id < $3)
+	GROUP BY t.id
+	ORDER BY t.id DESC
+	LIMIT $4;
+	`
+	rows, err := conn.Query(ctx, sql, acctID, filter.Reference, filter.Before, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	txns := []Transaction{}
+	for rows.Next() {
+		var t Transaction
+		err = rows.Scan(&t.ID, &t.Type, &t.Status, &t.Amount, &t.Fee, &t.Description, &t.Reference, &t.Metadata, &t.CreatedAt, &t.SettledAt)
+		if err != nil {
+			return nil, err
+		}
+		txns = append(txns, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("transactions rows.Scan: %w", err)
+	}
+	return txns, nil
+}
+
+var _ InterestStore = (*PostgresEndpoint)(nil)
+
+func (pg *PostgresEndpoint) EndOfDayBalances(
+	ctx context.Context,
+	currency string,
+	day time.Time,
+	exclude []snowflake.ID,
+) ([]AccountBalance, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `
+	SELECT a.pub_id, a.acct_type, COALESCE(SUM(CASE WHEN c.typ = 'debit' THEN c.amount ELSE -c.amount END), 0)
+	FROM accounts a
+	LEFT JOIN (charges c JOIN transactions t ON t.id = c.tx_id AND t.status = 'posted')
+		ON c.acct_id = a.pub_id AND COALESCE(t.settled_at, c.created_at) < $2::date + 1
+	WHERE a.currency = $1
+		AND a.created_at < $2::date + 1
+		AND a.pub_id <> ALL($3)
+	GROUP BY a.pub_id, a.acct_type;
+	`
+	ids := make([]int64, len(exclude))
+	for i, id := range exclude {
+		ids[i] = id.Int64()
+	}
+	rows, err := conn.Query(ctx, sql, currency, day, ids)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		id        int64
+		typ       string
+		bal       decimal.Decimal
+		collected []AccountBalance
+	)
+	for rows.Next() {
+		rows.Scan(&id, &typ, &bal)
+		collected = append(collected, AccountBalance{
+			AcctID:  snowflake.ParseInt64(id),
+			Type:    typ,
+			Balance: bal,
+		})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("balances rows.Scan: %w", err)
+	}
+
+	return collected, err
+}
+
+func (pg *PostgresEndpoint) InsertAccruals(ctx context.Context, accruals []InterestAccrual) (int64, error) {
+	if len(accruals) == 0 {
+		return 0, nil
+	}
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	sql := `
+	INSERT INTO interest_accruals (acct_id, accrual_date, balance, rate, amount)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (acct_id, accrual_date) DO NOTHING;
+	`
+	batch := &pgx.Batch{}
+	for _, a := range accruals {
+		batch.Queue(sql, a.AcctID, a.Date, a.Balance, a.Rate, a.Amount)
+	}
+	results := conn.SendBatch(ctx, batch)
+	defer results.Close()
+
+	var inserted int64
+	for range accruals {
+		tag, err := results.Exec()
+		if err != nil {
+			return inserted, fmt.Errorf("insert accrual: %w", err)
+		}
+		inserted += tag.RowsAffected()
+	}
+
+	return inserted, err
+}
+
+func (pg *PostgresEndpoint) UnpostedAccrualAccounts(ctx context.Context, currency string, from, to time.Time) ([]snowflake.ID, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `
+	SELECT DISTINCT ia.acct_id
+	FROM interest_accruals ia
+	JOIN accounts a ON a.pub_id = ia.acct_id
+	WHERE a.currency = $1
+		AND ia.accrual_date >= $2
+		AND ia.accrual_date < $3
+		AND ia.posted_at IS NULL;
+	`
+	rows, err := conn.Query(ctx, sql, currency, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		id        int64
+		collected []snowflake.ID
+	)
+	for rows.Next() {
+		rows.Scan(&id)
+		collected = append(collected, snowflake.ParseInt64(id))
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("accrual accounts rows.Scan: %w", err)
+	}
+
+	return collected, err
+}
+
+func (pg *PostgresEndpoint) PostInterest(
+	ctx context.Context,
+	acctID,
+	expenseAcct snowflake.ID,
+	from,
+	to time.Time,
+) (*decimal.Decimal, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, err
+	}
+	// no-op if the transaction is committed
+	defer tx.Rollback(ctx)
+
+	// locking the accruals makes concurrent or repeated posting runs
+	// skip those already posted
+	sumSQL := `
+	WITH locked AS (
+		SELECT amount
+		FROM interest_accruals
+		WHERE acct_id = $1
+			AND accrual_date >= $2
+			AND accrual_date < $3
+			AND posted_at IS NULL
+		FOR UPDATE
+	)
+	SELECT COALESCE(SUM(amount), 0), COUNT(*) FROM locked;
+	`
+	var (
+		sum   decimal.Decimal
+		count int
+	)
+	if err = tx.QueryRow(ctx, sumSQL, acctID, from, to).Scan(&sum, &count); err != nil {
+		return nil, fmt.Errorf("sum accruals: %w", err)
+	}
+	amount := sum.RoundBank(2)
+	if count == 0 {
+		return &amount, nil
+	}
+
+	var itxn *int64
+	if amount.IsPositive() {
+		var id int64
+		if err = tx.QueryRow(ctx, pgInsertTxnSQL, "interest").Scan(&id); err != nil {
+			return nil, fmt.Errorf("pgInsertTxnSQL: %w", err)
+		}
+		itxn = &id
+		if _, err = tx.Exec(ctx, pgDebitChargeSQL, amount, id, acctID); err != nil {
+			return nil, fmt.Errorf("pgDebitChargeSQL: %w", err)
+		}
+		if _, err = tx.Exec(ctx, pgCreditChargeSQL, amount, id, expenseAcct); err != nil {
+			return nil, fmt.Errorf("pgCreditChargeSQL: %w", err)
+		}
+		var bal decimal.Decimal
+		if err = tx.QueryRow(ctx, pgSelectForUpdateAcctSQL, acctID).Scan(&bal); err != nil {
+			return nil, fmt.Errorf("pgSelectForUpdateAcctSQL: %w", err)
+		}
+		if _, err = tx.Exec(ctx, pgUpdateAcctSQL, bal.Add(amount), acctID); err != nil {
+			return nil, fmt.Errorf("pgUpdateAcctSQL: %w", err)
+		}
+		ev := &BalanceEvent{AcctID: acctID, Type: "interest", Amount: amount, Balance: bal.Add(amount)}
+		if err = pgRecordBalanceEvent(ctx, tx, id, ev); err != nil {
+			return nil, err
+		}
+	}
+
+	markSQL := `
+	UPDATE interest_accruals
+	SET posted_at = CURRENT_TIMESTAMP, tx_id = $4
+	WHERE acct_id = $1
+		AND accrual_date >= $2
+		AND accrual_date < $3
+		AND posted_at IS NULL;
+	`
+	if _, err = tx.Exec(ctx, markSQL, acctID, from, to, itxn); err != nil {
+		return nil, fmt.Errorf("mark accruals posted: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		ctxLog(ctx, pg.log).Err(err).Msg("PostInterest: transaction commit fail")
+		return nil, err
+	}
+
+	return &amount, err
+}
+
+var _ OverdraftStore = (*PostgresEndpoint)(nil)
+
+func (pg *PostgresEndpoint) ChargeOverdraft(ctx context.Context, charge OverdraftCharge, incomeAcct snowflake.ID) (bool, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return false, err
+	}
+	// no-op if the transaction is committed
+	defer tx.Rollback(ctx)
+
+	// the charge of the day is claimed first, so concurrent or repeated runs
+	// skip accounts already charged
+	claimSQL := `
+	INSERT INTO overdraft_charges (acct_id, charge_date, balance, rate, interest, fee)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (acct_id, charge_date) DO NOTHING;
+	`
+	tag, err := tx.Exec(ctx, claimSQL, charge.AcctID, charge.Date, charge.Balance, charge.Rate, charge.Interest, charge.Fee)
+	if err != nil {
+		return false, fmt.Errorf("insert overdraft charge: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	var itxn int64
+	if err = tx.QueryRow(ctx, pgInsertTxnSQL, "overdraft").Scan(&itxn); err != nil {
+		return false, fmt.Errorf("pgInsertTxnSQL: %w", err)
+	}
+	if charge.Interest.IsPositive() {
+		if _, err = tx.Exec(ctx, pgDebitChargeSQL, charge.Interest, itxn, incomeAcct); err != nil {
+			return false, fmt.Errorf("pgDebitChargeSQL: %w", err)
+		}
+		if _, err = tx.Exec(ctx, pgCreditChargeSQL, charge.Interest, itxn, charge.AcctID); err != nil {
+			return false, fmt.Errorf("pgCreditChargeSQL: %w", err)
+		}
+	}
+	if charge.Fee.IsPositive() {
+		if _, err = tx.Exec(ctx, pgDebitFeeChargeSQL, charge.Fee, itxn, incomeAcct); err != nil {
+			return false, fmt.Errorf("pgDebitFeeChargeSQL: %w", err)
+		}
+		if _, err = tx.Exec(ctx, pgCreditFeeChargeSQL, charge.Fee, itxn, charge.AcctID); err != nil {
+			return false, fmt.Errorf("pgCreditFeeChargeSQL: %w", err)
+		}
+	}
+
+	// the charges may take the balance below the overdraft limit, they are
+	// owed regardless
+	var bal decimal.Decimal
+	if err = tx.QueryRow(ctx, pgSelectForUpdateAcctSQL, charge.AcctID).Scan(&bal); err != nil {
+		return false, fmt.Errorf("pgSelectForUpdateAcctSQL: %w", err)
+	}
+	newbal := bal.Sub(charge.Interest).Sub(charge.Fee)
+	if _, err = tx.Exec(ctx, pgUpdateAcctSQL, newbal, charge.AcctID); err != nil {
+		return false, fmt.Errorf("pgUpdateAcctSQL: %w", err)
+	}
+	ev := &BalanceEvent{
+		AcctID:  charge.AcctID,
+		Type:    "overdraft",
+		Amount:  charge.Interest.Neg(),
+		Fee:     charge.Fee,
+		Balance: newbal,
+	}
+	if err = pgRecordBalanceEvent(ctx, tx, itxn, ev); err != nil {
+		return false, err
+	}
+
+	markSQL := `
+	UPDATE overdraft_charges
+	SET tx_id = $3
+	WHERE acct_id = $1 AND charge_date = $2;
+	`
+	if _, err = tx.Exec(ctx, markSQL, charge.AcctID, charge.Date, itxn); err != nil {
+		return false, fmt.Errorf("mark overdraft charge: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		ctxLog(ctx, pg.log).Err(err).Msg("ChargeOverdraft: transaction commit fail")
+		return false, err
+	}
+
+	return true, nil
+}
+
+const pgStatementJobColumns = `
+	pub_id, acct_id, format, period_from, period_to, status,
+	attempts, COALESCE(error, ''), COALESCE(file_key, ''), created_at, finished_at
+`
+
+func scanStatementJob(row pgx.Row) (*StatementJob, error) {
+	var (
+		id, acctID int64
+		from       *time.Time
+		job        StatementJob
+	)
+	err := row.Scan(
+		&id, &acctID, &job.Format, &from, &job.To, &job.Status,
+		&job.Attempts, &job.Error, &job.FileKey, &job.CreatedAt, &job.FinishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	job.ID = snowflake.ParseInt64(id)
+	job.AcctID = snowflake.ParseInt64(acctID)
+	if from != nil {
+		job.From = *from
+	}
+	return &job, nil
+}
+
+// nullDate maps the zero time to NULL
+func nullDate(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func (pg *PostgresEndpoint) CreateStatementJob(ctx context.Context, job StatementJob) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sql := `
+	INSERT INTO statement_jobs (pub_id, acct_id, format, period_from, period_to, status)
+	VALUES ($1, $2, $3, $4, $5, $6);
+	`
+	_, err = conn.Exec(ctx, sql, job.ID, job.AcctID, job.Format, nullDate(job.From), job.To, job.Status)
+	return pgError(err)
+}
+
+func (pg *PostgresEndpoint) GetStatementJob(ctx context.Context, id snowflake.ID) (*StatementJob, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `SELECT ` + pgStatementJobColumns + ` FROM statement_jobs WHERE pub_id = $1;`
+	job, err := scanStatementJob(conn.QueryRow(ctx, sql, id))
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound{ID: id.Int64()}
+	}
+	return job, err
+}
+
+func (pg *PostgresEndpoint) FindDoneStatementJob(
+	ctx context.Context,
+	acctID snowflake.ID,
+	format string,
+	from,
+	to time.Time,
+) (*StatementJob, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `SELECT ` + pgStatementJobColumns + `
+	FROM statement_jobs
+	WHERE acct_id = $1
+		AND format = $2
+		AND period_from IS NOT DISTINCT FROM $3
+		AND period_to = $4
+		AND status = 'done'
+	ORDER BY id DESC
+	LIMIT 1;
+	`
+	job, err := scanStatementJob(conn.QueryRow(ctx, sql, acctID, format, nullDate(from), to))
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound{ID: acctID.Int64()}
+	}
+	return job, err
+}
+
+func (pg *PostgresEndpoint) ClaimStatementJob(ctx context.Context, lease time.Duration, maxAttempts int) (*StatementJob, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	abandonSQL := `
+	UPDATE statement_jobs
+	SET status = 'failed', error = 'abandoned after ' || attempts || ' attempts', finished_at = LOCALTIMESTAMP
+	WHERE status = 'running'
+		AND started_at < LOCALTIMESTAMP - make_interval(secs => $1)
+		AND attempts >= $2;
+	`
+	if _, err = conn.Exec(ctx, abandonSQL, lease.Seconds(), maxAttempts); err != nil {
+		return nil, fmt.Errorf("abandon statement jobs: %w", err)
+	}
+
+	sql := `
+	UPDATE statement_jobs
+	SET status = 'running', started_at = LOCALTIMESTAMP, attempts = attempts + 1
+	WHERE id = (
+		SELECT id
+		FROM statement_jobs
+		WHERE status = 'queued'
+			OR (status = 'running' AND started_at < LOCALTIMESTAMP - make_interval(secs => $1) AND attempts < $2)
+		ORDER BY id
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1
+	)
+	RETURNING ` + pgStatementJobColumns + `;`
+	job, err := scanStatementJob(conn.QueryRow(ctx, sql, lease.Seconds(), maxAttempts))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+func (pg *PostgresEndpoint) CompleteStatementJob(ctx context.Context, id snowflake.ID, fileKey string) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sql := `
+	UPDATE statement_jobs
+	SET status = 'done', file_key = $2, error = NULL, finished_at = LOCALTIMESTAMP
+	WHERE pub_id = $1;
+	`
+	_, err = conn.Exec(ctx, sql, id, fileKey)
+	return err
+}
+
+func (pg *PostgresEndpoint) FailStatementJob(ctx context.Context, id snowflake.ID, errMsg string, retry bool) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sql := `
+	UPDATE statement_jobs
+	SET status = 'failed', error = $2, finished_at = LOCALTIMESTAMP
+	WHERE pub_id = $1;
+	`
+	if retry {
+		sql = `
+		UPDATE statement_jobs
+		SET status = 'queued', error = $2
+		WHERE pub_id = $1;
+		`
+	}
+	_, err = conn.Exec(ctx, sql, id, errMsg)
+	return err
+}
+
+func (pg *PostgresEndpoint) CreateStatementVerification(ctx context.Context, v StatementVerification) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sql := `
+	INSERT INTO statement_verifications
+		(code, acct_id, currency, period_from, period_to, opening, closing, lines, content_hash, issued_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (code) DO NOTHING;
+	`
+	_, err = conn.Exec(ctx, sql,
+		v.Code, v.AcctID, v.Currency, v.From, v.To,
+		v.Opening, v.Closing, v.Lines, v.ContentHash, v.IssuedAt,
+	)
+	return err
+}
+
+func (pg *PostgresEndpoint) GetStatementVerification(ctx context.Context, code string) (*StatementVerification, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `
+	SELECT code, acct_id, currency, period_from, period_to, opening, closing, lines, content_hash, issued_at
+	FROM statement_verifications
+	WHERE code = $1;
+	`
+	var (
+		v      StatementVerification
+		acctID int64
+	)
+	err = conn.QueryRow(ctx, sql, code).Scan(
+		&v.Code, &acctID, &v.Currency, &v.From, &v.To,
+		&v.Opening, &v.Closing, &v.Lines, &v.ContentHash, &v.IssuedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	v.AcctID = snowflake.ParseInt64(acctID)
+	return &v, nil
+}
+
+func (pg *PostgresEndpoint) GetStatementPreference(ctx context.Context, acctID snowflake.ID) (*StatementPreference, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `
+	SELECT COALESCE(template, ''), COALESCE(locale, ''), COALESCE(cycle_day, 0)
+	FROM statement_preferences
+	WHERE acct_id = $1;
+	`
+	var pref StatementPreference
+	err = conn.QueryRow(ctx, sql, acctID).Scan(&pref.Template, &pref.Locale, &pref.CycleDay)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound{ID: acctID.Int64()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+func (pg *PostgresEndpoint) SetStatementPreference(ctx context.Context, acctID snowflake.ID, pref StatementPreference) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sql := `
+	INSERT INTO statement_preferences (acct_id, template, locale, cycle_day)
+	VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, 0))
+	ON CONFLICT (acct_id) DO UPDATE
+	SET template = EXCLUDED.template,
+		locale = EXCLUDED.locale,
+		cycle_day = EXCLUDED.cycle_day,
+		updated_at = CURRENT_TIMESTAMP;
+	`
+	_, err = conn.Exec(ctx, sql, acctID, pref.Template, pref.Locale, pref.CycleDay)
+	return err
+}
+
+func (pg *PostgresEndpoint) GetStatementPassword(ctx context.Context, acctID snowflake.ID) ([]byte, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `
+	SELECT sealed
+	FROM statement_passwords
+	WHERE acct_id = $1;
+	`
+	var sealed []byte
+	err = conn.QueryRow(ctx, sql, acctID).Scan(&sealed)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound{ID: acctID.Int64()}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sealed, nil
+}
+
+func (pg *PostgresEndpoint) SetStatementPassword(ctx context.Context, acctID snowflake.ID, sealed []byte) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if sealed == nil {
+		_, err = conn.Exec(ctx, `DELETE FROM statement_passwords WHERE acct_id = $1;`, acctID)
+		return err
+	}
+	sql := `
+	INSERT INTO statement_passwords (acct_id, sealed)
+	VALUES ($1, $2)
+	ON CONFLICT (acct_id) DO UPDATE
+	SET sealed = EXCLUDED.sealed,
+		updated_at = CURRENT_TIMESTAMP;
+	`
+	_, err = conn.Exec(ctx, sql, acctID, sealed)
+	return err
+}
+
+func (pg *PostgresEndpoint) StatementCycles(ctx context.Context, defaultDay int, exclude []snowflake.ID) ([]StatementCycle, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `
+	SELECT a.pub_id, COALESCE(p.cycle_day, $1), a.created_at, MAX(sp.period_to)
+	FROM accounts a
+	LEFT JOIN statement_preferences p ON p.acct_id = a.pub_id
+	LEFT JOIN statement_periods sp ON sp.acct_id = a.pub_id
+	WHERE a.pub_id <> ALL($2)
+	GROUP BY a.pub_id, p.cycle_day, a.created_at;
+	`
+	ids := make([]int64, len(exclude))
+	for i, id := range exclude {
+		ids[i] = id.Int64()
+	}
+	rows, err := conn.Query(ctx, sql, defaultDay, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var collected []StatementCycle
+	for rows.Next() {
+		var (
+			id         int64
+			c          StatementCycle
+			lastClosed *time.Time
+		)
+		if err = rows.Scan(&id, &c.Day, &c.CreatedAt, &lastClosed); err != nil {
+			return nil, err
+		}
+		c.AcctID = snowflake.ParseInt64(id)
+		if lastClosed != nil {
+			c.LastClosed = *lastClosed
+		}
+		collected = append(collected, c)
+	}
+	return collected, rows.Err()
+}
+
+func (pg *PostgresEndpoint) CreateStatementPeriod(ctx context.Context, p StatementPeriod) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sql := `
+	INSERT INTO statement_periods
+		(acct_id, period_from, period_to, opening, closing, format, file_key, closed_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (acct_id, period_to) DO NOTHING;
+	`
+	_, err = conn.Exec(ctx, sql,
+		p.AcctID, p.From, p.To, p.Opening, p.Closing, p.Format, p.FileKey, p.ClosedAt,
+	)
+	return err
+}
+
+const pgStatementPeriodColumns = `
+	acct_id, period_from, period_to, opening, closing, format, file_key, closed_at
+`
+
+func scanStatementPeriod(row pgx.Row) (*StatementPeriod, error) {
+	var (
+		acctID int64
+		p      StatementPeriod
+	)
+	err := row.Scan(&acctID, &p.From, &p.To, &p.Opening, &p.Closing, &p.Format, &p.FileKey, &p.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.AcctID = snowflake.ParseInt64(acctID)
+	return &p, nil
+}
+
+func (pg *PostgresEndpoint) ListStatementPeriods(ctx context.Context, acctID snowflake.ID) ([]StatementPeriod, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `SELECT ` + pgStatementPeriodColumns + `
+	FROM statement_periods
+	WHERE acct_id = $1
+	ORDER BY period_to DESC;
+	`
+	rows, err := conn.Query(ctx, sql, acctID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	collected := []StatementPeriod{}
+	for rows.Next() {
+		p, err := scanStatementPeriod(rows)
+		if err != nil {
+			return nil, err
+		}
+		collected = append(collected, *p)
+	}
+	return collected, rows.Err()
+}
+
+func (pg *PostgresEndpoint) GetStatementPeriod(ctx context.Context, acctID snowflake.ID, to time.Time) (*StatementPeriod, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `SELECT ` + pgStatementPeriodColumns + `
+	FROM statement_periods
+	WHERE acct_id = $1 AND period_to = $2;
+	`
+	p, err := scanStatementPeriod(conn.QueryRow(ctx, sql, acctID, to))
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound{ID: acctID.Int64()}
+	}
+	return p, err
+}
+
+// pgBalanceEventsChannel is the LISTEN/NOTIFY channel of balance events
+const pgBalanceEventsChannel = "balance_events"
+
+// pgRecordBalanceEvent stores ev and notifies the listeners of every instance,
+// which Postgres does once tx commits. It must be called with the account row
+// locked, so that the event IDs of the account increase in commit order.
+func pgRecordBalanceEvent(ctx context.Context, tx pgx.Tx, txID int64, ev *BalanceEvent) error {
+	sql := `
+	INSERT INTO balance_events (acct_id, tx_id, typ, amount, fee, balance)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at;
+	`
+	err := tx.QueryRow(ctx, sql, ev.AcctID, txID, ev.Type, ev.Amount, ev.Fee, ev.Balance).Scan(&ev.ID, &ev.At)
+	if err != nil {
+		return fmt.Errorf("insert balance event: %w", err)
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, `SELECT pg_notify($1, $2);`, pgBalanceEventsChannel, string(payload)); err != nil {
+		return fmt.Errorf("notify balance event: %w", err)
+	}
+	return nil
+}
+
+func (pg *PostgresEndpoint) BalanceEventsAfter(ctx context.Context, acctID snowflake.ID, after int64) ([]BalanceEvent, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `
+	SELECT id, acct_id, typ, amount, fee, balance, created_at
+	FROM balance_events
+	WHERE acct_id = $1 AND id > $2
+	ORDER BY id;
+	`
+	rows, err := conn.Query(ctx, sql, acctID, after)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	collected := []BalanceEvent{}
+	for rows.Next() {
+		var ev BalanceEvent
+		if err = rows.Scan(&ev.ID, &ev.AcctID, &ev.Type, &ev.Amount, &ev.Fee, &ev.Balance, &ev.At); err != nil {
+			return nil, fmt.Errorf("balance events rows.Scan: %w", err)
+		}
+		collected = append(collected, ev)
+	}
+	return collected, rows.Err()
+}
+
+var _ BalanceEventListener = (*PostgresEndpoint)(nil)
+
+// ListenBalanceEvents listens on a connection of its own, taken out of the
+// pool for good as it is left in the LISTEN state
+func (pg *PostgresEndpoint) ListenBalanceEvents(ctx context.Context, fn func(BalanceEvent)) error {
+	pconn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	conn := pconn.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, `LISTEN `+pgBalanceEventsChannel+`;`); err != nil {
+		return fmt.Errorf("LISTEN: %w", err)
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var ev BalanceEvent
+		if err = json.Unmarshal([]byte(n.Payload), &ev); err != nil {
+			pg.log.Err(err).Str("payload", n.Payload).Msg("malformed balance event")
+			continue
+		}
+		fn(ev)
+	}
+}
+
+const pgBatchItemColumns = `
+	typ, acct_id, amount, status, fee, balance, COALESCE(error_code, ''), COALESCE(error, '')
+`
+
+func (pg *PostgresEndpoint) CreateBatch(ctx context.Context, b Batch) (*Batch, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, err
+	}
+	// a concurrent insert with the same key blocks this one until it commits
+	sql := `
+	INSERT INTO batches (pub_id, idempotency_key, request_hash, mode)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (idempotency_key) DO NOTHING;
+	`
+	tag, err := tx.Exec(ctx, sql, b.ID, b.Key, b.Hash, b.Mode)
+	if err != nil {
+		if rerr := tx.Rollback(ctx); rerr != nil {
+			ctxLog(ctx, pg.log).Err(rerr).Msg("CreateBatch: transaction rollback fail")
+		}
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		if err = tx.Rollback(ctx); err != nil {
+			return nil, err
+		}
+		var id int64
+		sql = `SELECT pub_id FROM batches WHERE idempotency_key = $1;`
+		if err = conn.QueryRow(ctx, sql, b.Key).Scan(&id); err != nil {
+			return nil, err
+		}
+		return pg.GetBatch(ctx, snowflake.ParseInt64(id))
+	}
+
+	var (
+		idxs    = make([]int32, len(b.Items))
+		typs    = make([]string, len(b.Items))
+		accts   = make([]int64, len(b.Items))
+		amounts = make([]string, len(b.Items))
+	)
+	for i, it := range b.Items {
+		idxs[i] = int32(i)
+		typs[i] = it.Type
+		accts[i] = it.AcctID.Int64()
+		amounts[i] = it.Amount.String()
+	}
+	sql = `
+	INSERT INTO batch_items (batch_id, idx, typ, acct_id, amount)
+	SELECT $1, i, t::txn_type, a, m::numeric
+	FROM unnest($2::int[], $3::text[], $4::bigint[], $5::text[]) AS u(i, t, a, m);
+	`
+	if _, err = tx.Exec(ctx, sql, b.ID, idxs, typs, accts, amounts); err != nil {
+		if rerr := tx.Rollback(ctx); rerr != nil {
+			ctxLog(ctx, pg.log).Err(rerr).Msg("CreateBatch: transaction rollback fail")
+		}
+		return nil, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		ctxLog(ctx, pg.log).Err(err).Msg("CreateBatch: transaction commit fail")
+		return nil, err
+	}
+	return pg.GetBatch(ctx, b.ID)
+}
+
+func (pg *PostgresEndpoint) GetBatch(ctx context.Context, id snowflake.ID) (*Batch, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	b := Batch{ID: id}
+	sql := `
+	SELECT idempotency_key, request_hash, mode, status, created_at, finished_at
+	FROM batches
+	WHERE pub_id = $1;
+	`
+	err = conn.QueryRow(ctx, sql, id).Scan(&b.Key, &b.Hash, &b.Mode, &b.Status, &b.CreatedAt, &b.FinishedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound{ID: id.Int64()}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sql = `SELECT ` + pgBatchItemColumns + ` FROM batch_items WHERE batch_id = $1 ORDER BY idx;`
+	rows, err := conn.Query(ctx, sql, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	b.Items = []BatchItem{}
+	for rows.Next() {
+		var (
+			it           BatchItem
+			fee, balance decimal.NullDecimal
+		)
+		err = rows.Scan(&it.Type, &it.AcctID, &it.Amount, &it.Status, &fee, &balance, &it.ErrorCode, &it.Error)
+		if err != nil {
+			return nil, fmt.Errorf("batch items rows.Scan: %w", err)
+		}
+		if fee.Valid {
+			it.Fee = &fee.Decimal
+		}
+		if balance.Valid {
+			it.Balance = &balance.Decimal
+		}
+		b.Items = append(b.Items, it)
+	}
+	return &b, rows.Err()
+}
+
+// PostBatch posts the pending items of an atomic batch in one transaction, or
+// those of a best effort batch in a transaction each, and then settles the
+// status of the batch. Items failing with a DomainError are recorded as
+// failed, other errors are returned and leave the batch processing.
+func (pg *PostgresEndpoint) PostBatch(ctx context.Context, id snowflake.ID, mode string, postings []BatchPosting) (*Batch, error) {
+	for _, p := range postings {
+		// smoke test in case the service validation middleware
+		// somehow is not wired up correctly
+		if p.SysAcct == 0 || (p.Fee.Amount.IsPositive() && p.Fee.Account == 0) {
+			return nil, ErrInternalServer
+		}
+	}
+	if err := pg.postBatch(ctx, id, mode, postings); err != nil {
+		return nil, err
+	}
+	return pg.GetBatch(ctx, id)
+}
+
+func (pg *PostgresEndpoint) postBatch(ctx context.Context, id snowflake.ID, mode string, postings []BatchPosting) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if mode == BatchAtomic {
+		err = pg.postBatchAtomic(ctx, conn.Conn(), id, postings)
+	} else {
+		err = pg.postBatchBestEffort(ctx, conn.Conn(), id, postings)
+	}
+	if err != nil {
+		return err
+	}
+
+	sql := `
+	UPDATE batches b
+	SET status = CASE
+			WHEN s.pending > 0 THEN 'processing'
+			WHEN s.failed = 0 THEN 'posted'
+			WHEN s.posted = 0 THEN 'failed'
+			ELSE 'partial'
+		END,
+		finished_at = CASE WHEN s.pending > 0 THEN NULL ELSE CURRENT_TIMESTAMP END
+	FROM (
+		SELECT
+			COUNT(*) FILTER (WHERE status = 'pending') AS pending,
+			COUNT(*) FILTER (WHERE status = 'posted') AS posted,
+			COUNT(*) FILTER (WHERE status IN ('failed', 'skipped')) AS failed
+		FROM batch_items
+		WHERE batch_id = $1
+	) s
+	WHERE b.pub_id = $1 AND b.status = 'processing';
+	`
+	_, err = conn.Exec(ctx, sql, id)
+	return err
+}
+
+// postBatchAtomic locks the batch, so it is only posted once, and then the
+// accounts of its items in ascending order, so concurrent batches cannot
+// deadlock. The postings run in a savepoint which is rolled back as a whole
+// when an item fails.
+func (pg *PostgresEndpoint) postBatchAtomic(ctx context.Context, conn *pgx.Conn, id snowflake.ID, postings []BatchPosting) error {
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return err
+	}
+	rollback := func(err error) error {
+		if rerr := tx.Rollback(ctx); rerr != nil {
+			ctxLog(ctx, pg.log).Err(rerr).Msgf("batch `%v` rollback fail", id)
+		}
+		return err
+	}
+
+	var status string
+	sql := `SELECT status FROM batches WHERE pub_id = $1 FOR UPDATE;`
+	if err = tx.QueryRow(ctx, sql, id).Scan(&status); err != nil {
+		return rollback(err)
+	}
+	if status != BatchProcessing {
+		return rollback(nil)
+	}
+	sql = `SELECT pub_id FROM accounts WHERE pub_id = ANY($1) ORDER BY pub_id FOR UPDATE;`
+	if _, err = tx.Exec(ctx, sql, batchAccounts(postings)); err != nil {
+		return rollback(err)
+	}
+
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return rollback(err)
+	}
+	for _, p := range postings {
+		if err = pgPostBatchItem(ctx, sp, id, p); err == nil {
+			continue
+		}
+		var derr DomainError
+		if !errors.As(err, &derr) {
+			return rollback(err)
+		}
+		if err = sp.Rollback(ctx); err != nil {
+			return rollback(err)
+		}
+		if err = pgFailBatchItem(ctx, tx, id, p.Index, derr); err != nil {
+			return rollback(err)
+		}
+		sql = `UPDATE batch_items SET status = 'skipped' WHERE batch_id = $1 AND status = 'pending';`
+		if _, err = tx.Exec(ctx, sql, id); err != nil {
+			return rollback(err)
+		}
+		return tx.Commit(ctx)
+	}
+	if err = sp.Commit(ctx); err != nil {
+		return rollback(err)
+	}
+	return tx.Commit(ctx)
+}
+
+// postBatchBestEffort posts each item in a transaction of its own, locking the
+// item so it is only posted once
+func (pg *PostgresEndpoint) postBatchBestEffort(ctx context.Context, conn *pgx.Conn, id snowflake.ID, postings []BatchPosting) error {
+	sql := `SELECT status FROM batch_items WHERE batch_id = $1 AND idx = $2 FOR UPDATE;`
+	for _, p := range postings {
+		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+		if err != nil {
+			return err
+		}
+		rollback := func(err error) error {
+			if rerr := tx.Rollback(ctx); rerr != nil {
+				ctxLog(ctx, pg.log).Err(rerr).Msgf("batch `%v` item %d rollback fail", id, p.Index)
+			}
+			return err
+		}
+
+		var status string
+		if err = tx.QueryRow(ctx, sql, id, p.Index).Scan(&status); err != nil {
+			return rollback(err)
+		}
+		if status != BatchItemPending {
+			if err = rollback(nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return rollback(err)
+		}
+		if err = pgPostBatchItem(ctx, sp, id, p); err != nil {
+			var derr DomainError
+			if !errors.As(err, &derr) {
+				return rollback(err)
+			}
+			if err = sp.Rollback(ctx); err != nil {
+				return rollback(err)
+			}
+			if err = pgFailBatchItem(ctx, tx, id, p.Index, derr); err != nil {
+				return rollback(err)
+			}
+		} else if err = sp.Commit(ctx); err != nil {
+			return rollback(err)
+		}
+		if err = tx.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pgPostBatchItem charges the item within tx and records it as posted
+func pgPostBatchItem(ctx context.Context, tx pgx.Tx, batchID snowflake.ID, p BatchPosting) error {
+	var (
+		itxn int64
+		bal  decimal.Decimal
+		err  error
+	)
+	fee := decimal.Zero
+	switch p.Type {
+	case BatchDeposit:
+		itxn, bal, err = pgDeposit(ctx, tx, p.Amount, p.AcctID, p.SysAcct, Memo{})
+	case BatchWithdrawal:
+		itxn, bal, err = pgWithdraw(ctx, tx, p.Amount, p.AcctID, p.SysAcct, p.Limits, p.Fee, Memo{})
+		fee = p.Fee.Amount
+	default:
+		err = fmt.Errorf("unknown batch item type %q", p.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	sql := `
+	UPDATE batch_items
+	SET status = 'posted', fee = $3, balance = $4, tx_id = $5
+	WHERE batch_id = $1 AND idx = $2;
+	`
+	_, err = tx.Exec(ctx, sql, batchID, p.Index, fee, bal, itxn)
+	return err
+}
+
+func pgFailBatchItem(ctx context.Context, tx pgx.Tx, batchID snowflake.ID, idx int, derr DomainError) error {
+	code, detail := batchItemError(derr)
+	sql := `
+	UPDATE batch_items
+	SET status = 'failed', error_code = $3, error = $4
+	WHERE batch_id = $1 AND idx = $2;
+	`
+	_, err := tx.Exec(ctx, sql, batchID, idx, code, detail)
+	return err
+}
+
+var _ AdminStore = (*PostgresEndpoint)(nil)
+
+func (pg *PostgresEndpoint) GetAccountByEmail(ctx context.Context, email string) (*Account, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `
+	SELECT pub_id, email, currency, balance, frozen, acct_type, overdraft_limit, pending
+	FROM accounts
+	WHERE email = $1;
+	`
+	var (
+		id   int64
+		acct Account
+	)
+	err = conn.QueryRow(ctx, sql, email).Scan(
+		&id, &acct.Email, &acct.Currency, &acct.Balance, &acct.Frozen, &acct.Type, &acct.OverdraftLimit, &acct.Pending,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	acct.AcctID = snowflake.ParseInt64(id)
+	return &acct, nil
+}
+
+func (pg *PostgresEndpoint) SetAccountFrozen(ctx context.Context, id snowflake.ID, frozen bool) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sql := `UPDATE accounts SET frozen = $2 WHERE pub_id = $1;`
+	tag, err := conn.Exec(ctx, sql, id, frozen)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound{ID: id.Int64()}
+	}
+	return nil
+}
+
+func (pg *PostgresEndpoint) SetOverdraftLimit(ctx context.Context, id snowflake.ID, limit decimal.Decimal) error {
+	if limit.IsNegative() {
+		return ErrBadRequest{Fields: map[string]string{"limit": "negative"}}
+	}
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sql := `UPDATE accounts SET overdraft_limit = $2 WHERE pub_id = $1;`
+	tag, err := conn.Exec(ctx, sql, id, limit)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound{ID: id.Int64()}
+	}
+	return nil
+}
+
+func (pg *PostgresEndpoint) Adjust(ctx context.Context, adj Adjustment, sysAcct snowflake.ID) (*Adjustment, error) {
+	if sysAcct == 0 || adj.Reason == "" {
+		return nil, ErrInternalServer
+	}
+
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, err
+	}
+	// no-op if the transaction is committed
+	defer tx.Rollback(ctx)
+
+	var bal, overdraft decimal.Decimal
+	if err = tx.QueryRow(ctx, pgSelectForUpdateAcctLimitSQL, adj.AcctID).Scan(&bal, &overdraft); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound{ID: adj.AcctID.Int64()}
+		}
+		return nil, fmt.Errorf("pgSelectForUpdateAcctLimitSQL: %w", err)
+	}
+	newbal := bal.Add(adj.Amount)
+	if newbal.Add(overdraft).IsNegative() {
+		return nil, ErrInsufficientFunds{AcctID: adj.AcctID}
+	}
+
+	if err = tx.QueryRow(ctx, pgInsertTxnSQL, "adjustment").Scan(&adj.TxID); err != nil {
+		return nil, fmt.Errorf("pgInsertTxnSQL: %w", err)
+	}
+	// a positive adjustment is booked like a deposit, a negative one like a withdrawal
+	debitAcct, creditAcct := adj.AcctID, sysAcct
+	if adj.Amount.IsNegative() {
+		debitAcct, creditAcct = sysAcct, adj.AcctID
+	}
+	if _, err = tx.Exec(ctx, pgDebitChargeSQL, adj.Amount.Abs(), adj.TxID, debitAcct); err != nil {
+		return nil, fmt.Errorf("pgDebitChargeSQL: %w", err)
+	}
+	if _, err = tx.Exec(ctx, pgCreditChargeSQL, adj.Amount.Abs(), adj.TxID, creditAcct); err != nil {
+		return nil, fmt.Errorf("pgCreditChargeSQL: %w", err)
+	}
+	if _, err = tx.Exec(ctx, pgUpdateAcctSQL, newbal, adj.AcctID); err != nil {
+		return nil, fmt.Errorf("pgUpdateAcctSQL: %w", err)
+	}
+	ev := &BalanceEvent{AcctID: adj.AcctID, Type: "adjustment", Amount: adj.Amount, Balance: newbal}
+	if err = pgRecordBalanceEvent(ctx, tx, adj.TxID, ev); err != nil {
+		return nil, err
+	}
+
+	sql := `
+	INSERT INTO adjustments (tx_id, acct_id, amount, reason, operator)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING created_at;
+	`
+	err = tx.QueryRow(ctx, sql, adj.TxID, adj.AcctID, adj.Amount, adj.Reason, adj.Operator).Scan(&adj.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert adjustment: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		ctxLog(ctx, pg.log).Err(err).Msg("Adjust: transaction commit fail")
+		return nil, err
+	}
+	adj.Balance = newbal
+	return &adj, nil
+}
+
+func (pg *PostgresEndpoint) Reconcile(ctx context.Context, exclude []snowflake.ID) ([]ReconciliationIssue, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ids := make([]int64, len(exclude))
+	for i, id := range exclude {
+		ids[i] = id.Int64()
+	}
+	// every check selects the account or transaction, the expected and the
+	// actual value in that order
+	checks := []struct {
+		name string
+		sql  string
+		args []any
+	}{
+		{
+			name: CheckBalanceMismatch,
+			sql: `
+			SELECT a.pub_id, 0::BIGINT, COALESCE(SUM(CASE WHEN c.typ = 'debit' THEN c.amount ELSE -c.amount END), 0) AS ledger, a.balance
+			FROM accounts a
+			LEFT JOIN (charges c JOIN transactions t ON t.id = c.tx_id AND t.status = 'posted')
+				ON c.acct_id = a.pub_id
+			WHERE a.pub_id <> ALL($1)
+			GROUP BY a.pub_id, a.balance
+			HAVING a.balance <> COALESCE(SUM(CASE WHEN c.typ = 'debit' THEN c.amount ELSE -c.amount END), 0)
+			ORDER BY a.pub_id;
+			`,
+			args: []any{ids},
+		},
+		{
+			name: CheckNegativeBalance,
+			sql: `
+			SELECT a.pub_id, 0::BIGINT, -a.overdraft_limit - COALESCE(SUM(o.interest + o.fee), 0), a.balance
+			FROM accounts a
+			LEFT JOIN overdraft_charges o ON o.acct_id = a.pub_id AND o.tx_id IS NOT NULL
+			WHERE a.pub_id <> ALL($1)
+			GROUP BY a.pub_id, a.overdraft_limit, a.balance
+			HAVING a.balance < -a.overdraft_limit - COALESCE(SUM(o.interest + o.fee), 0)
+			ORDER BY a.pub_id;
+			`,
+			args: []any{ids},
+		},
+		{
+			name: CheckUnbalancedTxn,
+			sql: `
+			SELECT 0::BIGINT, tx_id,
+				SUM(CASE WHEN typ = 'debit' THEN amount ELSE 0 END),
+				SUM(CASE WHEN typ = 'credit' THEN amount ELSE 0 END)
+			FROM charges
+			GROUP BY tx_id
+			HAVING SUM(CASE WHEN typ = 'debit' THEN amount ELSE -amount END) <> 0
+			ORDER BY tx_id;
+			`,
+		},
+		{
+			name: CheckMixedCurrencyTxn,
+			sql: `
+			SELECT 0::BIGINT, c.tx_id, 1::NUMERIC, COUNT(DISTINCT a.currency)::NUMERIC
+			FROM charges c
+			JOIN accounts a ON a.pub_id = c.acct_id
+			GROUP BY c.tx_id
+			HAVING COUNT(DISTINCT a.currency) > 1
+			ORDER BY c.tx_id;
+			`,
+		},
+	}
+
+	issues := []ReconciliationIssue{}
+	for _, check := range checks {
+		rows, err := conn.Query(ctx, check.sql, check.args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", check.name, err)
+		}
+		for rows.Next() {
+			var acctID int64
+			issue := ReconciliationIssue{Check: check.name}
+			if err = rows.Scan(&acctID, &issue.TxID, &issue.Expected, &issue.Actual); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("%s: %w", check.name, err)
+			}
+			issue.AcctID = snowflake.ParseInt64(acctID)
+			issues = append(issues, issue)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", check.name, err)
+		}
+	}
+	return issues, nil
+}
+
+var _ NodeLeaseStore = (*PostgresEndpoint)(nil)
+
+func (pg *PostgresEndpoint) AcquireNodeLease(ctx context.Context, holder string, ttl time.Duration, maxNode int64) (int64, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	// instances racing for the same node conflict, the losers get no row and retry
+	sql := `
+	INSERT INTO node_leases (node_id, holder, expires_at)
+	SELECT n, $1, LOCALTIMESTAMP + make_interval(secs => $2)
+	FROM generate_series(0, $3::INT) AS n
+	WHERE NOT EXISTS (
+		SELECT 1 FROM node_leases l WHERE l.node_id = n AND l.expires_at > LOCALTIMESTAMP
+	)
+	ORDER BY n
+	LIMIT 1
+	ON CONFLICT (node_id) DO UPDATE
+	SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+	WHERE node_leases.expires_at <= LOCALTIMESTAMP
+	RETURNING node_id;
+	`
+	for attempt := 0; attempt < 5; attempt++ {
+		var node int64
+		err = conn.QueryRow(ctx, sql, holder, ttl.Seconds(), maxNode).Scan(&node)
+		if err == nil {
+			return node, nil
+		}
+		if err != pgx.ErrNoRows {
+			return 0, err
+		}
+	}
+	return 0, ErrServiceUnavailable
+}
+
+func (pg *PostgresEndpoint) RenewNodeLease(ctx context.Context, node int64, holder string, ttl time.Duration) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sql := `
+	UPDATE node_leases
+	SET expires_at = LOCALTIMESTAMP + make_interval(secs => $3)
+	WHERE node_id = $1 AND holder = $2;
+	`
+	tag, err := conn.Exec(ctx, sql, node, holder, ttl.Seconds())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound{ID: node}
+	}
+	return nil
+}
+
+func (pg *PostgresEndpoint) ReleaseNodeLease(ctx context.Context, node int64, holder string) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sql := `DELETE FROM node_leases WHERE node_id = $1 AND holder = $2;`
+	_, err = conn.Exec(ctx, sql, node, holder)
+	return err
+}
+
+const pgScheduledPaymentColumns = `
+	pub_id, acct_id, to_acct_id, amount, cron, every, every_interval, start_at, end_at,
+	on_insufficient_funds, status, next_run_at, attempt, attempt_at, created_at, updated_at
+`
+
+func scanScheduledPayment(row pgx.Row) (*ScheduledPayment, error) {
+	var (
+		id, acctID, toAcctID int64
+		p                    ScheduledPayment
+	)
+	err := row.Scan(
+		&id, &acctID, &toAcctID, &p.Amount, &p.Rule.Cron, &p.Rule.Every, &p.Rule.Interval, &p.StartAt, &p.EndAt,
+		&p.OnInsufficientFunds, &p.Status, &p.NextRunAt, &p.Attempt, &p.AttemptAt, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.ID = snowflake.ParseInt64(id)
+	p.AcctID = snowflake.ParseInt64(acctID)
+	p.ToAcctID = snowflake.ParseInt64(toAcctID)
+	return &p, nil
+}
+
+func (pg *PostgresEndpoint) CreateScheduledPayment(ctx context.Context, p ScheduledPayment) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sql := `INSERT INTO scheduled_payments (` + pgScheduledPaymentColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16);
+	`
+	_, err = conn.Exec(ctx, sql,
+		p.ID, p.AcctID, p.ToAcctID, p.Amount, p.Rule.Cron, p.Rule.Every, p.Rule.Interval, p.StartAt, p.EndAt,
+		p.OnInsufficientFunds, p.Status, p.NextRunAt, p.Attempt, p.AttemptAt, p.CreatedAt, p.UpdatedAt,
+	)
+	return err
+}
+
+func (pg *PostgresEndpoint) GetScheduledPayment(ctx context.Context, id snowflake.ID) (*ScheduledPayment, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `SELECT ` + pgScheduledPaymentColumns + ` FROM scheduled_payments WHERE pub_id = $1;`
+	p, err := scanScheduledPayment(conn.QueryRow(ctx, sql, id))
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound{ID: id.Int64()}
+	}
+	return p, err
+}
+
+func (pg *PostgresEndpoint) ListScheduledPayments(ctx context.Context, acctID snowflake.ID) ([]ScheduledPayment, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `SELECT ` + pgScheduledPaymentColumns + `
+	FROM scheduled_payments
+	WHERE acct_id = $1
+	ORDER BY id DESC;
+	`
+	rows, err := conn.Query(ctx, sql, acctID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	collected := []ScheduledPayment{}
+	for rows.Next() {
+		p, err := scanScheduledPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		collected = append(collected, *p)
+	}
+	return collected, rows.Err()
+}
+
+func (pg *PostgresEndpoint) UpdateScheduledPayment(ctx context.Context, p ScheduledPayment) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sql := `
+	UPDATE scheduled_payments
+	SET to_acct_id = $2, amount = $3, cron = $4, every = $5, every_interval = $6, start_at = $7,
+		end_at = $8, on_insufficient_funds = $9, status = $10, next_run_at = $11, attempt = $12,
+		attempt_at = $13, updated_at = $14
+	WHERE pub_id = $1;
+	`
+	tag, err := conn.Exec(ctx, sql,
+		p.ID, p.ToAcctID, p.Amount, p.Rule.Cron, p.Rule.Every, p.Rule.Interval, p.StartAt,
+		p.EndAt, p.OnInsufficientFunds, p.Status, p.NextRunAt, p.Attempt,
+		p.AttemptAt, p.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound{ID: p.ID.Int64()}
+	}
+	return nil
+}
+
+func (pg *PostgresEndpoint) ListScheduledPaymentRuns(ctx context.Context, paymentID snowflake.ID) ([]ScheduledPaymentRun, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `
+	SELECT due_at, attempt, status, batch_id, error_code, error, ran_at
+	FROM scheduled_payment_runs
+	WHERE payment_id = $1
+	ORDER BY id DESC;
+	`
+	rows, err := conn.Query(ctx, sql, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	collected := []ScheduledPaymentRun{}
+	for rows.Next() {
+		var (
+			run     = ScheduledPaymentRun{PaymentID: paymentID}
+			batchID *int64
+		)
+		err = rows.Scan(&run.DueAt, &run.Attempt, &run.Status, &batchID, &run.ErrorCode, &run.Error, &run.RanAt)
+		if err != nil {
+			return nil, fmt.Errorf("scheduled payment runs rows.Scan: %w", err)
+		}
+		if batchID != nil {
+			run.BatchID = snowflake.ParseInt64(*batchID)
+		}
+		collected = append(collected, run)
+	}
+	return collected, rows.Err()
+}
+
+var _ SchedulerStore = (*PostgresEndpoint)(nil)
+
+// pgSchedulerLock is the key of the scheduler advisory lock
+const pgSchedulerLock = 7241722241547768047
+
+// LockScheduler takes a session advisory lock, which Postgres releases should
+// the instance holding it lose its connection
+func (pg *PostgresEndpoint) LockScheduler(ctx context.Context) (func(), bool, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var ok bool
+	err = conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1);`, int64(pgSchedulerLock)).Scan(&ok)
+	if err != nil || !ok {
+		conn.Release()
+		return nil, false, err
+	}
+	unlock := func() {
+		// the lock is held by the session, so it is released on the same connection
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1);`, int64(pgSchedulerLock)); err != nil {
+			pg.log.Err(err).Msg("pg_advisory_unlock failed")
+			// closing the connection ends the session and with it the lock
+			conn.Hijack().Close(context.Background())
+			return
+		}
+		conn.Release()
+	}
+	return unlock, true, nil
+}
+
+func (pg *PostgresEndpoint) DueScheduledPayments(ctx context.Context, now time.Time, limit int) ([]ScheduledPayment, error) {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	sql := `SELECT ` + pgScheduledPaymentColumns + `
+	FROM scheduled_payments
+	WHERE status = 'active' AND attempt_at <= $1
+	ORDER BY attempt_at, id
+	LIMIT $2;
+	`
+	rows, err := conn.Query(ctx, sql, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	collected := []ScheduledPayment{}
+	for rows.Next() {
+		p, err := scanScheduledPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		collected = append(collected, *p)
+	}
+	return collected, rows.Err()
+}
+
+func (pg *PostgresEndpoint) RecordScheduledPaymentRun(ctx context.Context, run ScheduledPaymentRun, next ScheduledPayment) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var batchID *snowflake.ID
+	if run.BatchID != 0 {
+		batchID = &run.BatchID
+	}
+	sql := `
+	INSERT INTO scheduled_payment_runs (payment_id, due_at, attempt, status, batch_id, error_code, error, ran_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (payment_id, due_at, attempt) DO NOTHING;
+	`
+	_, err = tx.Exec(ctx, sql,
+		run.PaymentID, run.DueAt, run.Attempt, run.Status, batchID, run.ErrorCode, run.Error, run.RanAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	// a payment updated or cancelled meanwhile keeps its new schedule
+	sql = `
+	UPDATE scheduled_payments
+	SET status = $2, next_run_at = $3, attempt = $4, attempt_at = $5
+	WHERE pub_id = $1 AND status = 'active' AND updated_at = $6;
+	`
+	_, err = tx.Exec(ctx, sql, next.ID, next.Status, next.NextRunAt, next.Attempt, next.AttemptAt, next.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -4,8 +4,10 @@
 package bankxgo_test
 
 import (
+	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/bwmarrin/snowflake"
 	"github.com/rs/zerolog"
@@ -46,22 +48,30 @@ func TestPostgres(t *testing.T) {
 	reqrd.Nil(err)
 
 	log := zerolog.Nop()
-	endpt, err := bankxgo.NewPostgresEndpoint(cfg.Database.ConnStr, &log)
+	endpt, err := bankxgo.NewPostgresEndpoint(cfg.Database, &log)
 	reqrd.Nil(err)
 
+	t.Run("PrepareSystemAccounts leaves existing accounts alone", func(tt *testing.T) {
+		err := lh.PrepareSystemAccounts()
+		reqrd.Nil(err)
+		exists, err := lh.SchemaExists()
+		reqrd.Nil(err)
+		as.True(exists)
+	})
+
 	t.Run("DebitUser", func(tt *testing.T) {
 		car := bankxgo.CreateAccountReq{
 			Email:    "arhyth@gmail.com",
 			Currency: "USD",
 			AcctID:   node.Generate(),
 		}
-		endpt.CreateAccount(car)
+		endpt.CreateAccount(context.Background(), car)
 		reqrd.Nil(err)
 
 		amount := decimal.New(123, 0)
-		cbal, err := endpt.DebitUser(amount, car.AcctID, lh.SysAccts[car.Currency])
+		cbal, err := endpt.DebitUser(context.Background(), amount, car.AcctID, lh.SysAccts[car.Currency], bankxgo.Memo{})
 		reqrd.Nil(err)
-		retrieved, err := endpt.GetAccount(car.AcctID)
+		retrieved, err := endpt.GetAccount(context.Background(), car.AcctID)
 		reqrd.Nil(err)
 		as.Equal(retrieved.Balance, *cbal)
 		as.Equal(amount, retrieved.Balance)
@@ -73,32 +83,639 @@ func TestPostgres(t *testing.T) {
 			Currency: "PHP",
 			AcctID:   node.Generate(),
 		}
-		endpt.CreateAccount(car)
+		endpt.CreateAccount(context.Background(), car)
 		reqrd.Nil(err)
 
 		amount := decimal.New(5000, 0)
-		bal, err := endpt.CreditUser(amount, car.AcctID, lh.SysAccts[car.Currency])
-		reqrd.ErrorAs(err, &bankxgo.ErrBadRequest{})
+		bal, err := endpt.CreditUser(context.Background(), amount, car.AcctID, lh.SysAccts[car.Currency], bankxgo.WithdrawalLimits{}, bankxgo.Fee{}, bankxgo.Memo{})
+		as.Equal(bankxgo.ErrInsufficientFunds{AcctID: car.AcctID}, err)
 		as.Nil(bal)
 	})
 
+	t.Run("CreateAccount returns a conflict on a taken email", func(tt *testing.T) {
+		car := bankxgo.CreateAccountReq{
+			Email:    "taken@email.com",
+			Currency: "USD",
+			AcctID:   node.Generate(),
+		}
+		err := endpt.CreateAccount(context.Background(), car)
+		reqrd.Nil(err)
+
+		car.AcctID = node.Generate()
+		err = endpt.CreateAccount(context.Background(), car)
+		as.Equal(bankxgo.ErrConflict{Field: "email"}, err)
+	})
+
 	t.Run("CreditUser returns newly credited balance on success", func(tt *testing.T) {
 		car := bankxgo.CreateAccountReq{
 			Email:    "user@credit.com",
 			Currency: "PHP",
 			AcctID:   node.Generate(),
 		}
-		endpt.CreateAccount(car)
+		endpt.CreateAccount(context.Background(), car)
 		reqrd.Nil(err)
 
 		deposit := decimal.New(5000, 0)
-		bal, err := endpt.DebitUser(deposit, car.AcctID, lh.SysAccts[car.Currency])
+		bal, err := endpt.DebitUser(context.Background(), deposit, car.AcctID, lh.SysAccts[car.Currency], bankxgo.Memo{})
 		reqrd.Nil(err)
 		reqrd.Equal(deposit, *bal)
 
 		wdraw := decimal.New(3000, 0)
-		newbal, err := endpt.CreditUser(wdraw, car.AcctID, lh.SysAccts[car.Currency])
+		newbal, err := endpt.CreditUser(context.Background(), wdraw, car.AcctID, lh.SysAccts[car.Currency], bankxgo.WithdrawalLimits{}, bankxgo.Fee{}, bankxgo.Memo{})
 		reqrd.Nil(err)
 		reqrd.Equal(deposit.Sub(wdraw), *newbal)
 	})
+
+	t.Run("balance events are notified and replayed", func(tt *testing.T) {
+		car := bankxgo.CreateAccountReq{
+			Email:    "user@events.com",
+			Currency: "USD",
+			AcctID:   node.Generate(),
+		}
+		err := endpt.CreateAccount(context.Background(), car)
+		reqrd.Nil(err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		notified := make(chan bankxgo.BalanceEvent, 8)
+		listening := make(chan error, 1)
+		go func() {
+			listening <- endpt.ListenBalanceEvents(ctx, func(ev bankxgo.BalanceEvent) {
+				if ev.AcctID == car.AcctID {
+					notified <- ev
+				}
+			})
+		}()
+		// give the listener a moment to LISTEN
+		time.Sleep(200 * time.Millisecond)
+
+		_, err = endpt.DebitUser(context.Background(), decimal.New(500, 0), car.AcctID, lh.SysAccts[car.Currency], bankxgo.Memo{})
+		reqrd.Nil(err)
+		_, err = endpt.CreditUser(context.Background(), decimal.New(200, 0), car.AcctID, lh.SysAccts[car.Currency], bankxgo.WithdrawalLimits{}, bankxgo.Fee{}, bankxgo.Memo{})
+		reqrd.Nil(err)
+
+		var evs []bankxgo.BalanceEvent
+		for len(evs) < 2 {
+			select {
+			case ev := <-notified:
+				evs = append(evs, ev)
+			case <-time.After(5 * time.Second):
+				tt.Fatal("timed out waiting for balance events")
+			}
+		}
+		as.Equal("deposit", evs[0].Type)
+		as.True(decimal.New(500, 0).Equal(evs[0].Balance))
+		as.Equal("withdrawal", evs[1].Type)
+		as.True(decimal.New(-200, 0).Equal(evs[1].Amount))
+		as.True(decimal.New(300, 0).Equal(evs[1].Balance))
+		as.Greater(evs[1].ID, evs[0].ID)
+
+		replayed, err := endpt.BalanceEventsAfter(context.Background(), car.AcctID, evs[0].ID)
+		reqrd.Nil(err)
+		reqrd.Len(replayed, 1)
+		as.Equal(evs[1].ID, replayed[0].ID)
+		as.True(evs[1].Balance.Equal(replayed[0].Balance))
+
+		cancel()
+		as.ErrorIs(<-listening, context.Canceled)
+	})
+
+	t.Run("CreditUser enforces daily withdrawal limits", func(tt *testing.T) {
+		car := bankxgo.CreateAccountReq{
+			Email:    "user@limited.com",
+			Currency: "USD",
+			AcctID:   node.Generate(),
+		}
+		err := endpt.CreateAccount(context.Background(), car)
+		reqrd.Nil(err)
+		_, err = endpt.DebitUser(context.Background(), decimal.New(5000, 0), car.AcctID, lh.SysAccts[car.Currency], bankxgo.Memo{})
+		reqrd.Nil(err)
+
+		limits := bankxgo.WithdrawalLimits{
+			MaxPerTxn:     decimal.New(1000, 0),
+			MaxDailyTotal: decimal.New(1500, 0),
+		}
+		_, err = endpt.CreditUser(context.Background(), decimal.New(1001, 0), car.AcctID, lh.SysAccts[car.Currency], limits, bankxgo.Fee{}, bankxgo.Memo{})
+		brerr := bankxgo.ErrBadRequest{}
+		reqrd.ErrorAs(err, &brerr)
+		as.Equal("max_per_txn", brerr.Fields["withdrawalLimit"])
+
+		_, err = endpt.CreditUser(context.Background(), decimal.New(1000, 0), car.AcctID, lh.SysAccts[car.Currency], limits, bankxgo.Fee{}, bankxgo.Memo{})
+		reqrd.Nil(err)
+		_, err = endpt.CreditUser(context.Background(), decimal.New(600, 0), car.AcctID, lh.SysAccts[car.Currency], limits, bankxgo.Fee{}, bankxgo.Memo{})
+		reqrd.ErrorAs(err, &brerr)
+		as.Equal("max_daily_total", brerr.Fields["withdrawalLimit"])
+
+		// per account override takes precedence over the currency default
+		_, err = lh.Conn.Exec(context.Background(), `
+			INSERT INTO withdrawal_limits (acct_id, max_daily_total, max_daily_count)
+			VALUES ($1, 3000, 2);
+		`, car.AcctID)
+		reqrd.Nil(err)
+		_, err = endpt.CreditUser(context.Background(), decimal.New(600, 0), car.AcctID, lh.SysAccts[car.Currency], limits, bankxgo.Fee{}, bankxgo.Memo{})
+		reqrd.Nil(err)
+		_, err = endpt.CreditUser(context.Background(), decimal.New(1, 0), car.AcctID, lh.SysAccts[car.Currency], limits, bankxgo.Fee{}, bankxgo.Memo{})
+		reqrd.ErrorAs(err, &brerr)
+		as.Equal("max_daily_count", brerr.Fields["withdrawalLimit"])
+	})
+
+	t.Run("CreditUser books the fee to the fee account", func(tt *testing.T) {
+		car := bankxgo.CreateAccountReq{
+			Email:    "user@fees.com",
+			Currency: "USD",
+			AcctID:   node.Generate(),
+		}
+		err := endpt.CreateAccount(context.Background(), car)
+		reqrd.Nil(err)
+		_, err = endpt.DebitUser(context.Background(), decimal.New(100, 0), car.AcctID, lh.SysAccts[car.Currency], bankxgo.Memo{})
+		reqrd.Nil(err)
+
+		feeAcct := lh.FeeAccts[car.Currency]
+		fee := bankxgo.Fee{Amount: decimal.NewFromFloat(1.5), Account: feeAcct}
+		bal, err := endpt.CreditUser(context.Background(), decimal.New(100, 0), car.AcctID, lh.SysAccts[car.Currency], bankxgo.WithdrawalLimits{}, fee, bankxgo.Memo{})
+		reqrd.ErrorAs(err, &bankxgo.ErrBadRequest{})
+		as.Nil(bal)
+
+		bal, err = endpt.CreditUser(context.Background(), decimal.New(50, 0), car.AcctID, lh.SysAccts[car.Currency], bankxgo.WithdrawalLimits{}, fee, bankxgo.Memo{})
+		reqrd.Nil(err)
+		as.True(decimal.NewFromFloat(48.5).Equal(*bal))
+
+		charges, err := endpt.GetAccountCharges(context.Background(), car.AcctID)
+		reqrd.Nil(err)
+		reqrd.Len(charges, 3)
+		as.True(charges[2].Fee)
+		as.True(fee.Amount.Equal(charges[2].Amount))
+
+		feeCharges, err := endpt.GetAccountCharges(context.Background(), feeAcct)
+		reqrd.Nil(err)
+		reqrd.Len(feeCharges, 1)
+		as.Equal("debit", feeCharges[0].Typ)
+	})
+
+	t.Run("ListTransactions returns the memos and searches by reference", func(tt *testing.T) {
+		car := bankxgo.CreateAccountReq{
+			Email:    "user@memos.com",
+			Currency: "USD",
+			AcctID:   node.Generate(),
+		}
+		err := endpt.CreateAccount(context.Background(), car)
+		reqrd.Nil(err)
+		sysAcct := lh.SysAccts[car.Currency]
+		memo := bankxgo.Memo{
+			Description: "September salary",
+			Reference:   "PAY-2024-09",
+			Metadata:    map[string]any{"employer": "ACME"},
+		}
+		_, err = endpt.DebitUser(context.Background(), decimal.New(100, 0), car.AcctID, sysAcct, memo)
+		reqrd.Nil(err)
+		fee := bankxgo.Fee{Amount: decimal.New(1, 0), Account: lh.FeeAccts[car.Currency]}
+		_, err = endpt.CreditUser(context.Background(), decimal.New(30, 0), car.AcctID, sysAcct, bankxgo.WithdrawalLimits{}, fee, bankxgo.Memo{})
+		reqrd.Nil(err)
+
+		txns, err := endpt.ListTransactions(context.Background(), car.AcctID, bankxgo.TransactionFilter{Limit: 10})
+		reqrd.Nil(err)
+		reqrd.Len(txns, 2)
+		as.Equal("withdrawal", txns[0].Type)
+		as.True(decimal.New(-30, 0).Equal(txns[0].Amount))
+		as.True(decimal.New(1, 0).Equal(txns[0].Fee))
+		as.Equal(memo, txns[1].Memo)
+
+		txns, err = endpt.ListTransactions(context.Background(), car.AcctID, bankxgo.TransactionFilter{Reference: memo.Reference, Limit: 10})
+		reqrd.Nil(err)
+		reqrd.Len(txns, 1)
+		as.Equal("deposit", txns[0].Type)
+		txns, err = endpt.ListTransactions(context.Background(), car.AcctID, bankxgo.TransactionFilter{Before: txns[0].ID, Limit: 10})
+		reqrd.Nil(err)
+		as.Empty(txns)
+
+		charges, err := endpt.GetAccountCharges(context.Background(), car.AcctID)
+		reqrd.Nil(err)
+		reqrd.Len(charges, 3)
+		as.Equal(memo.Description, charges[0].Memo.Description)
+	})
+
+	t.Run("pending deposits are only part of the balance once posted", func(tt *testing.T) {
+		car := bankxgo.CreateAccountReq{
+			Email:    "user@pending.com",
+			Currency: "USD",
+			AcctID:   node.Generate(),
+		}
+		err := endpt.CreateAccount(context.Background(), car)
+		reqrd.Nil(err)
+		sysAcct := lh.SysAccts[car.Currency]
+		wire := bankxgo.Memo{Reference: "WIRE-0001"}
+		card := bankxgo.Memo{Reference: "CARD-0001"}
+		bal, err := endpt.DebitUserPending(context.Background(), decimal.New(500, 0), car.AcctID, sysAcct, wire)
+		reqrd.Nil(err)
+		as.True(bal.IsZero())
+		_, err = endpt.DebitUserPending(context.Background(), decimal.New(1, 0), car.AcctID, sysAcct, wire)
+		as.Equal(bankxgo.ErrConflict{Field: "reference"}, err)
+		_, err = endpt.DebitUserPending(context.Background(), decimal.New(80, 0), car.AcctID, sysAcct, card)
+		reqrd.Nil(err)
+
+		acct, err := endpt.GetAccount(context.Background(), car.AcctID)
+		reqrd.Nil(err)
+		as.True(acct.Balance.IsZero())
+		as.True(decimal.New(580, 0).Equal(acct.Pending))
+		_, err = endpt.CreditUser(context.Background(), decimal.New(1, 0), car.AcctID, sysAcct, bankxgo.WithdrawalLimits{}, bankxgo.Fee{}, bankxgo.Memo{})
+		as.ErrorAs(err, &bankxgo.ErrInsufficientFunds{})
+		txns, err := endpt.ListTransactions(context.Background(), car.AcctID, bankxgo.TransactionFilter{Limit: 10})
+		reqrd.Nil(err)
+		reqrd.Len(txns, 2)
+		as.Equal(bankxgo.TxnPending, txns[0].Status)
+
+		txn, err := endpt.SettleTransaction(context.Background(), car.AcctID, wire.Reference, bankxgo.TxnPosted)
+		reqrd.Nil(err)
+		as.Equal(bankxgo.TxnPosted, txn.Status)
+		as.True(decimal.New(500, 0).Equal(txn.Amount))
+		reqrd.NotNil(txn.SettledAt)
+		// a retried callback gets the same answer, a contradicting one an error
+		again, err := endpt.SettleTransaction(context.Background(), car.AcctID, wire.Reference, bankxgo.TxnPosted)
+		reqrd.Nil(err)
+		as.Equal(txn.ID, again.ID)
+		_, err = endpt.SettleTransaction(context.Background(), car.AcctID, wire.Reference, bankxgo.TxnFailed)
+		as.ErrorAs(err, &bankxgo.ErrBadRequest{})
+		_, err = endpt.SettleTransaction(context.Background(), car.AcctID, card.Reference, bankxgo.TxnFailed)
+		reqrd.Nil(err)
+		_, err = endpt.SettleTransaction(context.Background(), car.AcctID, "WIRE-9999", bankxgo.TxnPosted)
+		as.ErrorAs(err, &bankxgo.ErrNotFound{})
+		// references of settled deposits are not given out again
+		_, err = endpt.DebitUserPending(context.Background(), decimal.New(1, 0), car.AcctID, sysAcct, wire)
+		as.Equal(bankxgo.ErrConflict{Field: "reference"}, err)
+		_, err = endpt.DebitUserPending(context.Background(), decimal.New(1, 0), car.AcctID, sysAcct, card)
+		as.Equal(bankxgo.ErrConflict{Field: "reference"}, err)
+		// and deposits that were never pending are not settled
+		_, err = endpt.DebitUser(context.Background(), decimal.New(1, 0), car.AcctID, sysAcct, bankxgo.Memo{Reference: "PAY-0001"})
+		reqrd.Nil(err)
+		_, err = endpt.SettleTransaction(context.Background(), car.AcctID, "PAY-0001", bankxgo.TxnPosted)
+		as.ErrorAs(err, &bankxgo.ErrNotFound{})
+
+		acct, err = endpt.GetAccount(context.Background(), car.AcctID)
+		reqrd.Nil(err)
+		as.True(decimal.New(501, 0).Equal(acct.Balance))
+		as.True(acct.Pending.IsZero())
+		// only the posted deposits are on the ledger
+		charges, err := endpt.GetAccountCharges(context.Background(), car.AcctID)
+		reqrd.Nil(err)
+		reqrd.Len(charges, 2)
+		as.Equal(txn.ID, charges[0].TxID)
+		// booked when made, but valued when settled
+		as.Equal(*txn.SettledAt, charges[0].ValueDate())
+		as.False(charges[0].CreatedAt.After(charges[0].SettledAt))
+	})
+
+	t.Run("Adjust books a reasoned correction and keeps the ledger reconciled", func(tt *testing.T) {
+		car := bankxgo.CreateAccountReq{
+			Email:    "user@adjust.com",
+			Currency: "USD",
+			AcctID:   node.Generate(),
+		}
+		err := endpt.CreateAccount(context.Background(), car)
+		reqrd.Nil(err)
+		_, err = endpt.DebitUser(context.Background(), decimal.New(100, 0), car.AcctID, lh.SysAccts[car.Currency], bankxgo.Memo{})
+		reqrd.Nil(err)
+
+		adj := bankxgo.Adjustment{
+			AcctID:   car.AcctID,
+			Amount:   decimal.New(-150, 0),
+			Reason:   "duplicate deposit",
+			Operator: "ops",
+		}
+		_, err = endpt.Adjust(context.Background(), adj, lh.SysAccts[car.Currency])
+		reqrd.ErrorAs(err, &bankxgo.ErrBadRequest{})
+
+		adj.Amount = decimal.NewFromFloat(-10.5)
+		booked, err := endpt.Adjust(context.Background(), adj, lh.SysAccts[car.Currency])
+		reqrd.Nil(err)
+		as.True(decimal.NewFromFloat(89.5).Equal(booked.Balance))
+		as.Equal("ops", booked.Operator)
+
+		charges, err := endpt.GetAccountCharges(context.Background(), car.AcctID)
+		reqrd.Nil(err)
+		reqrd.Len(charges, 2)
+		as.Equal(bankxgo.LineKindAdjustment, charges[1].Kind())
+		as.Equal("credit", charges[1].Typ)
+
+		err = endpt.SetAccountFrozen(context.Background(), car.AcctID, true)
+		reqrd.Nil(err)
+		acct, err := endpt.GetAccountByEmail(context.Background(), car.Email)
+		reqrd.Nil(err)
+		as.True(acct.Frozen)
+
+		exclude := []snowflake.ID{}
+		for _, id := range lh.SysAccts {
+			exclude = append(exclude, id)
+		}
+		for _, id := range lh.FeeAccts {
+			exclude = append(exclude, id)
+		}
+		issues, err := endpt.Reconcile(context.Background(), exclude)
+		reqrd.Nil(err)
+		as.Empty(issues)
+	})
+
+	t.Run("CreditUser overdraws down to the limit and overdrafts are charged once a day", func(tt *testing.T) {
+		car := bankxgo.CreateAccountReq{
+			Email:    "user@overdraft.com",
+			Currency: "USD",
+			Type:     bankxgo.AccountCredit,
+			AcctID:   node.Generate(),
+		}
+		err := endpt.CreateAccount(context.Background(), car)
+		reqrd.Nil(err)
+		err = endpt.SetOverdraftLimit(context.Background(), car.AcctID, decimal.New(200, 0))
+		reqrd.Nil(err)
+		acct, err := endpt.GetAccount(context.Background(), car.AcctID)
+		reqrd.Nil(err)
+		as.Equal(bankxgo.AccountCredit, acct.Type)
+		as.True(decimal.New(200, 0).Equal(acct.OverdraftLimit))
+
+		sysAcct := lh.SysAccts[car.Currency]
+		_, err = endpt.CreditUser(context.Background(), decimal.New(201, 0), car.AcctID, sysAcct, bankxgo.WithdrawalLimits{}, bankxgo.Fee{}, bankxgo.Memo{})
+		reqrd.ErrorAs(err, &bankxgo.ErrInsufficientFunds{})
+		bal, err := endpt.CreditUser(context.Background(), decimal.New(200, 0), car.AcctID, sysAcct, bankxgo.WithdrawalLimits{}, bankxgo.Fee{}, bankxgo.Memo{})
+		reqrd.Nil(err)
+		as.True(decimal.New(-200, 0).Equal(*bal))
+
+		incomeAcct := lh.OdAccts[car.Currency]
+		charge := bankxgo.OverdraftCharge{
+			AcctID:   car.AcctID,
+			Date:     time.Now().UTC().AddDate(0, 0, -1).Truncate(24 * time.Hour),
+			Balance:  decimal.New(-200, 0),
+			Rate:     decimal.New(1825, -2),
+			Interest: decimal.New(1, -1),
+			Fee:      decimal.New(1, 0),
+		}
+		ok, err := endpt.ChargeOverdraft(context.Background(), charge, incomeAcct)
+		reqrd.Nil(err)
+		as.True(ok)
+		ok, err = endpt.ChargeOverdraft(context.Background(), charge, incomeAcct)
+		reqrd.Nil(err)
+		as.False(ok)
+
+		// the charges take the balance beyond the limit
+		acct, err = endpt.GetAccount(context.Background(), car.AcctID)
+		reqrd.Nil(err)
+		as.True(decimal.NewFromFloat(-201.1).Equal(acct.Balance), acct.Balance.String())
+		charges, err := endpt.GetAccountCharges(context.Background(), car.AcctID)
+		reqrd.Nil(err)
+		reqrd.Len(charges, 3)
+		as.Equal("Overdraft interest", charges[1].Description())
+		as.Equal(bankxgo.LineKindFee, charges[2].Kind())
+
+		exclude := []snowflake.ID{incomeAcct}
+		for _, id := range lh.SysAccts {
+			exclude = append(exclude, id)
+		}
+		for _, id := range lh.FeeAccts {
+			exclude = append(exclude, id)
+		}
+		issues, err := endpt.Reconcile(context.Background(), exclude)
+		reqrd.Nil(err)
+		as.Empty(issues)
+
+		// but not further than the charges
+		reqrd.Nil(endpt.SetOverdraftLimit(context.Background(), car.AcctID, decimal.New(100, 0)))
+		issues, err = endpt.Reconcile(context.Background(), exclude)
+		reqrd.Nil(err)
+		reqrd.Len(issues, 1)
+		as.Equal(bankxgo.CheckNegativeBalance, issues[0].Check)
+		as.Equal(car.AcctID, issues[0].AcctID)
+		as.True(decimal.NewFromFloat(-101.1).Equal(issues[0].Expected), issues[0].Expected.String())
+
+		// settle the overdraft so later reconciliations are clean
+		_, err = endpt.DebitUser(context.Background(), decimal.NewFromFloat(201.1), car.AcctID, sysAcct, bankxgo.Memo{})
+		reqrd.Nil(err)
+	})
+
+	t.Run("reconciliation is clean after the overdraft job", func(tt *testing.T) {
+		car := bankxgo.CreateAccountReq{
+			Email:    "user@overdraftjob.com",
+			Currency: "USD",
+			Type:     bankxgo.AccountCredit,
+			AcctID:   node.Generate(),
+		}
+		reqrd.Nil(endpt.CreateAccount(context.Background(), car))
+		reqrd.Nil(endpt.SetOverdraftLimit(context.Background(), car.AcctID, decimal.New(500, 0)))
+		sysAcct := lh.SysAccts[car.Currency]
+		_, err := endpt.CreditUser(context.Background(), decimal.New(500, 0), car.AcctID, sysAcct, bankxgo.WithdrawalLimits{}, bankxgo.Fee{}, bankxgo.Memo{})
+		reqrd.Nil(err)
+		// overdrawn at the limit since the day before yesterday
+		_, err = lh.Conn.Exec(context.Background(), `UPDATE accounts SET created_at = created_at - INTERVAL '2 days' WHERE pub_id = $1`, car.AcctID)
+		reqrd.Nil(err)
+		_, err = lh.Conn.Exec(context.Background(), `UPDATE charges SET created_at = created_at - INTERVAL '2 days' WHERE acct_id = $1`, car.AcctID)
+		reqrd.Nil(err)
+
+		exclude := []snowflake.ID{}
+		for _, accts := range []map[string]snowflake.ID{lh.SysAccts, lh.FeeAccts, lh.IntAccts, lh.OdAccts} {
+			for _, id := range accts {
+				exclude = append(exclude, id)
+			}
+		}
+		policies := map[string]bankxgo.OverdraftPolicy{
+			"USD": {Account: lh.OdAccts["USD"], AnnualRate: decimal.New(1825, -2), DailyFee: decimal.New(1, 0)},
+		}
+		job, err := bankxgo.NewOverdraftJob(endpt, policies, exclude, &log)
+		reqrd.Nil(err)
+		reqrd.Nil(job.Charge(time.Now().UTC().AddDate(0, 0, -1)))
+
+		acct, err := endpt.GetAccount(context.Background(), car.AcctID)
+		reqrd.Nil(err)
+		as.True(decimal.NewFromFloat(-501.25).Equal(acct.Balance), acct.Balance.String())
+		issues, err := endpt.Reconcile(context.Background(), exclude)
+		reqrd.Nil(err)
+		as.Empty(issues)
+
+		_, err = endpt.DebitUser(context.Background(), decimal.NewFromFloat(501.25), car.AcctID, sysAcct, bankxgo.Memo{})
+		reqrd.Nil(err)
+	})
+
+	t.Run("PostBatch posts atomic batches all or nothing", func(tt *testing.T) {
+		payer := bankxgo.CreateAccountReq{Email: "payer@batch.com", Currency: "USD", AcctID: node.Generate()}
+		payee := bankxgo.CreateAccountReq{Email: "payee@batch.com", Currency: "USD", AcctID: node.Generate()}
+		reqrd.Nil(endpt.CreateAccount(context.Background(), payer))
+		reqrd.Nil(endpt.CreateAccount(context.Background(), payee))
+		_, err := endpt.DebitUser(context.Background(), decimal.New(100, 0), payer.AcctID, lh.SysAccts["USD"], bankxgo.Memo{})
+		reqrd.Nil(err)
+
+		sysAcct := lh.SysAccts["USD"]
+		newBatch := func(key, mode string) bankxgo.Batch {
+			return bankxgo.Batch{
+				ID:   node.Generate(),
+				Key:  key,
+				Mode: mode,
+				Hash: key,
+				Items: []bankxgo.BatchItem{
+					{Type: bankxgo.BatchDeposit, AcctID: payee.AcctID, Amount: decimal.New(50, 0)},
+					{Type: bankxgo.BatchWithdrawal, AcctID: payer.AcctID, Amount: decimal.New(80, 0)},
+					{Type: bankxgo.BatchWithdrawal, AcctID: payer.AcctID, Amount: decimal.New(80, 0)},
+				},
+			}
+		}
+		postings := func(b *bankxgo.Batch) []bankxgo.BatchPosting {
+			ps := []bankxgo.BatchPosting{}
+			for i, it := range b.Items {
+				ps = append(ps, bankxgo.BatchPosting{Index: i, Type: it.Type, AcctID: it.AcctID, SysAcct: sysAcct, Amount: it.Amount})
+			}
+			return ps
+		}
+
+		atomic, err := endpt.CreateBatch(context.Background(), newBatch("batch-atomic", bankxgo.BatchAtomic))
+		reqrd.Nil(err)
+		again, err := endpt.CreateBatch(context.Background(), newBatch("batch-atomic", bankxgo.BatchAtomic))
+		reqrd.Nil(err)
+		as.Equal(atomic.ID, again.ID)
+
+		posted, err := endpt.PostBatch(context.Background(), atomic.ID, bankxgo.BatchAtomic, postings(atomic))
+		reqrd.Nil(err)
+		as.Equal(bankxgo.BatchFailed, posted.Status)
+		as.Equal(bankxgo.BatchItemSkipped, posted.Items[0].Status)
+		as.Equal(bankxgo.BatchItemSkipped, posted.Items[1].Status)
+		as.Equal(bankxgo.BatchItemFailed, posted.Items[2].Status)
+		as.Equal(bankxgo.CodeInsufficientFunds, posted.Items[2].ErrorCode)
+		charges, err := endpt.GetAccountCharges(context.Background(), payee.AcctID)
+		reqrd.Nil(err)
+		as.Empty(charges)
+		acct, err := endpt.GetAccount(context.Background(), payer.AcctID)
+		reqrd.Nil(err)
+		as.True(decimal.New(100, 0).Equal(acct.Balance))
+
+		best, err := endpt.CreateBatch(context.Background(), newBatch("batch-best-effort", bankxgo.BatchBestEffort))
+		reqrd.Nil(err)
+		posted, err = endpt.PostBatch(context.Background(), best.ID, bankxgo.BatchBestEffort, postings(best))
+		reqrd.Nil(err)
+		as.Equal(bankxgo.BatchPartial, posted.Status)
+		as.Equal(bankxgo.BatchItemPosted, posted.Items[0].Status)
+		as.Equal(bankxgo.BatchItemPosted, posted.Items[1].Status)
+		as.Equal(bankxgo.BatchItemFailed, posted.Items[2].Status)
+		as.True(decimal.New(20, 0).Equal(*posted.Items[1].Balance))
+		as.NotNil(posted.FinishedAt)
+	})
+
+	t.Run("scheduled payments keep their run history", func(tt *testing.T) {
+		payer := bankxgo.CreateAccountReq{Email: "payer@scheduled.com", Currency: "USD", AcctID: node.Generate()}
+		payee := bankxgo.CreateAccountReq{Email: "payee@scheduled.com", Currency: "USD", AcctID: node.Generate()}
+		reqrd.Nil(endpt.CreateAccount(context.Background(), payer))
+		reqrd.Nil(endpt.CreateAccount(context.Background(), payee))
+
+		now := time.Now().UTC().Truncate(time.Second)
+		due := now.Add(-time.Minute)
+		p := bankxgo.ScheduledPayment{
+			ID:     node.Generate(),
+			AcctID: payer.AcctID,
+			ScheduledPaymentSpec: bankxgo.ScheduledPaymentSpec{
+				ToAcctID:            payee.AcctID,
+				Amount:              decimal.New(25, 0),
+				Rule:                bankxgo.ScheduleRule{Every: bankxgo.ScheduleEveryDay},
+				StartAt:             due,
+				OnInsufficientFunds: bankxgo.ScheduleRetry,
+			},
+			Status:    bankxgo.ScheduledPaymentActive,
+			NextRunAt: &due,
+			AttemptAt: &due,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		reqrd.Nil(endpt.CreateScheduledPayment(context.Background(), p))
+
+		unlock, ok, err := endpt.LockScheduler(context.Background())
+		reqrd.Nil(err)
+		reqrd.True(ok)
+		// another instance waits for its turn
+		_, ok, err = endpt.LockScheduler(context.Background())
+		reqrd.Nil(err)
+		as.False(ok)
+
+		dues, err := endpt.DueScheduledPayments(context.Background(), now, 10)
+		reqrd.Nil(err)
+		reqrd.Len(dues, 1)
+		as.Equal(p.ID, dues[0].ID)
+		as.Equal(p.Rule, dues[0].Rule)
+
+		next := dues[0]
+		retryAt := now.Add(time.Hour)
+		next.Attempt, next.AttemptAt = 1, &retryAt
+		run := bankxgo.ScheduledPaymentRun{
+			PaymentID: p.ID,
+			DueAt:     due,
+			Status:    bankxgo.ScheduledRunRetrying,
+			ErrorCode: bankxgo.CodeInsufficientFunds,
+			RanAt:     now,
+		}
+		reqrd.Nil(endpt.RecordScheduledPaymentRun(context.Background(), run, next))
+		unlock()
+
+		dues, err = endpt.DueScheduledPayments(context.Background(), now, 10)
+		reqrd.Nil(err)
+		as.Empty(dues)
+		unlock, ok, err = endpt.LockScheduler(context.Background())
+		reqrd.Nil(err)
+		reqrd.True(ok)
+		unlock()
+
+		// a payment cancelled meanwhile stays cancelled
+		cancelled := next
+		cancelled.Status, cancelled.NextRunAt, cancelled.AttemptAt = bankxgo.ScheduledPaymentCancelled, nil, nil
+		cancelled.UpdatedAt = now.Add(time.Second)
+		reqrd.Nil(endpt.UpdateScheduledPayment(context.Background(), cancelled))
+		run.Attempt, run.Status = 1, bankxgo.ScheduledRunSkipped
+		reqrd.Nil(endpt.RecordScheduledPaymentRun(context.Background(), run, next))
+		got, err := endpt.GetScheduledPayment(context.Background(), p.ID)
+		reqrd.Nil(err)
+		as.Equal(bankxgo.ScheduledPaymentCancelled, got.Status)
+
+		runs, err := endpt.ListScheduledPaymentRuns(context.Background(), p.ID)
+		reqrd.Nil(err)
+		reqrd.Len(runs, 2)
+		as.Equal(bankxgo.ScheduledRunSkipped, runs[0].Status)
+		as.Equal(bankxgo.ScheduledRunRetrying, runs[1].Status)
+		payments, err := endpt.ListScheduledPayments(context.Background(), payer.AcctID)
+		reqrd.Nil(err)
+		as.Len(payments, 1)
+	})
+
+	t.Run("statement jobs that keep crashing their workers are failed", func(tt *testing.T) {
+		car := bankxgo.CreateAccountReq{Email: "user@statementjobs.com", Currency: "USD", AcctID: node.Generate()}
+		reqrd.Nil(endpt.CreateAccount(context.Background(), car))
+		job := bankxgo.StatementJob{
+			ID:     node.Generate(),
+			AcctID: car.AcctID,
+			Format: bankxgo.StatementFormatCSV,
+			To:     time.Now().UTC().Truncate(24 * time.Hour),
+		}
+		reqrd.Nil(endpt.CreateStatementJob(context.Background(), job))
+
+		// a zero lease has every running job abandoned by its worker
+		for attempt := 1; attempt <= 2; attempt++ {
+			claimed, err := endpt.ClaimStatementJob(context.Background(), 0, 2)
+			reqrd.Nil(err)
+			reqrd.NotNil(claimed)
+			as.Equal(job.ID, claimed.ID)
+			as.Equal(attempt, claimed.Attempts)
+		}
+		claimed, err := endpt.ClaimStatementJob(context.Background(), 0, 2)
+		reqrd.Nil(err)
+		as.Nil(claimed)
+		got, err := endpt.GetStatementJob(context.Background(), job.ID)
+		reqrd.Nil(err)
+		as.Equal(bankxgo.StatementJobFailed, got.Status)
+		as.Equal("abandoned after 2 attempts", got.Error)
+	})
+
+	t.Run("node leases are unique among holders", func(tt *testing.T) {
+		a, err := endpt.AcquireNodeLease(context.Background(), "host-a/1", time.Minute, 1021)
+		reqrd.Nil(err)
+		b, err := endpt.AcquireNodeLease(context.Background(), "host-b/1", time.Minute, 1021)
+		reqrd.Nil(err)
+		as.NotEqual(a, b)
+
+		reqrd.Nil(endpt.RenewNodeLease(context.Background(), a, "host-a/1", time.Minute))
+		as.ErrorAs(endpt.RenewNodeLease(context.Background(), a, "host-b/1", time.Minute), &bankxgo.ErrNotFound{})
+
+		reqrd.Nil(endpt.ReleaseNodeLease(context.Background(), a, "host-a/1"))
+		as.ErrorAs(endpt.RenewNodeLease(context.Background(), a, "host-a/1", time.Minute), &bankxgo.ErrNotFound{})
+		c, err := endpt.AcquireNodeLease(context.Background(), "host-c/1", time.Minute, 1021)
+		reqrd.Nil(err)
+		as.Equal(a, c)
+	})
 }
@@ -0,0 +1,209 @@
+package bankxgo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// reloadableKeys are the config keys applied by ConfigReloader, any other
+// change takes a restart
+var reloadableKeys = []string{"service_limits.", "system_accounts.", "retired_system_accounts"}
+
+// secretKeys are config keys whose values are never logged
+var secretKeys = []string{"database.conn_str", "statement_security.signing_key", "statement_security.password_key", "settlement.signing_key"}
+
+// ConfigReloader reloads the config on SIGHUP or when the file changes. The
+// service limits are updated in place and the system accounts are swapped
+// once verified against the database. Other changes are logged as needing a
+// restart.
+type ConfigReloader struct {
+	flags    *ConfigFlags
+	repo     Repository
+	limits   *ServiceLimits
+	sysAccts *SystemAccounts
+	log      *zerolog.Logger
+
+	mu      sync.Mutex
+	current *Config
+	modTime time.Time
+}
+
+// NewConfigReloader expects cfg to be the config the limits and system
+// accounts were created from
+func NewConfigReloader(
+	flags *ConfigFlags,
+	cfg *Config,
+	repo Repository,
+	limits *ServiceLimits,
+	sysAccts *SystemAccounts,
+	log *zerolog.Logger,
+) *ConfigReloader {
+	r := &ConfigReloader{
+		flags:    flags,
+		repo:     repo,
+		limits:   limits,
+		sysAccts: sysAccts,
+		log:      log,
+		current:  cfg,
+	}
+	// taken now rather than in Run so a change made in between is not missed
+	r.modTime = r.fileModTime()
+	return r
+}
+
+// Reload loads and applies the config. Nothing is applied if the config is
+// invalid, a system account does not check out or an endpoint's limit strategy
+// changed.
+func (r *ConfigReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := r.flags.Load()
+	if err != nil {
+		return err
+	}
+	byCurrency, internal, err := cfg.SystemAccountIDs()
+	if err != nil {
+		return err
+	}
+	// accounts of a removed currency could neither deposit nor withdraw anymore
+	for cur := range r.sysAccts.ByCurrency() {
+		if _, ok := byCurrency[cur]; !ok {
+			return ConfigError{Key: "system_accounts." + cur, Msg: "removing a currency requires a restart"}
+		}
+	}
+	if err = VerifySystemAccounts(r.repo, byCurrency); err != nil {
+		return fmt.Errorf("system_accounts: %w", err)
+	}
+	if err = r.limits.Update(&cfg.ServiceLimits); err != nil {
+		return err
+	}
+	r.sysAccts.Store(byCurrency, internal)
+
+	changes := diffConfig(r.current, cfg)
+	for _, c := range changes {
+		ev := r.log.Info()
+		msg := "config reloaded"
+		if !hasAnyPrefix(c.Key, reloadableKeys) {
+			ev = r.log.Warn()
+			msg = "config changed, restart to apply"
+		}
+		if hasAnyPrefix(c.Key, secretKeys) {
+			ev.Str("key", c.Key).Msg(msg)
+			continue
+		}
+		ev.Str("key", c.Key).Str("old", c.Old).Str("new", c.New).Msg(msg)
+	}
+	if len(changes) == 0 {
+		r.log.Info().Msg("config reloaded, no changes")
+	}
+	r.current = cfg
+	return nil
+}
+
+// Run reloads on every signal from hup and, if pollEvery is positive, whenever
+// the modification time of the config file changes, until ctx is done
+func (r *ConfigReloader) Run(ctx context.Context, hup <-chan os.Signal, pollEvery time.Duration) {
+	var poll <-chan time.Time
+	if pollEvery > 0 {
+		ticker := time.NewTicker(pollEvery)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-poll:
+			mt := r.fileModTime()
+			if mt.Equal(r.modTime) {
+				continue
+			}
+			r.modTime = mt
+		}
+		if err := r.Reload(); err != nil {
+			r.log.Err(err).Msg("config reload failed, keeping the current config")
+		}
+	}
+}
+
+func (r *ConfigReloader) fileModTime() time.Time {
+	info, err := os.Stat(r.flags.Path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// ConfigChange is a changed config value, Old or New being empty if the key
+// was added or removed
+type ConfigChange struct {
+	Key string
+	Old string
+	New string
+}
+
+// diffConfig returns the changed values by key path, sorted
+func diffConfig(old, new *Config) []ConfigChange {
+	before, after := flattenConfig(old), flattenConfig(new)
+	var changes []ConfigChange
+	for k, v := range after {
+		if before[k] != v {
+			changes = append(changes, ConfigChange{Key: k, Old: before[k], New: v})
+		}
+	}
+	for k, v := range before {
+		if _, ok := after[k]; !ok {
+			changes = append(changes, ConfigChange{Key: k, Old: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// flattenConfig returns the scalar values of the config by key path
+func flattenConfig(cfg *Config) map[string]string {
+	flat := make(map[string]string)
+	var n yaml.Node
+	if err := n.Encode(cfg); err != nil {
+		return flat
+	}
+	var walk func(n *yaml.Node, path string)
+	walk = func(n *yaml.Node, path string) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				walk(n.Content[i+1], strings.TrimPrefix(path+"."+n.Content[i].Value, "."))
+			}
+		case yaml.SequenceNode:
+			for i, item := range n.Content {
+				walk(item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		case yaml.ScalarNode:
+			// zero values are left out so unset and zero keys compare equal
+			if n.Value != "" && n.Value != "0" && n.Value != "false" && n.Tag != "!!null" {
+				flat[path] = n.Value
+			}
+		}
+	}
+	walk(&n, "")
+	return flat
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package bankxgo_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+func TestServiceLimitsUpdate(t *testing.T) {
+	t.Run("updates limiters in place", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		cfg := &bankxgo.ServiceLimitsCfg{
+			Deposit: bankxgo.EndpointLimitCfg{SloMs: 100, Rate: 10, Burst: 20},
+			Balance: bankxgo.EndpointLimitCfg{
+				Strategy: bankxgo.LimitStrategyAIMD,
+				SloMs:    100,
+				Adaptive: bankxgo.AdaptiveLimitCfg{InitialLimit: 50, MaxLimit: 100},
+			},
+		}
+		limits, err := bankxgo.NewServiceLimits(cfg)
+		reqrd.Nil(err)
+		deposit := limits.Deposit
+
+		cfg.Deposit = bankxgo.EndpointLimitCfg{
+			SloMs:      250,
+			Rate:       5,
+			Burst:      7,
+			PerAccount: bankxgo.KeyedLimitCfg{Rate: 1, Burst: 1},
+		}
+		cfg.Balance.Adaptive.MaxLimit = 30
+		err = limits.Update(cfg)
+		reqrd.Nil(err)
+
+		as.Same(deposit, limits.Deposit)
+		st := limits.Status()
+		as.Equal(bankxgo.EndpointLimitStatus{Strategy: "token_bucket", SloMs: 250, Limit: 5, Burst: 7}, st["deposit"])
+		as.Equal(float64(30), st["balance"].Limit)
+
+		// the per account limiter enabled by the update admits a single request
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		svc.EXPECT().Deposit(gomock.Any(), gomock.Any()).Return(nil, nil)
+		lsvc := bankxgo.NewlimitMiddleware(limits)(svc)
+		_, err = lsvc.Deposit(context.Background(), bankxgo.ChargeReq{AcctID: 1})
+		as.Nil(err)
+		_, err = lsvc.Deposit(context.Background(), bankxgo.ChargeReq{AcctID: 1})
+		as.ErrorAs(err, &bankxgo.ErrRateLimited{})
+	})
+
+	t.Run("applies nothing if a strategy changed", func(tt *testing.T) {
+		as := assert.New(tt)
+		cfg := &bankxgo.ServiceLimitsCfg{
+			Deposit: bankxgo.EndpointLimitCfg{SloMs: 100, Rate: 10, Burst: 20},
+			Balance: bankxgo.EndpointLimitCfg{SloMs: 100, Rate: 10, Burst: 20},
+		}
+		limits, err := bankxgo.NewServiceLimits(cfg)
+		require.Nil(tt, err)
+
+		cfg.Deposit.Rate = 1
+		cfg.Balance.Strategy = bankxgo.LimitStrategyAIMD
+		err = limits.Update(cfg)
+		var cerr bankxgo.ConfigError
+		as.ErrorAs(err, &cerr)
+		as.Equal("service_limits.balance.strategy", cerr.Key)
+		as.Equal(float64(10), limits.Status()["deposit"].Limit)
+	})
+}
+
+func TestConfigReloader(t *testing.T) {
+	usd := snowflake.ParseInt64(7241722241547767808)
+	eur := snowflake.ParseInt64(7241788881056567296)
+
+	setup := func(tt *testing.T) (*bankxgo.ConfigReloader, *bankxgo.ConfigFlags, *bankxgo.SystemAccounts, *bankxgo.ServiceLimits, *mocks.MockRepository, *bytes.Buffer) {
+		path := writeConfig(tt, testConfigYAML)
+		flags := &bankxgo.ConfigFlags{Path: path}
+		cfg, err := flags.Load()
+		require.Nil(tt, err)
+		byCurrency, internal, err := cfg.SystemAccountIDs()
+		require.Nil(tt, err)
+		sysAccts := bankxgo.NewSystemAccounts(byCurrency, internal)
+		limits, err := bankxgo.NewServiceLimits(&cfg.ServiceLimits)
+		require.Nil(tt, err)
+		repo := mocks.NewMockRepository(gomock.NewController(tt))
+		buf := new(bytes.Buffer)
+		log := zerolog.New(buf)
+		return bankxgo.NewConfigReloader(flags, cfg, repo, limits, sysAccts, &log), flags, sysAccts, limits, repo, buf
+	}
+	edit := func(tt *testing.T, path, old, new string) {
+		bits, err := os.ReadFile(path)
+		require.Nil(tt, err)
+		require.Contains(tt, string(bits), old)
+		err = os.WriteFile(path, []byte(strings.Replace(string(bits), old, new, 1)), 0o600)
+		require.Nil(tt, err)
+	}
+
+	t.Run("swaps the system accounts and updates limits, logging the diff", func(tt *testing.T) {
+		as := assert.New(tt)
+		reloader, flags, sysAccts, limits, repo, buf := setup(tt)
+		edit(tt, flags.Path, "  USD: 7241722241547767808\n", "  USD: 7241722241547767808\n  EUR: 7241788881056567296\n")
+		edit(tt, flags.Path, "  deposit:\n    rate: 10", "  deposit:\n    rate: 25")
+		edit(tt, flags.Path, "annual_rate: 2.5", "annual_rate: 3")
+
+		repo.EXPECT().GetAccount(gomock.Any(), usd).Return(&bankxgo.Account{AcctID: usd, Currency: "USD"}, nil)
+		repo.EXPECT().GetAccount(gomock.Any(), eur).Return(&bankxgo.Account{AcctID: eur, Currency: "EUR"}, nil)
+		err := reloader.Reload()
+		require.Nil(tt, err)
+
+		id, ok := sysAccts.Get("EUR")
+		as.True(ok)
+		as.Equal(eur, id)
+		as.Equal(float64(25), limits.Status()["deposit"].Limit)
+		logs := buf.String()
+		as.Contains(logs, `"key":"system_accounts.EUR","old":"","new":"7241788881056567296","message":"config reloaded"`)
+		as.Contains(logs, `"key":"service_limits.deposit.rate","old":"10","new":"25","message":"config reloaded"`)
+		as.Contains(logs, `"level":"warn","key":"interest.USD.annual_rate","old":"2.5","new":"3","message":"config changed, restart to apply"`)
+	})
+
+	t.Run("keeps the current config if a system account does not check out", func(tt *testing.T) {
+		as := assert.New(tt)
+		reloader, flags, sysAccts, limits, repo, _ := setup(tt)
+		edit(tt, flags.Path, "  USD: 7241722241547767808\n", "  USD: 7241722241547767808\n  EUR: 7241788881056567296\n")
+		edit(tt, flags.Path, "  deposit:\n    rate: 10", "  deposit:\n    rate: 25")
+
+		repo.EXPECT().GetAccount(gomock.Any(), usd).Return(&bankxgo.Account{AcctID: usd, Currency: "USD"}, nil).AnyTimes()
+		repo.EXPECT().GetAccount(gomock.Any(), eur).Return(&bankxgo.Account{AcctID: eur, Currency: "PHP"}, nil)
+		err := reloader.Reload()
+		as.ErrorAs(err, &bankxgo.ErrNotFound{})
+
+		_, ok := sysAccts.Get("EUR")
+		as.False(ok)
+		as.Equal(float64(10), limits.Status()["deposit"].Limit)
+	})
+
+	t.Run("refuses to remove a currency", func(tt *testing.T) {
+		reloader, flags, sysAccts, _, _, _ := setup(tt)
+		edit(tt, flags.Path, "  USD: 7241722241547767808\n", "  EUR: 7241788881056567296\n")
+
+		err := reloader.Reload()
+		var cerr bankxgo.ConfigError
+		assert.ErrorAs(tt, err, &cerr)
+		assert.Equal(tt, "system_accounts.USD", cerr.Key)
+		_, ok := sysAccts.Get("USD")
+		assert.True(tt, ok)
+	})
+
+	t.Run("reloads on signal and on file change", func(tt *testing.T) {
+		reloader, flags, _, limits, repo, _ := setup(tt)
+		repo.EXPECT().GetAccount(gomock.Any(), usd).Return(&bankxgo.Account{AcctID: usd, Currency: "USD"}, nil).AnyTimes()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		hup := make(chan os.Signal)
+		go reloader.Run(ctx, hup, 10*time.Millisecond)
+
+		// written with a later modification time, as the file system's
+		// resolution may be too coarse to tell writes apart
+		edit(tt, flags.Path, "  deposit:\n    rate: 10", "  deposit:\n    rate: 25")
+		later := time.Now().Add(time.Second)
+		require.Nil(tt, os.Chtimes(flags.Path, later, later))
+		assert.Eventually(tt, func() bool {
+			return limits.Status()["deposit"].Limit == 25
+		}, time.Second, 10*time.Millisecond)
+
+		// the modification time is kept so only the signal triggers a reload
+		edit(tt, flags.Path, "  deposit:\n    rate: 25", "  deposit:\n    rate: 30")
+		require.Nil(tt, os.Chtimes(flags.Path, later, later))
+		hup <- os.Interrupt
+		assert.Eventually(tt, func() bool {
+			return limits.Status()["deposit"].Limit == 30
+		}, time.Second, 10*time.Millisecond)
+	})
+}
@@ -1,14 +1,84 @@
 package bankxgo
 
 import (
+	"context"
+	"time"
+
 	"github.com/bwmarrin/snowflake"
 	"github.com/shopspring/decimal"
 )
 
 type Repository interface {
-	CreateAccount(req CreateAccountReq) error
-	CreditUser(amount decimal.Decimal, userAcct, systemAcct snowflake.ID) (*decimal.Decimal, error)
-	DebitUser(amount decimal.Decimal, userAcct, systemAcct snowflake.ID) (*decimal.Decimal, error)
-	GetAccount(id snowflake.ID) (*Account, error)
-	GetAccountCharges(id snowflake.ID) ([]Charge, error)
+	CreateAccount(ctx context.Context, req CreateAccountReq) error
+	CreditUser(ctx context.Context, amount decimal.Decimal, userAcct, systemAcct snowflake.ID, limits WithdrawalLimits, fee Fee, memo Memo) (*decimal.Decimal, error)
+	DebitUser(ctx context.Context, amount decimal.Decimal, userAcct, systemAcct snowflake.ID, memo Memo) (*decimal.Decimal, error)
+	// DebitUserPending records a pending deposit, which is added to the balance
+	// once settled, and returns the balance as is. The memo must have a
+	// reference no other pending deposit of the account has or had.
+	DebitUserPending(ctx context.Context, amount decimal.Decimal, userAcct, systemAcct snowflake.ID, memo Memo) (*decimal.Decimal, error)
+	// SettleTransaction posts or fails the pending deposit of the account with
+	// the reference and returns it. Settling it again the same way returns it
+	// as is.
+	SettleTransaction(ctx context.Context, acctID snowflake.ID, reference, status string) (*Transaction, error)
+	GetAccount(ctx context.Context, id snowflake.ID) (*Account, error)
+	GetAccountCharges(ctx context.Context, id snowflake.ID) ([]Charge, error)
+	// ListTransactions returns the transactions of the account matching the
+	// filter, latest first, with their amount and fee netted per transaction
+	ListTransactions(ctx context.Context, acctID snowflake.ID, filter TransactionFilter) ([]Transaction, error)
+
+	CreateStatementJob(ctx context.Context, job StatementJob) error
+	GetStatementJob(ctx context.Context, id snowflake.ID) (*StatementJob, error)
+	// FindDoneStatementJob returns the latest completed job for the same account,
+	// format and period or ErrNotFound
+	FindDoneStatementJob(ctx context.Context, acctID snowflake.ID, format string, from, to time.Time) (*StatementJob, error)
+	// ClaimStatementJob marks the oldest queued job, or a running job older than
+	// lease, as running, counts the attempt and returns it. It returns nil if
+	// there is none. Running jobs older than lease that were attempted
+	// maxAttempts times already are marked failed instead, as they keep
+	// crashing their workers.
+	ClaimStatementJob(ctx context.Context, lease time.Duration, maxAttempts int) (*StatementJob, error)
+	CompleteStatementJob(ctx context.Context, id snowflake.ID, fileKey string) error
+	// FailStatementJob puts the job back in the queue if retry, otherwise marks it failed
+	FailStatementJob(ctx context.Context, id snowflake.ID, errMsg string, retry bool) error
+
+	// CreateStatementVerification records an issued statement, reissuing the
+	// same statement keeps the original record
+	CreateStatementVerification(ctx context.Context, v StatementVerification) error
+	GetStatementVerification(ctx context.Context, code string) (*StatementVerification, error)
+
+	// GetStatementPreference returns ErrNotFound if the customer has no preference
+	GetStatementPreference(ctx context.Context, acctID snowflake.ID) (*StatementPreference, error)
+	SetStatementPreference(ctx context.Context, acctID snowflake.ID, pref StatementPreference) error
+	// GetStatementPassword returns the sealed statement password of the account,
+	// or ErrNotFound if the customer has not set one
+	GetStatementPassword(ctx context.Context, acctID snowflake.ID) ([]byte, error)
+	// SetStatementPassword stores the sealed statement password of the
+	// account, removing it if sealed is nil
+	SetStatementPassword(ctx context.Context, acctID snowflake.ID, sealed []byte) error
+
+	// ListStatementPeriods returns the closed periods of the account, latest first
+	ListStatementPeriods(ctx context.Context, acctID snowflake.ID) ([]StatementPeriod, error)
+	// GetStatementPeriod returns the closed period ending on to or ErrNotFound
+	GetStatementPeriod(ctx context.Context, acctID snowflake.ID, to time.Time) (*StatementPeriod, error)
+
+	// BalanceEventsAfter returns the balance events of the account after the
+	// event with ID after, oldest first
+	BalanceEventsAfter(ctx context.Context, acctID snowflake.ID, after int64) ([]BalanceEvent, error)
+
+	// CreateBatch records the batch with its items pending and returns it, or
+	// returns the batch recorded earlier with the same idempotency key
+	CreateBatch(ctx context.Context, b Batch) (*Batch, error)
+	GetBatch(ctx context.Context, id snowflake.ID) (*Batch, error)
+	// PostBatch posts the pending items of the batch and returns the batch with
+	// their outcomes
+	PostBatch(ctx context.Context, id snowflake.ID, mode string, postings []BatchPosting) (*Batch, error)
+
+	CreateScheduledPayment(ctx context.Context, p ScheduledPayment) error
+	GetScheduledPayment(ctx context.Context, id snowflake.ID) (*ScheduledPayment, error)
+	// ListScheduledPayments returns the payments of the account, latest first
+	ListScheduledPayments(ctx context.Context, acctID snowflake.ID) ([]ScheduledPayment, error)
+	// UpdateScheduledPayment saves the spec, status and next run of the payment
+	UpdateScheduledPayment(ctx context.Context, p ScheduledPayment) error
+	// ListScheduledPaymentRuns returns the runs of the payment, latest first
+	ListScheduledPaymentRuns(ctx context.Context, paymentID snowflake.ID) ([]ScheduledPaymentRun, error)
 }
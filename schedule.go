@@ -0,0 +1,366 @@
+package bankxgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ScheduledPaymentActive = "active"
+	// ScheduledPaymentCompleted payments have no occurrence left before their
+	// end date, they are active again if updated with a later one
+	ScheduledPaymentCompleted = "completed"
+	ScheduledPaymentCancelled = "cancelled"
+)
+
+// What a scheduled payment does when the source account has insufficient funds
+const (
+	// ScheduleSkip gives up the occurrence, the default
+	ScheduleSkip = "skip"
+	// ScheduleRetry attempts the occurrence again, see SchedulerCfg
+	ScheduleRetry = "retry"
+)
+
+// Outcomes of an attempt of a scheduled payment
+const (
+	ScheduledRunPosted = "posted"
+	// ScheduledRunRetrying attempts failed for insufficient funds and will be retried
+	ScheduledRunRetrying = "retrying"
+	// ScheduledRunSkipped attempts failed for insufficient funds and were not retried
+	ScheduledRunSkipped = "skipped"
+	// ScheduledRunFailed attempts failed otherwise, ie. an account was frozen
+	ScheduledRunFailed = "failed"
+)
+
+// Units of calendar schedules
+const (
+	ScheduleEveryDay   = "day"
+	ScheduleEveryWeek  = "week"
+	ScheduleEveryMonth = "month"
+	ScheduleEveryYear  = "year"
+)
+
+// ScheduleRule is when a scheduled payment is due, either a cron expression or
+// a calendar rule repeating every Interval days, weeks, months or years from
+// the start of the payment. All times are UTC.
+type ScheduleRule struct {
+	// Cron is a cron expression of five fields: minute, hour, day of month,
+	// month and day of week, ie. `0 9 1 * *` for 09:00 on the first of the month
+	Cron string `json:"cron,omitempty"`
+	// Every is one of the ScheduleEvery* units. Monthly and yearly payments
+	// starting on a day past the end of a shorter month are due on its last day.
+	Every string `json:"every,omitempty"`
+	// Interval is the number of units between payments, 1 if zero
+	Interval int `json:"interval,omitempty"`
+}
+
+// ScheduledPaymentSpec is what the customer sets on a scheduled payment
+type ScheduledPaymentSpec struct {
+	// ToAcctID is the account credited, it has to be of the same currency
+	ToAcctID snowflake.ID    `json:"toAcctID"`
+	Amount   decimal.Decimal `json:"amount"`
+	Rule     ScheduleRule    `json:"rule"`
+	// StartAt is the earliest time the payment is due, now if zero
+	StartAt time.Time `json:"startAt"`
+	// EndAt is the latest time the payment is due, if any
+	EndAt *time.Time `json:"endAt,omitempty"`
+	// OnInsufficientFunds is ScheduleSkip or ScheduleRetry
+	OnInsufficientFunds string `json:"onInsufficientFunds,omitempty"`
+}
+
+// ScheduledPayment is a standing order moving a fixed amount from the account
+// to another one as scheduled
+type ScheduledPayment struct {
+	ID     snowflake.ID `json:"paymentID"`
+	AcctID snowflake.ID `json:"acctID"`
+	ScheduledPaymentSpec
+	Status string `json:"status"`
+	// NextRunAt is the occurrence due next, nil unless the payment is active
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+	// Attempt counts the failed attempts of the occurrence, which is attempted
+	// again at AttemptAt
+	Attempt   int        `json:"-"`
+	AttemptAt *time.Time `json:"-"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// ScheduledPaymentRun is an attempt of an occurrence of a scheduled payment
+type ScheduledPaymentRun struct {
+	PaymentID snowflake.ID `json:"paymentID"`
+	DueAt     time.Time    `json:"dueAt"`
+	// Attempt is 0 for the first attempt of the occurrence
+	Attempt int    `json:"attempt"`
+	Status  string `json:"status"`
+	// BatchID is the batch the payment was posted as, if it got that far
+	BatchID snowflake.ID `json:"batchID,omitempty"`
+	// ErrorCode is one of the Code constants, set unless the run posted
+	ErrorCode string    `json:"errorCode,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	RanAt     time.Time `json:"ranAt"`
+}
+
+// ScheduledPaymentReq creates a scheduled payment or, if PaymentID is set,
+// replaces the spec of an existing one
+type ScheduledPaymentReq struct {
+	AcctID    snowflake.ID
+	PaymentID snowflake.ID
+	Email     string
+	Client    string
+	ScheduledPaymentSpec
+}
+
+type ScheduledPaymentsReq struct {
+	AcctID snowflake.ID
+	Email  string
+	Client string
+}
+
+type ScheduledPaymentReqByID struct {
+	AcctID    snowflake.ID
+	PaymentID snowflake.ID
+	Email     string
+	Client    string
+}
+
+// Schedule computes the occurrences of a ScheduleRule
+type Schedule interface {
+	// Next returns the first occurrence after `after` that is not before start
+	Next(start, after time.Time) time.Time
+}
+
+// ParseScheduleRule validates the rule, the errors are keyed by the field at
+// fault for ErrBadRequest
+func ParseScheduleRule(r ScheduleRule) (Schedule, map[string]string) {
+	switch {
+	case r.Cron != "" && r.Every != "":
+		return nil, map[string]string{"rule": "either cron or every"}
+	case r.Cron != "":
+		cs, err := parseCron(r.Cron)
+		if err != nil {
+			return nil, map[string]string{"rule.cron": err.Error()}
+		}
+		return cs, nil
+	case r.Every != "":
+		switch r.Every {
+		case ScheduleEveryDay, ScheduleEveryWeek, ScheduleEveryMonth, ScheduleEveryYear:
+		default:
+			return nil, map[string]string{"rule.every": "unsupported"}
+		}
+		if r.Interval < 0 {
+			return nil, map[string]string{"rule.interval": "negative"}
+		}
+		interval := r.Interval
+		if interval == 0 {
+			interval = 1
+		}
+		return calendarSchedule{every: r.Every, interval: interval}, nil
+	default:
+		return nil, map[string]string{"rule": "missing cron or every"}
+	}
+}
+
+// NextScheduledRun returns the first occurrence of the payment after `after`,
+// false if there is none before its end
+func NextScheduledRun(p ScheduledPaymentSpec, after time.Time) (time.Time, bool) {
+	sched, errs := ParseScheduleRule(p.Rule)
+	if errs != nil {
+		return time.Time{}, false
+	}
+	next := sched.Next(p.StartAt.UTC(), after.UTC())
+	if next.IsZero() || (p.EndAt != nil && next.After(*p.EndAt)) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// calendarSchedule repeats every interval units from the start
+type calendarSchedule struct {
+	every    string
+	interval int
+}
+
+// occurrence returns the nth occurrence from start, counted from the start
+// rather than the previous one so a payment starting on the 31st is back on
+// the 31st after February
+func (c calendarSchedule) occurrence(start time.Time, n int) time.Time {
+	switch c.every {
+	case ScheduleEveryDay:
+		return start.AddDate(0, 0, n*c.interval)
+	case ScheduleEveryWeek:
+		return start.AddDate(0, 0, 7*n*c.interval)
+	}
+	months := n * c.interval
+	if c.every == ScheduleEveryYear {
+		months *= 12
+	}
+	first := time.Date(start.Year(), start.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	day := start.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, start.Hour(), start.Minute(), start.Second(), 0, time.UTC)
+}
+
+func (c calendarSchedule) Next(start, after time.Time) time.Time {
+	if after.Before(start) {
+		return start
+	}
+	// estimate the occurrence from the average length of the unit and walk
+	// from there, a month or year is never off by more than a few
+	unit := 24 * time.Hour
+	switch c.every {
+	case ScheduleEveryWeek:
+		unit *= 7
+	case ScheduleEveryMonth:
+		unit *= 30
+	case ScheduleEveryYear:
+		unit *= 365
+	}
+	n := int(after.Sub(start) / (unit * time.Duration(c.interval)))
+	for n > 0 && c.occurrence(start, n).After(after) {
+		n--
+	}
+	for !c.occurrence(start, n).After(after) {
+		n++
+	}
+	return c.occurrence(start, n)
+}
+
+// cronSchedule is a parsed cron expression, each field a set of the values it
+// matches
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar tell whether the day fields are unrestricted, if both
+	// are restricted a day matching either of them matches, as in Vixie cron
+	domStar, dowStar bool
+}
+
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parseCron parses the five fields of a cron expression, each a `*` or a
+// comma separated list of values and ranges with an optional `/step`. Sunday
+// is both 0 and 7 in the day of week.
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected %d fields", len(cronFields))
+	}
+	sets := make([]uint64, len(fields))
+	for i, f := range fields {
+		set, err := parseCronField(f, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+	cs := &cronSchedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}
+	if cs.dow&(1<<7) != 0 {
+		cs.dow |= 1
+	}
+	return cs, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			rng, step = part[:i], s
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", bounds[0])
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value %q", bounds[1])
+				}
+			} else if step > 1 {
+				// `5/15` is every 15 from 5
+				hi = max
+			}
+			if lo < min || hi > max || lo > hi {
+				return 0, fmt.Errorf("%q out of range %d-%d", rng, min, max)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (cs *cronSchedule) matchesDay(t time.Time) bool {
+	dom := cs.dom&(1<<t.Day()) != 0
+	dow := cs.dow&(1<<t.Weekday()) != 0
+	switch {
+	case cs.domStar && cs.dowStar:
+		return true
+	case cs.domStar:
+		return dow
+	case cs.dowStar:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// cronSearchYears bounds the search for the next occurrence, an expression
+// like `0 0 31 2 *` never matches
+const cronSearchYears = 5
+
+func (cs *cronSchedule) Next(start, after time.Time) time.Time {
+	t := after.UTC()
+	if t.Before(start) {
+		// start itself may match
+		t = start.UTC().Add(-time.Nanosecond)
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case cs.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !cs.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case cs.hour&(1<<t.Hour()) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case cs.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
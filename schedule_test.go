@@ -0,0 +1,94 @@
+package bankxgo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/arhyth/bankxgo"
+)
+
+func TestParseScheduleRule(t *testing.T) {
+	cases := []struct {
+		rule   bankxgo.ScheduleRule
+		fields map[string]string
+	}{
+		{bankxgo.ScheduleRule{}, map[string]string{"rule": "missing cron or every"}},
+		{bankxgo.ScheduleRule{Cron: "0 9 * * *", Every: "day"}, map[string]string{"rule": "either cron or every"}},
+		{bankxgo.ScheduleRule{Cron: "0 9 * *"}, map[string]string{"rule.cron": "expected 5 fields"}},
+		{bankxgo.ScheduleRule{Cron: "0 24 * * *"}, map[string]string{"rule.cron": `hour: "24" out of range 0-23`}},
+		{bankxgo.ScheduleRule{Cron: "0 9 L * *"}, map[string]string{"rule.cron": `day of month: invalid value "L"`}},
+		{bankxgo.ScheduleRule{Cron: "*/0 * * * *"}, map[string]string{"rule.cron": `minute: invalid step "0"`}},
+		{bankxgo.ScheduleRule{Every: "fortnight"}, map[string]string{"rule.every": "unsupported"}},
+		{bankxgo.ScheduleRule{Every: "week", Interval: -2}, map[string]string{"rule.interval": "negative"}},
+		{bankxgo.ScheduleRule{Cron: "*/15 9-17 * * 1-5"}, nil},
+		{bankxgo.ScheduleRule{Every: "month"}, nil},
+	}
+	for _, c := range cases {
+		_, fields := bankxgo.ParseScheduleRule(c.rule)
+		assert.Equal(t, c.fields, fields, "%+v", c.rule)
+	}
+}
+
+func TestNextScheduledRun(t *testing.T) {
+	at := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	cases := []struct {
+		name  string
+		rule  bankxgo.ScheduleRule
+		start string
+		after string
+		want  string
+	}{
+		{"cron is due at the start if it matches", bankxgo.ScheduleRule{Cron: "0 9 * * *"}, "2024-10-01T09:00:00Z", "2024-09-01T00:00:00Z", "2024-10-01T09:00:00Z"},
+		{"cron waits for the start", bankxgo.ScheduleRule{Cron: "0 9 * * *"}, "2024-10-01T10:00:00Z", "2024-09-01T00:00:00Z", "2024-10-02T09:00:00Z"},
+		{"cron is after the previous run", bankxgo.ScheduleRule{Cron: "0 9 * * *"}, "2024-10-01T09:00:00Z", "2024-10-01T09:00:00Z", "2024-10-02T09:00:00Z"},
+		{"cron steps", bankxgo.ScheduleRule{Cron: "*/15 * * * *"}, "2024-10-01T00:00:00Z", "2024-10-01T10:16:30Z", "2024-10-01T10:30:00Z"},
+		{"cron weekdays", bankxgo.ScheduleRule{Cron: "30 8 * * 1-5"}, "2024-10-01T00:00:00Z", "2024-10-04T09:00:00Z", "2024-10-07T08:30:00Z"},
+		{"cron sunday is 7", bankxgo.ScheduleRule{Cron: "0 0 * * 7"}, "2024-10-01T00:00:00Z", "2024-10-01T00:00:00Z", "2024-10-06T00:00:00Z"},
+		{"cron either day field", bankxgo.ScheduleRule{Cron: "0 0 15 * 1"}, "2024-10-01T00:00:00Z", "2024-10-01T00:00:00Z", "2024-10-07T00:00:00Z"},
+		{"cron skips months without the day", bankxgo.ScheduleRule{Cron: "0 12 31 * *"}, "2024-01-01T00:00:00Z", "2024-01-31T12:00:00Z", "2024-03-31T12:00:00Z"},
+		{"cron leap day", bankxgo.ScheduleRule{Cron: "0 0 29 2 *"}, "2024-01-01T00:00:00Z", "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"every day", bankxgo.ScheduleRule{Every: "day"}, "2024-10-01T09:00:00Z", "2024-10-05T09:00:00Z", "2024-10-06T09:00:00Z"},
+		{"every other week", bankxgo.ScheduleRule{Every: "week", Interval: 2}, "2024-10-01T09:00:00Z", "2024-10-02T00:00:00Z", "2024-10-15T09:00:00Z"},
+		{"every month on the last day", bankxgo.ScheduleRule{Every: "month"}, "2024-01-31T09:00:00Z", "2024-01-31T09:00:00Z", "2024-02-29T09:00:00Z"},
+		{"every month back on the 31st", bankxgo.ScheduleRule{Every: "month"}, "2024-01-31T09:00:00Z", "2024-02-29T09:00:00Z", "2024-03-31T09:00:00Z"},
+		{"every quarter", bankxgo.ScheduleRule{Every: "month", Interval: 3}, "2024-01-15T00:00:00Z", "2024-11-20T00:00:00Z", "2025-01-15T00:00:00Z"},
+		{"every year from a leap day", bankxgo.ScheduleRule{Every: "year"}, "2024-02-29T00:00:00Z", "2024-02-29T00:00:00Z", "2025-02-28T00:00:00Z"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(tt *testing.T) {
+			spec := bankxgo.ScheduledPaymentSpec{Rule: c.rule, StartAt: at(c.start)}
+			next, ok := bankxgo.NextScheduledRun(spec, at(c.after))
+			assert.True(tt, ok)
+			assert.Equal(tt, at(c.want), next)
+		})
+	}
+
+	t.Run("none after the end", func(tt *testing.T) {
+		end := at("2024-12-31T00:00:00Z")
+		spec := bankxgo.ScheduledPaymentSpec{
+			Rule:    bankxgo.ScheduleRule{Every: "month"},
+			StartAt: at("2024-10-31T09:00:00Z"),
+			EndAt:   &end,
+		}
+		next, ok := bankxgo.NextScheduledRun(spec, at("2024-10-31T09:00:00Z"))
+		assert.True(tt, ok)
+		assert.Equal(tt, at("2024-11-30T09:00:00Z"), next)
+		_, ok = bankxgo.NextScheduledRun(spec, next)
+		assert.False(tt, ok)
+	})
+
+	t.Run("none if the cron never matches", func(tt *testing.T) {
+		spec := bankxgo.ScheduledPaymentSpec{Rule: bankxgo.ScheduleRule{Cron: "0 0 31 2 *"}, StartAt: at("2024-01-01T00:00:00Z")}
+		_, ok := bankxgo.NextScheduledRun(spec, at("2024-01-01T00:00:00Z"))
+		assert.False(tt, ok)
+	})
+}
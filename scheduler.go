@@ -0,0 +1,255 @@
+package bankxgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+)
+
+// SchedulerClient is the client of the batches the scheduler posts. It is only
+// a name, the scheduler is let past the batches limits by the context of its
+// requests, see withInternalCaller.
+const SchedulerClient = "scheduler"
+
+// SchedulerStore is the persistence needed by the scheduler
+type SchedulerStore interface {
+	GetAccount(ctx context.Context, id snowflake.ID) (*Account, error)
+	// LockScheduler takes the scheduler advisory lock, which one instance holds
+	// at a time, until unlock is called. ok is false if another instance holds it.
+	LockScheduler(ctx context.Context) (unlock func(), ok bool, err error)
+	// DueScheduledPayments returns up to limit active payments whose attempt is
+	// due by now, earliest first
+	DueScheduledPayments(ctx context.Context, now time.Time, limit int) ([]ScheduledPayment, error)
+	// RecordScheduledPaymentRun adds the run to the history of the payment and
+	// moves the payment to next, its state after the run. Only the history is
+	// added to if the payment was updated or cancelled since it was fetched.
+	RecordScheduledPaymentRun(ctx context.Context, run ScheduledPaymentRun, next ScheduledPayment) error
+}
+
+// Scheduler runs the scheduled payments as they fall due. Every instance may
+// run a scheduler, the one holding the scheduler advisory lock runs the due
+// payments while the others wait for their turn.
+//
+// A payment is posted as an atomic batch of a withdrawal from its account and
+// a deposit to the other through the (middleware wrapped) service, so the same
+// validation, fees, idempotency and per account limits of deposits and
+// withdrawals apply as to any other batch. Only the limits of the batches
+// endpoint are skipped. The
+// idempotency key identifies the attempt, an attempt interrupted before it was
+// recorded is replayed rather than posted twice.
+type Scheduler struct {
+	store      SchedulerStore
+	svc        Service
+	pollEvery  time.Duration
+	batchSize  int
+	retryEvery time.Duration
+	maxRetries int
+	log        *zerolog.Logger
+}
+
+// NewScheduler expects svc to be wrapped by the validation and limit
+// middlewares, the latter skipping the batches endpoint limits for payments
+func NewScheduler(store SchedulerStore, svc Service, cfg SchedulerCfg, log *zerolog.Logger) *Scheduler {
+	s := &Scheduler{
+		store:      store,
+		svc:        svc,
+		pollEvery:  time.Duration(cfg.PollSec) * time.Second,
+		batchSize:  cfg.BatchSize,
+		retryEvery: time.Duration(cfg.RetrySec) * time.Second,
+		maxRetries: cfg.MaxRetries,
+		log:        log,
+	}
+	if s.pollEvery <= 0 {
+		s.pollEvery = 30 * time.Second
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = 100
+	}
+	if s.retryEvery <= 0 {
+		s.retryEvery = time.Hour
+	}
+	if s.maxRetries <= 0 {
+		s.maxRetries = 3
+	}
+	return s
+}
+
+// Run polls for due payments until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		n, err := s.RunOnce(ctx, time.Now().UTC())
+		if err != nil {
+			s.log.Err(err).Msg("scheduler failed")
+		}
+		// a full round means more may be due already
+		if err == nil && n == s.batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.pollEvery):
+		}
+	}
+}
+
+// RunOnce runs the payments due by now if the instance holds the scheduler
+// lock and returns how many it ran. A payment held up by the limits of its
+// accounts is attempted in the next round, one failing with anything but a
+// domain error, ie. a database failure, ends the round.
+func (s *Scheduler) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	unlock, ok, err := s.store.LockScheduler(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("LockScheduler: %w", err)
+	}
+	if !ok {
+		return 0, nil
+	}
+	defer unlock()
+	// the payments of every account are posted as one client, so they skip
+	// the limits of the batches endpoint, but not those of their accounts
+	ctx = withInternalCaller(ctx)
+
+	due, err := s.store.DueScheduledPayments(ctx, now, s.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("DueScheduledPayments: %w", err)
+	}
+	ran := 0
+	for _, p := range due {
+		run, next, err := s.runPayment(ctx, p, now)
+		if errors.As(err, &ErrRateLimited{}) {
+			// the limits are those of its accounts, the payments of other
+			// accounts are not held up by them
+			s.log.Warn().Err(err).Str("paymentID", p.ID.String()).Msg("scheduled payment rate limited")
+			continue
+		}
+		if err != nil {
+			return ran, fmt.Errorf("scheduled payment %s: %w", p.ID, err)
+		}
+		if err = s.store.RecordScheduledPaymentRun(ctx, run, next); err != nil {
+			return ran, fmt.Errorf("RecordScheduledPaymentRun: %w", err)
+		}
+		ran++
+		s.log.Info().
+			Str("paymentID", p.ID.String()).
+			Time("dueAt", run.DueAt).
+			Int("attempt", run.Attempt).
+			Str("status", run.Status).
+			Msg("scheduled payment run")
+	}
+	return ran, nil
+}
+
+// scheduledBatchKey identifies the attempt of the occurrence of the payment,
+// along with the version of the payment so an updated amount is not taken for
+// a reused key
+func scheduledBatchKey(p ScheduledPayment) string {
+	return fmt.Sprintf("scheduled-%s-%d-%d-%d", p.ID, p.NextRunAt.Unix(), p.Attempt, p.UpdatedAt.UnixMilli())
+}
+
+// runPayment posts the due occurrence of the payment and returns the run and
+// the state of the payment after it
+func (s *Scheduler) runPayment(ctx context.Context, p ScheduledPayment, now time.Time) (ScheduledPaymentRun, ScheduledPayment, error) {
+	run := ScheduledPaymentRun{
+		PaymentID: p.ID,
+		DueAt:     *p.NextRunAt,
+		Attempt:   p.Attempt,
+		RanAt:     now,
+	}
+	from, err := s.store.GetAccount(ctx, p.AcctID)
+	if err != nil {
+		return run, p, err
+	}
+	to, err := s.store.GetAccount(ctx, p.ToAcctID)
+	if err != nil {
+		return run, p, err
+	}
+
+	// the scheduler acts on behalf of the customer who authorised the
+	// payment, so the emails of the accounts are filled in
+	req := BatchReq{
+		Key:  scheduledBatchKey(p),
+		Mode: BatchAtomic,
+		Items: []BatchItemReq{
+			{Type: BatchWithdrawal, AcctID: from.AcctID, Email: from.Email, Amount: p.Amount},
+			{Type: BatchDeposit, AcctID: to.AcctID, Email: to.Email, Amount: p.Amount},
+		},
+		Client: SchedulerClient,
+	}
+	b, err := s.svc.PostBatch(ctx, req)
+	var derr DomainError
+	switch {
+	case err == nil:
+	case errors.As(err, &ErrRateLimited{}):
+		// not a rejection of the payment, see RunOnce
+		return run, p, err
+	case errors.As(err, &derr):
+		// rejected by the validation, ie. an account was frozen
+		run.Status = ScheduledRunFailed
+		run.ErrorCode, run.Error = batchItemError(derr)
+	default:
+		return run, p, err
+	}
+
+	if b != nil {
+		run.BatchID = b.ID
+		switch b.Status {
+		case BatchPosted:
+			run.Status = ScheduledRunPosted
+		case BatchProcessing:
+			return run, p, fmt.Errorf("batch %s interrupted", b.ID)
+		default:
+			run.Status = ScheduledRunFailed
+			for _, it := range b.Items {
+				if it.Status == BatchItemFailed {
+					run.ErrorCode, run.Error = it.ErrorCode, it.Error
+					break
+				}
+			}
+			if run.ErrorCode == CodeInsufficientFunds {
+				run.Status = ScheduledRunSkipped
+				if p.OnInsufficientFunds == ScheduleRetry && p.Attempt < s.maxRetries {
+					run.Status = ScheduledRunRetrying
+				}
+			}
+		}
+	}
+
+	next := p
+	if run.Status == ScheduledRunRetrying {
+		next.Attempt++
+		at := now.Add(s.retryEvery)
+		next.AttemptAt = &at
+	} else {
+		next.scheduleAfter(run.DueAt)
+	}
+	return run, next, nil
+}
+
+// scheduleAfter sets the payment to its first occurrence after t, or completes
+// it if there is none before its end
+func (p *ScheduledPayment) scheduleAfter(t time.Time) {
+	p.Attempt = 0
+	next, ok := NextScheduledRun(p.ScheduledPaymentSpec, t)
+	if !ok {
+		p.Status = ScheduledPaymentCompleted
+		p.NextRunAt, p.AttemptAt = nil, nil
+		return
+	}
+	p.Status = ScheduledPaymentActive
+	p.NextRunAt, p.AttemptAt = &next, &next
+}
+
+// reschedule sets the payment to its first occurrence from now, a payment with
+// none before its end is rejected
+func (p *ScheduledPayment) reschedule(now time.Time) error {
+	p.scheduleAfter(now.Add(-time.Nanosecond))
+	if p.Status == ScheduledPaymentCompleted {
+		return ErrBadRequest{Fields: map[string]string{"endAt": "no payment due before it"}}
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package bankxgo_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+func TestSchedulerRunOnce(t *testing.T) {
+	log := zerolog.Nop()
+	payer := snowflake.ParseInt64(7241407009730334720)
+	payee := snowflake.ParseInt64(7241407009730334721)
+	now := time.Date(2024, 10, 31, 9, 0, 30, 0, time.UTC)
+	due := time.Date(2024, 10, 31, 9, 0, 0, 0, time.UTC)
+	cfg := bankxgo.SchedulerCfg{RetrySec: 3600, MaxRetries: 2}
+	payment := func() bankxgo.ScheduledPayment {
+		next := due
+		return bankxgo.ScheduledPayment{
+			ID:     snowflake.ParseInt64(7241407009730334722),
+			AcctID: payer,
+			ScheduledPaymentSpec: bankxgo.ScheduledPaymentSpec{
+				ToAcctID:            payee,
+				Amount:              decimal.New(250, 0),
+				Rule:                bankxgo.ScheduleRule{Every: bankxgo.ScheduleEveryMonth},
+				StartAt:             time.Date(2024, 8, 31, 9, 0, 0, 0, time.UTC),
+				OnInsufficientFunds: bankxgo.ScheduleRetry,
+			},
+			Status:    bankxgo.ScheduledPaymentActive,
+			NextRunAt: &next,
+			AttemptAt: &next,
+			UpdatedAt: time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC),
+		}
+	}
+	newStore := func(tt *testing.T, due ...bankxgo.ScheduledPayment) *mocks.MockSchedulerStore {
+		store := mocks.NewMockSchedulerStore(gomock.NewController(tt))
+		store.EXPECT().LockScheduler(gomock.Any()).Return(func() {}, true, nil)
+		store.EXPECT().DueScheduledPayments(gomock.Any(), now, 100).Return(due, nil)
+		store.EXPECT().
+			GetAccount(gomock.Any(), payer).
+			Return(&bankxgo.Account{AcctID: payer, Email: "payer@bank.com", Currency: "USD"}, nil).
+			AnyTimes()
+		store.EXPECT().
+			GetAccount(gomock.Any(), payee).
+			Return(&bankxgo.Account{AcctID: payee, Email: "payee@bank.com", Currency: "USD"}, nil).
+			AnyTimes()
+		return store
+	}
+	failed := func(code, msg string) *bankxgo.Batch {
+		return &bankxgo.Batch{
+			ID:     42,
+			Status: bankxgo.BatchFailed,
+			Items: []bankxgo.BatchItem{
+				{Status: bankxgo.BatchItemFailed, ErrorCode: code, Error: msg},
+				{Status: bankxgo.BatchItemSkipped},
+			},
+		}
+	}
+
+	t.Run("does nothing without the lock", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockSchedulerStore(ctrl)
+		store.EXPECT().LockScheduler(gomock.Any()).Return(nil, false, nil)
+		sched := bankxgo.NewScheduler(store, mocks.NewMockService(ctrl), cfg, &log)
+
+		n, err := sched.RunOnce(context.Background(), now)
+		assert.Nil(tt, err)
+		assert.Zero(tt, n)
+	})
+
+	t.Run("posts the payment and schedules the next occurrence", func(tt *testing.T) {
+		as := assert.New(tt)
+		p := payment()
+		store := newStore(tt, p)
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		svc.EXPECT().
+			PostBatch(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req bankxgo.BatchReq) (*bankxgo.Batch, error) {
+				as.Equal(bankxgo.BatchAtomic, req.Mode)
+				as.Equal(bankxgo.SchedulerClient, req.Client)
+				as.True(strings.HasPrefix(req.Key, "scheduled-7241407009730334722-"))
+				as.Equal([]bankxgo.BatchItemReq{
+					{Type: bankxgo.BatchWithdrawal, AcctID: payer, Email: "payer@bank.com", Amount: p.Amount},
+					{Type: bankxgo.BatchDeposit, AcctID: payee, Email: "payee@bank.com", Amount: p.Amount},
+				}, req.Items)
+				return &bankxgo.Batch{ID: 42, Status: bankxgo.BatchPosted}, nil
+			})
+		store.EXPECT().
+			RecordScheduledPaymentRun(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, run bankxgo.ScheduledPaymentRun, next bankxgo.ScheduledPayment) error {
+				as.Equal(bankxgo.ScheduledPaymentRun{
+					PaymentID: p.ID,
+					DueAt:     due,
+					Status:    bankxgo.ScheduledRunPosted,
+					BatchID:   42,
+					RanAt:     now,
+				}, run)
+				// back on the 31st after November
+				nov := time.Date(2024, 11, 30, 9, 0, 0, 0, time.UTC)
+				as.Equal(&nov, next.NextRunAt)
+				as.Equal(&nov, next.AttemptAt)
+				as.Equal(bankxgo.ScheduledPaymentActive, next.Status)
+				as.Equal(p.UpdatedAt, next.UpdatedAt)
+				return nil
+			})
+
+		n, err := bankxgo.NewScheduler(store, svc, cfg, &log).RunOnce(context.Background(), now)
+		as.Nil(err)
+		as.Equal(1, n)
+	})
+
+	t.Run("retries on insufficient funds until out of retries", func(tt *testing.T) {
+		as := assert.New(tt)
+		first := payment()
+		last := payment()
+		last.Attempt = 2
+		store := newStore(tt, first, last)
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		keys := map[string]bool{}
+		svc.EXPECT().
+			PostBatch(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req bankxgo.BatchReq) (*bankxgo.Batch, error) {
+				keys[req.Key] = true
+				return failed(bankxgo.CodeInsufficientFunds, "insufficient funds"), nil
+			}).
+			Times(2)
+		gomock.InOrder(
+			store.EXPECT().
+				RecordScheduledPaymentRun(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, run bankxgo.ScheduledPaymentRun, next bankxgo.ScheduledPayment) error {
+					as.Equal(bankxgo.ScheduledRunRetrying, run.Status)
+					as.Equal(bankxgo.CodeInsufficientFunds, run.ErrorCode)
+					as.Equal(1, next.Attempt)
+					as.Equal(&due, next.NextRunAt)
+					retryAt := now.Add(time.Hour)
+					as.Equal(&retryAt, next.AttemptAt)
+					return nil
+				}),
+			store.EXPECT().
+				RecordScheduledPaymentRun(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, run bankxgo.ScheduledPaymentRun, next bankxgo.ScheduledPayment) error {
+					as.Equal(bankxgo.ScheduledRunSkipped, run.Status)
+					as.Equal(2, run.Attempt)
+					as.Zero(next.Attempt)
+					as.Equal(time.Date(2024, 11, 30, 9, 0, 0, 0, time.UTC), *next.NextRunAt)
+					return nil
+				}),
+		)
+
+		n, err := bankxgo.NewScheduler(store, svc, cfg, &log).RunOnce(context.Background(), now)
+		as.Nil(err)
+		as.Equal(2, n)
+		// every attempt is a batch of its own
+		as.Len(keys, 2)
+	})
+
+	t.Run("records a rejected payment as failed", func(tt *testing.T) {
+		as := assert.New(tt)
+		store := newStore(tt, payment())
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		svc.EXPECT().
+			PostBatch(gomock.Any(), gomock.Any()).
+			Return(nil, bankxgo.ErrAccountFrozen{AcctID: payee})
+		store.EXPECT().
+			RecordScheduledPaymentRun(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, run bankxgo.ScheduledPaymentRun, next bankxgo.ScheduledPayment) error {
+				as.Equal(bankxgo.ScheduledRunFailed, run.Status)
+				as.Equal(bankxgo.CodeAccountFrozen, run.ErrorCode)
+				as.Zero(run.BatchID)
+				as.Equal(time.Date(2024, 11, 30, 9, 0, 0, 0, time.UTC), *next.NextRunAt)
+				return nil
+			})
+
+		_, err := bankxgo.NewScheduler(store, svc, cfg, &log).RunOnce(context.Background(), now)
+		as.Nil(err)
+	})
+
+	t.Run("skips the batches limits but not those of its accounts", func(tt *testing.T) {
+		as := assert.New(tt)
+		store := newStore(tt, payment(), payment())
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		svc.EXPECT().
+			PostBatch(gomock.Any(), gomock.Any()).
+			Return(&bankxgo.Batch{ID: 42, Status: bankxgo.BatchPosted}, nil).
+			Times(2)
+		store.EXPECT().RecordScheduledPaymentRun(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		one := bankxgo.KeyedLimitCfg{Rate: 1, Burst: 1}
+		limits, err := bankxgo.NewServiceLimits(&bankxgo.ServiceLimitsCfg{
+			Deposit:  bankxgo.EndpointLimitCfg{SloMs: 10, Rate: 1000, Burst: 1000, PerAccount: one},
+			Withdraw: bankxgo.EndpointLimitCfg{SloMs: 10, Rate: 1000, Burst: 1000, PerAccount: one},
+			Batches:  bankxgo.EndpointLimitCfg{SloMs: 10, Rate: 1, Burst: 1, PerClient: one},
+		})
+		require.Nil(tt, err)
+		limited := bankxgo.NewlimitMiddleware(limits)(svc)
+
+		// the second payment of the same accounts is over their budget
+		n, err := bankxgo.NewScheduler(store, limited, cfg, &log).RunOnce(context.Background(), now)
+		as.Nil(err)
+		as.Equal(1, n)
+
+		// the batches budget was left to API callers, who are limited like any
+		// other even if they name themselves the scheduler
+		req := bankxgo.BatchReq{Client: bankxgo.SchedulerClient}
+		_, err = limited.PostBatch(context.Background(), req)
+		as.Nil(err)
+		_, err = limited.PostBatch(context.Background(), req)
+		as.ErrorAs(err, &bankxgo.ErrRateLimited{})
+	})
+
+	t.Run("moves on to the next payment when rate limited", func(tt *testing.T) {
+		store := newStore(tt, payment(), payment())
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		gomock.InOrder(
+			svc.EXPECT().
+				PostBatch(gomock.Any(), gomock.Any()).
+				Return(nil, bankxgo.ErrRateLimited{RetryAfter: time.Second}),
+			svc.EXPECT().
+				PostBatch(gomock.Any(), gomock.Any()).
+				Return(&bankxgo.Batch{ID: 42, Status: bankxgo.BatchPosted}, nil),
+		)
+		store.EXPECT().RecordScheduledPaymentRun(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+		n, err := bankxgo.NewScheduler(store, svc, cfg, &log).RunOnce(context.Background(), now)
+		require.Nil(tt, err)
+		assert.Equal(tt, 1, n)
+	})
+
+	t.Run("ends the round on other errors", func(tt *testing.T) {
+		store := newStore(tt, payment(), payment())
+		svc := mocks.NewMockService(gomock.NewController(tt))
+		svc.EXPECT().
+			PostBatch(gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("connection reset"))
+
+		n, err := bankxgo.NewScheduler(store, svc, cfg, &log).RunOnce(context.Background(), now)
+		require.NotNil(tt, err)
+		assert.Zero(tt, n)
+	})
+}
+
+func TestCreateScheduledPayment(t *testing.T) {
+	log := zerolog.Nop()
+	usdSysAcct := snowflake.ParseInt64(7241301734201495552)
+	payer := snowflake.ParseInt64(7241407009730334720)
+	newService := func(tt *testing.T) (bankxgo.Service, *mocks.MockRepository) {
+		repo := mocks.NewMockRepository(gomock.NewController(tt))
+		repo.EXPECT().
+			GetAccount(gomock.Any(), usdSysAcct).
+			Return(&bankxgo.Account{AcctID: usdSysAcct, Currency: "USD"}, nil)
+		svc, err := bankxgo.NewService(
+			repo,
+			bankxgo.NewSystemAccounts(map[string]snowflake.ID{"USD": usdSysAcct}, nil),
+			nil,
+			nil,
+			&log,
+			bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 100}),
+		)
+		require.Nil(tt, err)
+		return svc, repo
+	}
+
+	t.Run("is due at the first occurrence from now", func(tt *testing.T) {
+		as := assert.New(tt)
+		svc, repo := newService(tt)
+		start := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+		repo.EXPECT().CreateScheduledPayment(gomock.Any(), gomock.Any()).Return(nil)
+
+		p, err := svc.CreateScheduledPayment(context.Background(), bankxgo.ScheduledPaymentReq{
+			AcctID: payer,
+			ScheduledPaymentSpec: bankxgo.ScheduledPaymentSpec{
+				Amount:  decimal.New(250, 0),
+				Rule:    bankxgo.ScheduleRule{Every: bankxgo.ScheduleEveryDay},
+				StartAt: start,
+			},
+		})
+		as.Nil(err)
+		as.Equal(snowflake.ID(100), p.ID)
+		as.Equal(bankxgo.ScheduledPaymentActive, p.Status)
+		as.True(p.NextRunAt.After(time.Now().Add(-time.Second)))
+		as.Equal(start.Hour(), p.NextRunAt.Hour())
+		as.Equal(p.NextRunAt, p.AttemptAt)
+	})
+
+	t.Run("rejects a payment ending before it is due", func(tt *testing.T) {
+		svc, _ := newService(tt)
+		end := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		_, err := svc.CreateScheduledPayment(context.Background(), bankxgo.ScheduledPaymentReq{
+			AcctID: payer,
+			ScheduledPaymentSpec: bankxgo.ScheduledPaymentSpec{
+				Amount:  decimal.New(250, 0),
+				Rule:    bankxgo.ScheduleRule{Every: bankxgo.ScheduleEveryDay},
+				StartAt: time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC),
+				EndAt:   &end,
+			},
+		})
+		assert.Equal(tt, bankxgo.ErrBadRequest{Fields: map[string]string{"endAt": "no payment due before it"}}, err)
+	})
+}
@@ -1,12 +1,17 @@
 package bankxgo
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/bwmarrin/snowflake"
-	"github.com/go-pdf/fpdf"
 	"github.com/rs/zerolog"
 	"github.com/shopspring/decimal"
 )
@@ -19,68 +24,314 @@ type Account struct {
 	Email    string          `json:"-"`
 	Currency string          `json:"-"`
 	Balance  decimal.Decimal `json:"-"`
+	Frozen   bool            `json:"-"`
+	// Type is one of the Account* constants
+	Type string `json:"-"`
+	// OverdraftLimit is how far below zero withdrawals may take the balance
+	OverdraftLimit decimal.Decimal `json:"-"`
+	// Pending is the sum of the deposits not settled yet, which are not part
+	// of the balance
+	Pending decimal.Decimal `json:"-"`
 }
 
+const (
+	// AccountDeposit is a deposit account, which earns interest on its balance
+	AccountDeposit = "deposit"
+	// AccountCredit is a credit line, its overdraft limit is its credit limit
+	// and it earns no interest
+	AccountCredit = "credit"
+)
+
 type CreateAccountReq struct {
 	Email    string `json:"email"`
 	Currency string `json:"currency"`
-	AcctID   snowflake.ID
+	// Type is one of the Account* constants, a deposit account if empty
+	Type   string `json:"type"`
+	AcctID snowflake.ID
+
+	// Client identifies the caller (API client or remote IP), set by the transport
+	Client string `json:"-"`
 }
 
 type ChargeReq struct {
 	Amount decimal.Decimal `json:"amount"`
-	AcctID snowflake.ID
-	Email  string
+	Memo
+	// Pending deposits are only added to the balance once settled, see Settle.
+	// Withdrawals cannot be pending.
+	Pending bool `json:"pending"`
+	AcctID  snowflake.ID
+	Email   string
 
 	// not passed from input but from middleware
 	Currency string
+
+	// Client identifies the caller (API client or remote IP), set by the transport
+	Client string `json:"-"`
+}
+
+// Receipt is the breakdown of a charge to an account
+type Receipt struct {
+	Amount  decimal.Decimal `json:"amount"`
+	Fee     decimal.Decimal `json:"fee"`
+	Balance decimal.Decimal `json:"balance"`
+}
+
+// Balances is the balance of an account as reported to its holder
+type Balances struct {
+	// Balance is the ledger balance, negative if the account is overdrawn
+	Balance        decimal.Decimal `json:"balance"`
+	OverdraftLimit decimal.Decimal `json:"overdraftLimit"`
+	// AvailableCredit is the part of the overdraft limit not drawn yet
+	AvailableCredit decimal.Decimal `json:"availableCredit"`
+	// Pending is the sum of the deposits not settled yet, they are not part of
+	// the balance and cannot be withdrawn
+	Pending decimal.Decimal `json:"pending"`
+	// Available is how much withdrawals, fees included, may take off the
+	// balance, ie. the balance if positive plus the available credit
+	Available decimal.Decimal `json:"available"`
+}
+
+// NewBalances reports the balance of acct
+func NewBalances(acct *Account) *Balances {
+	credit := acct.OverdraftLimit
+	if acct.Balance.IsNegative() {
+		credit = decimal.Max(credit.Add(acct.Balance), decimal.Zero)
+	}
+	return &Balances{
+		Balance:         acct.Balance,
+		OverdraftLimit:  acct.OverdraftLimit,
+		AvailableCredit: credit,
+		Pending:         acct.Pending,
+		Available:       decimal.Max(acct.Balance, decimal.Zero).Add(credit),
+	}
 }
 
 type BalanceReq struct {
 	AcctID snowflake.ID
 	Email  string
+	Client string
 }
 
 type StatementReq struct {
 	AcctID snowflake.ID
 	Email  string
+	Client string
+	// Format is one of the StatementFormat* constants, PDF if empty
+	Format string
+	// From and To are the first and last day of the statement period, if zero
+	// the period starts at the first charge and ends today respectively
+	From time.Time
+	To   time.Time
+}
+
+type VerifyStatementReq struct {
+	Code   string
+	Client string
 }
 
 type Service interface {
-	CreateAccount(CreateAccountReq) (*Account, error)
-	Deposit(ChargeReq) (*decimal.Decimal, error)
-	Withdraw(ChargeReq) (*decimal.Decimal, error)
-	Balance(BalanceReq) (*decimal.Decimal, error)
-	Statement(io.Writer, StatementReq) error
+	CreateAccount(context.Context, CreateAccountReq) (*Account, error)
+	Deposit(context.Context, ChargeReq) (*decimal.Decimal, error)
+	Withdraw(context.Context, ChargeReq) (*Receipt, error)
+	Balance(context.Context, BalanceReq) (*Balances, error)
+	Statement(context.Context, io.Writer, StatementReq) error
+	// RequestStatement enqueues a statement to be rendered by the statement
+	// workers, or returns the completed job of an identical earlier request
+	RequestStatement(context.Context, StatementReq) (*StatementJob, error)
+	// GetStatementJob returns the job and, if it is done, its rendered file
+	// which the caller must close
+	GetStatementJob(context.Context, StatementJobReq) (*StatementJob, io.ReadCloser, error)
+	// VerifyStatement returns the record of the statement issued with the code
+	VerifyStatement(context.Context, VerifyStatementReq) (*StatementVerification, error)
+	SetStatementPreference(context.Context, StatementPreferenceReq) (*StatementPreference, error)
+	// SetStatementPassword sets the password the customer's PDF statements are
+	// encrypted with, or removes it if empty
+	SetStatementPassword(context.Context, StatementPasswordReq) error
+	// ListStatementPeriods returns the closed statement cycles of the account, latest first
+	ListStatementPeriods(context.Context, StatementPeriodsReq) ([]StatementPeriod, error)
+	// GetStatementPeriod returns the closed period and the document rendered when
+	// it closed, which the caller must close
+	GetStatementPeriod(context.Context, StatementPeriodReq) (*StatementPeriod, io.ReadCloser, error)
+	// BalanceEvents streams the balance changes of the account until ctx is
+	// done, after replaying those since req.LastEventID. The channel is closed
+	// early if the stream falls behind, to be resumed from the last event.
+	BalanceEvents(context.Context, BalanceEventsReq) (<-chan BalanceEvent, error)
+	// ListTransactions returns the history of the account, latest first
+	ListTransactions(context.Context, TransactionsReq) ([]Transaction, error)
+	// Settle posts or fails a pending deposit and returns it
+	Settle(context.Context, SettleReq) (*Transaction, error)
+	// PostBatch posts the deposits and withdrawals of the batch, or returns the
+	// batch posted earlier with the same idempotency key
+	PostBatch(context.Context, BatchReq) (*Batch, error)
+	GetBatch(context.Context, BatchReqByID) (*Batch, error)
+	CreateScheduledPayment(context.Context, ScheduledPaymentReq) (*ScheduledPayment, error)
+	ListScheduledPayments(context.Context, ScheduledPaymentsReq) ([]ScheduledPayment, error)
+	GetScheduledPayment(context.Context, ScheduledPaymentReqByID) (*ScheduledPayment, error)
+	// UpdateScheduledPayment replaces the spec of the payment, which is due
+	// next at its first occurrence from now
+	UpdateScheduledPayment(context.Context, ScheduledPaymentReq) (*ScheduledPayment, error)
+	CancelScheduledPayment(context.Context, ScheduledPaymentReqByID) (*ScheduledPayment, error)
+	// ListScheduledPaymentRuns returns the run history of the payment, latest first
+	ListScheduledPaymentRuns(context.Context, ScheduledPaymentReqByID) ([]ScheduledPaymentRun, error)
+}
+
+// ServiceOption configures optional dependencies of the service
+type ServiceOption func(*serviceImpl)
+
+// WithBlobStore sets the store of asynchronously rendered statements,
+// without it statement jobs are unavailable
+func WithBlobStore(bs BlobStore) ServiceOption {
+	return func(s *serviceImpl) {
+		s.blobs = bs
+	}
+}
+
+// WithStatementTemplates sets the templates of PDF statements keyed by name,
+// see NewStatementTemplates. Without it every statement uses DefaultStatementTemplate.
+func WithStatementTemplates(templates map[string]*StatementTemplate) ServiceOption {
+	return func(s *serviceImpl) {
+		s.templates = templates
+	}
+}
+
+// WithStatementSigner signs and, if configured, encrypts PDF statements.
+// Without it statements cannot be verified.
+func WithStatementSigner(signer *StatementSigner) ServiceOption {
+	return func(s *serviceImpl) {
+		s.signer = signer
+	}
+}
+
+// WithIDGenerator sets the generator of account and statement job IDs, see
+// NewIDGenerator. It is required, tools use the nodes reserved for them.
+func WithIDGenerator(ids IDGenerator) ServiceOption {
+	return func(s *serviceImpl) {
+		s.ids = ids
+	}
+}
+
+// WithBalanceEvents sets the broker of live balance events, without it
+// balance event streams are unavailable
+func WithBalanceEvents(b *BalanceEventBroker) ServiceOption {
+	return func(s *serviceImpl) {
+		s.events = b
+	}
+}
+
+// SystemAccounts are the system accounts keyed by currency along with the
+// internal accounts, ie. fee revenue and interest expense, which are system
+// accounts not used for deposits and withdrawals. They are shared by the
+// service and the validation middleware and swapped atomically on reload.
+type SystemAccounts struct {
+	set atomic.Pointer[systemAccountSet]
+}
+
+type systemAccountSet struct {
+	byCurrency map[string]snowflake.ID
+	internal   []snowflake.ID
+}
+
+func NewSystemAccounts(byCurrency map[string]snowflake.ID, internal []snowflake.ID) *SystemAccounts {
+	sa := &SystemAccounts{}
+	sa.Store(byCurrency, internal)
+	return sa
+}
+
+// Store replaces the accounts, readers see either the old or the new set
+func (sa *SystemAccounts) Store(byCurrency map[string]snowflake.ID, internal []snowflake.ID) {
+	if byCurrency == nil {
+		byCurrency = map[string]snowflake.ID{}
+	}
+	sa.set.Store(&systemAccountSet{byCurrency: byCurrency, internal: internal})
+}
+
+// Get returns the system account of the currency
+func (sa *SystemAccounts) Get(currency string) (snowflake.ID, bool) {
+	id, ok := sa.set.Load().byCurrency[currency]
+	return id, ok
+}
+
+// ByCurrency returns the system accounts keyed by currency, which must not be modified
+func (sa *SystemAccounts) ByCurrency() map[string]snowflake.ID {
+	return sa.set.Load().byCurrency
+}
+
+// Contains tells whether the account is a system or internal account
+func (sa *SystemAccounts) Contains(acctID snowflake.ID) bool {
+	set := sa.set.Load()
+	for _, id := range set.byCurrency {
+		if id == acctID {
+			return true
+		}
+	}
+	for _, id := range set.internal {
+		if id == acctID {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifySystemAccounts checks that the accounts exist and are of their currency
+func VerifySystemAccounts(repo Repository, byCurrency map[string]snowflake.ID) error {
+	for c, id := range byCurrency {
+		a, err := repo.GetAccount(context.Background(), id)
+		if err != nil {
+			return err
+		}
+		if a.Currency != c {
+			return ErrNotFound{ID: id.Int64()}
+		}
+	}
+	return nil
 }
 
 func NewService(
 	repo Repository,
-	sysAccts map[string]snowflake.ID,
+	sysAccts *SystemAccounts,
+	wdLimits map[string]WithdrawalLimits,
+	fees map[string]FeePolicy,
 	log *zerolog.Logger,
+	opts ...ServiceOption,
 ) (Service, error) {
-	for c, id := range sysAccts {
-		a, err := repo.GetAccount(id)
+	if err := VerifySystemAccounts(repo, sysAccts.ByCurrency()); err != nil {
+		return nil, err
+	}
+	for c, fp := range fees {
+		a, err := repo.GetAccount(context.Background(), fp.Account)
 		if err != nil {
 			return nil, err
 		}
 		if a.Currency != c {
-			return nil, ErrNotFound{ID: id.Int64()}
+			return nil, ErrNotFound{ID: fp.Account.Int64()}
 		}
 	}
 
-	// hardcoded for "simplicity", but in a real world service this should be
-	// seeded with data from the node environment, ie., EC2 identifier
-	node, err := snowflake.NewNode(888)
-	if err != nil {
-		return nil, err
+	limits := make(map[string]WithdrawalLimits, len(wdLimits))
+	for c, l := range wdLimits {
+		limits[strings.ToUpper(c)] = l
 	}
 	svc := &serviceImpl{
 		repo:     repo,
 		sysAccts: sysAccts,
-		node:     node,
+		wdLimits: limits,
+		fees:     fees,
 		log:      log,
 	}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	// a default node could collide with one of the running instances
+	if svc.ids == nil {
+		return nil, errors.New("service: an ID generator is required, see WithIDGenerator")
+	}
+	if _, ok := svc.templates[DefaultStatementTemplateName]; !ok {
+		templates := map[string]*StatementTemplate{DefaultStatementTemplateName: DefaultStatementTemplate()}
+		for name, tmpl := range svc.templates {
+			templates[name] = tmpl
+		}
+		svc.templates = templates
+	}
 	return svc, nil
 }
 
@@ -90,14 +341,39 @@ var (
 
 type serviceImpl struct {
 	repo     Repository
-	sysAccts map[string]snowflake.ID
-	node     *snowflake.Node
-	log      *zerolog.Logger
+	sysAccts *SystemAccounts
+	wdLimits map[string]WithdrawalLimits
+	fees     map[string]FeePolicy
+	blobs    BlobStore
+	signer   *StatementSigner
+	// templates always has the default template, see NewService
+	templates map[string]*StatementTemplate
+	ids       IDGenerator
+	events    *BalanceEventBroker
+	log       *zerolog.Logger
+}
+
+func (s *serviceImpl) sysAcct(currency string) snowflake.ID {
+	id, _ := s.sysAccts.Get(currency)
+	return id
 }
 
-func (s *serviceImpl) CreateAccount(req CreateAccountReq) (*Account, error) {
-	req.AcctID = s.node.Generate()
-	err := s.repo.CreateAccount(req)
+// generateID mints an ID, failing the request while the node lease lapsed
+func (s *serviceImpl) generateID(ctx context.Context) (snowflake.ID, error) {
+	id, err := s.ids.Generate()
+	if err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("Generate failed")
+		return 0, ErrServiceUnavailable
+	}
+	return id, nil
+}
+
+func (s *serviceImpl) CreateAccount(ctx context.Context, req CreateAccountReq) (*Account, error) {
+	var err error
+	if req.AcctID, err = s.generateID(ctx); err != nil {
+		return nil, err
+	}
+	err = s.repo.CreateAccount(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -108,145 +384,619 @@ func (s *serviceImpl) CreateAccount(req CreateAccountReq) (*Account, error) {
 	return acct, err
 }
 
-func (s *serviceImpl) Deposit(req ChargeReq) (*decimal.Decimal, error) {
-	bal, err := s.repo.DebitUser(req.Amount, req.AcctID, s.sysAccts[req.Currency])
+func (s *serviceImpl) Deposit(ctx context.Context, req ChargeReq) (*decimal.Decimal, error) {
+	debit := s.repo.DebitUser
+	if req.Pending {
+		debit = s.repo.DebitUserPending
+	}
+	bal, err := debit(ctx, req.Amount, req.AcctID, s.sysAcct(req.Currency), req.Memo)
 	if err != nil {
-		s.log.Error().Err(err).Msg("Deposit failed")
+		ctxLog(ctx, s.log).Error().Err(err).Msg("Deposit failed")
 		return nil, err
 	}
 	return bal, err
 }
 
-func (s *serviceImpl) Withdraw(req ChargeReq) (*decimal.Decimal, error) {
-	bal, err := s.repo.CreditUser(req.Amount, req.AcctID, s.sysAccts[req.Currency])
+// withdrawalFee is the fee charged on top of a withdrawal of amount
+func (s *serviceImpl) withdrawalFee(currency string, amount decimal.Decimal) Fee {
+	var fee Fee
+	if fp, exists := s.fees[currency]; exists {
+		fee.Amount = fp.Withdraw.Compute(amount)
+		fee.Account = fp.Account
+	}
+	return fee
+}
+
+func (s *serviceImpl) Withdraw(ctx context.Context, req ChargeReq) (*Receipt, error) {
+	fee := s.withdrawalFee(req.Currency, req.Amount)
+	bal, err := s.repo.CreditUser(
+		ctx,
+		req.Amount,
+		req.AcctID,
+		s.sysAcct(req.Currency),
+		s.wdLimits[req.Currency],
+		fee,
+		req.Memo,
+	)
 	if err != nil {
-		s.log.Error().Err(err).Msg("Withdraw failed")
+		ctxLog(ctx, s.log).Error().Err(err).Msg("Withdraw failed")
 		return nil, err
 	}
-	return bal, err
+	rcpt := &Receipt{
+		Amount:  req.Amount,
+		Fee:     fee.Amount,
+		Balance: *bal,
+	}
+	return rcpt, err
 }
 
-func (s *serviceImpl) Balance(req BalanceReq) (*decimal.Decimal, error) {
-	acct, err := s.repo.GetAccount(req.AcctID)
+func (s *serviceImpl) Balance(ctx context.Context, req BalanceReq) (*Balances, error) {
+	acct, err := s.repo.GetAccount(ctx, req.AcctID)
 	if err != nil {
-		s.log.Error().Err(err).Msg("Balance failed")
+		ctxLog(ctx, s.log).Error().Err(err).Msg("Balance failed")
 		return nil, err
 	}
-	bal := acct.Balance
-	return &bal, err
+	return NewBalances(acct), err
 }
 
 type Charge struct {
-	Amount    decimal.Decimal
-	Typ       string
+	ID     int64
+	Amount decimal.Decimal
+	Typ    string
+	Fee    bool
+	// TxID and TxTyp are the ID and type of the transaction the charge belongs to
+	TxID  int64
+	TxTyp string
+	// Memo is the memo of the transaction
+	Memo      Memo
 	CreatedAt time.Time
+	// SettledAt is when the pending deposit the charge belongs to settled,
+	// zero for charges of transactions that were never pending
+	SettledAt time.Time
 }
 
-func (s *serviceImpl) Statement(w io.Writer, req StatementReq) error {
-	charges, err := s.repo.GetAccountCharges(req.AcctID)
-	if err != nil {
-		s.log.Error().Err(err).Msg("Statement failed")
-		return err
+// ValueDate is when the charge counts towards the balance, which for pending
+// deposits is when they settled rather than when they were booked
+func (c Charge) ValueDate() time.Time {
+	if !c.SettledAt.IsZero() {
+		return c.SettledAt
 	}
+	return c.CreatedAt
+}
 
-	pdf := fpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetFont("Arial", "B", 16)
-	pdf.CellFormat(190, 10, "Statement of Account", "", 1, "C", false, 0, "")
+// Kind classifies the charge for statements
+func (c Charge) Kind() string {
+	switch {
+	case c.Fee:
+		return LineKindFee
+	case c.TxTyp == "interest", c.TxTyp == "overdraft":
+		return LineKindInterest
+	case c.TxTyp == "adjustment":
+		return LineKindAdjustment
+	case c.Typ == "credit":
+		return LineKindWithdrawal
+	default:
+		return LineKindDeposit
+	}
+}
 
-	pdf.SetFont("Arial", "B", 12)
-	pdf.CellFormat(25, 8, "Account ID:", "", 0, "L", false, 0, "")
-	pdf.Cell(2, 8, "")
-	pdf.SetFillColor(211, 212, 208)
-	pdf.SetFont("Arial", "", 12)
-	pdf.CellFormat(50, 8, req.AcctID.String(), "", 1, "R", true, 0, "")
-	pdf.Ln(6)
+// Description is the label of the charge in statements
+func (c Charge) Description() string {
+	switch c.Kind() {
+	case LineKindFee:
+		return "Fee"
+	case LineKindInterest:
+		if c.TxTyp == "overdraft" {
+			return "Overdraft interest"
+		}
+		return "Interest"
+	case LineKindAdjustment:
+		return "Adjustment"
+	case LineKindWithdrawal:
+		return "Withdrawal"
+	default:
+		return "Deposit"
+	}
+}
 
-	tableHeader(pdf)
+func (s *serviceImpl) Statement(ctx context.Context, w io.Writer, req StatementReq) error {
+	rndr, ok := StatementRendererFor(req.Format)
+	if !ok {
+		return ErrBadRequest{Fields: map[string]string{"format": "unsupported"}}
+	}
+	acct, err := s.repo.GetAccount(ctx, req.AcctID)
+	if err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("Statement failed")
+		return err
+	}
+	charges, err := s.repo.GetAccountCharges(ctx, req.AcctID)
+	if err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("Statement failed")
+		return err
+	}
 
-	balance := decimal.New(0, 0)
-	creditStr, debitStr, dateStr := "", "", ""
-	var (
-		isFirstPage bool
-		lineCount   int
-	)
-	isFirstPage = true
-	cl := len(charges)
-	for ci, charge := range charges {
-		// skip last line and process separately for estetik ;)
-		if ci == cl-1 {
-			break
-		}
-		if lineCount == 30 && isFirstPage {
-			pdf.AddPage()
-			pdf.Ln(5)
-			tableHeader(pdf)
-			lineCount = 0
-			isFirstPage = false
-		}
-		if lineCount == 35 {
-			pdf.AddPage()
-			pdf.Ln(5)
-			tableHeader(pdf)
-			lineCount = 0
-		}
-		dateStr = charge.CreatedAt.Format("2006-01-02")
-		if charge.Typ == "credit" {
-			debitStr = ""
-			creditStr = charge.Amount.StringFixed(2)
-			balance = balance.Sub(charge.Amount)
-		} else {
-			creditStr = ""
-			debitStr = charge.Amount.StringFixed(2)
-			balance = balance.Add(charge.Amount)
-		}
-		pdf.Cell(20, 6, "")
-		pdf.CellFormat(30, 6, dateStr, "", 0, "C", false, 0, "")
-		pdf.CellFormat(40, 6, debitStr, "", 0, "C", false, 0, "")
-		pdf.CellFormat(40, 6, creditStr, "", 0, "C", false, 0, "")
-		pdf.CellFormat(40, 6, balance.StringFixed(2), "", 1, "C", false, 0, "")
-		pdf.Ln(1)
-
-		lineCount += 1
-	}
-
-	// process last line
-	charge := charges[cl-1]
-	dateStr = charge.CreatedAt.Format("2006-01-02")
-	if charge.Typ == "credit" {
-		debitStr = ""
-		creditStr = charge.Amount.StringFixed(2)
-		balance = balance.Sub(charge.Amount)
-	} else {
-		creditStr = ""
-		debitStr = charge.Amount.StringFixed(2)
-		balance = balance.Add(charge.Amount)
-	}
-	pdf.Cell(20, 6, "")
-	pdf.CellFormat(30, 6, dateStr, "", 0, "C", false, 0, "")
-	pdf.CellFormat(40, 6, debitStr, "", 0, "C", false, 0, "")
-	pdf.CellFormat(40, 6, creditStr, "", 0, "C", false, 0, "")
-	pdf.SetFillColor(140, 212, 130)
-	pdf.CellFormat(40, 6, balance.StringFixed(2), "", 1, "C", true, 0, "")
-
-	err = pdf.Output(w)
-	if err != nil {
-		s.log.
+	stmt := NewAccountStatement(*acct, charges, req.From, req.To)
+	if _, isPDF := rndr.(pdfRenderer); isPDF && s.signer != nil {
+		if v := s.signer.Sign(stmt); v != nil {
+			if err = s.repo.CreateStatementVerification(ctx, *v); err != nil {
+				ctxLog(ctx, s.log).Error().Err(err).Msg("Statement failed")
+				return err
+			}
+			stmt.Verification = v
+			stmt.QRCode = s.signer.VerifyURL(v.Code)
+		}
+		if stmt.Password, err = s.statementPassword(ctx, acct.AcctID); err != nil {
+			ctxLog(ctx, s.log).Error().Err(err).Msg("Statement failed")
+			return err
+		}
+	}
+	if _, isPDF := rndr.(pdfRenderer); isPDF {
+		if err = s.applyStatementPreference(ctx, stmt); err != nil {
+			ctxLog(ctx, s.log).Error().Err(err).Msg("Statement failed")
+			return err
+		}
+	}
+	if err = rndr.Render(w, stmt); err != nil {
+		ctxLog(ctx, s.log).
 			Error().
-			Err(fmt.Errorf("pdf.Output: %w", err)).
+			Err(err).
+			Str("format", req.Format).
 			Msg("Statement failed")
 	}
 
 	return err
 }
 
-func tableHeader(pdf *fpdf.Fpdf) {
-	pdf.SetFont("Arial", "B", 12)
-	pdf.Cell(20, 10, "")
-	pdf.CellFormat(30, 10, "Date", "", 0, "C", false, 0, "")
-	pdf.CellFormat(40, 10, "Debit", "", 0, "C", false, 0, "")
-	pdf.CellFormat(40, 10, "Credit", "", 0, "C", false, 0, "")
-	pdf.CellFormat(40, 10, "Balance", "", 1, "C", false, 0, "")
-	pdf.Ln(1)
-	pdf.SetFont("Arial", "", 10)
+func (s *serviceImpl) RequestStatement(ctx context.Context, req StatementReq) (*StatementJob, error) {
+	if s.blobs == nil {
+		return nil, ErrServiceUnavailable
+	}
+	if _, ok := StatementRendererFor(req.Format); !ok {
+		return nil, ErrBadRequest{Fields: map[string]string{"format": "unsupported"}}
+	}
+	format := strings.ToLower(req.Format)
+	if format == "" {
+		format = StatementFormatPDF
+	}
+	today := truncateDay(time.Now())
+	to := req.To
+	if to.IsZero() {
+		to = today
+	}
+
+	// only statements of closed periods can be cached as charges may still be
+	// added to a period that includes today
+	if to.Before(today) {
+		job, err := s.repo.FindDoneStatementJob(ctx, req.AcctID, format, req.From, to)
+		if err == nil {
+			return job, nil
+		}
+		if !errors.As(err, &ErrNotFound{}) {
+			ctxLog(ctx, s.log).Error().Err(err).Msg("RequestStatement failed")
+			return nil, err
+		}
+	}
+
+	jobID, err := s.generateID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	job := StatementJob{
+		ID:        jobID,
+		AcctID:    req.AcctID,
+		Format:    format,
+		From:      req.From,
+		To:        to,
+		Status:    StatementJobQueued,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.CreateStatementJob(ctx, job); err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("RequestStatement failed")
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *serviceImpl) GetStatementJob(ctx context.Context, req StatementJobReq) (*StatementJob, io.ReadCloser, error) {
+	if s.blobs == nil {
+		return nil, nil, ErrServiceUnavailable
+	}
+	job, err := s.repo.GetStatementJob(ctx, req.JobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != StatementJobDone {
+		return job, nil, nil
+	}
+	file, err := s.blobs.Get(job.FileKey)
+	if err != nil {
+		ctxLog(ctx, s.log).
+			Error().
+			Err(err).
+			Str("jobID", job.ID.String()).
+			Msg("GetStatementJob failed")
+		return nil, nil, err
+	}
+	return job, file, nil
+}
+
+func (s *serviceImpl) VerifyStatement(ctx context.Context, req VerifyStatementReq) (*StatementVerification, error) {
+	if s.signer == nil {
+		return nil, ErrServiceUnavailable
+	}
+	code, err := ParseVerificationCode(req.Code)
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.repo.GetStatementVerification(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if !s.signer.Verify(v) {
+		// either the record was altered or the signing key was rotated, both of
+		// which need attention but the statement cannot be vouched for regardless
+		ctxLog(ctx, s.log).
+			Error().
+			Str("code", code).
+			Msg("VerifyStatement signature mismatch")
+		return nil, ErrNotFound{}
+	}
+	return v, nil
+}
+
+// applyStatementPreference sets the template and locale of stmt to the
+// customer's preference. A preference for a template that was since removed
+// from the config falls back to the default template.
+func (s *serviceImpl) applyStatementPreference(ctx context.Context, stmt *AccountStatement) error {
+	stmt.Template = s.templates[DefaultStatementTemplateName]
+	pref, err := s.repo.GetStatementPreference(ctx, stmt.Account.AcctID)
+	if errors.As(err, &ErrNotFound{}) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if tmpl, ok := s.templates[pref.Template]; ok {
+		stmt.Template = tmpl
+	}
+	if loc, ok := StatementLocaleFor(pref.Locale); ok {
+		stmt.Locale = loc
+	}
+	return nil
+}
+
+func (s *serviceImpl) SetStatementPreference(ctx context.Context, req StatementPreferenceReq) (*StatementPreference, error) {
+	pref := req.StatementPreference
+	if pref.Template != "" {
+		if _, ok := s.templates[pref.Template]; !ok {
+			return nil, ErrBadRequest{Fields: map[string]string{"template": "unsupported"}}
+		}
+	}
+	if pref.Locale != "" {
+		loc, ok := StatementLocaleFor(pref.Locale)
+		if !ok {
+			return nil, ErrBadRequest{Fields: map[string]string{"locale": "unsupported"}}
+		}
+		pref.Locale = loc.Tag
+	}
+	if pref.CycleDay < 0 || pref.CycleDay > 31 {
+		return nil, ErrBadRequest{Fields: map[string]string{"cycleDay": "must be between 1 and 31"}}
+	}
+	if err := s.repo.SetStatementPreference(ctx, req.AcctID, pref); err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("SetStatementPreference failed")
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// statementPassword returns the password the customer set for their
+// statements, or an empty string if they have none or encryption is off
+func (s *serviceImpl) statementPassword(ctx context.Context, acctID snowflake.ID) (string, error) {
+	if !s.signer.Encrypts() {
+		return "", nil
+	}
+	sealed, err := s.repo.GetStatementPassword(ctx, acctID)
+	if errors.As(err, &ErrNotFound{}) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.signer.OpenPassword(acctID, sealed)
+}
+
+func (s *serviceImpl) SetStatementPassword(ctx context.Context, req StatementPasswordReq) error {
+	if s.signer == nil || !s.signer.Encrypts() {
+		return ErrServiceUnavailable
+	}
+	var sealed []byte
+	if req.Password != "" {
+		if n := utf8.RuneCountInString(req.Password); n < minStatementPasswordLen || n > maxStatementPasswordLen {
+			return ErrBadRequest{Fields: map[string]string{
+				"password": fmt.Sprintf("must be %d to %d characters", minStatementPasswordLen, maxStatementPasswordLen),
+			}}
+		}
+		var err error
+		if sealed, err = s.signer.SealPassword(req.AcctID, req.Password); err != nil {
+			ctxLog(ctx, s.log).Error().Err(err).Msg("SetStatementPassword failed")
+			return err
+		}
+	}
+	if err := s.repo.SetStatementPassword(ctx, req.AcctID, sealed); err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("SetStatementPassword failed")
+		return err
+	}
+	return nil
+}
+
+func (s *serviceImpl) ListStatementPeriods(ctx context.Context, req StatementPeriodsReq) ([]StatementPeriod, error) {
+	periods, err := s.repo.ListStatementPeriods(ctx, req.AcctID)
+	if err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("ListStatementPeriods failed")
+		return nil, err
+	}
+	return periods, nil
+}
+
+func (s *serviceImpl) GetStatementPeriod(ctx context.Context, req StatementPeriodReq) (*StatementPeriod, io.ReadCloser, error) {
+	if s.blobs == nil {
+		return nil, nil, ErrServiceUnavailable
+	}
+	period, err := s.repo.GetStatementPeriod(ctx, req.AcctID, truncateDay(req.To))
+	if err != nil {
+		return nil, nil, err
+	}
+	// the document is never re-rendered, corrections posted after the period
+	// closed must not change what the customer was issued
+	file, err := s.blobs.Get(period.FileKey)
+	if err != nil {
+		ctxLog(ctx, s.log).
+			Error().
+			Err(err).
+			Str("acctID", req.AcctID.String()).
+			Time("to", period.To).
+			Msg("GetStatementPeriod failed")
+		return nil, nil, err
+	}
+	return period, file, nil
+}
+
+func (s *serviceImpl) BalanceEvents(ctx context.Context, req BalanceEventsReq) (<-chan BalanceEvent, error) {
+	if s.events == nil {
+		return nil, ErrServiceUnavailable
+	}
+	// subscribing before the replay query means no event falls in between,
+	// those in both are skipped by their ID
+	live, unsubscribe := s.events.Subscribe(req.AcctID)
+	var missed []BalanceEvent
+	if req.LastEventID > 0 {
+		var err error
+		missed, err = s.repo.BalanceEventsAfter(ctx, req.AcctID, req.LastEventID)
+		if err != nil {
+			unsubscribe()
+			ctxLog(ctx, s.log).Error().Err(err).Msg("BalanceEvents failed")
+			return nil, err
+		}
+	}
+
+	out := make(chan BalanceEvent)
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+		last := req.LastEventID
+		send := func(ev BalanceEvent) bool {
+			if ev.ID <= last {
+				return true
+			}
+			select {
+			case out <- ev:
+				last = ev.ID
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for _, ev := range missed {
+			if !send(ev) {
+				return
+			}
+		}
+		for {
+			select {
+			case ev, ok := <-live:
+				if !ok || !send(ev) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (s *serviceImpl) ListTransactions(ctx context.Context, req TransactionsReq) ([]Transaction, error) {
+	filter := req.TransactionFilter
+	if filter.Limit <= 0 {
+		filter.Limit = defaultTransactionsLimit
+	}
+	txns, err := s.repo.ListTransactions(ctx, req.AcctID, filter)
+	if err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("ListTransactions failed")
+		return nil, err
+	}
+	return txns, nil
+}
+
+func (s *serviceImpl) Settle(ctx context.Context, req SettleReq) (*Transaction, error) {
+	txn, err := s.repo.SettleTransaction(ctx, req.AcctID, req.Reference, req.Status)
+	if err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("Settle failed")
+		return nil, err
+	}
+	return txn, nil
+}
+
+func (s *serviceImpl) PostBatch(ctx context.Context, req BatchReq) (*Batch, error) {
+	batchID, err := s.generateID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b := Batch{
+		ID:     batchID,
+		Key:    req.Key,
+		Mode:   req.Mode,
+		Status: BatchProcessing,
+		Hash:   batchHash(req),
+		Items:  make([]BatchItem, len(req.Items)),
+	}
+	for i, it := range req.Items {
+		b.Items[i] = BatchItem{
+			Type:   it.Type,
+			AcctID: it.AcctID,
+			Amount: it.Amount,
+			Status: BatchItemPending,
+		}
+	}
+	recorded, err := s.repo.CreateBatch(ctx, b)
+	if err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("PostBatch failed")
+		return nil, err
+	}
+	if recorded.Hash != b.Hash {
+		return nil, ErrConflict{Field: "Idempotency-Key"}
+	}
+	recorded.Replayed = recorded.ID != b.ID
+	if recorded.Status != BatchProcessing {
+		return recorded, nil
+	}
+
+	// the batch is new or was interrupted, either way its pending items are
+	// posted; the repository makes sure each is posted once
+	postings := make([]BatchPosting, 0, len(req.Items))
+	for i, it := range req.Items {
+		if recorded.Items[i].Status != BatchItemPending {
+			continue
+		}
+		p := BatchPosting{
+			Index:   i,
+			Type:    it.Type,
+			AcctID:  it.AcctID,
+			SysAcct: s.sysAcct(it.Currency),
+			Amount:  it.Amount,
+		}
+		if it.Type == BatchWithdrawal {
+			p.Limits = s.wdLimits[it.Currency]
+			p.Fee = s.withdrawalFee(it.Currency, it.Amount)
+		}
+		postings = append(postings, p)
+	}
+	posted, err := s.repo.PostBatch(ctx, recorded.ID, recorded.Mode, postings)
+	if err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("PostBatch failed")
+		return nil, err
+	}
+	posted.Replayed = recorded.Replayed
+	return posted, nil
+}
+
+func (s *serviceImpl) GetBatch(ctx context.Context, req BatchReqByID) (*Batch, error) {
+	b, err := s.repo.GetBatch(ctx, req.BatchID)
+	if err != nil {
+		return nil, err
+	}
+	// the key is the credential of the batch, which must not be told apart
+	// from a batch that does not exist
+	if subtle.ConstantTimeCompare([]byte(b.Key), []byte(req.Key)) != 1 {
+		return nil, ErrNotFound{ID: req.BatchID.Int64()}
+	}
+	return b, nil
+}
+
+func (s *serviceImpl) CreateScheduledPayment(ctx context.Context, req ScheduledPaymentReq) (*ScheduledPayment, error) {
+	id, err := s.generateID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	p := ScheduledPayment{
+		ID:                   id,
+		AcctID:               req.AcctID,
+		ScheduledPaymentSpec: req.ScheduledPaymentSpec,
+		CreatedAt:            now,
+		UpdatedAt:            now,
+	}
+	if p.StartAt.IsZero() {
+		p.StartAt = now
+	}
+	if err := p.reschedule(now); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateScheduledPayment(ctx, p); err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("CreateScheduledPayment failed")
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *serviceImpl) ListScheduledPayments(ctx context.Context, req ScheduledPaymentsReq) ([]ScheduledPayment, error) {
+	payments, err := s.repo.ListScheduledPayments(ctx, req.AcctID)
+	if err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("ListScheduledPayments failed")
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (s *serviceImpl) GetScheduledPayment(ctx context.Context, req ScheduledPaymentReqByID) (*ScheduledPayment, error) {
+	return s.repo.GetScheduledPayment(ctx, req.PaymentID)
+}
+
+func (s *serviceImpl) UpdateScheduledPayment(ctx context.Context, req ScheduledPaymentReq) (*ScheduledPayment, error) {
+	p, err := s.repo.GetScheduledPayment(ctx, req.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	start := p.StartAt
+	p.ScheduledPaymentSpec = req.ScheduledPaymentSpec
+	if p.StartAt.IsZero() {
+		p.StartAt = start
+	}
+	p.UpdatedAt = now
+	if err = p.reschedule(now); err != nil {
+		return nil, err
+	}
+	if err = s.repo.UpdateScheduledPayment(ctx, *p); err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("UpdateScheduledPayment failed")
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *serviceImpl) CancelScheduledPayment(ctx context.Context, req ScheduledPaymentReqByID) (*ScheduledPayment, error) {
+	p, err := s.repo.GetScheduledPayment(ctx, req.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == ScheduledPaymentCancelled {
+		return p, nil
+	}
+	p.Status = ScheduledPaymentCancelled
+	p.NextRunAt, p.AttemptAt, p.Attempt = nil, nil, 0
+	p.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	if err = s.repo.UpdateScheduledPayment(ctx, *p); err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("CancelScheduledPayment failed")
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *serviceImpl) ListScheduledPaymentRuns(ctx context.Context, req ScheduledPaymentReqByID) ([]ScheduledPaymentRun, error) {
+	runs, err := s.repo.ListScheduledPaymentRuns(ctx, req.PaymentID)
+	if err != nil {
+		ctxLog(ctx, s.log).Error().Err(err).Msg("ListScheduledPaymentRuns failed")
+		return nil, err
+	}
+	return runs, nil
 }
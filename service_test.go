@@ -1,6 +1,8 @@
 package bankxgo_test
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/arhyth/bankxgo"
@@ -23,11 +25,60 @@ func TestNewService(t *testing.T) {
 		}
 		log := zerolog.Nop()
 		repo.EXPECT().
-			GetAccount(sysAccts["USD"]).
+			GetAccount(gomock.Any(), sysAccts["USD"]).
 			Return(nil, bankxgo.ErrNotFound{})
-		_, err := bankxgo.NewService(repo, sysAccts, &log)
+		_, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(sysAccts, nil), nil, nil, &log, bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}))
 		as.NotNil(err)
 	})
+
+	t.Run("returns an error without an ID generator", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		log := zerolog.Nop()
+		_, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(nil, nil), nil, nil, &log)
+		assert.NotNil(tt, err)
+	})
+}
+
+func TestCreateAccount(t *testing.T) {
+	t.Run("generates the account ID with the configured generator", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		log := zerolog.Nop()
+		ids := &bankxgo.SequenceIDGenerator{Next: 100}
+		svc, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(nil, nil), nil, nil, &log, bankxgo.WithIDGenerator(ids))
+		reqrd.Nil(err)
+
+		for _, want := range []snowflake.ID{100, 101} {
+			acr := bankxgo.CreateAccountReq{Email: "user@ids.com", Currency: "USD"}
+			created := acr
+			created.AcctID = want
+			repo.EXPECT().CreateAccount(gomock.Any(), created).Return(nil)
+			acct, err := svc.CreateAccount(context.Background(), acr)
+			reqrd.Nil(err)
+			as.Equal(want, acct.AcctID)
+		}
+	})
+
+	t.Run("is unavailable while the generator fails", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		log := zerolog.Nop()
+		svc, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(nil, nil), nil, nil, &log, bankxgo.WithIDGenerator(lapsedIDGenerator{}))
+		require.Nil(tt, err)
+
+		_, err = svc.CreateAccount(context.Background(), bankxgo.CreateAccountReq{Email: "user@ids.com", Currency: "USD"})
+		assert.ErrorIs(tt, err, bankxgo.ErrServiceUnavailable)
+	})
+}
+
+// lapsedIDGenerator fails like the generator of a node whose lease lapsed
+type lapsedIDGenerator struct{}
+
+func (lapsedIDGenerator) Generate() (snowflake.ID, error) {
+	return 0, errors.New("lease lapsed")
 }
 
 func TestBalance(t *testing.T) {
@@ -44,13 +95,13 @@ func TestBalance(t *testing.T) {
 			Currency: "USD",
 		}
 		repo.EXPECT().
-			GetAccount(sysAccts["USD"]).
+			GetAccount(gomock.Any(), sysAccts["USD"]).
 			Return(usdAcct, nil)
 		userDeposit := decimal.New(1234, 0)
 		userAcctID := snowflake.ParseInt64(7241407009730334720)
 		userAcctCurr := "USD"
 		log := zerolog.Nop()
-		svc, err := bankxgo.NewService(repo, sysAccts, &log)
+		svc, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(sysAccts, nil), nil, nil, &log, bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}))
 		reqrd.Nil(err)
 
 		userEmail := "newuser@balance.com"
@@ -60,23 +111,110 @@ func TestBalance(t *testing.T) {
 			Currency: userAcctCurr,
 		}
 		repo.EXPECT().
-			CreateAccount(gomock.AssignableToTypeOf(bankxgo.CreateAccountReq{})).
+			CreateAccount(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.CreateAccountReq{})).
 			Return(nil)
-		_, err = svc.CreateAccount(acr)
+		_, err = svc.CreateAccount(context.Background(), acr)
 		reqrd.Nil(err)
 		dep := bankxgo.ChargeReq{
-			Amount:   userDeposit,
+			Amount: userDeposit,
+			Memo: bankxgo.Memo{
+				Description: "September salary",
+				Reference:   "PAY-2024-09",
+				Metadata:    map[string]any{"employer": "ACME"},
+			},
 			AcctID:   userAcctID,
 			Email:    userEmail,
 			Currency: userAcctCurr,
 		}
 		repo.EXPECT().
-			DebitUser(userDeposit, userAcctID, sysAccts["USD"]).
+			DebitUser(gomock.Any(), userDeposit, userAcctID, sysAccts["USD"], dep.Memo).
 			Return(&userDeposit, nil)
-		bal, err := svc.Deposit(dep)
+		bal, err := svc.Deposit(context.Background(), dep)
 		reqrd.Nil(err)
 		as.Equal(userDeposit, *bal)
 	})
+
+	t.Run("records pending deposits apart from the balance", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		sysAcct := snowflake.ParseInt64(7241301734201495552)
+		repo.EXPECT().
+			GetAccount(gomock.Any(), sysAcct).
+			Return(&bankxgo.Account{AcctID: sysAcct, Currency: "USD"}, nil)
+		log := zerolog.Nop()
+		sysAccts := bankxgo.NewSystemAccounts(map[string]snowflake.ID{"USD": sysAcct}, nil)
+		svc, err := bankxgo.NewService(repo, sysAccts, nil, nil, &log, bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}))
+		reqrd.Nil(err)
+
+		userAcctID := snowflake.ParseInt64(7241407009730334720)
+		dep := bankxgo.ChargeReq{
+			Amount:   decimal.New(500, 0),
+			Memo:     bankxgo.Memo{Reference: "WIRE-0001"},
+			Pending:  true,
+			AcctID:   userAcctID,
+			Currency: "USD",
+		}
+		bal := decimal.New(20, 0)
+		repo.EXPECT().
+			DebitUserPending(gomock.Any(), dep.Amount, userAcctID, sysAcct, dep.Memo).
+			Return(&bal, nil)
+		got, err := svc.Deposit(context.Background(), dep)
+		reqrd.Nil(err)
+		as.Equal(bal, *got)
+
+		settled := &bankxgo.Transaction{ID: 42, Type: "deposit", Status: bankxgo.TxnPosted, Amount: dep.Amount}
+		repo.EXPECT().
+			SettleTransaction(gomock.Any(), userAcctID, "WIRE-0001", bankxgo.TxnPosted).
+			Return(settled, nil)
+		txn, err := svc.Settle(context.Background(), bankxgo.SettleReq{
+			AcctID:    userAcctID,
+			Reference: "WIRE-0001",
+			Status:    bankxgo.TxnPosted,
+		})
+		reqrd.Nil(err)
+		as.Equal(settled, txn)
+	})
+
+	t.Run("reports the credit left of the overdraft limit", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		log := zerolog.Nop()
+		svc, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(nil, nil), nil, nil, &log, bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}))
+		reqrd.Nil(err)
+
+		userAcctID := snowflake.ParseInt64(7241407009730334720)
+		cases := []struct {
+			balance, limit, credit, available int64
+		}{
+			{250, 100, 100, 350},
+			{-30, 100, 70, 70},
+			// overdraft charges may exceed the limit
+			{-105, 100, 0, 0},
+			{-10, 0, 0, 0},
+		}
+		for _, c := range cases {
+			repo.EXPECT().
+				GetAccount(gomock.Any(), userAcctID).
+				Return(&bankxgo.Account{
+					AcctID:         userAcctID,
+					Balance:        decimal.NewFromInt(c.balance),
+					OverdraftLimit: decimal.NewFromInt(c.limit),
+					Pending:        decimal.NewFromInt(40),
+				}, nil)
+			bals, err := svc.Balance(context.Background(), bankxgo.BalanceReq{AcctID: userAcctID})
+			reqrd.Nil(err)
+			as.Equal(decimal.NewFromInt(c.balance), bals.Balance)
+			as.Equal(decimal.NewFromInt(c.limit), bals.OverdraftLimit)
+			as.True(decimal.NewFromInt(c.credit).Equal(bals.AvailableCredit), "%+v: %s", c, bals.AvailableCredit)
+			// pending deposits cannot be withdrawn
+			as.True(decimal.NewFromInt(c.available).Equal(bals.Available), "%+v: %s", c, bals.Available)
+			as.Equal(decimal.NewFromInt(40), bals.Pending)
+		}
+	})
 }
 
 func TestWithdraw(t *testing.T) {
@@ -93,13 +231,13 @@ func TestWithdraw(t *testing.T) {
 			Currency: "USD",
 		}
 		repo.EXPECT().
-			GetAccount(sysAccts["USD"]).
+			GetAccount(gomock.Any(), sysAccts["USD"]).
 			Return(usdAcct, nil)
 		userDeposit := decimal.New(1234, 0)
 		userAcctID := snowflake.ParseInt64(7241407009730334720)
 		userAcctCurr := "USD"
 		log := zerolog.Nop()
-		svc, err := bankxgo.NewService(repo, sysAccts, &log)
+		svc, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(sysAccts, nil), nil, nil, &log, bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}))
 		reqrd.Nil(err)
 
 		userEmail := "newuser@balance.com"
@@ -109,9 +247,9 @@ func TestWithdraw(t *testing.T) {
 			Currency: userAcctCurr,
 		}
 		repo.EXPECT().
-			CreateAccount(gomock.AssignableToTypeOf(bankxgo.CreateAccountReq{})).
+			CreateAccount(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.CreateAccountReq{})).
 			Return(nil)
-		_, err = svc.CreateAccount(acr)
+		_, err = svc.CreateAccount(context.Background(), acr)
 		reqrd.Nil(err)
 		dep := bankxgo.ChargeReq{
 			Amount:   userDeposit,
@@ -120,9 +258,9 @@ func TestWithdraw(t *testing.T) {
 			Currency: userAcctCurr,
 		}
 		repo.EXPECT().
-			DebitUser(userDeposit, userAcctID, sysAccts["USD"]).
+			DebitUser(gomock.Any(), userDeposit, userAcctID, sysAccts["USD"], bankxgo.Memo{}).
 			Return(&userDeposit, nil)
-		bal, err := svc.Deposit(dep)
+		bal, err := svc.Deposit(context.Background(), dep)
 		reqrd.Nil(err)
 		reqrd.Equal(userDeposit, *bal)
 
@@ -133,10 +271,87 @@ func TestWithdraw(t *testing.T) {
 			Currency: userAcctCurr,
 		}
 		repo.EXPECT().
-			CreditUser(withdraw.Amount, userAcctID, sysAccts["USD"]).
+			CreditUser(gomock.Any(), withdraw.Amount, userAcctID, sysAccts["USD"], bankxgo.WithdrawalLimits{}, bankxgo.Fee{}, bankxgo.Memo{}).
 			Return(&withdraw.Amount, nil)
-		bal, err = svc.Withdraw(withdraw)
+		rcpt, err := svc.Withdraw(context.Background(), withdraw)
+		reqrd.Nil(err)
+		as.Equal(withdraw.Amount, rcpt.Balance)
+	})
+
+	t.Run("passes the currency withdrawal limits to the repository", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		sysAccts := map[string]snowflake.ID{
+			"USD": snowflake.ParseInt64(7241301734201495552),
+		}
+		repo.EXPECT().
+			GetAccount(gomock.Any(), sysAccts["USD"]).
+			Return(&bankxgo.Account{AcctID: sysAccts["USD"], Currency: "USD"}, nil)
+		wdLimits := map[string]bankxgo.WithdrawalLimits{
+			"usd": {MaxPerTxn: decimal.New(1000, 0), MaxDailyCount: 3},
+		}
+		log := zerolog.Nop()
+		svc, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(sysAccts, nil), wdLimits, nil, &log, bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}))
+		reqrd.Nil(err)
+
+		withdraw := bankxgo.ChargeReq{
+			Amount:   decimal.New(100, 0),
+			AcctID:   snowflake.ParseInt64(7241407009730334720),
+			Currency: "USD",
+		}
+		newbal := decimal.New(900, 0)
+		repo.EXPECT().
+			CreditUser(gomock.Any(), withdraw.Amount, withdraw.AcctID, sysAccts["USD"], wdLimits["usd"], bankxgo.Fee{}, bankxgo.Memo{}).
+			Return(&newbal, nil)
+		rcpt, err := svc.Withdraw(context.Background(), withdraw)
+		reqrd.Nil(err)
+		as.Equal(newbal, rcpt.Balance)
+	})
+
+	t.Run("charges the currency withdrawal fee to the fee account", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockRepository(ctrl)
+		sysAccts := map[string]snowflake.ID{
+			"USD": snowflake.ParseInt64(7241301734201495552),
+		}
+		fees := map[string]bankxgo.FeePolicy{
+			"USD": {
+				Account:  snowflake.ParseInt64(7241301734201495999),
+				Withdraw: bankxgo.FeeSchedule{Flat: decimal.New(2, 0)},
+			},
+		}
+		repo.EXPECT().
+			GetAccount(gomock.Any(), sysAccts["USD"]).
+			Return(&bankxgo.Account{AcctID: sysAccts["USD"], Currency: "USD"}, nil)
+		repo.EXPECT().
+			GetAccount(gomock.Any(), fees["USD"].Account).
+			Return(&bankxgo.Account{AcctID: fees["USD"].Account, Currency: "USD"}, nil)
+		log := zerolog.Nop()
+		svc, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(sysAccts, nil), nil, fees, &log, bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}))
+		reqrd.Nil(err)
+
+		withdraw := bankxgo.ChargeReq{
+			Amount:   decimal.New(100, 0),
+			AcctID:   snowflake.ParseInt64(7241407009730334720),
+			Currency: "USD",
+		}
+		fee := bankxgo.Fee{Amount: decimal.New(2, 0), Account: fees["USD"].Account}
+		newbal := decimal.New(898, 0)
+		repo.EXPECT().
+			CreditUser(gomock.Any(), withdraw.Amount, withdraw.AcctID, sysAccts["USD"], bankxgo.WithdrawalLimits{}, gomock.Any(), bankxgo.Memo{}).
+			DoAndReturn(func(_ context.Context, _ decimal.Decimal, _, _ snowflake.ID, _ bankxgo.WithdrawalLimits, f bankxgo.Fee, _ bankxgo.Memo) (*decimal.Decimal, error) {
+				as.True(fee.Amount.Equal(f.Amount))
+				as.Equal(fee.Account, f.Account)
+				return &newbal, nil
+			})
+		rcpt, err := svc.Withdraw(context.Background(), withdraw)
 		reqrd.Nil(err)
-		as.Equal(withdraw.Amount, *bal)
+		as.Equal(withdraw.Amount, rcpt.Amount)
+		as.True(fee.Amount.Equal(rcpt.Fee))
+		as.Equal(newbal, rcpt.Balance)
 	})
 }
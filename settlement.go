@@ -0,0 +1,67 @@
+package bankxgo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// SettlementSignatureHeader carries the signature of a settlement callback,
+// see SignSettlement
+const SettlementSignatureHeader = "X-Bankxgo-Signature"
+
+// SettleReq posts or fails a pending deposit. Settlements are callbacks of the
+// payment processors, which know deposits by their own reference rather than
+// by transaction ID, so a reference is never reused for another pending
+// deposit of the account.
+type SettleReq struct {
+	AcctID    snowflake.ID `json:"acctID"`
+	Reference string       `json:"reference"`
+	// Status is TxnPosted or TxnFailed
+	Status string `json:"status"`
+
+	// Client identifies the caller (API client or remote IP), set by the transport
+	Client string `json:"-"`
+}
+
+// SettlementTolerance is how far the time a settlement callback was signed at
+// may be from the time it is received. Older callbacks are rejected, so a
+// captured callback cannot be replayed later on.
+const SettlementTolerance = 5 * time.Minute
+
+// SignSettlement returns the signature of a settlement callback body signed
+// at the given time, the unix time and a hex encoded HMAC-SHA256 of the unix
+// time, a dot and the body, ie. "t=1725148800,sha256=4f2a..."
+func SignSettlement(key []byte, at time.Time, body []byte) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + ts + "," + settlementMAC(key, ts, body)
+}
+
+func settlementMAC(key []byte, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySettlement returns ErrForbidden unless sig is the signature of body
+// and was made within SettlementTolerance of now
+func verifySettlement(key, body []byte, sig string, now time.Time) error {
+	ts, mac, ok := strings.Cut(strings.TrimPrefix(sig, "t="), ",")
+	if !ok || !hmac.Equal([]byte(settlementMAC(key, ts, body)), []byte(mac)) {
+		return ErrForbidden{Reason: "invalid signature"}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrForbidden{Reason: "invalid signature"}
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > SettlementTolerance || d < -SettlementTolerance {
+		return ErrForbidden{Reason: "stale signature"}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package bankxgo
+
+import (
+	"io"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// AccountStatement is the format agnostic model of a statement of account
+// fed to the statement renderers
+type AccountStatement struct {
+	Account Account
+	// From and To are the first and last day (UTC) of the statement period
+	From        time.Time
+	To          time.Time
+	Opening     decimal.Decimal
+	Closing     decimal.Decimal
+	Lines       []StatementLine
+	GeneratedAt time.Time
+
+	// Verification is set if statements are signed, see StatementSigner
+	Verification *StatementVerification
+	// Password encrypts the statement if the format supports it, ie. PDF
+	Password string
+	// QRCode is the content of the verification QR code, none if empty
+	QRCode string
+
+	// Template and Locale lay out human readable formats, ie. PDF. If nil,
+	// DefaultStatementTemplate and its locale are used.
+	Template *StatementTemplate
+	Locale   *StatementLocale
+}
+
+// StatementLine is a single charge to the account. Amount is signed, ie.
+// positive amounts add to the balance and negative amounts deduct from it.
+type StatementLine struct {
+	ID          int64
+	Date        time.Time
+	Kind        string
+	Description string
+	// Memo is the description the charge was posted with, if any
+	Memo    string
+	Amount  decimal.Decimal
+	Balance decimal.Decimal
+}
+
+const (
+	LineKindDeposit    = "deposit"
+	LineKindWithdrawal = "withdrawal"
+	LineKindFee        = "fee"
+	LineKindInterest   = "interest"
+	LineKindAdjustment = "adjustment"
+)
+
+// NewAccountStatement builds the statement of acct for the period [from, to] from the
+// full charge history of the account, in ascending order of value date. A zero from starts the
+// period at the first charge and a zero to ends it today.
+func NewAccountStatement(acct Account, charges []Charge, from, to time.Time) *AccountStatement {
+	now := time.Now().UTC()
+	if to.IsZero() {
+		to = now
+	}
+	to = truncateDay(to)
+	if from.IsZero() {
+		from = to
+		if len(charges) > 0 {
+			from = charges[0].ValueDate()
+		}
+	}
+	from = truncateDay(from)
+	end := to.AddDate(0, 0, 1)
+
+	stmt := &AccountStatement{
+		Account:     acct,
+		From:        from,
+		To:          to,
+		GeneratedAt: now,
+	}
+	balance := decimal.Zero
+	for _, c := range charges {
+		if !c.ValueDate().Before(end) {
+			break
+		}
+		amt := c.Amount
+		if c.Typ == "credit" {
+			amt = amt.Neg()
+		}
+		balance = balance.Add(amt)
+		if c.ValueDate().Before(from) {
+			stmt.Opening = balance
+			continue
+		}
+		stmt.Lines = append(stmt.Lines, StatementLine{
+			ID:          c.ID,
+			Date:        c.ValueDate(),
+			Kind:        c.Kind(),
+			Description: c.Description(),
+			Memo:        c.Memo.Description,
+			Amount:      amt,
+			Balance:     balance,
+		})
+	}
+	stmt.Closing = balance
+
+	return stmt
+}
+
+// StatementRenderer renders a statement in a specific file format
+type StatementRenderer interface {
+	ContentType() string
+	Render(w io.Writer, stmt *AccountStatement) error
+}
+
+const (
+	StatementFormatPDF     = "pdf"
+	StatementFormatCSV     = "csv"
+	StatementFormatOFX     = "ofx"
+	StatementFormatCamt053 = "camt053"
+)
+
+var statementRenderers = map[string]StatementRenderer{
+	StatementFormatPDF:     pdfRenderer{},
+	StatementFormatCSV:     csvRenderer{},
+	StatementFormatOFX:     ofxRenderer{},
+	StatementFormatCamt053: camt053Renderer{},
+}
+
+// StatementRendererFor returns the renderer of a statement format, an empty format is PDF
+func StatementRendererFor(format string) (StatementRenderer, bool) {
+	if format == "" {
+		format = StatementFormatPDF
+	}
+	r, ok := statementRenderers[strings.ToLower(format)]
+	return r, ok
+}
+
+// statementMediaTypes maps the media types accepted in the `Accept` header to formats
+var statementMediaTypes = map[string]string{
+	"application/pdf":   StatementFormatPDF,
+	"text/csv":          StatementFormatCSV,
+	"application/x-ofx": StatementFormatOFX,
+	"application/ofx":   StatementFormatOFX,
+	"application/xml":   StatementFormatCamt053,
+	"text/xml":          StatementFormatCamt053,
+}
+
+// StatementFormatFromAccept returns the format of the first supported media type
+// listed in an `Accept` header value, ignoring q-values, or "" if there is none
+func StatementFormatFromAccept(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if f, ok := statementMediaTypes[mt]; ok {
+			return f
+		}
+	}
+	return ""
+}
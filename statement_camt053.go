@@ -0,0 +1,146 @@
+package bankxgo
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// camt053Renderer renders an ISO 20022 camt.053.001.02 bank to customer statement
+type camt053Renderer struct{}
+
+func (camt053Renderer) ContentType() string {
+	return "application/xml"
+}
+
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+type camtDocument struct {
+	XMLName xml.Name `xml:"Document"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Stmt    struct {
+		GrpHdr struct {
+			MsgID   string `xml:"MsgId"`
+			CreDtTm string `xml:"CreDtTm"`
+		} `xml:"GrpHdr"`
+		Stmt camtStmt `xml:"Stmt"`
+	} `xml:"BkToCstmrStmt"`
+}
+
+type camtStmt struct {
+	ID      string `xml:"Id"`
+	CreDtTm string `xml:"CreDtTm"`
+	FrToDt  struct {
+		FrDtTm string `xml:"FrDtTm"`
+		ToDtTm string `xml:"ToDtTm"`
+	} `xml:"FrToDt"`
+	Acct struct {
+		ID struct {
+			Othr struct {
+				ID string `xml:"Id"`
+			} `xml:"Othr"`
+		} `xml:"Id"`
+		Ccy string `xml:"Ccy"`
+	} `xml:"Acct"`
+	Bals  []camtBal   `xml:"Bal"`
+	Ntrys []camtEntry `xml:"Ntry"`
+}
+
+type camtAmt struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+type camtBal struct {
+	Tp struct {
+		CdOrPrtry struct {
+			Cd string `xml:"Cd"`
+		} `xml:"CdOrPrtry"`
+	} `xml:"Tp"`
+	Amt       camtAmt `xml:"Amt"`
+	CdtDbtInd string  `xml:"CdtDbtInd"`
+	Dt        struct {
+		Dt string `xml:"Dt"`
+	} `xml:"Dt"`
+}
+
+type camtEntry struct {
+	NtryRef   string  `xml:"NtryRef"`
+	Amt       camtAmt `xml:"Amt"`
+	CdtDbtInd string  `xml:"CdtDbtInd"`
+	Sts       string  `xml:"Sts"`
+	BookgDt   struct {
+		DtTm string `xml:"DtTm"`
+	} `xml:"BookgDt"`
+	ValDt struct {
+		Dt string `xml:"Dt"`
+	} `xml:"ValDt"`
+	AcctSvcrRef string `xml:"AcctSvcrRef"`
+	BkTxCd      struct {
+		Prtry struct {
+			Cd string `xml:"Cd"`
+		} `xml:"Prtry"`
+	} `xml:"BkTxCd"`
+	AddtlNtryInf string `xml:"AddtlNtryInf"`
+}
+
+func camtCdtDbt(amt decimal.Decimal) string {
+	if amt.IsNegative() {
+		return "DBIT"
+	}
+	return "CRDT"
+}
+
+func camtBalance(code string, amt decimal.Decimal, ccy string, day time.Time) camtBal {
+	b := camtBal{
+		Amt:       camtAmt{Ccy: ccy, Value: amt.Abs().StringFixed(2)},
+		CdtDbtInd: camtCdtDbt(amt),
+	}
+	b.Tp.CdOrPrtry.Cd = code
+	b.Dt.Dt = day.Format(time.DateOnly)
+	return b
+}
+
+func (camt053Renderer) Render(w io.Writer, stmt *AccountStatement) error {
+	ccy := stmt.Account.Currency
+	stmtID := stmt.Account.AcctID.String() + "-" + stmt.To.Format("20060102")
+	doc := camtDocument{Xmlns: camt053Namespace}
+	doc.Stmt.GrpHdr.MsgID = stmtID
+	doc.Stmt.GrpHdr.CreDtTm = stmt.GeneratedAt.Format(time.RFC3339)
+
+	s := &doc.Stmt.Stmt
+	s.ID = stmtID
+	s.CreDtTm = stmt.GeneratedAt.Format(time.RFC3339)
+	s.FrToDt.FrDtTm = stmt.From.Format(time.RFC3339)
+	s.FrToDt.ToDtTm = stmt.To.AddDate(0, 0, 1).Add(-time.Second).Format(time.RFC3339)
+	s.Acct.ID.Othr.ID = stmt.Account.AcctID.String()
+	s.Acct.Ccy = ccy
+	s.Bals = []camtBal{
+		camtBalance("OPBD", stmt.Opening, ccy, stmt.From),
+		camtBalance("CLBD", stmt.Closing, ccy, stmt.To),
+	}
+	for _, l := range stmt.Lines {
+		e := camtEntry{
+			NtryRef:      strconv.FormatInt(l.ID, 10),
+			Amt:          camtAmt{Ccy: ccy, Value: l.Amount.Abs().StringFixed(2)},
+			CdtDbtInd:    camtCdtDbt(l.Amount),
+			Sts:          "BOOK",
+			AcctSvcrRef:  strconv.FormatInt(l.ID, 10),
+			AddtlNtryInf: l.Description,
+		}
+		e.BookgDt.DtTm = l.Date.UTC().Format(time.RFC3339)
+		e.ValDt.Dt = l.Date.UTC().Format(time.DateOnly)
+		e.BkTxCd.Prtry.Cd = l.Kind
+		s.Ntrys = append(s.Ntrys, e)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,38 @@
+package bankxgo
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+type csvRenderer struct{}
+
+func (csvRenderer) ContentType() string {
+	return "text/csv"
+}
+
+// Render writes one row per statement line, preceded by the opening balance
+// and followed by the closing balance, which have no id and amount
+func (csvRenderer) Render(w io.Writer, stmt *AccountStatement) error {
+	cw := csv.NewWriter(w)
+	cur := stmt.Account.Currency
+	rows := [][]string{
+		{"id", "date", "type", "description", "amount", "currency", "balance"},
+		{"", stmt.From.Format("2006-01-02"), "opening", "Opening balance", "", cur, stmt.Opening.StringFixed(2)},
+	}
+	for _, l := range stmt.Lines {
+		rows = append(rows, []string{
+			strconv.FormatInt(l.ID, 10),
+			l.Date.Format("2006-01-02"),
+			l.Kind,
+			l.Description,
+			l.Amount.StringFixed(2),
+			cur,
+			l.Balance.StringFixed(2),
+		})
+	}
+	rows = append(rows, []string{"", stmt.To.Format("2006-01-02"), "closing", "Closing balance", "", cur, stmt.Closing.StringFixed(2)})
+
+	return cw.WriteAll(rows)
+}
@@ -0,0 +1,216 @@
+package bankxgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+)
+
+// StatementPeriod is a closed statement cycle of an account. Its balances and
+// document are snapshots taken when the cycle closed and are never updated,
+// later corrections show up in later periods.
+type StatementPeriod struct {
+	AcctID   snowflake.ID    `json:"acctID"`
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"`
+	Opening  decimal.Decimal `json:"opening"`
+	Closing  decimal.Decimal `json:"closing"`
+	Format   string          `json:"format"`
+	FileKey  string          `json:"-"`
+	ClosedAt time.Time       `json:"closedAt"`
+}
+
+// StatementCycle is the statement cycle of an account
+type StatementCycle struct {
+	AcctID snowflake.ID
+	// Day is the day of the month the cycle closes on, clamped to the last
+	// day of shorter months
+	Day       int
+	CreatedAt time.Time
+	// LastClosed is the last day of the last closed period, zero if none
+	LastClosed time.Time
+}
+
+type StatementPeriodsReq struct {
+	AcctID snowflake.ID
+	Email  string
+	Client string
+}
+
+type StatementPeriodReq struct {
+	AcctID snowflake.ID
+	Email  string
+	Client string
+	// To is the last day of the period
+	To time.Time
+}
+
+// StatementCycleStore is the persistence needed by the statement cycle job
+type StatementCycleStore interface {
+	GetAccount(ctx context.Context, id snowflake.ID) (*Account, error)
+	GetAccountCharges(ctx context.Context, id snowflake.ID) ([]Charge, error)
+	// StatementCycles returns the cycle of every account except the excluded
+	// (system) accounts. Accounts without a preferred cycle day get defaultDay.
+	StatementCycles(ctx context.Context, defaultDay int, exclude []snowflake.ID) ([]StatementCycle, error)
+	// CreateStatementPeriod stores a closed period, a period that was already
+	// closed is kept as is
+	CreateStatementPeriod(ctx context.Context, p StatementPeriod) error
+}
+
+// StatementCycleJob closes the statement cycles of every account, snapshotting
+// the period's balances and pre-rendering its PDF. It is idempotent and catches
+// up on cycles missed by earlier runs, so it can simply be run daily.
+type StatementCycleJob struct {
+	store      StatementCycleStore
+	svc        Service
+	blobs      BlobStore
+	defaultDay int
+	exclude    []snowflake.ID
+	log        *zerolog.Logger
+}
+
+// NewStatementCycleJob expects svc to be the bare service, ie. not wrapped by
+// the validation and limit middlewares. Accounts in exclude, ie. system
+// accounts, get no statements.
+func NewStatementCycleJob(
+	store StatementCycleStore,
+	svc Service,
+	blobs BlobStore,
+	cfg StatementCyclesCfg,
+	exclude []snowflake.ID,
+	log *zerolog.Logger,
+) (*StatementCycleJob, error) {
+	if blobs == nil {
+		return nil, errors.New("statement_jobs.dir: statement cycles need a blob store")
+	}
+	day := cfg.DefaultDay
+	if day == 0 {
+		day = 1
+	}
+	if day < 1 || day > 31 {
+		return nil, fmt.Errorf("statement_cycles.default_day: must be between 1 and 31")
+	}
+	job := &StatementCycleJob{
+		store:      store,
+		svc:        svc,
+		blobs:      blobs,
+		defaultDay: day,
+		exclude:    exclude,
+		log:        log,
+	}
+	return job, nil
+}
+
+// Close closes every cycle that ended on or before the given (UTC) day
+func (j *StatementCycleJob) Close(day time.Time) error {
+	day = truncateDay(day)
+	if !day.Before(truncateDay(time.Now())) {
+		return ErrBadRequest{Fields: map[string]string{"date": "day has not ended yet"}}
+	}
+
+	ctx := context.Background()
+	cycles, err := j.store.StatementCycles(ctx, j.defaultDay, j.exclude)
+	if err != nil {
+		return fmt.Errorf("StatementCycles: %w", err)
+	}
+	var failed int
+	for _, c := range cycles {
+		from := truncateDay(c.CreatedAt)
+		if !c.LastClosed.IsZero() {
+			from = truncateDay(c.LastClosed).AddDate(0, 0, 1)
+		}
+		for to := NextCycleEnd(from, c.Day); !to.After(day); to = NextCycleEnd(from, c.Day) {
+			if err = j.closePeriod(ctx, c.AcctID, from, to); err != nil {
+				j.log.Err(err).
+					Str("acctID", c.AcctID.String()).
+					Time("to", to).
+					Msg("closing statement period failed")
+				failed++
+				// later periods must wait for this one
+				break
+			}
+			from = to.AddDate(0, 0, 1)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("closing statement periods failed for %d accounts", failed)
+	}
+	return nil
+}
+
+func (j *StatementCycleJob) closePeriod(ctx context.Context, acctID snowflake.ID, from, to time.Time) error {
+	acct, err := j.store.GetAccount(ctx, acctID)
+	if err != nil {
+		return err
+	}
+	charges, err := j.store.GetAccountCharges(ctx, acctID)
+	if err != nil {
+		return err
+	}
+	stmt := NewAccountStatement(*acct, charges, from, to)
+
+	buf := new(bytes.Buffer)
+	req := StatementReq{
+		AcctID: acctID,
+		Format: StatementFormatPDF,
+		From:   from,
+		To:     to,
+	}
+	if err = j.svc.Statement(ctx, buf, req); err != nil {
+		return err
+	}
+	// the key is unique per render so a concurrent run can never overwrite
+	// the document of a period closed by another
+	closedAt := time.Now().UTC()
+	key := fmt.Sprintf("statements/%s/periods/%s-%d.pdf", acctID, to.Format(time.DateOnly), closedAt.UnixNano())
+	if err = j.blobs.Put(key, buf); err != nil {
+		return err
+	}
+
+	period := StatementPeriod{
+		AcctID:   acctID,
+		From:     from,
+		To:       to,
+		Opening:  stmt.Opening,
+		Closing:  stmt.Closing,
+		Format:   StatementFormatPDF,
+		FileKey:  key,
+		ClosedAt: closedAt,
+	}
+	if err = j.store.CreateStatementPeriod(ctx, period); err != nil {
+		return err
+	}
+	j.log.Info().
+		Str("acctID", acctID.String()).
+		Time("from", from).
+		Time("to", to).
+		Str("closing", stmt.Closing.String()).
+		Msg("statement period closed")
+	return nil
+}
+
+// NextCycleEnd returns the first cycle end, ie. the cycle day of a month clamped
+// to the month's last day, on or after the given (UTC) day
+func NextCycleEnd(day time.Time, cycleDay int) time.Time {
+	day = truncateDay(day)
+	end := cycleEnd(day.Year(), day.Month(), cycleDay)
+	if end.Before(day) {
+		end = cycleEnd(day.Year(), day.Month()+1, cycleDay)
+	}
+	return end
+}
+
+func cycleEnd(year int, month time.Month, cycleDay int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	if cycleDay > last {
+		cycleDay = last
+	}
+	return time.Date(first.Year(), first.Month(), cycleDay, 0, 0, 0, 0, time.UTC)
+}
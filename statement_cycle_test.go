@@ -0,0 +1,209 @@
+package bankxgo_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+func TestNextCycleEnd(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		name     string
+		day      time.Time
+		cycleDay int
+		want     time.Time
+	}{
+		{"cycle day later this month", date(2024, 9, 3), 15, date(2024, 9, 15)},
+		{"cycle day is today", date(2024, 9, 15).Add(13 * time.Hour), 15, date(2024, 9, 15)},
+		{"cycle day passed rolls to next month", date(2024, 9, 16), 15, date(2024, 10, 15)},
+		{"clamped to the end of shorter months", date(2023, 2, 1), 31, date(2023, 2, 28)},
+		{"clamped to leap day", date(2024, 2, 1), 30, date(2024, 2, 29)},
+		{"rolls over the year", date(2024, 12, 2), 1, date(2025, 1, 1)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(tt *testing.T) {
+			assert.Equal(tt, c.want, bankxgo.NextCycleEnd(c.day, c.cycleDay))
+		})
+	}
+}
+
+func TestStatementCycleJob(t *testing.T) {
+	nooplog := zerolog.Nop()
+	acctID := snowflake.ID(7241722241547769001)
+	sysAcctID := snowflake.ID(7241722241547767808)
+	acct := &bankxgo.Account{AcctID: acctID, Currency: "USD"}
+	charges := []bankxgo.Charge{
+		{ID: 1, Amount: decimal.NewFromInt(100), Typ: "debit", TxTyp: "deposit", CreatedAt: time.Date(2024, 8, 10, 9, 0, 0, 0, time.UTC)},
+		{ID: 2, Amount: decimal.NewFromInt(30), Typ: "credit", TxTyp: "withdrawal", CreatedAt: time.Date(2024, 9, 10, 9, 0, 0, 0, time.UTC)},
+	}
+
+	newJob := func(tt *testing.T) (*bankxgo.StatementCycleJob, *mocks.MockStatementCycleStore, *mocks.MockService, bankxgo.BlobStore) {
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockStatementCycleStore(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		blobs := &bankxgo.LocalBlobStore{Dir: tt.TempDir()}
+		cfg := bankxgo.StatementCyclesCfg{DefaultDay: 1}
+		job, err := bankxgo.NewStatementCycleJob(store, svc, blobs, cfg, []snowflake.ID{sysAcctID}, &nooplog)
+		require.Nil(tt, err)
+		return job, store, svc, blobs
+	}
+
+	t.Run("catches up on every cycle ended since the last closed period", func(tt *testing.T) {
+		as := assert.New(tt)
+		job, store, svc, blobs := newJob(tt)
+		store.EXPECT().
+			StatementCycles(gomock.Any(), 1, []snowflake.ID{sysAcctID}).
+			Return([]bankxgo.StatementCycle{{
+				AcctID:     acctID,
+				Day:        15,
+				CreatedAt:  time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+				LastClosed: time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC),
+			}}, nil)
+		store.EXPECT().GetAccount(gomock.Any(), acctID).Return(acct, nil).Times(2)
+		store.EXPECT().GetAccountCharges(gomock.Any(), acctID).Return(charges, nil).Times(2)
+		svc.EXPECT().
+			Statement(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementReq{})).
+			DoAndReturn(func(_ context.Context, w io.Writer, r bankxgo.StatementReq) error {
+				as.Equal(bankxgo.StatementFormatPDF, r.Format)
+				_, err := w.Write([]byte("%PDF " + r.To.Format(time.DateOnly)))
+				return err
+			}).
+			Times(2)
+		var closed []bankxgo.StatementPeriod
+		store.EXPECT().
+			CreateStatementPeriod(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementPeriod{})).
+			DoAndReturn(func(_ context.Context, p bankxgo.StatementPeriod) error {
+				closed = append(closed, p)
+				return nil
+			}).
+			Times(2)
+
+		as.Nil(job.Close(time.Date(2024, 9, 20, 0, 0, 0, 0, time.UTC)))
+		as.Len(closed, 2)
+		as.Equal(time.Date(2024, 7, 16, 0, 0, 0, 0, time.UTC), closed[0].From)
+		as.Equal(time.Date(2024, 8, 15, 0, 0, 0, 0, time.UTC), closed[0].To)
+		as.True(decimal.NewFromInt(100).Equal(closed[0].Closing), closed[0].Closing.String())
+		as.Equal(time.Date(2024, 8, 16, 0, 0, 0, 0, time.UTC), closed[1].From)
+		as.Equal(time.Date(2024, 9, 15, 0, 0, 0, 0, time.UTC), closed[1].To)
+		as.True(decimal.NewFromInt(100).Equal(closed[1].Opening), closed[1].Opening.String())
+		as.True(decimal.NewFromInt(70).Equal(closed[1].Closing), closed[1].Closing.String())
+
+		file, err := blobs.Get(closed[1].FileKey)
+		require.Nil(tt, err)
+		defer file.Close()
+		doc, _ := io.ReadAll(file)
+		as.Equal("%PDF 2024-09-15", string(doc))
+	})
+
+	t.Run("first cycle starts on the day the account was created", func(tt *testing.T) {
+		as := assert.New(tt)
+		job, store, svc, _ := newJob(tt)
+		store.EXPECT().
+			StatementCycles(gomock.Any(), 1, gomock.Any()).
+			Return([]bankxgo.StatementCycle{{
+				AcctID:    acctID,
+				Day:       31,
+				CreatedAt: time.Date(2024, 2, 10, 15, 0, 0, 0, time.UTC),
+			}}, nil)
+		store.EXPECT().GetAccount(gomock.Any(), acctID).Return(acct, nil)
+		store.EXPECT().GetAccountCharges(gomock.Any(), acctID).Return(nil, nil)
+		svc.EXPECT().Statement(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		store.EXPECT().
+			CreateStatementPeriod(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementPeriod{})).
+			DoAndReturn(func(_ context.Context, p bankxgo.StatementPeriod) error {
+				as.Equal(time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC), p.From)
+				as.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), p.To)
+				return nil
+			})
+
+		as.Nil(job.Close(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("stops closing an account's cycles at the first failure", func(tt *testing.T) {
+		as := assert.New(tt)
+		job, store, svc, _ := newJob(tt)
+		store.EXPECT().
+			StatementCycles(gomock.Any(), 1, gomock.Any()).
+			Return([]bankxgo.StatementCycle{{
+				AcctID:     acctID,
+				Day:        1,
+				LastClosed: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			}}, nil)
+		store.EXPECT().GetAccount(gomock.Any(), acctID).Return(acct, nil)
+		store.EXPECT().GetAccountCharges(gomock.Any(), acctID).Return(charges, nil)
+		svc.EXPECT().Statement(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("render failed"))
+
+		as.NotNil(job.Close(time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("refuses to close a day that has not ended", func(tt *testing.T) {
+		job, _, _, _ := newJob(tt)
+		err := job.Close(time.Now())
+		assert.ErrorAs(tt, err, &bankxgo.ErrBadRequest{})
+	})
+
+	t.Run("requires a blob store", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		store := mocks.NewMockStatementCycleStore(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		_, err := bankxgo.NewStatementCycleJob(store, svc, nil, bankxgo.StatementCyclesCfg{}, nil, &nooplog)
+		assert.NotNil(tt, err)
+	})
+}
+
+func TestGetStatementPeriod(t *testing.T) {
+	nooplog := zerolog.Nop()
+	sysAcctID := snowflake.ID(7241722241547767808)
+	acctID := snowflake.ID(7241722241547769001)
+
+	t.Run("returns the document rendered at closing without re-rendering", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		repo.EXPECT().
+			GetAccount(gomock.Any(), sysAcctID).
+			Return(&bankxgo.Account{AcctID: sysAcctID, Currency: "USD"}, nil)
+		blobs := &bankxgo.LocalBlobStore{Dir: tt.TempDir()}
+		svc, err := bankxgo.NewService(
+			repo,
+			bankxgo.NewSystemAccounts(map[string]snowflake.ID{"USD": sysAcctID}, nil),
+			nil,
+			nil,
+			&nooplog,
+			bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}),
+			bankxgo.WithBlobStore(blobs),
+		)
+		require.Nil(tt, err)
+
+		to := time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)
+		key := "statements/7241722241547769001/periods/2024-09-30-1.pdf"
+		require.Nil(tt, blobs.Put(key, bytes.NewBufferString("%PDF issued")))
+		period := &bankxgo.StatementPeriod{AcctID: acctID, To: to, Format: "pdf", FileKey: key}
+		// charges are never read, so corrections posted since cannot alter the document
+		repo.EXPECT().GetStatementPeriod(gomock.Any(), acctID, to).Return(period, nil)
+
+		got, file, err := svc.GetStatementPeriod(context.Background(), bankxgo.StatementPeriodReq{AcctID: acctID, To: to})
+		require.Nil(tt, err)
+		defer file.Close()
+		doc, _ := io.ReadAll(file)
+		as.Equal(period, got)
+		as.Equal("%PDF issued", string(doc))
+	})
+
+}
@@ -0,0 +1,211 @@
+package bankxgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+)
+
+const (
+	StatementJobQueued  = "queued"
+	StatementJobRunning = "running"
+	StatementJobDone    = "done"
+	StatementJobFailed  = "failed"
+)
+
+// StatementJob is a statement rendered asynchronously by the statement workers
+type StatementJob struct {
+	ID     snowflake.ID `json:"jobID"`
+	AcctID snowflake.ID `json:"acctID"`
+	Format string       `json:"format"`
+	// From is zero if the statement starts at the first charge of the account
+	From       time.Time  `json:"from"`
+	To         time.Time  `json:"to"`
+	Status     string     `json:"status"`
+	Attempts   int        `json:"-"`
+	Error      string     `json:"error,omitempty"`
+	FileKey    string     `json:"-"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+type StatementJobReq struct {
+	JobID  snowflake.ID
+	Email  string
+	Client string
+}
+
+// BlobStore stores rendered statement files
+type BlobStore interface {
+	Put(key string, r io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+}
+
+// LocalBlobStore is a BlobStore on the local filesystem, it is fine for a
+// single instance but multiple instances need a shared (network) directory
+type LocalBlobStore struct {
+	Dir string
+}
+
+var _ BlobStore = (*LocalBlobStore)(nil)
+
+func (ls *LocalBlobStore) path(key string) (string, error) {
+	p := filepath.Join(ls.Dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(p, filepath.Clean(ls.Dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return p, nil
+}
+
+// Put writes to a temporary file first so readers never see a partial file
+func (ls *LocalBlobStore) Put(key string, r io.Reader) error {
+	p, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (ls *LocalBlobStore) Get(key string) (io.ReadCloser, error) {
+	p, err := ls.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// StatementWorker renders queued statement jobs. Jobs are claimed from the
+// `statement_jobs` table with `SKIP LOCKED` so any number of workers across
+// server instances can share the queue. A job left running longer than the
+// lease, ie. its worker crashed, is claimed again until it was attempted
+// max_attempts times, then it is failed.
+type StatementWorker struct {
+	repo        Repository
+	blobs       BlobStore
+	svc         Service
+	lease       time.Duration
+	pollEvery   time.Duration
+	maxAttempts int
+	log         *zerolog.Logger
+}
+
+// NewStatementWorker expects svc to be the bare service, ie. not wrapped by the
+// validation and limit middlewares, as jobs are validated when enqueued
+func NewStatementWorker(
+	repo Repository,
+	blobs BlobStore,
+	svc Service,
+	cfg StatementJobsCfg,
+	log *zerolog.Logger,
+) *StatementWorker {
+	sw := &StatementWorker{
+		repo:        repo,
+		blobs:       blobs,
+		svc:         svc,
+		lease:       time.Duration(cfg.LeaseSec) * time.Second,
+		pollEvery:   time.Duration(cfg.PollMs) * time.Millisecond,
+		maxAttempts: cfg.MaxAttempts,
+		log:         log,
+	}
+	if sw.lease <= 0 {
+		sw.lease = 5 * time.Minute
+	}
+	if sw.pollEvery <= 0 {
+		sw.pollEvery = time.Second
+	}
+	if sw.maxAttempts <= 0 {
+		sw.maxAttempts = 3
+	}
+	return sw
+}
+
+// Run starts n workers and blocks until ctx is done and they finished the
+// jobs they were rendering
+func (sw *StatementWorker) Run(ctx context.Context, n int) {
+	done := make(chan struct{})
+	for i := 0; i < n; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for ctx.Err() == nil {
+				worked, err := sw.RunOnce()
+				if err != nil {
+					sw.log.Err(err).Msg("statement worker failed")
+				}
+				if worked {
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(sw.pollEvery):
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-done
+	}
+}
+
+// RunOnce claims and renders a single job, it returns false if the queue is empty
+func (sw *StatementWorker) RunOnce() (bool, error) {
+	ctx := context.Background()
+	job, err := sw.repo.ClaimStatementJob(ctx, sw.lease, sw.maxAttempts)
+	if err != nil {
+		return false, fmt.Errorf("ClaimStatementJob: %w", err)
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	buf := new(bytes.Buffer)
+	req := StatementReq{
+		AcctID: job.AcctID,
+		Format: job.Format,
+		From:   job.From,
+		To:     job.To,
+	}
+	err = sw.svc.Statement(ctx, buf, req)
+	if err == nil {
+		key := fmt.Sprintf("statements/%s/%s.%s", job.AcctID, job.ID, job.Format)
+		if err = sw.blobs.Put(key, buf); err == nil {
+			return true, sw.repo.CompleteStatementJob(ctx, job.ID, key)
+		}
+	}
+
+	// bad requests, ie. validation errors, will never succeed so no point retrying
+	retry := job.Attempts < sw.maxAttempts && !errors.As(err, &ErrBadRequest{})
+	sw.log.Err(err).
+		Str("jobID", job.ID.String()).
+		Int("attempts", job.Attempts).
+		Bool("retry", retry).
+		Msg("statement job failed")
+	if ferr := sw.repo.FailStatementJob(ctx, job.ID, err.Error(), retry); ferr != nil {
+		return true, fmt.Errorf("FailStatementJob: %w", ferr)
+	}
+	return true, nil
+}
@@ -0,0 +1,218 @@
+package bankxgo_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	t.Run("reads back what was put", func(tt *testing.T) {
+		reqrd := require.New(tt)
+		bs := &bankxgo.LocalBlobStore{Dir: tt.TempDir()}
+		reqrd.Nil(bs.Put("statements/1/2.csv", bytes.NewBufferString("id,date")))
+
+		rc, err := bs.Get("statements/1/2.csv")
+		reqrd.Nil(err)
+		defer rc.Close()
+		content, err := io.ReadAll(rc)
+		reqrd.Nil(err)
+		assert.Equal(tt, "id,date", string(content))
+	})
+
+	t.Run("rejects keys outside of its directory", func(tt *testing.T) {
+		as := assert.New(tt)
+		dir := tt.TempDir()
+		bs := &bankxgo.LocalBlobStore{Dir: filepath.Join(dir, "blobs")}
+		as.NotNil(bs.Put("../escaped", bytes.NewBufferString("x")))
+		_, err := os.Stat(filepath.Join(dir, "escaped"))
+		as.True(os.IsNotExist(err))
+	})
+}
+
+func TestStatementWorker(t *testing.T) {
+	nooplog := zerolog.Nop()
+	job := bankxgo.StatementJob{
+		ID:       snowflake.ID(7241722241547769000),
+		AcctID:   snowflake.ID(7241722241547769001),
+		Format:   bankxgo.StatementFormatCSV,
+		To:       time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		Status:   bankxgo.StatementJobRunning,
+		Attempts: 1,
+	}
+
+	t.Run("returns false when the queue is empty", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		repo.EXPECT().ClaimStatementJob(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+
+		sw := bankxgo.NewStatementWorker(repo, &bankxgo.LocalBlobStore{Dir: tt.TempDir()}, svc, bankxgo.StatementJobsCfg{}, &nooplog)
+		worked, err := sw.RunOnce()
+		assert.Nil(tt, err)
+		assert.False(tt, worked)
+	})
+
+	t.Run("stores the rendered statement and completes the job", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		blobs := &bankxgo.LocalBlobStore{Dir: tt.TempDir()}
+		repo.EXPECT().ClaimStatementJob(gomock.Any(), 5*time.Minute, 3).Return(&job, nil)
+		svc.EXPECT().
+			Statement(gomock.Any(), gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementReq{})).
+			DoAndReturn(func(_ context.Context, w io.Writer, req bankxgo.StatementReq) error {
+				as.Equal(job.AcctID, req.AcctID)
+				as.Equal(job.Format, req.Format)
+				as.Equal(job.To, req.To)
+				_, err := io.WriteString(w, "id,date")
+				return err
+			})
+		var key string
+		repo.EXPECT().
+			CompleteStatementJob(gomock.Any(), job.ID, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ snowflake.ID, k string) error {
+				key = k
+				return nil
+			})
+
+		sw := bankxgo.NewStatementWorker(repo, blobs, svc, bankxgo.StatementJobsCfg{}, &nooplog)
+		worked, err := sw.RunOnce()
+		as.Nil(err)
+		as.True(worked)
+
+		rc, err := blobs.Get(key)
+		require.Nil(tt, err)
+		defer rc.Close()
+		content, _ := io.ReadAll(rc)
+		as.Equal("id,date", string(content))
+	})
+
+	t.Run("requeues the job on error until max attempts", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		repo.EXPECT().ClaimStatementJob(gomock.Any(), gomock.Any(), gomock.Any()).Return(&job, nil)
+		svc.EXPECT().Statement(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("db down"))
+		repo.EXPECT().FailStatementJob(gomock.Any(), job.ID, "db down", true).Return(nil)
+
+		sw := bankxgo.NewStatementWorker(repo, &bankxgo.LocalBlobStore{Dir: tt.TempDir()}, svc, bankxgo.StatementJobsCfg{MaxAttempts: 2}, &nooplog)
+		worked, err := sw.RunOnce()
+		assert.Nil(tt, err)
+		assert.True(tt, worked)
+	})
+
+	t.Run("stops claiming jobs once ctx is done", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		ctx, cancel := context.WithCancel(context.Background())
+		// the queue is never empty, the worker stops after the job at hand
+		repo.EXPECT().ClaimStatementJob(gomock.Any(), gomock.Any(), gomock.Any()).Return(&job, nil)
+		svc.EXPECT().Statement(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		repo.EXPECT().
+			CompleteStatementJob(gomock.Any(), job.ID, gomock.Any()).
+			DoAndReturn(func(context.Context, snowflake.ID, string) error {
+				cancel()
+				return nil
+			})
+
+		sw := bankxgo.NewStatementWorker(repo, &bankxgo.LocalBlobStore{Dir: tt.TempDir()}, svc, bankxgo.StatementJobsCfg{}, &nooplog)
+		sw.Run(ctx, 1)
+	})
+
+	t.Run("fails the job without retry on bad request", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := mocks.NewMockService(ctrl)
+		repo.EXPECT().ClaimStatementJob(gomock.Any(), gomock.Any(), gomock.Any()).Return(&job, nil)
+		svc.EXPECT().
+			Statement(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(bankxgo.ErrBadRequest{Fields: map[string]string{"format": "unsupported"}})
+		repo.EXPECT().FailStatementJob(gomock.Any(), job.ID, gomock.Any(), false).Return(nil)
+
+		sw := bankxgo.NewStatementWorker(repo, &bankxgo.LocalBlobStore{Dir: tt.TempDir()}, svc, bankxgo.StatementJobsCfg{}, &nooplog)
+		_, err := sw.RunOnce()
+		assert.Nil(tt, err)
+	})
+}
+
+func TestRequestStatement(t *testing.T) {
+	nooplog := zerolog.Nop()
+	sysAcctID := snowflake.ID(7241722241547767808)
+	acctID := snowflake.ID(7241722241547769001)
+	newService := func(tt *testing.T, repo *mocks.MockRepository) bankxgo.Service {
+		repo.EXPECT().
+			GetAccount(gomock.Any(), sysAcctID).
+			Return(&bankxgo.Account{AcctID: sysAcctID, Currency: "USD", Balance: decimal.NewFromInt(1)}, nil)
+		svc, err := bankxgo.NewService(
+			repo,
+			bankxgo.NewSystemAccounts(map[string]snowflake.ID{"USD": sysAcctID}, nil),
+			nil,
+			nil,
+			&nooplog,
+			bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}),
+			bankxgo.WithBlobStore(&bankxgo.LocalBlobStore{Dir: tt.TempDir()}),
+		)
+		require.Nil(tt, err)
+		return svc
+	}
+
+	t.Run("returns the cached job of a closed period", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(tt, repo)
+		to := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+		done := &bankxgo.StatementJob{ID: 1, AcctID: acctID, Format: "pdf", To: to, Status: bankxgo.StatementJobDone}
+		repo.EXPECT().FindDoneStatementJob(gomock.Any(), acctID, "pdf", time.Time{}, to).Return(done, nil)
+
+		job, err := svc.RequestStatement(context.Background(), bankxgo.StatementReq{AcctID: acctID, To: to})
+		assert.Nil(tt, err)
+		assert.Equal(tt, done, job)
+	})
+
+	t.Run("enqueues a job for an open period without checking the cache", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(tt, repo)
+		repo.EXPECT().
+			CreateStatementJob(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementJob{})).
+			Return(nil)
+
+		job, err := svc.RequestStatement(context.Background(), bankxgo.StatementReq{AcctID: acctID, Format: "CSV"})
+		as.Nil(err)
+		as.Equal(bankxgo.StatementJobQueued, job.Status)
+		as.Equal(bankxgo.StatementFormatCSV, job.Format)
+		as.False(job.To.IsZero())
+	})
+
+	t.Run("is unavailable without a blob store", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		repo.EXPECT().
+			GetAccount(gomock.Any(), sysAcctID).
+			Return(&bankxgo.Account{AcctID: sysAcctID, Currency: "USD"}, nil)
+		svc, err := bankxgo.NewService(repo, bankxgo.NewSystemAccounts(map[string]snowflake.ID{"USD": sysAcctID}, nil), nil, nil, &nooplog, bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}))
+		require.Nil(tt, err)
+
+		_, err = svc.RequestStatement(context.Background(), bankxgo.StatementReq{AcctID: acctID})
+		assert.Equal(tt, bankxgo.ErrServiceUnavailable, err)
+	})
+}
@@ -0,0 +1,265 @@
+package bankxgo
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// StatementLocale formats the numbers, dates and labels of rendered statements.
+// Only the human readable formats, ie. PDF, are localized. Machine readable
+// formats stick to their specs.
+type StatementLocale struct {
+	Tag        string
+	DecimalSep string
+	GroupSep   string
+	DateLayout string
+	// SymbolAfter places the currency symbol after the amount, ie. 1.234,56 €
+	SymbolAfter bool
+	Labels      map[string]string
+}
+
+const (
+	LabelTitle          = "title"
+	LabelAccount        = "account"
+	LabelPeriod         = "period"
+	LabelDate           = "date"
+	LabelDescription    = "description"
+	LabelDebit          = "debit"
+	LabelCredit         = "credit"
+	LabelBalance        = "balance"
+	LabelOpeningBalance = "opening_balance"
+	LabelClosingBalance = "closing_balance"
+	// LabelPage is printed in the page footer, {n} is replaced with the page
+	// number and {nb} with the number of pages
+	LabelPage             = "page"
+	LabelVerificationCode = "verification_code"
+	LabelVerificationNote = "verification_note"
+)
+
+// DefaultStatementLocale is used when neither the customer nor the template
+// specify a locale
+const DefaultStatementLocale = "en-US"
+
+var enLabels = map[string]string{
+	LabelTitle:            "Statement of Account",
+	LabelAccount:          "Account ID",
+	LabelPeriod:           "Period",
+	LabelDate:             "Date",
+	LabelDescription:      "Description",
+	LabelDebit:            "Debit",
+	LabelCredit:           "Credit",
+	LabelBalance:          "Balance",
+	LabelOpeningBalance:   "Opening balance",
+	LabelClosingBalance:   "Closing balance",
+	LabelPage:             "Page {n} of {nb}",
+	LabelVerificationCode: "Verification code",
+	LabelVerificationNote: "Confirm this statement is genuine and unaltered by looking up the verification code with the bank. The account, period and balances on record must match this statement.",
+	LineKindDeposit:       "Deposit",
+	LineKindWithdrawal:    "Withdrawal",
+	LineKindFee:           "Fee",
+	LineKindInterest:      "Interest",
+	LineKindAdjustment:    "Adjustment",
+}
+
+var statementLocales = map[string]*StatementLocale{
+	"en-US": {
+		Tag:        "en-US",
+		DecimalSep: ".",
+		GroupSep:   ",",
+		DateLayout: "01/02/2006",
+		Labels:     enLabels,
+	},
+	"en-GB": {
+		Tag:        "en-GB",
+		DecimalSep: ".",
+		GroupSep:   ",",
+		DateLayout: "02/01/2006",
+		Labels:     enLabels,
+	},
+	"fil-PH": {
+		Tag:        "fil-PH",
+		DecimalSep: ".",
+		GroupSep:   ",",
+		DateLayout: "01/02/2006",
+		Labels: map[string]string{
+			LabelTitle:            "Pahayag ng Account",
+			LabelAccount:          "Account ID",
+			LabelPeriod:           "Panahon",
+			LabelDate:             "Petsa",
+			LabelDescription:      "Paglalarawan",
+			LabelDebit:            "Debit",
+			LabelCredit:           "Kredit",
+			LabelBalance:          "Balanse",
+			LabelOpeningBalance:   "Panimulang balanse",
+			LabelClosingBalance:   "Pangwakas na balanse",
+			LabelPage:             "Pahina {n} ng {nb}",
+			LabelVerificationCode: "Verification code",
+			LabelVerificationNote: "Tiyaking tunay at hindi binago ang pahayag na ito sa pamamagitan ng paghahanap ng verification code sa bangko. Dapat tumugma ang account, panahon at mga balanse sa talaan.",
+			LineKindDeposit:       "Deposito",
+			LineKindWithdrawal:    "Pag-withdraw",
+			LineKindFee:           "Bayad",
+			LineKindInterest:      "Interes",
+			LineKindAdjustment:    "Pagsasaayos",
+		},
+	},
+	"de-DE": {
+		Tag:         "de-DE",
+		DecimalSep:  ",",
+		GroupSep:    ".",
+		DateLayout:  "02.01.2006",
+		SymbolAfter: true,
+		Labels: map[string]string{
+			LabelTitle:            "Kontoauszug",
+			LabelAccount:          "Konto-ID",
+			LabelPeriod:           "Zeitraum",
+			LabelDate:             "Datum",
+			LabelDescription:      "Beschreibung",
+			LabelDebit:            "Soll",
+			LabelCredit:           "Haben",
+			LabelBalance:          "Saldo",
+			LabelOpeningBalance:   "Anfangssaldo",
+			LabelClosingBalance:   "Endsaldo",
+			LabelPage:             "Seite {n} von {nb}",
+			LabelVerificationCode: "Prüfcode",
+			LabelVerificationNote: "Prüfen Sie die Echtheit dieses Kontoauszugs, indem Sie den Prüfcode bei der Bank abfragen. Konto, Zeitraum und Salden müssen mit diesem Kontoauszug übereinstimmen.",
+			LineKindDeposit:       "Einzahlung",
+			LineKindWithdrawal:    "Auszahlung",
+			LineKindFee:           "Gebühr",
+			LineKindInterest:      "Zinsen",
+			LineKindAdjustment:    "Korrektur",
+		},
+	},
+	"fr-FR": {
+		Tag:         "fr-FR",
+		DecimalSep:  ",",
+		GroupSep:    " ",
+		DateLayout:  "02/01/2006",
+		SymbolAfter: true,
+		Labels: map[string]string{
+			LabelTitle:            "Relevé de compte",
+			LabelAccount:          "N° de compte",
+			LabelPeriod:           "Période",
+			LabelDate:             "Date",
+			LabelDescription:      "Libellé",
+			LabelDebit:            "Débit",
+			LabelCredit:           "Crédit",
+			LabelBalance:          "Solde",
+			LabelOpeningBalance:   "Solde initial",
+			LabelClosingBalance:   "Solde final",
+			LabelPage:             "Page {n} sur {nb}",
+			LabelVerificationCode: "Code de vérification",
+			LabelVerificationNote: "Vérifiez l'authenticité de ce relevé en consultant le code de vérification auprès de la banque. Le compte, la période et les soldes enregistrés doivent correspondre à ce relevé.",
+			LineKindDeposit:       "Dépôt",
+			LineKindWithdrawal:    "Retrait",
+			LineKindFee:           "Frais",
+			LineKindInterest:      "Intérêts",
+			LineKindAdjustment:    "Régularisation",
+		},
+	},
+	"es-ES": {
+		Tag:         "es-ES",
+		DecimalSep:  ",",
+		GroupSep:    ".",
+		DateLayout:  "02/01/2006",
+		SymbolAfter: true,
+		Labels: map[string]string{
+			LabelTitle:            "Extracto de cuenta",
+			LabelAccount:          "ID de cuenta",
+			LabelPeriod:           "Periodo",
+			LabelDate:             "Fecha",
+			LabelDescription:      "Concepto",
+			LabelDebit:            "Cargo",
+			LabelCredit:           "Abono",
+			LabelBalance:          "Saldo",
+			LabelOpeningBalance:   "Saldo inicial",
+			LabelClosingBalance:   "Saldo final",
+			LabelPage:             "Página {n} de {nb}",
+			LabelVerificationCode: "Código de verificación",
+			LabelVerificationNote: "Compruebe la autenticidad de este extracto consultando el código de verificación con el banco. La cuenta, el periodo y los saldos registrados deben coincidir con este extracto.",
+			LineKindDeposit:       "Depósito",
+			LineKindWithdrawal:    "Retirada",
+			LineKindFee:           "Comisión",
+			LineKindInterest:      "Intereses",
+			LineKindAdjustment:    "Ajuste",
+		},
+	},
+}
+
+// currencySymbols are limited to what the PDF core fonts (cp1252) can print,
+// other currencies are printed with their ISO code
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+}
+
+// StatementLocaleFor looks up a locale by its BCP 47 tag, case-insensitively
+// and with either - or _ as separator
+func StatementLocaleFor(tag string) (*StatementLocale, bool) {
+	tag = strings.ReplaceAll(tag, "_", "-")
+	for t, l := range statementLocales {
+		if strings.EqualFold(t, tag) {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// Label returns the translated label, falling back to English
+func (l *StatementLocale) Label(key string) string {
+	if v, ok := l.Labels[key]; ok {
+		return v
+	}
+	return enLabels[key]
+}
+
+func (l *StatementLocale) FormatDate(t time.Time) string {
+	return t.Format(l.DateLayout)
+}
+
+// FormatNumber formats d with 2 decimal places and the locale separators
+func (l *StatementLocale) FormatNumber(d decimal.Decimal) string {
+	s := d.Abs().StringFixed(2)
+	intPart, frac, _ := strings.Cut(s, ".")
+
+	var sb strings.Builder
+	if d.IsNegative() {
+		sb.WriteByte('-')
+	}
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			sb.WriteString(l.GroupSep)
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteString(l.DecimalSep)
+	sb.WriteString(frac)
+	return sb.String()
+}
+
+// FormatAmount formats d with the currency symbol, or ISO code if the symbol
+// is not printable, placed according to the locale
+func (l *StatementLocale) FormatAmount(d decimal.Decimal, currency string) string {
+	num := l.FormatNumber(d)
+	if currency == "" {
+		return num
+	}
+	sym, ok := currencySymbols[currency]
+	if !ok {
+		sym = currency
+	}
+	if l.SymbolAfter {
+		return num + " " + sym
+	}
+	if !ok {
+		// ISO codes need a space, ie. PHP 1,234.00
+		sym += " "
+	}
+	if neg, found := strings.CutPrefix(num, "-"); found {
+		return "-" + sym + neg
+	}
+	return sym + num
+}
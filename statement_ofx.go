@@ -0,0 +1,114 @@
+package bankxgo
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ofxRenderer renders an OFX 2.2 bank statement response
+type ofxRenderer struct{}
+
+func (ofxRenderer) ContentType() string {
+	return "application/x-ofx"
+}
+
+const (
+	ofxHeader     = `<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+	ofxTimeLayout = "20060102150405"
+)
+
+type ofxDoc struct {
+	XMLName xml.Name `xml:"OFX"`
+	SignOn  struct {
+		SonRs struct {
+			Status   ofxStatus `xml:"STATUS"`
+			DtServer string    `xml:"DTSERVER"`
+			Language string    `xml:"LANGUAGE"`
+		} `xml:"SONRS"`
+	} `xml:"SIGNONMSGSRSV1"`
+	Bank struct {
+		StmtTrnRs struct {
+			TrnUID string    `xml:"TRNUID"`
+			Status ofxStatus `xml:"STATUS"`
+			StmtRs ofxStmtRs `xml:"STMTRS"`
+		} `xml:"STMTTRNRS"`
+	} `xml:"BANKMSGSRSV1"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxStmtRs struct {
+	CurDef   string `xml:"CURDEF"`
+	AcctFrom struct {
+		BankID   string `xml:"BANKID"`
+		AcctID   string `xml:"ACCTID"`
+		AcctType string `xml:"ACCTTYPE"`
+	} `xml:"BANKACCTFROM"`
+	TranList struct {
+		DtStart string       `xml:"DTSTART"`
+		DtEnd   string       `xml:"DTEND"`
+		Trns    []ofxStmtTrn `xml:"STMTTRN"`
+	} `xml:"BANKTRANLIST"`
+	LedgerBal struct {
+		BalAmt string `xml:"BALAMT"`
+		DtAsOf string `xml:"DTASOF"`
+	} `xml:"LEDGERBAL"`
+}
+
+type ofxStmtTrn struct {
+	TrnType  string `xml:"TRNTYPE"`
+	DtPosted string `xml:"DTPOSTED"`
+	TrnAmt   string `xml:"TRNAMT"`
+	FitID    string `xml:"FITID"`
+	Name     string `xml:"NAME"`
+}
+
+var ofxTrnTypes = map[string]string{
+	LineKindDeposit:    "CREDIT",
+	LineKindWithdrawal: "DEBIT",
+	LineKindFee:        "FEE",
+	LineKindInterest:   "INT",
+	LineKindAdjustment: "OTHER",
+}
+
+func (ofxRenderer) Render(w io.Writer, stmt *AccountStatement) error {
+	doc := ofxDoc{}
+	doc.SignOn.SonRs.Status = ofxStatus{Code: 0, Severity: "INFO"}
+	doc.SignOn.SonRs.DtServer = stmt.GeneratedAt.Format(ofxTimeLayout)
+	doc.SignOn.SonRs.Language = "ENG"
+
+	trnrs := &doc.Bank.StmtTrnRs
+	trnrs.TrnUID = "0"
+	trnrs.Status = ofxStatus{Code: 0, Severity: "INFO"}
+	rs := &trnrs.StmtRs
+	rs.CurDef = stmt.Account.Currency
+	rs.AcctFrom.BankID = "BANKXGO"
+	rs.AcctFrom.AcctID = stmt.Account.AcctID.String()
+	rs.AcctFrom.AcctType = "CHECKING"
+	rs.TranList.DtStart = stmt.From.Format(ofxTimeLayout)
+	// DTEND is exclusive
+	rs.TranList.DtEnd = stmt.To.AddDate(0, 0, 1).Format(ofxTimeLayout)
+	for _, l := range stmt.Lines {
+		rs.TranList.Trns = append(rs.TranList.Trns, ofxStmtTrn{
+			TrnType:  ofxTrnTypes[l.Kind],
+			DtPosted: l.Date.UTC().Format(ofxTimeLayout),
+			TrnAmt:   l.Amount.StringFixed(2),
+			FitID:    strconv.FormatInt(l.ID, 10),
+			Name:     l.Description,
+		})
+	}
+	rs.LedgerBal.BalAmt = stmt.Closing.StringFixed(2)
+	rs.LedgerBal.DtAsOf = stmt.To.AddDate(0, 0, 1).Add(-time.Second).Format(ofxTimeLayout)
+
+	if _, err := io.WriteString(w, xml.Header+ofxHeader); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,313 @@
+package bankxgo
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/go-pdf/fpdf"
+	"github.com/shopspring/decimal"
+)
+
+type pdfRenderer struct{}
+
+func (pdfRenderer) ContentType() string {
+	return "application/pdf"
+}
+
+// pdfMargin is the page margin on every side, in mm
+const pdfMargin = 10.0
+
+// pdfColumns are the transaction table columns as fractions of the usable page width
+var pdfColumns = []struct {
+	label string
+	width float64
+	align string
+}{
+	{LabelDate, 0.16, "L"},
+	{LabelDescription, 0.30, "L"},
+	{LabelDebit, 0.18, "R"},
+	{LabelCredit, 0.18, "R"},
+	{LabelBalance, 0.18, "R"},
+}
+
+// pdfLayout renders a statement with its template. Positions and sizes are
+// computed from the page geometry and the font size so templates can change
+// either without breaking the layout.
+type pdfLayout struct {
+	pdf    *fpdf.Fpdf
+	stmt   *AccountStatement
+	tmpl   *StatementTemplate
+	loc    *StatementLocale
+	tr     func(string) string
+	widths []float64
+	// rowH is the height of a table row, footerH the space reserved for the page footer
+	rowH    float64
+	footerH float64
+}
+
+func (pdfRenderer) Render(w io.Writer, stmt *AccountStatement) error {
+	tmpl := stmt.Template
+	if tmpl == nil {
+		tmpl = DefaultStatementTemplate()
+	}
+	loc := stmt.Locale
+	if loc == nil {
+		loc = tmpl.Locale
+	}
+
+	pdf := fpdf.New("P", "mm", tmpl.PageSize, "")
+	if stmt.Password != "" {
+		// an empty owner password is replaced with a random one, so nobody can
+		// lift the restrictions and edit the document
+		pdf.SetProtection(fpdf.CnProtectPrint, stmt.Password, "")
+	}
+	if v := stmt.Verification; v != nil {
+		pdf.SetSubject("Verification code "+FormatVerificationCode(v.Code), true)
+		pdf.SetKeywords("sha256:"+v.ContentHash, true)
+	}
+	pdf.SetAuthor(tmpl.BankName, true)
+	pdf.SetTitle(tmpl.Label(loc, LabelTitle), true)
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	// pages are broken by the layout so the table header can be repeated
+	pdf.SetAutoPageBreak(false, pdfMargin)
+	pdf.AliasNbPages("{nb}")
+	if len(tmpl.Logo) > 0 {
+		opts := fpdf.ImageOptions{ImageType: tmpl.LogoType}
+		pdf.RegisterImageOptionsReader("logo", opts, bytes.NewReader(tmpl.Logo))
+	}
+
+	pageW, _ := pdf.GetPageSize()
+	usable := pageW - 2*pdfMargin
+	l := &pdfLayout{
+		pdf:     pdf,
+		stmt:    stmt,
+		tmpl:    tmpl,
+		loc:     loc,
+		tr:      pdf.UnicodeTranslatorFromDescriptor(""),
+		rowH:    pdf.PointConvert(tmpl.FontSize) * 1.8,
+		footerH: pdf.PointConvert(tmpl.FontSize) * 3,
+	}
+	for _, col := range pdfColumns {
+		l.widths = append(l.widths, usable*col.width)
+	}
+	pdf.SetHeaderFunc(l.header)
+	pdf.SetFooterFunc(l.footer)
+
+	pdf.AddPage()
+	l.summary()
+	l.tableHeader()
+	l.balanceRow(stmt.From, LabelOpeningBalance, stmt.Opening, false)
+	for _, line := range stmt.Lines {
+		l.ensureSpace(l.rowH)
+		var debit, credit string
+		if line.Amount.IsNegative() {
+			credit = loc.FormatNumber(line.Amount.Neg())
+		} else {
+			debit = loc.FormatNumber(line.Amount)
+		}
+		desc := tmpl.Label(loc, line.Kind)
+		if desc == "" {
+			desc = line.Description
+		}
+		if line.Memo != "" {
+			desc = l.fit(desc+" - "+line.Memo, l.widths[1])
+		}
+		l.row([]string{loc.FormatDate(line.Date), desc, debit, credit, loc.FormatNumber(line.Balance)}, false)
+	}
+	l.balanceRow(stmt.To, LabelClosingBalance, stmt.Closing, true)
+
+	if stmt.Verification != nil {
+		if err := l.verification(); err != nil {
+			return err
+		}
+	}
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("pdf.Output: %w", err)
+	}
+	return nil
+}
+
+// label returns the translated label encoded for the core fonts
+func (l *pdfLayout) label(key string) string {
+	return l.tr(l.tmpl.Label(l.loc, key))
+}
+
+// header prints the logo, bank name and address at the top of every page
+func (l *pdfLayout) header() {
+	pdf := l.pdf
+	pageW, _ := pdf.GetPageSize()
+	top := pdf.GetY()
+	lineH := pdf.PointConvert(l.tmpl.FontSize) * 1.3
+	bottom := top + lineH*float64(1+len(l.tmpl.Address))
+
+	if len(l.tmpl.Logo) > 0 {
+		logoH := bottom - top
+		if logoH < 15 {
+			logoH = 15
+		}
+		// zero width keeps the aspect ratio
+		pdf.ImageOptions("logo", pdfMargin, top, 0, logoH, false, fpdf.ImageOptions{}, 0, "")
+		if top+logoH > bottom {
+			bottom = top + logoH
+		}
+	}
+
+	pdf.SetFont(l.tmpl.Font, "B", l.tmpl.FontSize+2)
+	pdf.SetXY(pageW/2, top)
+	pdf.CellFormat(pageW/2-pdfMargin, lineH, l.tr(l.tmpl.BankName), "", 2, "R", false, 0, "")
+	pdf.SetFont(l.tmpl.Font, "", l.tmpl.FontSize-1)
+	for _, line := range l.tmpl.Address {
+		pdf.CellFormat(pageW/2-pdfMargin, lineH, l.tr(line), "", 2, "R", false, 0, "")
+	}
+	pdf.SetXY(pdfMargin, bottom)
+	pdf.Ln(lineH)
+
+	// the table header is repeated on every page but the first, where it
+	// follows the summary
+	if pdf.PageNo() > 1 {
+		l.tableHeader()
+	}
+}
+
+func (l *pdfLayout) footer() {
+	pdf := l.pdf
+	pageW, pageH := pdf.GetPageSize()
+	pdf.SetY(pageH - pdfMargin - l.footerH/2)
+	pdf.SetFont(l.tmpl.Font, "", l.tmpl.FontSize-2)
+	page := strings.ReplaceAll(l.tmpl.Label(l.loc, LabelPage), "{n}", strconv.Itoa(pdf.PageNo()))
+	pdf.CellFormat(pageW-2*pdfMargin, l.footerH/2, l.tr(page), "", 0, "C", false, 0, "")
+}
+
+// summary prints the title, account and period on the first page
+func (l *pdfLayout) summary() {
+	pdf := l.pdf
+	pageW, _ := pdf.GetPageSize()
+	size := l.tmpl.FontSize
+	pdf.SetFont(l.tmpl.Font, "B", size+6)
+	pdf.CellFormat(pageW-2*pdfMargin, pdf.PointConvert(size+6)*1.6, l.label(LabelTitle), "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	labelW := (pageW - 2*pdfMargin) * 0.2
+	valueW := (pageW - 2*pdfMargin) * 0.35
+	period := fmt.Sprintf("%s - %s", l.loc.FormatDate(l.stmt.From), l.loc.FormatDate(l.stmt.To))
+	pdf.SetFillColor(211, 212, 208)
+	for _, kv := range [][2]string{
+		{l.label(LabelAccount), l.stmt.Account.AcctID.String()},
+		{l.label(LabelPeriod), period},
+	} {
+		pdf.SetFont(l.tmpl.Font, "B", size+1)
+		pdf.CellFormat(labelW, l.rowH, kv[0]+":", "", 0, "L", false, 0, "")
+		pdf.SetFont(l.tmpl.Font, "", size+1)
+		pdf.CellFormat(valueW, l.rowH, kv[1], "", 1, "R", true, 0, "")
+	}
+	pdf.Ln(l.rowH / 2)
+}
+
+func (l *pdfLayout) tableHeader() {
+	pdf := l.pdf
+	pdf.SetFont(l.tmpl.Font, "B", l.tmpl.FontSize+1)
+	for i, col := range pdfColumns {
+		ln := 0
+		if i == len(pdfColumns)-1 {
+			ln = 1
+		}
+		pdf.CellFormat(l.widths[i], l.rowH*1.2, l.label(col.label), "B", ln, col.align, false, 0, "")
+	}
+	pdf.SetFont(l.tmpl.Font, "", l.tmpl.FontSize)
+}
+
+// row prints a table row, highlighting the balance if highlight
+func (l *pdfLayout) row(cells []string, highlight bool) {
+	pdf := l.pdf
+	if highlight {
+		pdf.SetFillColor(l.tmpl.Accent[0], l.tmpl.Accent[1], l.tmpl.Accent[2])
+	}
+	for i, col := range pdfColumns {
+		ln := 0
+		if i == len(pdfColumns)-1 {
+			ln = 1
+		}
+		fill := highlight && i == len(pdfColumns)-1
+		pdf.CellFormat(l.widths[i], l.rowH, l.tr(cells[i]), "", ln, col.align, fill, 0, "")
+	}
+}
+
+// fit shortens s with an ellipsis until it fits a cell of width w
+func (l *pdfLayout) fit(s string, w float64) string {
+	w -= 2 * l.pdf.GetCellMargin()
+	if l.pdf.GetStringWidth(l.tr(s)) <= w {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && l.pdf.GetStringWidth(l.tr(string(r)+"...")) > w {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "..."
+}
+
+func (l *pdfLayout) balanceRow(date time.Time, key string, amount decimal.Decimal, highlight bool) {
+	l.ensureSpace(l.rowH)
+	l.pdf.SetFont(l.tmpl.Font, "B", l.tmpl.FontSize)
+	balance := l.loc.FormatAmount(amount, l.stmt.Account.Currency)
+	l.row([]string{l.loc.FormatDate(date), l.tmpl.Label(l.loc, key), "", "", balance}, highlight)
+	l.pdf.SetFont(l.tmpl.Font, "", l.tmpl.FontSize)
+}
+
+// ensureSpace starts a new page if h does not fit above the footer
+func (l *pdfLayout) ensureSpace(h float64) {
+	_, pageH := l.pdf.GetPageSize()
+	if l.pdf.GetY()+h > pageH-pdfMargin-l.footerH {
+		l.pdf.AddPage()
+	}
+}
+
+// verification prints the verification code, and its QR code if enabled,
+// below the transactions
+func (l *pdfLayout) verification() error {
+	const qrSize = 30.0
+	pdf := l.pdf
+	stmt := l.stmt
+	height := l.rowH * 4
+	if stmt.QRCode != "" && qrSize > height {
+		height = qrSize
+	}
+	l.ensureSpace(height + l.rowH)
+	pdf.Ln(l.rowH)
+	y := pdf.GetY()
+	pageW, _ := pdf.GetPageSize()
+	textW := pageW - 2*pdfMargin - qrSize - 5
+
+	pdf.SetFont(l.tmpl.Font, "B", l.tmpl.FontSize)
+	pdf.CellFormat(textW*0.4, l.rowH, l.label(LabelVerificationCode)+":", "", 0, "L", false, 0, "")
+	pdf.SetFont("Courier", "B", l.tmpl.FontSize+1)
+	pdf.CellFormat(textW*0.6, l.rowH, FormatVerificationCode(stmt.Verification.Code), "", 1, "L", false, 0, "")
+	pdf.SetFont(l.tmpl.Font, "", l.tmpl.FontSize-2)
+	pdf.MultiCell(textW, pdf.PointConvert(l.tmpl.FontSize-2)*1.4, l.label(LabelVerificationNote), "", "L", false)
+
+	if stmt.QRCode == "" {
+		return nil
+	}
+	code, err := qr.Encode(stmt.QRCode, qr.M, qr.Auto)
+	if err != nil {
+		return fmt.Errorf("qr.Encode: %w", err)
+	}
+	if code, err = barcode.Scale(code, 256, 256); err != nil {
+		return fmt.Errorf("barcode.Scale: %w", err)
+	}
+	buf := new(bytes.Buffer)
+	if err = png.Encode(buf, code); err != nil {
+		return fmt.Errorf("png.Encode: %w", err)
+	}
+	opts := fpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader("verification-qr", opts, buf)
+	pdf.ImageOptions("verification-qr", pageW-pdfMargin-qrSize, y, qrSize, qrSize, false, opts, 0, "")
+	return pdf.Error()
+}
@@ -0,0 +1,176 @@
+package bankxgo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// DefaultStatementTemplateName is the template of customers without a preference
+const DefaultStatementTemplateName = "default"
+
+// StatementTemplate is the layout and branding of PDF statements, built from
+// StatementTemplateCfg with its logo loaded and its locale resolved
+type StatementTemplate struct {
+	Name     string
+	BankName string
+	Address  []string
+	Logo     []byte
+	// LogoType is the fpdf image type of Logo, ie. PNG or JPG
+	LogoType string
+	Locale   *StatementLocale
+	Font     string
+	FontSize float64
+	PageSize string
+	Accent   [3]int
+	Labels   map[string]string
+}
+
+// StatementPreference is the customer's choice of statement template, locale
+// and statement cycle day, empty fields fall back to the defaults
+type StatementPreference struct {
+	Template string `json:"template"`
+	Locale   string `json:"locale"`
+	// CycleDay is the day of the month the statement cycle closes on, 1-31
+	CycleDay int `json:"cycleDay,omitempty"`
+}
+
+type StatementPreferenceReq struct {
+	AcctID snowflake.ID
+	Email  string
+	Client string
+	StatementPreference
+}
+
+var (
+	coreFonts = []string{"Arial", "Helvetica", "Times", "Courier"}
+	pageSizes = []string{"A3", "A4", "A5", "Letter", "Legal", "Tabloid"}
+)
+
+// DefaultStatementTemplate is used when no `default` template is configured
+func DefaultStatementTemplate() *StatementTemplate {
+	return &StatementTemplate{
+		Name:     DefaultStatementTemplateName,
+		BankName: "BankXGo",
+		Locale:   statementLocales[DefaultStatementLocale],
+		Font:     "Arial",
+		FontSize: 10,
+		PageSize: "A4",
+		Accent:   [3]int{140, 212, 130},
+	}
+}
+
+// NewStatementTemplates builds the configured templates keyed by name, adding
+// DefaultStatementTemplate if there is no `default` template
+func NewStatementTemplates(cfgs map[string]StatementTemplateCfg) (map[string]*StatementTemplate, error) {
+	tmpls := make(map[string]*StatementTemplate, len(cfgs)+1)
+	for name, cfg := range cfgs {
+		tmpl, err := newStatementTemplate(name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		tmpls[name] = tmpl
+	}
+	if _, ok := tmpls[DefaultStatementTemplateName]; !ok {
+		tmpls[DefaultStatementTemplateName] = DefaultStatementTemplate()
+	}
+	return tmpls, nil
+}
+
+func newStatementTemplate(name string, cfg StatementTemplateCfg) (*StatementTemplate, error) {
+	tmpl := DefaultStatementTemplate()
+	tmpl.Name = name
+	tmpl.Address = cfg.Address
+	tmpl.Labels = cfg.Labels
+	if cfg.BankName != "" {
+		tmpl.BankName = cfg.BankName
+	}
+	if cfg.FontSize < 0 {
+		return nil, fmt.Errorf("statement_templates.%s.font_size: must be positive", name)
+	}
+	if cfg.FontSize > 0 {
+		tmpl.FontSize = cfg.FontSize
+	}
+
+	if cfg.Locale != "" {
+		loc, ok := StatementLocaleFor(cfg.Locale)
+		if !ok {
+			return nil, fmt.Errorf("statement_templates.%s.locale: unsupported locale %q", name, cfg.Locale)
+		}
+		tmpl.Locale = loc
+	}
+	if cfg.Font != "" {
+		font, ok := lookupFold(coreFonts, cfg.Font)
+		if !ok {
+			return nil, fmt.Errorf("statement_templates.%s.font: unsupported font %q", name, cfg.Font)
+		}
+		tmpl.Font = font
+	}
+	if cfg.PageSize != "" {
+		size, ok := lookupFold(pageSizes, cfg.PageSize)
+		if !ok {
+			return nil, fmt.Errorf("statement_templates.%s.page_size: unsupported page size %q", name, cfg.PageSize)
+		}
+		tmpl.PageSize = size
+	}
+	if cfg.AccentColor != "" {
+		rgb, err := parseHexColor(cfg.AccentColor)
+		if err != nil {
+			return nil, fmt.Errorf("statement_templates.%s.accent_color: %w", name, err)
+		}
+		tmpl.Accent = rgb
+	}
+	if cfg.Logo != "" {
+		switch strings.ToLower(filepath.Ext(cfg.Logo)) {
+		case ".png":
+			tmpl.LogoType = "PNG"
+		case ".jpg", ".jpeg":
+			tmpl.LogoType = "JPG"
+		default:
+			return nil, fmt.Errorf("statement_templates.%s.logo: must be a PNG or JPEG image", name)
+		}
+		logo, err := os.ReadFile(cfg.Logo)
+		if err != nil {
+			return nil, fmt.Errorf("statement_templates.%s.logo: %w", name, err)
+		}
+		tmpl.Logo = logo
+	}
+	return tmpl, nil
+}
+
+// Label returns the template's override of the label, or the label of loc
+func (t *StatementTemplate) Label(loc *StatementLocale, key string) string {
+	if v, ok := t.Labels[key]; ok {
+		return v
+	}
+	return loc.Label(key)
+}
+
+func lookupFold(options []string, s string) (string, bool) {
+	for _, o := range options {
+		if strings.EqualFold(o, s) {
+			return o, true
+		}
+	}
+	return "", false
+}
+
+func parseHexColor(s string) ([3]int, error) {
+	var rgb [3]int
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return rgb, fmt.Errorf("invalid color %q, expected #RRGGBB", s)
+	}
+	for i := range rgb {
+		v, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return rgb, fmt.Errorf("invalid color %q, expected #RRGGBB", s)
+		}
+		rgb[i] = int(v)
+	}
+	return rgb, nil
+}
@@ -0,0 +1,240 @@
+package bankxgo_test
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+func TestStatementLocale(t *testing.T) {
+	amount := decimal.RequireFromString("-1234567.891")
+	for _, tc := range []struct {
+		tag, currency, amount, date string
+	}{
+		{"en-US", "USD", "-$1,234,567.89", "09/30/2024"},
+		{"en_gb", "GBP", "-£1,234,567.89", "30/09/2024"},
+		{"fil-PH", "PHP", "-PHP 1,234,567.89", "09/30/2024"},
+		{"de-DE", "EUR", "-1.234.567,89 €", "30.09.2024"},
+		{"fr-FR", "EUR", "-1 234 567,89 €", "30/09/2024"},
+		{"es-ES", "EUR", "-1.234.567,89 €", "30/09/2024"},
+	} {
+		t.Run(tc.tag, func(tt *testing.T) {
+			loc, ok := bankxgo.StatementLocaleFor(tc.tag)
+			require.True(tt, ok)
+			assert.Equal(tt, tc.amount, loc.FormatAmount(amount, tc.currency))
+			assert.Equal(tt, tc.date, loc.FormatDate(time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)))
+			assert.NotEmpty(tt, loc.Label(bankxgo.LabelTitle))
+		})
+	}
+
+	t.Run("small amounts have no group separator", func(tt *testing.T) {
+		loc, _ := bankxgo.StatementLocaleFor("en-US")
+		assert.Equal(tt, "999.50", loc.FormatNumber(decimal.RequireFromString("999.5")))
+	})
+
+	t.Run("unknown locale", func(tt *testing.T) {
+		_, ok := bankxgo.StatementLocaleFor("xx-XX")
+		assert.False(tt, ok)
+	})
+}
+
+func TestNewStatementTemplates(t *testing.T) {
+	t.Run("adds the default template", func(tt *testing.T) {
+		as := assert.New(tt)
+		tmpls, err := bankxgo.NewStatementTemplates(map[string]bankxgo.StatementTemplateCfg{
+			"europe": {BankName: "Bank EU", Locale: "de-DE", Font: "helvetica", PageSize: "a4", AccentColor: "#4A90D9"},
+		})
+		as.Nil(err)
+		as.Contains(tmpls, bankxgo.DefaultStatementTemplateName)
+		eu := tmpls["europe"]
+		as.Equal("de-DE", eu.Locale.Tag)
+		as.Equal("Helvetica", eu.Font)
+		as.Equal("A4", eu.PageSize)
+		as.Equal([3]int{0x4A, 0x90, 0xD9}, eu.Accent)
+	})
+
+	for field, cfg := range map[string]bankxgo.StatementTemplateCfg{
+		"locale":       {Locale: "xx-XX"},
+		"font":         {Font: "Comic Sans"},
+		"page_size":    {PageSize: "B7"},
+		"accent_color": {AccentColor: "green"},
+		"logo":         {Logo: "logo.gif"},
+	} {
+		t.Run("rejects invalid "+field, func(tt *testing.T) {
+			_, err := bankxgo.NewStatementTemplates(map[string]bankxgo.StatementTemplateCfg{"bad": cfg})
+			assert.ErrorContains(tt, err, "statement_templates.bad."+field)
+		})
+	}
+
+	t.Run("label overrides take precedence over the locale", func(tt *testing.T) {
+		tmpls, err := bankxgo.NewStatementTemplates(map[string]bankxgo.StatementTemplateCfg{
+			"default": {Labels: map[string]string{bankxgo.LabelTitle: "Account Summary"}},
+		})
+		require.Nil(tt, err)
+		tmpl := tmpls[bankxgo.DefaultStatementTemplateName]
+		assert.Equal(tt, "Account Summary", tmpl.Label(tmpl.Locale, bankxgo.LabelTitle))
+		assert.Equal(tt, "Balance", tmpl.Label(tmpl.Locale, bankxgo.LabelBalance))
+	})
+}
+
+// pdfPageCount reads the page count from the (uncompressed) page tree
+func pdfPageCount(t *testing.T, pdf []byte) int {
+	m := regexp.MustCompile(`/Type /Pages\s*/Kids \[[^\]]*\]\s*/Count (\d+)`).FindSubmatch(pdf)
+	require.NotNil(t, m, "page tree not found")
+	n, _ := strconv.Atoi(string(m[1]))
+	return n
+}
+
+func TestPDFPagination(t *testing.T) {
+	acct := bankxgo.Account{AcctID: snowflake.ID(7241407009730334720), Currency: "EUR"}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newStatement := func(n int) *bankxgo.AccountStatement {
+		charges := make([]bankxgo.Charge, n)
+		for i := range charges {
+			charges[i] = bankxgo.Charge{
+				ID:        int64(i + 1),
+				Amount:    decimal.NewFromInt(10),
+				Typ:       "debit",
+				TxTyp:     "deposit",
+				CreatedAt: start.Add(time.Duration(i) * time.Hour),
+			}
+		}
+		return bankxgo.NewAccountStatement(acct, charges, start, start.AddDate(0, 1, 0))
+	}
+	render := func(stmt *bankxgo.AccountStatement) []byte {
+		rndr, _ := bankxgo.StatementRendererFor(bankxgo.StatementFormatPDF)
+		buf := new(bytes.Buffer)
+		require.Nil(t, rndr.Render(buf, stmt))
+		return buf.Bytes()
+	}
+
+	t.Run("fits a short statement on a single page", func(tt *testing.T) {
+		assert.Equal(tt, 1, pdfPageCount(tt, render(newStatement(5))))
+	})
+
+	t.Run("grows the number of pages with the number of lines", func(tt *testing.T) {
+		as := assert.New(tt)
+		pages100 := pdfPageCount(tt, render(newStatement(100)))
+		pages400 := pdfPageCount(tt, render(newStatement(400)))
+		as.Greater(pages100, 1)
+		as.Greater(pages400, pages100*3)
+	})
+
+	t.Run("fits fewer lines per page with a larger font", func(tt *testing.T) {
+		stmt := newStatement(200)
+		pages := pdfPageCount(tt, render(stmt))
+		tmpls, err := bankxgo.NewStatementTemplates(map[string]bankxgo.StatementTemplateCfg{
+			"large": {FontSize: 14, Locale: "fr-FR"},
+		})
+		require.Nil(tt, err)
+		stmt.Template = tmpls["large"]
+		assert.Greater(tt, pdfPageCount(tt, render(stmt)), pages)
+	})
+}
+
+func TestStatementPreference(t *testing.T) {
+	nooplog := zerolog.Nop()
+	sysAcctID := snowflake.ID(7241722241547767808)
+	acct := bankxgo.Account{AcctID: snowflake.ID(7241407009730334720), Currency: "USD"}
+	tmpls, err := bankxgo.NewStatementTemplates(map[string]bankxgo.StatementTemplateCfg{
+		"europe": {BankName: "Bank EU", Locale: "de-DE"},
+	})
+	require.Nil(t, err)
+	newService := func(repo *mocks.MockRepository) bankxgo.Service {
+		repo.EXPECT().
+			GetAccount(gomock.Any(), sysAcctID).
+			Return(&bankxgo.Account{AcctID: sysAcctID, Currency: "USD"}, nil)
+		svc, err := bankxgo.NewService(
+			repo,
+			bankxgo.NewSystemAccounts(map[string]snowflake.ID{"USD": sysAcctID}, nil),
+			nil,
+			nil,
+			&nooplog,
+			bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}),
+			bankxgo.WithStatementTemplates(tmpls),
+		)
+		require.Nil(t, err)
+		return svc
+	}
+
+	t.Run("stores the canonical locale tag", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(repo)
+		want := bankxgo.StatementPreference{Template: "europe", Locale: "fr-FR"}
+		repo.EXPECT().SetStatementPreference(gomock.Any(), acct.AcctID, want).Return(nil)
+
+		pref, err := svc.SetStatementPreference(context.Background(), bankxgo.StatementPreferenceReq{
+			AcctID:              acct.AcctID,
+			StatementPreference: bankxgo.StatementPreference{Template: "europe", Locale: "FR_fr"},
+		})
+		assert.Nil(tt, err)
+		assert.Equal(tt, &want, pref)
+	})
+
+	for field, pref := range map[string]bankxgo.StatementPreference{
+		"template": {Template: "nope"},
+		"locale":   {Locale: "xx-XX"},
+	} {
+		t.Run("rejects unsupported "+field, func(tt *testing.T) {
+			ctrl := gomock.NewController(tt)
+			repo := mocks.NewMockRepository(ctrl)
+			svc := newService(repo)
+			_, err := svc.SetStatementPreference(context.Background(), bankxgo.StatementPreferenceReq{
+				AcctID:              acct.AcctID,
+				StatementPreference: pref,
+			})
+			assert.Equal(tt, bankxgo.ErrBadRequest{Fields: map[string]string{field: "unsupported"}}, err)
+		})
+	}
+
+	t.Run("rejects a cycle day out of range", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(repo)
+		_, err := svc.SetStatementPreference(context.Background(), bankxgo.StatementPreferenceReq{
+			AcctID:              acct.AcctID,
+			StatementPreference: bankxgo.StatementPreference{CycleDay: 32},
+		})
+		assert.ErrorAs(tt, err, &bankxgo.ErrBadRequest{})
+	})
+
+	t.Run("renders the PDF with the preferred template", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(repo)
+		repo.EXPECT().GetAccount(gomock.Any(), acct.AcctID).Return(&acct, nil)
+		repo.EXPECT().GetAccountCharges(gomock.Any(), acct.AcctID).Return(nil, nil)
+		repo.EXPECT().
+			GetStatementPreference(gomock.Any(), acct.AcctID).
+			Return(&bankxgo.StatementPreference{Template: "europe"}, nil)
+
+		buf := new(bytes.Buffer)
+		require.Nil(tt, svc.Statement(context.Background(), buf, bankxgo.StatementReq{AcctID: acct.AcctID}))
+		// the document info is not compressed but UTF-16 encoded
+		utf16 := func(s string) string {
+			var sb strings.Builder
+			for _, r := range s {
+				sb.WriteByte(0)
+				sb.WriteRune(r)
+			}
+			return sb.String()
+		}
+		assert.Contains(tt, buf.String(), "/Author (\xfe\xff"+utf16("Bank EU")+")")
+		assert.Contains(tt, buf.String(), "/Title (\xfe\xff"+utf16("Kontoauszug")+")")
+	})
+}
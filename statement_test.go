@@ -0,0 +1,172 @@
+package bankxgo_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arhyth/bankxgo"
+)
+
+func testStatement() *bankxgo.AccountStatement {
+	day := func(d int) time.Time { return time.Date(2024, 9, d, 10, 0, 0, 0, time.UTC) }
+	acct := bankxgo.Account{AcctID: snowflake.ParseInt64(7241407009730334720), Currency: "USD"}
+	// the memo is longer than the description column of the PDF
+	rent := bankxgo.Memo{Description: "Rent for September, apartment 4B, paid to Landlord & Sons Property Management"}
+	charges := []bankxgo.Charge{
+		{ID: 1, Amount: decimal.New(500, 0), Typ: "debit", TxTyp: "deposit", CreatedAt: day(1)},
+		{ID: 2, Amount: decimal.New(100, 0), Typ: "credit", TxTyp: "withdrawal", Memo: rent, CreatedAt: day(10)},
+		{ID: 3, Amount: decimal.New(15, -1), Typ: "credit", Fee: true, TxTyp: "withdrawal", Memo: rent, CreatedAt: day(10)},
+		{ID: 4, Amount: decimal.New(42, -2), Typ: "debit", TxTyp: "interest", CreatedAt: day(30)},
+		{ID: 5, Amount: decimal.New(50, 0), Typ: "debit", TxTyp: "deposit", CreatedAt: day(30).AddDate(0, 0, 2)},
+	}
+	return bankxgo.NewAccountStatement(acct, charges, day(5), day(30))
+}
+
+func TestNewAccountStatement(t *testing.T) {
+	as := assert.New(t)
+	stmt := testStatement()
+
+	as.Equal(time.Date(2024, 9, 5, 0, 0, 0, 0, time.UTC), stmt.From)
+	as.Equal(time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC), stmt.To)
+	as.True(decimal.New(500, 0).Equal(stmt.Opening))
+	as.True(decimal.RequireFromString("398.92").Equal(stmt.Closing), stmt.Closing.String())
+	as.Len(stmt.Lines, 3)
+	as.Equal(bankxgo.LineKindWithdrawal, stmt.Lines[0].Kind)
+	as.True(decimal.New(-100, 0).Equal(stmt.Lines[0].Amount))
+	as.Equal("Withdrawal", stmt.Lines[0].Description)
+	as.Contains(stmt.Lines[0].Memo, "Rent for September")
+	as.Equal(bankxgo.LineKindFee, stmt.Lines[1].Kind)
+	as.Equal("Fee", stmt.Lines[1].Description)
+	as.Equal(bankxgo.LineKindInterest, stmt.Lines[2].Kind)
+
+	t.Run("dates settled deposits by their settlement", func(tt *testing.T) {
+		as := assert.New(tt)
+		booked := time.Date(2024, 9, 28, 10, 0, 0, 0, time.UTC)
+		settled := time.Date(2024, 10, 2, 10, 0, 0, 0, time.UTC)
+		charges := []bankxgo.Charge{
+			{ID: 1, Amount: decimal.New(500, 0), Typ: "debit", TxTyp: "deposit", CreatedAt: booked, SettledAt: settled},
+		}
+		sept := bankxgo.NewAccountStatement(stmt.Account, charges, time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC))
+		as.Empty(sept.Lines)
+		as.True(sept.Closing.IsZero())
+
+		oct := bankxgo.NewAccountStatement(stmt.Account, charges, time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 10, 31, 0, 0, 0, 0, time.UTC))
+		as.Len(oct.Lines, 1)
+		as.Equal(settled, oct.Lines[0].Date)
+		as.True(decimal.New(500, 0).Equal(oct.Closing))
+	})
+}
+
+func TestStatementRenderers(t *testing.T) {
+	stmt := testStatement()
+
+	t.Run("pdf", func(tt *testing.T) {
+		as := assert.New(tt)
+		rndr, ok := bankxgo.StatementRendererFor("")
+		as.True(ok)
+		as.Equal("application/pdf", rndr.ContentType())
+		buf := &bytes.Buffer{}
+		as.Nil(rndr.Render(buf, stmt))
+		as.True(bytes.HasPrefix(buf.Bytes(), []byte("%PDF")))
+	})
+
+	t.Run("pdf of an account without charges", func(tt *testing.T) {
+		as := assert.New(tt)
+		rndr, _ := bankxgo.StatementRendererFor(bankxgo.StatementFormatPDF)
+		empty := bankxgo.NewAccountStatement(stmt.Account, nil, time.Time{}, time.Time{})
+		as.Nil(rndr.Render(&bytes.Buffer{}, empty))
+	})
+
+	t.Run("csv", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		rndr, ok := bankxgo.StatementRendererFor("CSV")
+		reqrd.True(ok)
+		buf := &bytes.Buffer{}
+		reqrd.Nil(rndr.Render(buf, stmt))
+		rows, err := csv.NewReader(buf).ReadAll()
+		reqrd.Nil(err)
+		reqrd.Len(rows, 6)
+		as.Equal([]string{"", "2024-09-05", "opening", "Opening balance", "", "USD", "500.00"}, rows[1])
+		as.Equal([]string{"3", "2024-09-10", "fee", "Fee", "-1.50", "USD", "398.50"}, rows[3])
+		as.Equal("398.92", rows[5][6])
+	})
+
+	t.Run("ofx", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		rndr, ok := bankxgo.StatementRendererFor(bankxgo.StatementFormatOFX)
+		reqrd.True(ok)
+		buf := &bytes.Buffer{}
+		reqrd.Nil(rndr.Render(buf, stmt))
+		as.Contains(buf.String(), `<?OFX OFXHEADER="200" VERSION="220"`)
+
+		var doc struct {
+			Trns []struct {
+				TrnType string `xml:"TRNTYPE"`
+				TrnAmt  string `xml:"TRNAMT"`
+				FitID   string `xml:"FITID"`
+			} `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>BANKTRANLIST>STMTTRN"`
+			BalAmt string `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>LEDGERBAL>BALAMT"`
+		}
+		reqrd.Nil(xml.Unmarshal(buf.Bytes(), &doc))
+		reqrd.Len(doc.Trns, 3)
+		as.Equal("DEBIT", doc.Trns[0].TrnType)
+		as.Equal("-100.00", doc.Trns[0].TrnAmt)
+		as.Equal("FEE", doc.Trns[1].TrnType)
+		as.Equal("INT", doc.Trns[2].TrnType)
+		as.Equal("398.92", doc.BalAmt)
+	})
+
+	t.Run("camt.053", func(tt *testing.T) {
+		as := assert.New(tt)
+		reqrd := require.New(tt)
+		rndr, ok := bankxgo.StatementRendererFor(bankxgo.StatementFormatCamt053)
+		reqrd.True(ok)
+		buf := &bytes.Buffer{}
+		reqrd.Nil(rndr.Render(buf, stmt))
+		as.True(strings.Contains(buf.String(), "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"))
+
+		var doc struct {
+			Bals []struct {
+				Cd  string `xml:"Tp>CdOrPrtry>Cd"`
+				Amt string `xml:"Amt"`
+			} `xml:"BkToCstmrStmt>Stmt>Bal"`
+			Ntrys []struct {
+				Amt       string `xml:"Amt"`
+				CdtDbtInd string `xml:"CdtDbtInd"`
+			} `xml:"BkToCstmrStmt>Stmt>Ntry"`
+		}
+		reqrd.Nil(xml.Unmarshal(buf.Bytes(), &doc))
+		reqrd.Len(doc.Bals, 2)
+		as.Equal("OPBD", doc.Bals[0].Cd)
+		as.Equal("500.00", doc.Bals[0].Amt)
+		as.Equal("CLBD", doc.Bals[1].Cd)
+		reqrd.Len(doc.Ntrys, 3)
+		as.Equal("DBIT", doc.Ntrys[0].CdtDbtInd)
+		as.Equal("100.00", doc.Ntrys[0].Amt)
+		as.Equal("CRDT", doc.Ntrys[2].CdtDbtInd)
+	})
+
+	t.Run("unsupported format", func(tt *testing.T) {
+		_, ok := bankxgo.StatementRendererFor("xlsx")
+		assert.False(tt, ok)
+	})
+}
+
+func TestStatementFormatFromAccept(t *testing.T) {
+	as := assert.New(t)
+	as.Equal("csv", bankxgo.StatementFormatFromAccept("text/csv"))
+	as.Equal("ofx", bankxgo.StatementFormatFromAccept("text/html;q=0.9, application/x-ofx"))
+	as.Equal("camt053", bankxgo.StatementFormatFromAccept("application/xml"))
+	as.Equal("", bankxgo.StatementFormatFromAccept("*/*"))
+}
@@ -0,0 +1,249 @@
+package bankxgo
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/shopspring/decimal"
+)
+
+// StatementVerification is the record of an issued statement that a verification
+// code resolves to. It carries what a reader needs to tell whether the statement
+// in front of them was altered: the account, period and closing balance.
+type StatementVerification struct {
+	Code     string          `json:"code"`
+	AcctID   snowflake.ID    `json:"acctID"`
+	Currency string          `json:"currency"`
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"`
+	Opening  decimal.Decimal `json:"opening"`
+	Closing  decimal.Decimal `json:"closing"`
+	Lines    int             `json:"lines"`
+	// ContentHash is the hex SHA-256 of the canonical statement content
+	ContentHash string    `json:"contentHash"`
+	IssuedAt    time.Time `json:"issuedAt"`
+}
+
+// StatementPasswordReq sets the password the customer's PDF statements are
+// encrypted with. The customer chooses it, so it never has to be sent to them.
+type StatementPasswordReq struct {
+	AcctID snowflake.ID `json:"-"`
+	Email  string       `json:"-"`
+	// Password is 8 to 64 characters, an empty password removes it
+	Password string `json:"password"`
+
+	// Client identifies the caller (API client or remote IP), set by the transport
+	Client string `json:"-"`
+}
+
+const (
+	minStatementPasswordLen = 8
+	maxStatementPasswordLen = 64
+)
+
+// verificationCodeLen is in base32 characters, ie. 100 bits
+const verificationCodeLen = 20
+
+var (
+	b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	errInvalidVerificationCode = ErrBadRequest{Fields: map[string]string{"code": "invalid format"}}
+)
+
+// StatementSigner issues verification codes for statements and seals the
+// passwords they are encrypted with. Codes are an HMAC of the statement content
+// so the same statement always gets the same code and a code cannot be forged
+// without the signing key.
+type StatementSigner struct {
+	signingKey  []byte
+	passwordKey []byte
+	qrCode      bool
+	verifyURL   string
+}
+
+// NewStatementSigner returns nil if neither signing nor encryption is configured
+func NewStatementSigner(cfg StatementSecurityCfg) (*StatementSigner, error) {
+	if cfg.SigningKey == "" && cfg.PasswordKey == "" {
+		return nil, nil
+	}
+	if cfg.SigningKey != "" && len(cfg.SigningKey) < 16 {
+		return nil, errors.New("statement_security.signing_key: must be at least 16 characters")
+	}
+	if cfg.PasswordKey != "" && len(cfg.PasswordKey) < 16 {
+		return nil, errors.New("statement_security.password_key: must be at least 16 characters")
+	}
+	if cfg.QRCode && cfg.SigningKey == "" {
+		return nil, errors.New("statement_security.qr_code: requires signing_key")
+	}
+	return &StatementSigner{
+		signingKey:  []byte(cfg.SigningKey),
+		passwordKey: []byte(cfg.PasswordKey),
+		qrCode:      cfg.QRCode,
+		verifyURL:   cfg.VerifyURL,
+	}, nil
+}
+
+// Sign returns the verification of stmt, or nil if signing is not configured
+func (s *StatementSigner) Sign(stmt *AccountStatement) *StatementVerification {
+	if len(s.signingKey) == 0 {
+		return nil
+	}
+	hash := statementContentHash(stmt)
+	return &StatementVerification{
+		Code:        s.code(hash),
+		AcctID:      stmt.Account.AcctID,
+		Currency:    stmt.Account.Currency,
+		From:        stmt.From,
+		To:          stmt.To,
+		Opening:     stmt.Opening,
+		Closing:     stmt.Closing,
+		Lines:       len(stmt.Lines),
+		ContentHash: hex.EncodeToString(hash),
+		IssuedAt:    stmt.GeneratedAt,
+	}
+}
+
+// Verify checks the code of a stored verification against its content hash,
+// a mismatch means the record was tampered with
+func (s *StatementSigner) Verify(v *StatementVerification) bool {
+	if len(s.signingKey) == 0 {
+		return false
+	}
+	hash, err := hex.DecodeString(v.ContentHash)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(s.code(hash)), []byte(v.Code))
+}
+
+// Encrypts reports whether statements are encrypted with the passwords
+// customers set, see SealPassword
+func (s *StatementSigner) Encrypts() bool {
+	return len(s.passwordKey) > 0
+}
+
+// SealPassword encrypts the statement password a customer chose for the
+// account with the password key, so it is not stored in the clear but can be
+// recovered to encrypt each statement
+func (s *StatementSigner) SealPassword(acctID snowflake.ID, password string) ([]byte, error) {
+	aead, err := s.passwordAEAD()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	// the account ID is authenticated so a sealed password cannot be moved
+	// to another account
+	return aead.Seal(nonce, nonce, []byte(password), []byte(acctID.String())), nil
+}
+
+// OpenPassword decrypts a password sealed by SealPassword
+func (s *StatementSigner) OpenPassword(acctID snowflake.ID, sealed []byte) (string, error) {
+	aead, err := s.passwordAEAD()
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("sealed statement password too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	pw, err := aead.Open(nil, nonce, ciphertext, []byte(acctID.String()))
+	if err != nil {
+		return "", err
+	}
+	return string(pw), nil
+}
+
+func (s *StatementSigner) passwordAEAD() (cipher.AEAD, error) {
+	if len(s.passwordKey) == 0 {
+		return nil, errors.New("statement encryption not configured")
+	}
+	// the configured key is an arbitrary string, hashed to an AES-256 key
+	key := sha256.Sum256(s.passwordKey)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// VerifyURL returns what the QR code of a statement encodes, or an empty string
+// if QR codes are not configured
+func (s *StatementSigner) VerifyURL(code string) string {
+	if !s.qrCode {
+		return ""
+	}
+	if s.verifyURL == "" {
+		return code
+	}
+	return strings.TrimRight(s.verifyURL, "/") + "/" + code
+}
+
+func (s *StatementSigner) code(contentHash []byte) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write(contentHash)
+	return b32.EncodeToString(mac.Sum(nil))[:verificationCodeLen]
+}
+
+// statementContentHash hashes everything a statement states, in a format
+// independent of the rendering, so only alterations to the figures matter
+func statementContentHash(stmt *AccountStatement) []byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%s|%s|%s\n",
+		stmt.Account.AcctID,
+		stmt.Account.Currency,
+		stmt.From.Format(time.DateOnly),
+		stmt.To.Format(time.DateOnly),
+		stmt.Opening.StringFixed(2),
+		stmt.Closing.StringFixed(2),
+	)
+	for _, l := range stmt.Lines {
+		fmt.Fprintf(h, "%d|%s|%s|%s|%s\n",
+			l.ID,
+			l.Date.UTC().Format(time.RFC3339),
+			l.Kind,
+			l.Amount.StringFixed(2),
+			l.Balance.StringFixed(2),
+		)
+	}
+	return h.Sum(nil)
+}
+
+// FormatVerificationCode groups the code in blocks of 5 for readability
+func FormatVerificationCode(code string) string {
+	var sb strings.Builder
+	for i := 0; i < len(code); i += 5 {
+		if i > 0 {
+			sb.WriteByte('-')
+		}
+		sb.WriteString(code[i:min(i+5, len(code))])
+	}
+	return sb.String()
+}
+
+// ParseVerificationCode normalizes a code as typed by a reader, ie. in lower
+// case and with or without the grouping dashes
+func ParseVerificationCode(code string) (string, error) {
+	code = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	if len(code) != verificationCodeLen {
+		return "", errInvalidVerificationCode
+	}
+	for _, r := range code {
+		if !(r >= 'A' && r <= 'Z' || r >= '2' && r <= '7') {
+			return "", errInvalidVerificationCode
+		}
+	}
+	return code, nil
+}
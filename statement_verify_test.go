@@ -0,0 +1,276 @@
+package bankxgo_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/arhyth/bankxgo"
+	"github.com/arhyth/bankxgo/mocks"
+)
+
+func testSigner(t *testing.T, cfg bankxgo.StatementSecurityCfg) *bankxgo.StatementSigner {
+	signer, err := bankxgo.NewStatementSigner(cfg)
+	require.Nil(t, err)
+	require.NotNil(t, signer)
+	return signer
+}
+
+func TestStatementSigner(t *testing.T) {
+	cfg := bankxgo.StatementSecurityCfg{
+		SigningKey:  "0123456789abcdef-signing",
+		PasswordKey: "0123456789abcdef-password",
+		QRCode:      true,
+		VerifyURL:   "https://bank.example/statements/verify/",
+	}
+
+	t.Run("signs the same statement with the same code", func(tt *testing.T) {
+		as := assert.New(tt)
+		signer := testSigner(tt, cfg)
+		v1 := signer.Sign(testStatement())
+		v2 := signer.Sign(testStatement())
+		as.Equal(v1.Code, v2.Code)
+		as.Len(v1.Code, 20)
+		as.True(signer.Verify(v1))
+	})
+
+	t.Run("signs an altered statement with a different code", func(tt *testing.T) {
+		signer := testSigner(tt, cfg)
+		stmt := testStatement()
+		v1 := signer.Sign(stmt)
+		stmt.Lines[0].Amount = stmt.Lines[0].Amount.Add(decimal.NewFromInt(1))
+		v2 := signer.Sign(stmt)
+		assert.NotEqual(tt, v1.Code, v2.Code)
+	})
+
+	t.Run("rejects a record altered after signing", func(tt *testing.T) {
+		signer := testSigner(tt, cfg)
+		v := signer.Sign(testStatement())
+		v.ContentHash = strings.Repeat("0", 64)
+		assert.False(tt, signer.Verify(v))
+	})
+
+	t.Run("seals passwords for their account only", func(tt *testing.T) {
+		as := assert.New(tt)
+		signer := testSigner(tt, cfg)
+		sealed, err := signer.SealPassword(snowflake.ID(1), "correct horse")
+		as.Nil(err)
+		as.NotContains(string(sealed), "correct horse")
+
+		pw, err := signer.OpenPassword(snowflake.ID(1), sealed)
+		as.Nil(err)
+		as.Equal("correct horse", pw)
+		_, err = signer.OpenPassword(snowflake.ID(2), sealed)
+		as.Error(err)
+	})
+
+	t.Run("rejects short keys", func(tt *testing.T) {
+		_, err := bankxgo.NewStatementSigner(bankxgo.StatementSecurityCfg{SigningKey: "short"})
+		assert.ErrorContains(tt, err, "statement_security.signing_key")
+	})
+
+	t.Run("is disabled without keys", func(tt *testing.T) {
+		signer, err := bankxgo.NewStatementSigner(bankxgo.StatementSecurityCfg{})
+		assert.Nil(tt, err)
+		assert.Nil(tt, signer)
+	})
+}
+
+func TestParseVerificationCode(t *testing.T) {
+	as := assert.New(t)
+	code := "ABCDEFGHIJKLMNOPQRS2"
+	formatted := bankxgo.FormatVerificationCode(code)
+	as.Equal("ABCDE-FGHIJ-KLMNO-PQRS2", formatted)
+
+	parsed, err := bankxgo.ParseVerificationCode(strings.ToLower(formatted))
+	as.Nil(err)
+	as.Equal(code, parsed)
+
+	_, err = bankxgo.ParseVerificationCode("ABCDE-FGHIJ-KLMNO-PQRS1")
+	as.IsType(bankxgo.ErrBadRequest{}, err)
+	_, err = bankxgo.ParseVerificationCode("ABCDE")
+	as.IsType(bankxgo.ErrBadRequest{}, err)
+}
+
+func TestProtectedPDFStatement(t *testing.T) {
+	as := assert.New(t)
+	signer := testSigner(t, bankxgo.StatementSecurityCfg{
+		SigningKey:  "0123456789abcdef-signing",
+		PasswordKey: "0123456789abcdef-password",
+		QRCode:      true,
+	})
+	stmt := testStatement()
+	stmt.Verification = signer.Sign(stmt)
+	stmt.QRCode = signer.VerifyURL(stmt.Verification.Code)
+	stmt.Password = "correct horse"
+
+	rndr, _ := bankxgo.StatementRendererFor(bankxgo.StatementFormatPDF)
+	buf := new(bytes.Buffer)
+	require.Nil(t, rndr.Render(buf, stmt))
+	as.True(bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+	as.Contains(buf.String(), "/Encrypt")
+}
+
+func TestVerifyStatement(t *testing.T) {
+	nooplog := zerolog.Nop()
+	sysAcctID := snowflake.ID(7241722241547767808)
+	signer := testSigner(t, bankxgo.StatementSecurityCfg{SigningKey: "0123456789abcdef-signing"})
+	newService := func(repo *mocks.MockRepository) bankxgo.Service {
+		repo.EXPECT().
+			GetAccount(gomock.Any(), sysAcctID).
+			Return(&bankxgo.Account{AcctID: sysAcctID, Currency: "USD"}, nil)
+		svc, err := bankxgo.NewService(
+			repo,
+			bankxgo.NewSystemAccounts(map[string]snowflake.ID{"USD": sysAcctID}, nil),
+			nil,
+			nil,
+			&nooplog,
+			bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}),
+			bankxgo.WithStatementSigner(signer),
+		)
+		require.Nil(t, err)
+		return svc
+	}
+
+	t.Run("returns the record of a genuine statement", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(repo)
+		v := signer.Sign(testStatement())
+		repo.EXPECT().GetStatementVerification(gomock.Any(), v.Code).Return(v, nil)
+
+		got, err := svc.VerifyStatement(context.Background(), bankxgo.VerifyStatementReq{Code: bankxgo.FormatVerificationCode(v.Code)})
+		assert.Nil(tt, err)
+		assert.Equal(tt, v, got)
+	})
+
+	t.Run("returns not found for a tampered record", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(repo)
+		v := signer.Sign(testStatement())
+		v.ContentHash = strings.Repeat("f", 64)
+		repo.EXPECT().GetStatementVerification(gomock.Any(), v.Code).Return(v, nil)
+
+		_, err := svc.VerifyStatement(context.Background(), bankxgo.VerifyStatementReq{Code: v.Code})
+		assert.IsType(tt, bankxgo.ErrNotFound{}, err)
+	})
+
+	t.Run("records the verification of signed PDF statements", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(repo)
+		stmt := testStatement()
+		repo.EXPECT().GetAccount(gomock.Any(), stmt.Account.AcctID).Return(&stmt.Account, nil)
+		repo.EXPECT().GetAccountCharges(gomock.Any(), stmt.Account.AcctID).Return(nil, nil)
+		repo.EXPECT().GetStatementPreference(gomock.Any(), stmt.Account.AcctID).Return(nil, bankxgo.ErrNotFound{})
+		repo.EXPECT().
+			CreateStatementVerification(gomock.Any(), gomock.AssignableToTypeOf(bankxgo.StatementVerification{})).
+			Return(nil)
+
+		buf := new(bytes.Buffer)
+		err := svc.Statement(context.Background(), buf, bankxgo.StatementReq{AcctID: stmt.Account.AcctID})
+		assert.Nil(tt, err)
+	})
+}
+
+func TestStatementPassword(t *testing.T) {
+	nooplog := zerolog.Nop()
+	sysAcctID := snowflake.ID(7241722241547767808)
+	signer := testSigner(t, bankxgo.StatementSecurityCfg{PasswordKey: "0123456789abcdef-password"})
+	newService := func(repo *mocks.MockRepository, opts ...bankxgo.ServiceOption) bankxgo.Service {
+		repo.EXPECT().
+			GetAccount(gomock.Any(), sysAcctID).
+			Return(&bankxgo.Account{AcctID: sysAcctID, Currency: "USD"}, nil)
+		svc, err := bankxgo.NewService(
+			repo,
+			bankxgo.NewSystemAccounts(map[string]snowflake.ID{"USD": sysAcctID}, nil),
+			nil,
+			nil,
+			&nooplog,
+			append(opts, bankxgo.WithIDGenerator(&bankxgo.SequenceIDGenerator{Next: 1}))...,
+		)
+		require.Nil(t, err)
+		return svc
+	}
+
+	t.Run("encrypts statements with the password the customer set", func(tt *testing.T) {
+		as := assert.New(tt)
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(repo, bankxgo.WithStatementSigner(signer))
+		stmt := testStatement()
+		acctID := stmt.Account.AcctID
+
+		var sealed []byte
+		repo.EXPECT().
+			SetStatementPassword(gomock.Any(), acctID, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ snowflake.ID, s []byte) error {
+				sealed = s
+				return nil
+			})
+		err := svc.SetStatementPassword(context.Background(), bankxgo.StatementPasswordReq{AcctID: acctID, Password: "correct horse"})
+		require.Nil(tt, err)
+		as.NotContains(string(sealed), "correct horse")
+
+		repo.EXPECT().GetAccount(gomock.Any(), acctID).Return(&stmt.Account, nil)
+		repo.EXPECT().GetAccountCharges(gomock.Any(), acctID).Return(nil, nil)
+		repo.EXPECT().GetStatementPreference(gomock.Any(), acctID).Return(nil, bankxgo.ErrNotFound{})
+		repo.EXPECT().GetStatementPassword(gomock.Any(), acctID).Return(sealed, nil)
+		buf := new(bytes.Buffer)
+		require.Nil(tt, svc.Statement(context.Background(), buf, bankxgo.StatementReq{AcctID: acctID}))
+		as.Contains(buf.String(), "/Encrypt")
+	})
+
+	t.Run("does not encrypt statements of customers without a password", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(repo, bankxgo.WithStatementSigner(signer))
+		stmt := testStatement()
+		acctID := stmt.Account.AcctID
+		repo.EXPECT().GetAccount(gomock.Any(), acctID).Return(&stmt.Account, nil)
+		repo.EXPECT().GetAccountCharges(gomock.Any(), acctID).Return(nil, nil)
+		repo.EXPECT().GetStatementPreference(gomock.Any(), acctID).Return(nil, bankxgo.ErrNotFound{})
+		repo.EXPECT().GetStatementPassword(gomock.Any(), acctID).Return(nil, bankxgo.ErrNotFound{})
+
+		buf := new(bytes.Buffer)
+		require.Nil(tt, svc.Statement(context.Background(), buf, bankxgo.StatementReq{AcctID: acctID}))
+		assert.NotContains(tt, buf.String(), "/Encrypt")
+	})
+
+	t.Run("removes the password when it is empty", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(repo, bankxgo.WithStatementSigner(signer))
+		repo.EXPECT().SetStatementPassword(gomock.Any(), snowflake.ID(1), nil).Return(nil)
+
+		err := svc.SetStatementPassword(context.Background(), bankxgo.StatementPasswordReq{AcctID: 1})
+		assert.Nil(tt, err)
+	})
+
+	t.Run("rejects passwords out of bounds", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(repo, bankxgo.WithStatementSigner(signer))
+		for _, pw := range []string{"short", strings.Repeat("x", 65)} {
+			err := svc.SetStatementPassword(context.Background(), bankxgo.StatementPasswordReq{AcctID: 1, Password: pw})
+			assert.IsType(tt, bankxgo.ErrBadRequest{}, err)
+		}
+	})
+
+	t.Run("returns ErrServiceUnavailable if encryption is off", func(tt *testing.T) {
+		ctrl := gomock.NewController(tt)
+		repo := mocks.NewMockRepository(ctrl)
+		svc := newService(repo)
+		err := svc.SetStatementPassword(context.Background(), bankxgo.StatementPasswordReq{AcctID: 1, Password: "correct horse"})
+		assert.ErrorIs(tt, err, bankxgo.ErrServiceUnavailable)
+	})
+}
@@ -4,17 +4,35 @@ CREATE TABLE accounts (
     email TEXT NOT NULL UNIQUE,
     currency TEXT NOT NULL,
     balance NUMERIC DEFAULT 0,
+    -- frozen accounts cannot deposit or withdraw
+    frozen BOOLEAN NOT NULL DEFAULT FALSE,
+    -- credit accounts are credit lines, they earn no deposit interest
+    acct_type TEXT NOT NULL DEFAULT 'deposit' CHECK (acct_type IN ('deposit', 'credit')),
+    -- how far below zero withdrawals may take the balance
+    overdraft_limit NUMERIC NOT NULL DEFAULT 0 CHECK (overdraft_limit >= 0),
+    -- deposits not settled yet, they are not part of the balance
+    pending NUMERIC NOT NULL DEFAULT 0 CHECK (pending >= 0),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE TYPE txn_type AS ENUM ('deposit', 'withdrawal');
+CREATE TYPE txn_type AS ENUM ('deposit', 'withdrawal', 'interest', 'adjustment', 'overdraft');
 
 CREATE TABLE transactions (
     id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
     typ txn_type NOT NULL,
+    -- pending deposits are booked but only count towards the balance once
+    -- posted, failed ones never do
+    status TEXT NOT NULL DEFAULT 'posted' CHECK (status IN ('pending', 'posted', 'failed')),
+    settled_at TIMESTAMP,
+    -- the memo given with deposits and withdrawals, see `Memo`
+    description TEXT,
+    reference TEXT,
+    metadata JSONB,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE INDEX transactions_reference_idx ON transactions (reference) WHERE reference IS NOT NULL;
+
 CREATE TYPE charge_type AS ENUM ('debit', 'credit');
 
 CREATE TABLE charges (
@@ -23,5 +41,223 @@ CREATE TABLE charges (
     amount NUMERIC NOT NULL,
     tx_id BIGINT REFERENCES transactions(id) ON DELETE RESTRICT,
     acct_id BIGINT REFERENCES accounts(pub_id) ON DELETE RESTRICT,
+    is_fee BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- per account overrides of the per currency withdrawal limits in the config,
+-- NULL means the currency default applies and 0 means no limit
+CREATE TABLE withdrawal_limits (
+    acct_id BIGINT PRIMARY KEY REFERENCES accounts(pub_id) ON DELETE CASCADE,
+    max_per_txn NUMERIC,
+    max_daily_total NUMERIC,
+    max_daily_count INT
+);
+
+-- daily interest accruals, posted monthly as a single `interest` transaction
+CREATE TABLE interest_accruals (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    acct_id BIGINT NOT NULL REFERENCES accounts(pub_id) ON DELETE RESTRICT,
+    accrual_date DATE NOT NULL,
+    balance NUMERIC NOT NULL,
+    rate NUMERIC NOT NULL,
+    amount NUMERIC NOT NULL,
+    posted_at TIMESTAMP,
+    tx_id BIGINT REFERENCES transactions(id) ON DELETE RESTRICT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (acct_id, accrual_date)
+);
+
+-- daily overdraft interest and fees, at most one charge per account and day
+CREATE TABLE overdraft_charges (
+    acct_id BIGINT NOT NULL REFERENCES accounts(pub_id) ON DELETE RESTRICT,
+    charge_date DATE NOT NULL,
+    balance NUMERIC NOT NULL,
+    rate NUMERIC NOT NULL,
+    interest NUMERIC NOT NULL,
+    fee NUMERIC NOT NULL,
+    tx_id BIGINT REFERENCES transactions(id) ON DELETE RESTRICT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (acct_id, charge_date)
+);
+
+-- queue of asynchronously rendered statements, also serves as the cache of
+-- completed statements by account, format and period
+CREATE TABLE statement_jobs (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    pub_id BIGINT NOT NULL UNIQUE,
+    acct_id BIGINT NOT NULL REFERENCES accounts(pub_id) ON DELETE RESTRICT,
+    format TEXT NOT NULL,
+    period_from DATE,
+    period_to DATE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued',
+    attempts INT NOT NULL DEFAULT 0,
+    error TEXT,
+    file_key TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX statement_jobs_queued_idx ON statement_jobs (id) WHERE status IN ('queued', 'running');
+CREATE INDEX statement_jobs_cache_idx ON statement_jobs (acct_id, format, period_to) WHERE status = 'done';
+
+-- issued statements by verification code, see StatementSigner
+CREATE TABLE statement_verifications (
+    code TEXT PRIMARY KEY,
+    acct_id BIGINT NOT NULL REFERENCES accounts(pub_id) ON DELETE RESTRICT,
+    currency TEXT NOT NULL,
+    period_from DATE NOT NULL,
+    period_to DATE NOT NULL,
+    opening NUMERIC NOT NULL,
+    closing NUMERIC NOT NULL,
+    lines INT NOT NULL,
+    content_hash TEXT NOT NULL,
+    issued_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- customer choice of PDF statement template and locale, NULL means the default
+CREATE TABLE statement_preferences (
+    acct_id BIGINT PRIMARY KEY REFERENCES accounts(pub_id) ON DELETE CASCADE,
+    template TEXT,
+    locale TEXT,
+    -- day of the month the statement cycle closes on, NULL means the default
+    cycle_day INT CHECK (cycle_day BETWEEN 1 AND 31),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- statement passwords chosen by customers, sealed with the password key so
+-- they are not stored in the clear
+CREATE TABLE statement_passwords (
+    acct_id BIGINT PRIMARY KEY REFERENCES accounts(pub_id) ON DELETE CASCADE,
+    sealed BYTEA NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- closed statement cycles, the balances and rendered document are snapshots
+-- taken at closing and must never change
+CREATE TABLE statement_periods (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    acct_id BIGINT NOT NULL REFERENCES accounts(pub_id),
+    period_from DATE NOT NULL,
+    period_to DATE NOT NULL,
+    opening NUMERIC NOT NULL,
+    closing NUMERIC NOT NULL,
+    format TEXT NOT NULL,
+    file_key TEXT NOT NULL,
+    closed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (acct_id, period_to)
+);
+
+CREATE OR REPLACE FUNCTION statement_periods_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'statement periods are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER statement_periods_no_update
+BEFORE UPDATE ON statement_periods
+FOR EACH ROW EXECUTE FUNCTION statement_periods_immutable();
+
+-- audit trail of manual balance corrections by operators
+CREATE TABLE adjustments (
+    tx_id BIGINT PRIMARY KEY REFERENCES transactions(id),
+    acct_id BIGINT NOT NULL REFERENCES accounts(pub_id),
+    amount NUMERIC NOT NULL,
+    reason TEXT NOT NULL CHECK (reason <> ''),
+    operator TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- snowflake nodes leased by running server instances, see NodeCfg
+CREATE TABLE node_leases (
+    node_id INT PRIMARY KEY CHECK (node_id BETWEEN 0 AND 1023),
+    holder TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- outbox of balance changes, streamed to clients through LISTEN/NOTIFY on the
+-- `balance_events` channel and replayed from here when a stream resumes. An
+-- event is inserted with its account row locked, so the IDs of an account's
+-- events increase in commit order.
+CREATE TABLE balance_events (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    acct_id BIGINT NOT NULL REFERENCES accounts(pub_id) ON DELETE RESTRICT,
+    tx_id BIGINT NOT NULL REFERENCES transactions(id) ON DELETE RESTRICT,
+    typ txn_type NOT NULL,
+    amount NUMERIC NOT NULL,
+    fee NUMERIC NOT NULL DEFAULT 0,
+    balance NUMERIC NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
+
+CREATE INDEX balance_events_acct_id_idx ON balance_events (acct_id, id);
+
+-- batches of deposits and withdrawals by idempotency key, see PostBatch. A batch
+-- stays `processing` until every item is posted, failed or skipped, an
+-- interrupted batch is resumed by posting it again.
+CREATE TABLE batches (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    pub_id BIGINT NOT NULL UNIQUE,
+    idempotency_key TEXT NOT NULL UNIQUE,
+    -- identifies the request, the key cannot be reused for another one
+    request_hash TEXT NOT NULL,
+    mode TEXT NOT NULL CHECK (mode IN ('atomic', 'best_effort')),
+    status TEXT NOT NULL DEFAULT 'processing',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE TABLE batch_items (
+    batch_id BIGINT NOT NULL REFERENCES batches(pub_id) ON DELETE CASCADE,
+    idx INT NOT NULL,
+    typ txn_type NOT NULL CHECK (typ IN ('deposit', 'withdrawal')),
+    acct_id BIGINT NOT NULL REFERENCES accounts(pub_id) ON DELETE RESTRICT,
+    amount NUMERIC NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    fee NUMERIC,
+    balance NUMERIC,
+    tx_id BIGINT REFERENCES transactions(id) ON DELETE RESTRICT,
+    error_code TEXT,
+    error TEXT,
+    PRIMARY KEY (batch_id, idx)
+);
+
+-- standing orders moving a fixed amount between two accounts of a customer's
+-- choosing as scheduled, see Scheduler. attempt_at is when the occurrence due
+-- at next_run_at is attempted next, later than it while retrying.
+CREATE TABLE scheduled_payments (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    pub_id BIGINT NOT NULL UNIQUE,
+    acct_id BIGINT NOT NULL REFERENCES accounts(pub_id) ON DELETE RESTRICT,
+    to_acct_id BIGINT NOT NULL REFERENCES accounts(pub_id) ON DELETE RESTRICT,
+    amount NUMERIC NOT NULL CHECK (amount > 0),
+    cron TEXT NOT NULL DEFAULT '',
+    every TEXT NOT NULL DEFAULT '',
+    every_interval INT NOT NULL DEFAULT 0,
+    start_at TIMESTAMP NOT NULL,
+    end_at TIMESTAMP,
+    on_insufficient_funds TEXT NOT NULL CHECK (on_insufficient_funds IN ('skip', 'retry')),
+    status TEXT NOT NULL CHECK (status IN ('active', 'completed', 'cancelled')),
+    next_run_at TIMESTAMP,
+    attempt INT NOT NULL DEFAULT 0,
+    attempt_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX scheduled_payments_acct_id_idx ON scheduled_payments (acct_id);
+CREATE INDEX scheduled_payments_due_idx ON scheduled_payments (attempt_at) WHERE status = 'active';
+
+CREATE TABLE scheduled_payment_runs (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    payment_id BIGINT NOT NULL REFERENCES scheduled_payments(pub_id) ON DELETE CASCADE,
+    due_at TIMESTAMP NOT NULL,
+    attempt INT NOT NULL,
+    status TEXT NOT NULL,
+    batch_id BIGINT REFERENCES batches(pub_id) ON DELETE RESTRICT,
+    error_code TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    ran_at TIMESTAMP NOT NULL,
+    UNIQUE (payment_id, due_at, attempt)
+);
@@ -1,3 +1,19 @@
+DROP TABLE IF EXISTS scheduled_payment_runs;
+DROP TABLE IF EXISTS scheduled_payments;
+DROP TABLE IF EXISTS batch_items;
+DROP TABLE IF EXISTS batches;
+DROP TABLE IF EXISTS balance_events;
+DROP TABLE IF EXISTS node_leases;
+DROP TABLE IF EXISTS adjustments;
+DROP TABLE IF EXISTS statement_periods;
+DROP FUNCTION IF EXISTS statement_periods_immutable;
+DROP TABLE IF EXISTS statement_passwords;
+DROP TABLE IF EXISTS statement_preferences;
+DROP TABLE IF EXISTS statement_verifications;
+DROP TABLE IF EXISTS statement_jobs;
+DROP TABLE IF EXISTS overdraft_charges;
+DROP TABLE IF EXISTS interest_accruals;
+DROP TABLE IF EXISTS withdrawal_limits;
 DROP TABLE IF EXISTS charges;
 DROP TABLE IF EXISTS transactions;
 DROP TABLE IF EXISTS accounts;
@@ -0,0 +1,112 @@
+package bankxgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/shopspring/decimal"
+)
+
+// Limits of the memo of a charge. Metadata is bounded by its size as JSON,
+// nested values included.
+const (
+	MaxDescriptionLen = 140
+	MaxReferenceLen   = 64
+	MaxMetadataKeys   = 20
+	MaxMetadataSize   = 2048
+)
+
+// Memo tells what a charge was for. It is stored on the transaction and
+// returned as is with the account history.
+type Memo struct {
+	// Description is shown to the account holder, ie. on statements
+	Description string `json:"description,omitempty"`
+	// Reference is the caller's reference of the charge, ie. a wire or invoice
+	// number, which the history can be searched by
+	Reference string         `json:"reference,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+// validateMemo checks the memo against its size limits
+func validateMemo(m Memo) error {
+	fields := map[string]string{}
+	if utf8.RuneCountInString(m.Description) > MaxDescriptionLen {
+		fields["description"] = fmt.Sprintf("longer than %d characters", MaxDescriptionLen)
+	}
+	if utf8.RuneCountInString(m.Reference) > MaxReferenceLen {
+		fields["reference"] = fmt.Sprintf("longer than %d characters", MaxReferenceLen)
+	}
+	if len(m.Metadata) > MaxMetadataKeys {
+		fields["metadata"] = fmt.Sprintf("more than %d keys", MaxMetadataKeys)
+	} else if b, err := m.metadataJSON(); err != nil {
+		fields["metadata"] = "not JSON"
+	} else if len(b) > MaxMetadataSize {
+		fields["metadata"] = fmt.Sprintf("larger than %d bytes", MaxMetadataSize)
+	}
+	if len(fields) > 0 {
+		return ErrBadRequest{Fields: fields}
+	}
+	return nil
+}
+
+// metadataJSON returns the metadata as stored, nil if there is none
+func (m Memo) metadataJSON() ([]byte, error) {
+	if len(m.Metadata) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(m.Metadata)
+}
+
+// Statuses of a transaction. Pending deposits are listed in the history but
+// are only part of the balance once posted.
+const (
+	TxnPending = "pending"
+	TxnPosted  = "posted"
+	TxnFailed  = "failed"
+)
+
+// Transaction is an entry of the history of an account
+type Transaction struct {
+	ID int64 `json:"id"`
+	// Type is the type of the transaction, ie. deposit or withdrawal
+	Type string `json:"type"`
+	// Status is one of the Txn* constants
+	Status string `json:"status"`
+	// Amount is added to the balance, negative amounts are taken off. The fee
+	// is taken off on top.
+	Amount decimal.Decimal `json:"amount"`
+	Fee    decimal.Decimal `json:"fee"`
+	Memo
+	CreatedAt time.Time `json:"createdAt"`
+	// SettledAt is when a pending deposit was posted or failed, posted ones
+	// count towards the balance from then on
+	SettledAt *time.Time `json:"settledAt,omitempty"`
+}
+
+// MaxTransactionsLimit bounds the transactions listed at once
+const MaxTransactionsLimit = 500
+
+// defaultTransactionsLimit is the number of transactions listed if the request
+// does not say
+const defaultTransactionsLimit = 50
+
+type TransactionsReq struct {
+	AcctID snowflake.ID
+	Email  string
+	Client string
+	TransactionFilter
+}
+
+// TransactionFilter selects the transactions of an account to list, latest
+// first
+type TransactionFilter struct {
+	// Reference only lists the transactions with the reference, if set
+	Reference string
+	// Before only lists the transactions older than the one with the ID, if
+	// set, to page through the history
+	Before int64
+	Limit  int
+}